@@ -499,6 +499,14 @@ func collectTargets(opts BackupOptions, args []string) (targets []string, err er
 // parent returns the ID of the parent snapshot. If there is none, nil is
 // returned.
 func findParentSnapshot(ctx context.Context, repo restic.Repository, opts BackupOptions, targets []string, timeStampLimit time.Time) (parentID *restic.ID, err error) {
+	// snapshots cannot be read with a write-only key, so all files are read
+	if repo.Config().Version >= restic.SealedRepoVersion && repo.Key().Private == nil {
+		if !opts.Force && opts.Parent != "" {
+			return nil, errors.Fatal("a parent snapshot cannot be used with a write-only key")
+		}
+		return nil, nil
+	}
+
 	// Force using a parent
 	if !opts.Force && opts.Parent != "" {
 		id, err := restic.FindSnapshot(ctx, repo.Backend(), opts.Parent)
@@ -737,6 +745,9 @@ func runBackup(opts BackupOptions, gopts GlobalOptions, term *termstatus.Termina
 
 	// Report finished execution
 	progressReporter.Finish(id)
+	if gopts.metrics != nil {
+		progressReporter.RecordMetrics(gopts.metrics)
+	}
 	if !gopts.JSON && !opts.DryRun {
 		progressPrinter.P("snapshot %s saved\n", id.Str())
 	}
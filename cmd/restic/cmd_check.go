@@ -14,6 +14,7 @@ import (
 	"github.com/restic/restic/internal/checker"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 )
 
@@ -43,10 +44,12 @@ Exit status is 0 if the command was successful, and non-zero if there was any er
 
 // CheckOptions bundles all options for the 'check' command.
 type CheckOptions struct {
-	ReadData       bool
-	ReadDataSubset string
-	CheckUnused    bool
-	WithCache      bool
+	ReadData         bool
+	ReadDataSubset   string
+	VerifyChecksums  bool
+	RemoveDuplicates bool
+	CheckUnused      bool
+	WithCache        bool
 }
 
 var checkOptions CheckOptions
@@ -57,6 +60,8 @@ func init() {
 	f := cmdCheck.Flags()
 	f.BoolVar(&checkOptions.ReadData, "read-data", false, "read all data blobs")
 	f.StringVar(&checkOptions.ReadDataSubset, "read-data-subset", "", "read a `subset` of data packs, specified as 'n/t' for specific part, or either 'x%' or 'x.y%' or a size in bytes with suffixes k/K, m/M, g/G, t/T for a random subset")
+	f.BoolVar(&checkOptions.VerifyChecksums, "verify-checksums", false, "verify all data packs using the checksums recorded by the backend, without downloading them (NeoFS only)")
+	f.BoolVar(&checkOptions.RemoveDuplicates, "remove-duplicates", false, "remove extra copies of files the backend stores more than once with identical content (NeoFS only)")
 	f.BoolVar(&checkOptions.CheckUnused, "check-unused", false, "find unused blobs")
 	f.BoolVar(&checkOptions.WithCache, "with-cache", false, "use the cache")
 }
@@ -65,6 +70,9 @@ func checkFlags(opts CheckOptions) error {
 	if opts.ReadData && opts.ReadDataSubset != "" {
 		return errors.Fatal("check flags --read-data and --read-data-subset cannot be used together")
 	}
+	if opts.ReadData && opts.VerifyChecksums {
+		return errors.Fatal("check flags --read-data and --verify-checksums cannot be used together")
+	}
 	if opts.ReadDataSubset != "" {
 		dataSubset, err := stringToIntSlice(opts.ReadDataSubset)
 		argumentError := errors.Fatal("check flag --read-data-subset has invalid value, please see documentation")
@@ -201,6 +209,18 @@ func runCheck(opts CheckOptions, gopts GlobalOptions, args []string) error {
 		return err
 	}
 
+	if repo.WriteOnly() {
+		return repository.ErrWriteOnly
+	}
+
+	if opts.RemoveDuplicates && gopts.NoLock {
+		return errors.Fatal("check flag --remove-duplicates cannot be used with --no-lock")
+	}
+
+	if opts.VerifyChecksums && restic.AsChecksumBackend(repo.Backend()) == nil {
+		return errors.Fatal("the repository backend does not record checksums, use --read-data instead of --verify-checksums")
+	}
+
 	if !gopts.NoLock {
 		Verbosef("create exclusive lock for repository\n")
 		lock, err := lockRepoExclusive(gopts.ctx, repo)
@@ -263,6 +283,40 @@ func runCheck(opts CheckOptions, gopts GlobalOptions, args []string) error {
 		Verbosef("%d additional files were found in the repo, which likely contain duplicate data.\nThis is non-critical, you can run `restic prune` to correct this.\n", orphanedPacks)
 	}
 
+	if dbe := restic.AsDuplicatesBackend(repo.Backend()); dbe != nil {
+		duplicateFiles := 0
+		errChan = make(chan error)
+
+		Verbosef("check for duplicate files\n")
+		go chkr.Duplicates(gopts.ctx, errChan)
+
+		for err := range errChan {
+			e, ok := err.(*checker.ErrDuplicateFile)
+			if !ok || !e.Identical {
+				errorsFound = true
+				Warnf("error: %v\n", err)
+				continue
+			}
+
+			if opts.RemoveDuplicates {
+				if err := dbe.RemoveDuplicates(gopts.ctx, e.Handle); err != nil {
+					errorsFound = true
+					Warnf("error: %v\n", err)
+					continue
+				}
+				Verbosef("removed %d extra copies of %v\n", e.Copies-1, e.Handle)
+				continue
+			}
+
+			duplicateFiles++
+			Verbosef("%v\n", err)
+		}
+
+		if duplicateFiles > 0 {
+			Verbosef("%d files are stored more than once with identical content.\nThis is non-critical, you can run `restic check --remove-duplicates` to correct this.\n", duplicateFiles)
+		}
+	}
+
 	Verbosef("check snapshots, trees and blobs\n")
 	errChan = make(chan error)
 	var wg sync.WaitGroup
@@ -314,6 +368,21 @@ func runCheck(opts CheckOptions, gopts GlobalOptions, args []string) error {
 		p.Done()
 	}
 
+	if opts.VerifyChecksums {
+		Verbosef("verify checksums of all data packs\n")
+		packs := chkr.GetPacks()
+		p := newProgressMax(!gopts.Quiet, uint64(len(packs)), "packs")
+		errChan := make(chan error)
+
+		go chkr.VerifyPackChecksums(gopts.ctx, packs, p, errChan)
+
+		for err := range errChan {
+			errorsFound = true
+			Warnf("%v\n", err)
+		}
+		p.Done()
+	}
+
 	switch {
 	case opts.ReadData:
 		Verbosef("read all data\n")
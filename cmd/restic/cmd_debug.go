@@ -311,7 +311,7 @@ func decryptUnsigned(ctx context.Context, k *crypto.Key, buf []byte) []byte {
 	return out
 }
 
-func loadBlobs(ctx context.Context, repo restic.Repository, pack restic.ID, list []restic.Blob) error {
+func loadBlobs(ctx context.Context, repo restic.Repository, pack restic.ID, sealed bool, list []restic.Blob) error {
 	dec, err := zstd.NewReader(nil)
 	if err != nil {
 		panic(err)
@@ -321,6 +321,15 @@ func loadBlobs(ctx context.Context, repo restic.Repository, pack restic.ID, list
 		Name: pack.String(),
 		Type: restic.PackFile,
 	}
+
+	key := repo.Key()
+	if sealed {
+		key, err = repository.LoadPackKey(ctx, be, repo.Key(), pack)
+		if err != nil {
+			return err
+		}
+	}
+
 	for _, blob := range list {
 		Printf("      loading blob %v at %v (length %v)\n", blob.ID, blob.Offset, blob.Length)
 		buf := make([]byte, blob.Length)
@@ -336,8 +345,6 @@ func loadBlobs(ctx context.Context, repo restic.Repository, pack restic.ID, list
 			continue
 		}
 
-		key := repo.Key()
-
 		nonce, plaintext := buf[:key.NonceSize()], buf[key.NonceSize():]
 		plaintext, err = key.Open(plaintext[:0], nonce, plaintext, nil)
 		outputPrefix := ""
@@ -505,6 +512,7 @@ func examinePack(ctx context.Context, repo restic.Repository, id restic.ID) erro
 	Printf("  looking for info in the indexes\n")
 
 	blobsLoaded := false
+	sealed := repo.Index().IsSealedPack(id)
 	// examine all data the indexes have for the pack file
 	for b := range repo.Index().ListPacks(ctx, restic.NewIDSet(id)) {
 		blobs := b.Blobs
@@ -512,9 +520,9 @@ func examinePack(ctx context.Context, repo restic.Repository, id restic.ID) erro
 			continue
 		}
 
-		checkPackSize(blobs, fi.Size)
+		checkPackSize(blobs, sealed, fi.Size)
 
-		err = loadBlobs(ctx, repo, id, blobs)
+		err = loadBlobs(ctx, repo, id, sealed, blobs)
 		if err != nil {
 			Warnf("error: %v\n", err)
 		} else {
@@ -525,21 +533,25 @@ func examinePack(ctx context.Context, repo restic.Repository, id restic.ID) erro
 	Printf("  ========================================\n")
 	Printf("  inspect the pack itself\n")
 
-	blobs, _, err := pack.List(repo.Key(), restic.ReaderAt(ctx, repo.Backend(), h), fi.Size)
+	blobs, _, sealed, err := pack.ListSealed(repo.Key(), restic.ReaderAt(ctx, repo.Backend(), h), fi.Size)
 	if err != nil {
 		return fmt.Errorf("pack %v: %v", id.Str(), err)
 	}
-	checkPackSize(blobs, fi.Size)
+	checkPackSize(blobs, sealed, fi.Size)
 
 	if !blobsLoaded {
-		return loadBlobs(ctx, repo, id, blobs)
+		return loadBlobs(ctx, repo, id, sealed, blobs)
 	}
 	return nil
 }
 
-func checkPackSize(blobs []restic.Blob, fileSize int64) {
-	// track current size and offset
-	var size, offset uint64
+func checkPackSize(blobs []restic.Blob, sealed bool, fileSize int64) {
+	// track current size and offset, sealed packs start with the sealed data key
+	var start uint64
+	if sealed {
+		start = pack.SealedKeySize
+	}
+	size, offset := start, start
 
 	sort.Slice(blobs, func(i, j int) bool {
 		return blobs[i].Offset < blobs[j].Offset
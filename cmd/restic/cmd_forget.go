@@ -224,10 +224,15 @@ func runForget(opts ForgetOptions, gopts GlobalOptions, args []string) error {
 
 	if len(removeSnIDs) > 0 {
 		if !opts.DryRun {
-			err := DeleteFilesChecked(gopts, repo, removeSnIDs, restic.SnapshotFile)
+			retained, err := deleteFiles(gopts, false, repo, removeSnIDs, restic.SnapshotFile)
 			if err != nil {
 				return err
 			}
+
+			// snapshots under retention are kept, prune must not remove their data
+			for id := range retained {
+				removeSnIDs.Delete(id)
+			}
 		} else {
 			if !gopts.JSON {
 				Printf("Would have removed the following snapshots:\n%v\n\n", removeSnIDs)
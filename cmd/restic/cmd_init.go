@@ -1,9 +1,9 @@
 package main
 
 import (
+	"math/bits"
 	"strconv"
 
-	"github.com/restic/chunker"
 	"github.com/restic/restic/internal/backend/location"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/repository"
@@ -33,6 +33,9 @@ Exit status is 0 if the command was successful, and non-zero if there was any er
 type InitOptions struct {
 	secondaryRepoOptions
 	CopyChunkerParameters bool
+	ChunkerMinSize        string
+	ChunkerAvgSize        string
+	ChunkerMaxSize        string
 	RepositoryVersion     string
 }
 
@@ -44,6 +47,9 @@ func init() {
 	f := cmdInit.Flags()
 	initSecondaryRepoOptions(f, &initOptions.secondaryRepoOptions, "secondary", "to copy chunker parameters from")
 	f.BoolVar(&initOptions.CopyChunkerParameters, "copy-chunker-params", false, "copy chunker parameters from the secondary repository (useful with the copy command)")
+	f.StringVar(&initOptions.ChunkerMinSize, "chunker-min-size", "", "minimum `size` of chunks (allowed suffixes: k/K, m/M, default: half of the average size)")
+	f.StringVar(&initOptions.ChunkerAvgSize, "chunker-avg-size", "", "average `size` of chunks, must be a power of two (allowed suffixes: k/K, m/M, default: 1M)")
+	f.StringVar(&initOptions.ChunkerMaxSize, "chunker-max-size", "", "maximum `size` of chunks (allowed suffixes: k/K, m/M, default: eight times the average size)")
 	f.StringVar(&initOptions.RepositoryVersion, "repository-version", "stable", "repository format version to use, allowed values are a format version, 'latest' and 'stable'")
 }
 
@@ -64,7 +70,12 @@ func runInit(opts InitOptions, gopts GlobalOptions, args []string) error {
 		return errors.Fatalf("only repository versions between %v and %v are allowed", restic.MinRepoVersion, restic.MaxRepoVersion)
 	}
 
-	chunkerPolynomial, err := maybeReadChunkerPolynomial(opts, gopts)
+	repoOpts, err := repositoryOptions(gopts)
+	if err != nil {
+		return err
+	}
+
+	chunkerParams, err := readChunkerParams(opts, gopts)
 	if err != nil {
 		return err
 	}
@@ -86,9 +97,9 @@ func runInit(opts InitOptions, gopts GlobalOptions, args []string) error {
 		return errors.Fatalf("create repository at %s failed: %v\n", location.StripPassword(gopts.Repo), err)
 	}
 
-	s := repository.New(be, repository.Options{Compression: gopts.Compression})
+	s := repository.New(be, repoOpts)
 
-	err = s.Init(gopts.ctx, version, gopts.password, chunkerPolynomial)
+	err = s.Init(gopts.ctx, version, gopts.password, chunkerParams)
 	if err != nil {
 		return errors.Fatalf("create key in repository at %s failed: %v\n", location.StripPassword(gopts.Repo), err)
 	}
@@ -102,8 +113,16 @@ func runInit(opts InitOptions, gopts GlobalOptions, args []string) error {
 	return nil
 }
 
-func maybeReadChunkerPolynomial(opts InitOptions, gopts GlobalOptions) (*chunker.Pol, error) {
+// readChunkerParams returns the chunker parameters copied from the secondary
+// repository or set by the options, nil selects the defaults.
+func readChunkerParams(opts InitOptions, gopts GlobalOptions) (*restic.ChunkerParams, error) {
+	sizesSet := opts.ChunkerMinSize != "" || opts.ChunkerAvgSize != "" || opts.ChunkerMaxSize != ""
+
 	if opts.CopyChunkerParameters {
+		if sizesSet {
+			return nil, errors.Fatal("the chunk sizes cannot be set when copying the chunker parameters")
+		}
+
 		otherGopts, err := fillSecondaryGlobalOpts(opts.secondaryRepoOptions, gopts, "secondary")
 		if err != nil {
 			return nil, err
@@ -114,12 +133,68 @@ func maybeReadChunkerPolynomial(opts InitOptions, gopts GlobalOptions) (*chunker
 			return nil, err
 		}
 
-		pol := otherRepo.Config().ChunkerPolynomial
-		return &pol, nil
+		params := otherRepo.Config().ChunkerParams()
+		return &params, nil
 	}
 
 	if opts.Repo != "" {
 		return nil, errors.Fatal("Secondary repository must only be specified when copying the chunker parameters")
 	}
-	return nil, nil
+
+	if !sizesSet {
+		return nil, nil
+	}
+	return parseChunkerSizes(opts)
+}
+
+// parseChunkerSizes returns the chunker parameters for the chunk sizes set in
+// opts. The minimum and maximum size are derived from the average size if
+// they are not set, in the same ratio as the defaults of the chunker.
+func parseChunkerSizes(opts InitOptions) (*restic.ChunkerParams, error) {
+	parse := func(name, value string) (uint, error) {
+		if value == "" {
+			return 0, nil
+		}
+		size, err := parseSizeStr(value)
+		if err != nil || size <= 0 || size > restic.MaxChunkSize {
+			return 0, errors.Fatalf("invalid %v %q", name, value)
+		}
+		return uint(size), nil
+	}
+
+	minSize, err := parse("--chunker-min-size", opts.ChunkerMinSize)
+	if err != nil {
+		return nil, err
+	}
+	avgSize, err := parse("--chunker-avg-size", opts.ChunkerAvgSize)
+	if err != nil {
+		return nil, err
+	}
+	maxSize, err := parse("--chunker-max-size", opts.ChunkerMaxSize)
+	if err != nil {
+		return nil, err
+	}
+
+	params := &restic.ChunkerParams{MinSize: minSize, MaxSize: maxSize}
+	if avgSize != 0 {
+		if avgSize&(avgSize-1) != 0 {
+			return nil, errors.Fatalf("invalid --chunker-avg-size %q, the size must be a power of two", opts.ChunkerAvgSize)
+		}
+		params.AverageBits = uint(bits.TrailingZeros(avgSize))
+
+		if params.MinSize == 0 {
+			params.MinSize = avgSize / 2
+		}
+		if params.MaxSize == 0 {
+			params.MaxSize = avgSize * 8
+			if params.MaxSize > restic.MaxChunkSize {
+				params.MaxSize = restic.MaxChunkSize
+			}
+		}
+	}
+
+	if err := params.Check(); err != nil {
+		return nil, errors.Fatalf("invalid chunk sizes: %v", err)
+	}
+	return params, nil
 }
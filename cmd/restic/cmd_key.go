@@ -7,6 +7,9 @@ import (
 	"os"
 	"strings"
 
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/checker"
+	"github.com/restic/restic/internal/crypto"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
@@ -16,11 +19,26 @@ import (
 )
 
 var cmdKey = &cobra.Command{
-	Use:   "key [flags] [list|add|remove|passwd] [ID]",
+	Use:   "key [flags] [list|add|remove|passwd|rotate-master] [ID]",
 	Short: "Manage keys (passwords)",
 	Long: `
 The "key" command manages keys (passwords) for accessing the repository.
 
+All keys wrap the same master key, which encrypts the repository. The
+"rotate-master" subcommand re-encrypts the whole repository with a new master
+key and re-wraps all keys which can be opened with the current password. Keys
+with other passwords cannot be re-wrapped, the rotation refuses to start if
+there are any, unless "--remove-other-keys" is given. Then they are removed and
+must be added again afterwards. An interrupted rotation is resumed by running
+"rotate-master" again. Until it has
+finished, other commands cannot use the repository. The IDs of all snapshots
+change during the rotation.
+
+In repositories of version 3, "add --write-only" adds a key which does not
+contain the private key for sealed data. It can be used to create backups, but
+it cannot read snapshots or data, so restore, check, prune and most other
+commands require a regular key.
+
 EXIT STATUS
 ===========
 
@@ -36,6 +54,8 @@ var (
 	newPasswordFile string
 	keyUsername     string
 	keyHostname     string
+	keyWriteOnly    bool
+	keyRemoveOthers bool
 )
 
 func init() {
@@ -45,6 +65,8 @@ func init() {
 	flags.StringVarP(&newPasswordFile, "new-password-file", "", "", "`file` from which to read the new password")
 	flags.StringVarP(&keyUsername, "user", "", "", "the username for new keys")
 	flags.StringVarP(&keyHostname, "host", "", "", "the hostname for new keys")
+	flags.BoolVarP(&keyWriteOnly, "write-only", "", false, "add a key which can only create backups (requires repository version 3)")
+	flags.BoolVarP(&keyRemoveOthers, "remove-other-keys", "", false, "remove the keys which cannot be opened with the current password when rotating the master key")
 }
 
 func listKeys(ctx context.Context, s *repository.Repository, gopts GlobalOptions) error {
@@ -121,17 +143,34 @@ func getNewPassword(gopts GlobalOptions) (string, error) {
 }
 
 func addKey(gopts GlobalOptions, repo *repository.Repository) error {
+	if keyWriteOnly && repo.Config().Version < restic.SealedRepoVersion {
+		return errors.Fatalf("write-only keys require repository version %v, run \"restic migrate upgrade_repo_v3\" first", restic.SealedRepoVersion)
+	}
+	if !keyWriteOnly && repo.WriteOnly() {
+		return errors.Fatal("a write-only key can only add write-only keys, use --write-only")
+	}
+
 	pw, err := getNewPassword(gopts)
 	if err != nil {
 		return err
 	}
 
-	id, err := repository.AddKey(gopts.ctx, repo, pw, keyUsername, keyHostname, repo.Key())
+	var id *repository.Key
+	if keyWriteOnly {
+		// a write-only key only has the write key and a copy of the config
+		id, err = repository.AddWriteOnlyKey(gopts.ctx, repo, pw, keyUsername, keyHostname)
+	} else {
+		id, err = repository.AddKey(gopts.ctx, repo, pw, keyUsername, keyHostname, repo.Key())
+	}
 	if err != nil {
 		return errors.Fatalf("creating new key failed: %v\n", err)
 	}
 
-	err = switchToNewKeyAndRemoveIfBroken(gopts.ctx, repo, id, pw)
+	if keyWriteOnly {
+		err = openNewKeyAndRemoveIfBroken(gopts.ctx, repo, id, pw)
+	} else {
+		err = switchToNewKeyAndRemoveIfBroken(gopts.ctx, repo, id, pw)
+	}
 	if err != nil {
 		return err
 	}
@@ -162,7 +201,7 @@ func changePassword(gopts GlobalOptions, repo *repository.Repository) error {
 		return err
 	}
 
-	id, err := repository.AddKey(gopts.ctx, repo, pw, "", "", repo.Key())
+	id, err := repository.CopyKey(gopts.ctx, repo, pw)
 	if err != nil {
 		return errors.Fatalf("creating new key failed: %v\n", err)
 	}
@@ -184,6 +223,18 @@ func changePassword(gopts GlobalOptions, repo *repository.Repository) error {
 	return nil
 }
 
+// openNewKeyAndRemoveIfBroken checks that the new write-only key can be opened,
+// the repository keeps using the current key.
+func openNewKeyAndRemoveIfBroken(ctx context.Context, repo *repository.Repository, key *repository.Key, pw string) error {
+	_, err := repository.OpenKey(ctx, repo, key.Name(), pw)
+	if err != nil {
+		h := restic.Handle{Type: restic.KeyFile, Name: key.Name()}
+		_ = repo.Backend().Remove(ctx, h)
+		return errors.Fatalf("failed to open the new key: %v", err)
+	}
+	return nil
+}
+
 func switchToNewKeyAndRemoveIfBroken(ctx context.Context, repo *repository.Repository, key *repository.Key, pw string) error {
 	// Verify new key to make sure it really works. A broken key can render the
 	// whole repository inaccessible
@@ -197,6 +248,490 @@ func switchToNewKeyAndRemoveIfBroken(ctx context.Context, repo *repository.Repos
 	return nil
 }
 
+// rotateMasterKey re-encrypts the repository with a new master key. The new
+// master key is saved in a pending key file first, so that an interrupted
+// rotation is resumed by running the command again.
+func rotateMasterKey(ctx context.Context, gopts GlobalOptions, repo *repository.Repository) error {
+	if repo.WriteOnly() {
+		return errors.Fatal("a write-only key cannot rotate the master key")
+	}
+
+	pending, err := repository.FindPendingKey(ctx, repo, gopts.password)
+	if err != nil {
+		return err
+	}
+	if pending != nil && pending.Rotation == nil {
+		return errors.Fatalf("pending key %v does not record the files of the rotation", pending.Name())
+	}
+
+	// only the keys which existed when the rotation was started are re-wrapped
+	var keys restic.IDs
+	if pending == nil {
+		err = repo.List(ctx, restic.KeyFile, func(id restic.ID, size int64) error {
+			keys = append(keys, id)
+			return nil
+		})
+	} else {
+		keys, _, err = listRotationFiles(ctx, repo, restic.KeyFile, pending.Rotation.Keys)
+	}
+	if err != nil {
+		return err
+	}
+
+	others, err := findOtherKeys(ctx, gopts, repo, keys)
+	if err != nil {
+		return err
+	}
+	for _, other := range others {
+		Warnf("key %v of %v@%v cannot be opened with the current password\n", other.id.Str(), other.key.Username, other.key.Hostname)
+	}
+	if len(others) > 0 && !keyRemoveOthers {
+		return errors.Fatalf("%d keys cannot be re-wrapped for the new master key, use --remove-other-keys to remove them", len(others))
+	}
+
+	if pending == nil {
+		current, err := repository.LoadKey(ctx, repo, repo.KeyName())
+		if err != nil {
+			return err
+		}
+
+		pending, err = repository.AddPendingKey(ctx, repo, gopts.password, current.Username, current.Hostname)
+		if err != nil {
+			return errors.Fatalf("creating new key failed: %v\n", err)
+		}
+		Verbosef("saved new master key as pending key %s\n", pending.Name())
+	} else {
+		Verbosef("resuming the rotation to the master key of pending key %s\n", pending.Name())
+	}
+
+	Verbosef("loading indexes...\n")
+	newRepo, err := loadRotationIndexes(ctx, repo, pending)
+	if err != nil {
+		return err
+	}
+
+	if newRepo != repo {
+		err = reencryptRepository(ctx, gopts, repo, newRepo, pending.Rotation)
+		if err != nil {
+			return err
+		}
+
+		err = switchConfig(ctx, repo, newRepo)
+		if err != nil {
+			return err
+		}
+
+		// use the new master key from now on, also for refreshing the lock
+		err = repo.SearchKey(ctx, gopts.password, 0, pending.Name())
+		if err != nil {
+			return err
+		}
+	}
+
+	packs := newRepo.Index().Packs(restic.NewIDSet())
+	oldPacks := restic.NewIDSet()
+	err = repo.List(ctx, restic.PackFile, func(id restic.ID, size int64) error {
+		if !packs.Has(id) {
+			oldPacks.Insert(id)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(oldPacks) > 0 {
+		Verbosef("removing %d old packs\n", len(oldPacks))
+		DeleteFiles(gopts, repo, oldPacks, restic.PackFile)
+	}
+
+	err = rewrapKeys(ctx, gopts, repo, pending)
+	if err != nil {
+		return err
+	}
+
+	Verbosef("done\n")
+	return nil
+}
+
+// listRotationFiles lists the files of type tpe. It returns the files which are
+// recorded in the rotation, and thus are still encrypted with the old master
+// key, and the files which were created since the rotation was started.
+func listRotationFiles(ctx context.Context, repo *repository.Repository, tpe restic.FileType, recorded restic.IDs) (old, created restic.IDs, err error) {
+	oldIDs := restic.NewIDSet(recorded...)
+	err = repo.List(ctx, tpe, func(id restic.ID, size int64) error {
+		if oldIDs.Has(id) {
+			old = append(old, id)
+		} else {
+			created = append(created, id)
+		}
+		return nil
+	})
+	return old, created, err
+}
+
+// loadRotationIndexes loads the index files recorded in the rotation of pending
+// into the index of repo and the index files created by the rotation into the
+// index of a repository for the master key of pending, which is returned. If
+// the config was already switched to the new master key, the old index files
+// were removed before and repo is returned.
+func loadRotationIndexes(ctx context.Context, repo *repository.Repository, pending *repository.Key) (*repository.Repository, error) {
+	oldIndexes, newIndexes, err := listRotationFiles(ctx, repo, restic.IndexFile, pending.Rotation.Indexes)
+	if err != nil {
+		return nil, err
+	}
+
+	if repo.HasMasterKey(pending) {
+		if len(oldIndexes) > 0 {
+			return nil, errors.Fatalf("index %v of the old master key was not removed", oldIndexes[0].Str())
+		}
+		return repo, repository.LoadIndexFiles(ctx, repo, newIndexes)
+	}
+
+	newRepo := repo.WithKey(pending)
+	err = repository.LoadIndexFiles(ctx, repo, oldIndexes)
+	if err != nil {
+		return nil, err
+	}
+	err = repository.LoadIndexFiles(ctx, newRepo, newIndexes)
+	if err != nil {
+		return nil, err
+	}
+	return newRepo, nil
+}
+
+// reencryptRepository saves the blobs, index and snapshot files of repo with
+// the master key of newRepo, unless this was already done by an interrupted
+// run. After reading back everything encrypted with the new master key, the
+// old index and snapshot files are removed.
+func reencryptRepository(ctx context.Context, gopts GlobalOptions, repo, newRepo *repository.Repository, rotation *repository.Rotation) error {
+	keepBlobs := restic.NewBlobSet()
+	packs := restic.NewIDSet()
+	for blob := range repo.Index().Each(ctx) {
+		if !newRepo.Index().Has(blob.BlobHandle) {
+			keepBlobs.Insert(blob.BlobHandle)
+			packs.Insert(blob.PackID)
+		}
+	}
+
+	if len(packs) > 0 {
+		Verbosef("re-encrypting %d packs\n", len(packs))
+		bar := newProgressMax(!gopts.Quiet, uint64(len(packs)), "packs re-encrypted")
+		_, err := repository.Repack(ctx, repo, newRepo, packs, keepBlobs, bar)
+		bar.Done()
+		if err != nil {
+			return errors.Fatalf("%s", err)
+		}
+	}
+
+	Verbosef("re-encrypting snapshots\n")
+	oldSnapshots, err := reencryptSnapshots(ctx, repo, newRepo, rotation)
+	if err != nil {
+		return err
+	}
+
+	Verbosef("verifying the re-encrypted repository\n")
+	for blob := range repo.Index().Each(ctx) {
+		if !newRepo.Index().Has(blob.BlobHandle) {
+			return errors.Fatalf("blob %v is missing in the re-encrypted index", blob.BlobHandle)
+		}
+	}
+
+	err = verifyReencryption(ctx, gopts, repo, newRepo, oldSnapshots)
+	if err != nil {
+		return err
+	}
+
+	oldIndexes := repo.Index().(*repository.MasterIndex).IDs()
+	for _, files := range []struct {
+		ids restic.IDSet
+		tpe restic.FileType
+	}{
+		{oldSnapshots, restic.SnapshotFile},
+		{oldIndexes, restic.IndexFile},
+	} {
+		if len(files.ids) == 0 {
+			continue
+		}
+
+		Verbosef("removing %d old %v files\n", len(files.ids), files.tpe)
+		retained, err := deleteFiles(gopts, false, repo, files.ids, files.tpe)
+		if err != nil {
+			return errors.Fatalf("%s", err)
+		}
+		if len(retained) > 0 {
+			return errors.Fatalf("%d old %v files are still under retention, run the command again after the retention period has expired", len(retained), files.tpe)
+		}
+	}
+
+	return nil
+}
+
+// verifyReencryption reads back all packs, index and snapshot files which are
+// encrypted with the master key of newRepo. The snapshot files in
+// oldSnapshots are still encrypted with the old master key and are skipped.
+func verifyReencryption(ctx context.Context, gopts GlobalOptions, repo, newRepo *repository.Repository, oldSnapshots restic.IDSet) error {
+	sizes := make(map[restic.ID]int64)
+	err := repo.List(ctx, restic.PackFile, func(id restic.ID, size int64) error {
+		sizes[id] = size
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	packs := make(map[restic.ID]int64)
+	for id := range newRepo.Index().Packs(restic.NewIDSet()) {
+		size, ok := sizes[id]
+		if !ok {
+			return errors.Fatalf("re-encrypted pack %v is missing", id.Str())
+		}
+		packs[id] = size
+	}
+
+	bar := newProgressMax(!gopts.Quiet, uint64(len(packs)), "packs verified")
+	errChan := make(chan error)
+	go checker.New(newRepo, false).ReadPacks(ctx, packs, bar, errChan)
+
+	damaged := 0
+	for err := range errChan {
+		damaged++
+		Warnf("%v\n", err)
+	}
+	bar.Done()
+	if damaged > 0 {
+		return errors.Fatalf("%d re-encrypted packs are damaged, run the command again to repeat the rotation", damaged)
+	}
+
+	for id := range newRepo.Index().(*repository.MasterIndex).IDs() {
+		_, err = newRepo.LoadUnpacked(ctx, nil, restic.IndexFile, id)
+		if err != nil {
+			return errors.Fatalf("unable to load re-encrypted index %v: %v", id.Str(), err)
+		}
+	}
+
+	return repo.List(ctx, restic.SnapshotFile, func(id restic.ID, size int64) error {
+		if oldSnapshots.Has(id) {
+			return nil
+		}
+
+		_, err := newRepo.LoadUnpacked(ctx, nil, restic.SnapshotFile, id)
+		if err != nil {
+			return errors.Fatalf("unable to load re-encrypted snapshot %v: %v", id.Str(), err)
+		}
+		return nil
+	})
+}
+
+// reencryptSnapshots saves the snapshot files recorded in the rotation which
+// do not have a copy encrypted with the master key of newRepo yet. It returns
+// the IDs of all snapshot files encrypted with the old master key.
+func reencryptSnapshots(ctx context.Context, repo, newRepo *repository.Repository, rotation *repository.Rotation) (restic.IDSet, error) {
+	oldIDs, newIDs, err := listRotationFiles(ctx, repo, restic.SnapshotFile, rotation.Snapshots)
+	if err != nil {
+		return nil, err
+	}
+
+	// the plaintext of the snapshot files is copied, so copies are found
+	// by the hash of the plaintext
+	copied := restic.NewIDSet()
+	for _, id := range newIDs {
+		buf, err := newRepo.LoadUnpacked(ctx, nil, restic.SnapshotFile, id)
+		if err != nil {
+			return nil, errors.Fatalf("unable to load re-encrypted snapshot %v: %v", id.Str(), err)
+		}
+		copied.Insert(restic.Hash(buf))
+	}
+
+	for _, id := range oldIDs {
+		buf, err := repo.LoadUnpacked(ctx, nil, restic.SnapshotFile, id)
+		if err != nil {
+			return nil, errors.Fatalf("unable to load snapshot %v: %v", id.Str(), err)
+		}
+		if copied.Has(restic.Hash(buf)) {
+			continue
+		}
+
+		newID, err := newRepo.SaveUnpacked(ctx, restic.SnapshotFile, buf)
+		if err != nil {
+			return nil, err
+		}
+		copied.Insert(restic.Hash(buf))
+		Verboseff("snapshot %v saved as %v\n", id.Str(), newID.Str())
+	}
+
+	return restic.NewIDSet(oldIDs...), nil
+}
+
+// switchConfig replaces the config with the config of newRepo, which is
+// encrypted with its master key and contains its recipient key. If this
+// fails, the old config is restored.
+func switchConfig(ctx context.Context, repo, newRepo *repository.Repository) error {
+	h := restic.Handle{Type: restic.ConfigFile}
+	oldConfig, err := backend.LoadAll(ctx, nil, repo.Backend(), h)
+	if err != nil {
+		return err
+	}
+
+	if !repo.Backend().HasAtomicReplace() {
+		// remove the original file for backends which do not support atomic overwriting
+		err = repo.Backend().Remove(ctx, h)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = newRepo.SaveJSONUnpacked(ctx, restic.ConfigFile, newRepo.Config())
+	if err != nil {
+		_ = repo.Backend().Remove(ctx, h)
+		restoreErr := repo.Backend().Save(ctx, h, restic.NewByteReader(oldConfig, repo.Backend().Hasher()))
+		if restoreErr != nil {
+			return errors.Fatalf("saving the new config failed: %v, restoring the old config failed as well: %v", err, restoreErr)
+		}
+		return errors.Fatalf("saving the new config failed: %v", err)
+	}
+
+	return nil
+}
+
+// otherKey is a key which cannot be opened with the current password.
+type otherKey struct {
+	id  restic.ID
+	key *repository.Key
+}
+
+// findOtherKeys returns the keys in ids which cannot be opened with the current
+// password, so they cannot be re-wrapped for a new master key.
+func findOtherKeys(ctx context.Context, gopts GlobalOptions, repo *repository.Repository, ids restic.IDs) ([]otherKey, error) {
+	var others []otherKey
+	for _, id := range ids {
+		_, err := repository.OpenKey(ctx, repo, id.String(), gopts.password)
+		if errors.Is(err, crypto.ErrUnauthenticated) {
+			k, err := repository.LoadKey(ctx, repo, id.String())
+			if err != nil {
+				return nil, err
+			}
+			others = append(others, otherKey{id: id, key: k})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return others, nil
+}
+
+// rewrapKeys replaces the keys recorded in the rotation of pending which can be
+// opened with the current password by keys for the new master key and removes
+// the pending key. Keys with other passwords cannot be re-wrapped, they are
+// removed if --remove-other-keys is given. Keys which were created since the
+// rotation was started are already for the new master key. If the rotation is
+// interrupted after re-wrapping a key, but before removing the old key, the
+// old key is re-wrapped again by the next run.
+func rewrapKeys(ctx context.Context, gopts GlobalOptions, repo *repository.Repository, pending *repository.Key) error {
+	names, created, err := listRotationFiles(ctx, repo, restic.KeyFile, pending.Rotation.Keys)
+	if err != nil {
+		return err
+	}
+
+	rewrapped := false
+	for _, id := range created {
+		if id.String() == pending.Name() {
+			continue
+		}
+
+		k, err := repository.OpenKey(ctx, repo, id.String(), gopts.password)
+		if errors.Is(err, crypto.ErrUnauthenticated) {
+			// added with another password, not by the rotation
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		writeOnly := repo.Config().Version >= restic.SealedRepoVersion && !k.HasPrivateKey()
+		rewrapped = rewrapped || !writeOnly
+	}
+
+	for _, id := range names {
+		name := id.String()
+		k, err := repository.OpenKey(ctx, repo, name, gopts.password)
+		if errors.Is(err, crypto.ErrUnauthenticated) {
+			k, err = repository.LoadKey(ctx, repo, name)
+			if err != nil {
+				return err
+			}
+			if !keyRemoveOthers {
+				return errors.Fatalf("key %v of %v@%v cannot be re-wrapped for the new master key, use --remove-other-keys to remove it", name, k.Username, k.Hostname)
+			}
+			Warnf("removing key %v of %v@%v, it must be added again with \"restic key add\"\n", name, k.Username, k.Hostname)
+			err = deleteKey(ctx, repo, name)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		// write-only keys stay write-only
+		writeOnly := repo.Config().Version >= restic.SealedRepoVersion && !k.HasPrivateKey()
+
+		err = addRewrappedKey(ctx, gopts, repo, k.Username, k.Hostname, writeOnly)
+		if err != nil {
+			return err
+		}
+		rewrapped = rewrapped || !writeOnly
+
+		err = deleteKey(ctx, repo, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !rewrapped {
+		err = addRewrappedKey(ctx, gopts, repo, pending.Username, pending.Hostname, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	h := restic.Handle{Type: restic.KeyFile, Name: pending.Name()}
+	return repo.Backend().Remove(ctx, h)
+}
+
+// addRewrappedKey adds a key for the current master key and password and
+// switches to it. A write-only key does not receive the private key, the
+// repository keeps using the current key.
+func addRewrappedKey(ctx context.Context, gopts GlobalOptions, repo *repository.Repository, username, hostname string, writeOnly bool) error {
+	var id *repository.Key
+	var err error
+	if writeOnly {
+		id, err = repository.AddWriteOnlyKey(ctx, repo, gopts.password, username, hostname)
+	} else {
+		id, err = repository.AddKey(ctx, repo, gopts.password, username, hostname, repo.Key())
+	}
+	if err != nil {
+		return errors.Fatalf("creating new key failed: %v\n", err)
+	}
+
+	if writeOnly {
+		err = openNewKeyAndRemoveIfBroken(ctx, repo, id, gopts.password)
+		if err != nil {
+			return err
+		}
+	} else {
+		err = switchToNewKeyAndRemoveIfBroken(ctx, repo, id, gopts.password)
+		if err != nil {
+			return err
+		}
+	}
+
+	Verbosef("saved new key as %s\n", id)
+	return nil
+}
+
 func runKey(gopts GlobalOptions, args []string) error {
 	if len(args) < 1 || (args[0] == "remove" && len(args) != 2) || (args[0] != "remove" && len(args) != 1) {
 		return errors.Fatal("wrong number of arguments")
@@ -248,6 +783,14 @@ func runKey(gopts GlobalOptions, args []string) error {
 		}
 
 		return changePassword(gopts, repo)
+	case "rotate-master":
+		lock, err := lockRepoExclusive(ctx, repo)
+		defer unlockRepo(lock)
+		if err != nil {
+			return err
+		}
+
+		return rotateMasterKey(ctx, gopts, repo)
 	}
 
 	return nil
@@ -36,7 +36,7 @@ var migrateOptions MigrateOptions
 func init() {
 	cmdRoot.AddCommand(cmdMigrate)
 	f := cmdMigrate.Flags()
-	f.BoolVarP(&migrateOptions.Force, "force", "f", false, `apply a migration a second time`)
+	f.BoolVarP(&migrateOptions.Force, "force", "f", false, `apply a migration a second time or even if it makes other keys write-only`)
 }
 
 func checkMigrations(opts MigrateOptions, gopts GlobalOptions, repo restic.Repository) error {
@@ -84,6 +84,22 @@ func applyMigrations(opts MigrateOptions, gopts GlobalOptions, repo restic.Repos
 					Warnf("check for migration %v failed, continuing anyway\n", m.Name())
 				}
 
+				if d, ok := m.(migrations.KeyDemotion); ok {
+					keys, err := d.DemotedKeys(ctx, repo)
+					if err != nil {
+						return err
+					}
+
+					for _, k := range keys {
+						Warnf("key %v of %v@%v will become write-only\n", k.Name(), k.Username, k.Hostname)
+					}
+
+					if len(keys) > 0 && !opts.Force {
+						Warnf("migration %v cannot be applied: %d other keys lose read access\nIf you want to apply this migration anyway, re-run with option --force\n", m.Name(), len(keys))
+						continue
+					}
+				}
+
 				if m.RepoCheck() {
 					Printf("checking repository integrity...\n")
 
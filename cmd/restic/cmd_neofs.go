@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"strings"
+
+	"github.com/nspcc-dev/neo-go/pkg/crypto/keys"
+	"github.com/nspcc-dev/neofs-sdk-go/eacl"
+	"github.com/spf13/cobra"
+
+	"github.com/restic/restic/internal/backend/location"
+	"github.com/restic/restic/internal/backend/neofs"
+	"github.com/restic/restic/internal/errors"
+)
+
+var cmdNeoFS = &cobra.Command{
+	Use:   "neofs",
+	Short: "Manage the access to a repository stored in NeoFS",
+	Long: `
+The "neofs" commands manage the extended ACL of the container which stores a
+repository in NeoFS. They connect with the wallet of the container owner.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+}
+
+var cmdNeoFSEACL = &cobra.Command{
+	Use:   "eacl",
+	Short: "Print the extended ACL of the container",
+	Long: `
+The "eacl" command prints the records of the extended ACL of the container.
+The first record matching a request decides whether it is allowed.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runNeoFSEACL(globalOptions, args)
+	},
+}
+
+var cmdNeoFSGrant = &cobra.Command{
+	Use:   "grant [flags] public-key",
+	Short: "Allow a key to read the repository",
+	Long: `
+The "grant" command adds records to the extended ACL of the container which
+allow the owner of the given public key (hex encoded) to get, head, search
+and read ranges of the objects, i.e. to read the repository. The basic ACL of
+the container must allow extending it.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runNeoFSGrant(globalOptions, args)
+	},
+}
+
+var cmdNeoFSRevoke = &cobra.Command{
+	Use:   "revoke [flags] public-key",
+	Short: "Revoke the read access of a key",
+	Long: `
+The "revoke" command removes the records added by "grant" for the given
+public key from the extended ACL of the container.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runNeoFSRevoke(globalOptions, args)
+	},
+}
+
+var cmdNeoFSBearer = &cobra.Command{
+	Use:   "bearer [flags] public-key",
+	Short: "Issue a bearer token to read the repository",
+	Long: `
+The "bearer" command issues a bearer token signed by the container owner. It
+allows the owner of the given public key (hex encoded) to read the repository
+without changing the extended ACL of the container, writing and removing
+objects is denied. The token can be passed to restic using the
+"neofs.bearer-token" option.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runNeoFSBearer(neofsBearerOptions, globalOptions, args)
+	},
+}
+
+// NeoFSBearerOptions bundles all options for the 'neofs bearer' command.
+type NeoFSBearerOptions struct {
+	Lifetime uint64
+	Output   string
+}
+
+var neofsBearerOptions NeoFSBearerOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdNeoFS)
+	cmdNeoFS.AddCommand(cmdNeoFSEACL)
+	cmdNeoFS.AddCommand(cmdNeoFSGrant)
+	cmdNeoFS.AddCommand(cmdNeoFSRevoke)
+	cmdNeoFS.AddCommand(cmdNeoFSBearer)
+
+	f := cmdNeoFSBearer.Flags()
+	f.Uint64Var(&neofsBearerOptions.Lifetime, "lifetime", 100, "number of epochs the token is valid for")
+	f.StringVarP(&neofsBearerOptions.Output, "output", "o", "", "write the token to `file` instead of stdout")
+}
+
+// openNeoFSAdmin connects to the network hosting the repository.
+func openNeoFSAdmin(gopts GlobalOptions) (*neofs.Admin, error) {
+	repo, err := ReadRepo(gopts)
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := location.Parse(repo)
+	if err != nil {
+		return nil, errors.Fatalf("parsing repository location failed: %v", err)
+	}
+	if loc.Scheme != "neofs" {
+		return nil, errors.Fatalf("repository %v is not stored in NeoFS", location.StripPassword(repo))
+	}
+
+	cfg, err := parseConfig(loc, gopts.extended)
+	if err != nil {
+		return nil, err
+	}
+
+	admin, err := neofs.OpenAdmin(gopts.ctx, neofsConfig(cfg.(neofs.Config), gopts))
+	if err != nil {
+		return nil, errors.Fatalf("unable to connect to NeoFS: %v", err)
+	}
+	return admin, nil
+}
+
+// parseNeoFSPublicKey parses the only argument as a hex encoded public key.
+func parseNeoFSPublicKey(args []string) (*keys.PublicKey, error) {
+	if len(args) != 1 {
+		return nil, errors.Fatal("public key not specified")
+	}
+
+	key, err := keys.NewPublicKeyFromString(args[0])
+	if err != nil {
+		return nil, errors.Fatalf("invalid public key %q: %v", args[0], err)
+	}
+	return key, nil
+}
+
+func runNeoFSEACL(gopts GlobalOptions, args []string) error {
+	if len(args) != 0 {
+		return errors.Fatal("the eacl command expects no arguments")
+	}
+
+	admin, err := openNeoFSAdmin(gopts)
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+
+	table, err := admin.EACL(gopts.ctx)
+	if err != nil {
+		return err
+	}
+
+	Printf("extended ACL of container %v\n", admin.ContainerID())
+	records := table.Records()
+	if len(records) == 0 {
+		Printf("no records\n")
+	}
+	for _, rec := range records {
+		Printf("%-6s %-8s %s\n", rec.Action(), rec.Operation(), formatEACLTargets(rec))
+	}
+	return nil
+}
+
+// formatEACLTargets describes the targets and filters of rec.
+func formatEACLTargets(rec eacl.Record) string {
+	var parts []string
+	for _, target := range rec.Targets() {
+		if target.Role() != eacl.RoleUnknown {
+			parts = append(parts, target.Role().String())
+		}
+		for _, key := range target.BinaryKeys() {
+			parts = append(parts, hex.EncodeToString(key))
+		}
+	}
+	for _, filter := range rec.Filters() {
+		parts = append(parts, "filter "+filter.Key()+" "+filter.Matcher().String()+" "+filter.Value().String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+func runNeoFSGrant(gopts GlobalOptions, args []string) error {
+	key, err := parseNeoFSPublicKey(args)
+	if err != nil {
+		return err
+	}
+
+	admin, err := openNeoFSAdmin(gopts)
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+
+	if err = admin.GrantReadOnly(gopts.ctx, key); err != nil {
+		return err
+	}
+
+	Verbosef("granted read access to key %s\n", key.StringCompressed())
+	return nil
+}
+
+func runNeoFSRevoke(gopts GlobalOptions, args []string) error {
+	key, err := parseNeoFSPublicKey(args)
+	if err != nil {
+		return err
+	}
+
+	admin, err := openNeoFSAdmin(gopts)
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+
+	if err = admin.RevokeReadOnly(gopts.ctx, key); err != nil {
+		return err
+	}
+
+	Verbosef("revoked read access of key %s\n", key.StringCompressed())
+	return nil
+}
+
+func runNeoFSBearer(opts NeoFSBearerOptions, gopts GlobalOptions, args []string) error {
+	key, err := parseNeoFSPublicKey(args)
+	if err != nil {
+		return err
+	}
+	if opts.Lifetime == 0 {
+		return errors.Fatal("the lifetime of the token must be at least one epoch")
+	}
+
+	admin, err := openNeoFSAdmin(gopts)
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+
+	tok, err := admin.ReadOnlyBearerToken(gopts.ctx, key, opts.Lifetime)
+	if err != nil {
+		return err
+	}
+
+	data, err := tok.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	if opts.Output == "" {
+		Println(string(data))
+		return nil
+	}
+
+	if err = ioutil.WriteFile(opts.Output, append(data, '\n'), 0600); err != nil {
+		return errors.Fatalf("unable to write token: %v", err)
+	}
+	Verbosef("bearer token written to %v\n", opts.Output)
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"math"
 	"sort"
 	"strconv"
@@ -51,6 +52,7 @@ type PruneOptions struct {
 	MaxRepackBytes uint64
 
 	RepackCachableOnly bool
+	RepackSmall        bool
 	RepackUncompressed bool
 }
 
@@ -69,6 +71,7 @@ func addPruneOptions(c *cobra.Command) {
 	f.StringVar(&pruneOptions.MaxUnused, "max-unused", "5%", "tolerate given `limit` of unused data (absolute value in bytes with suffixes k/K, m/M, g/G, t/T, a value in % or the word 'unlimited')")
 	f.StringVar(&pruneOptions.MaxRepackSize, "max-repack-size", "", "maximum `size` to repack (allowed suffixes: k/K, m/M, g/G, t/T)")
 	f.BoolVar(&pruneOptions.RepackCachableOnly, "repack-cacheable-only", false, "only repack packs which are cacheable")
+	f.BoolVar(&pruneOptions.RepackSmall, "repack-small", false, "repack pack files below the target pack size")
 	f.BoolVar(&pruneOptions.RepackUncompressed, "repack-uncompressed", false, "repack all uncompressed data")
 }
 
@@ -146,6 +149,10 @@ func runPrune(opts PruneOptions, gopts GlobalOptions) error {
 		return err
 	}
 
+	if repo.WriteOnly() {
+		return repository.ErrWriteOnly
+	}
+
 	if repo.Backend().Connections() < 2 {
 		return errors.Fatal("prune requires a backend connection limit of at least two")
 	}
@@ -207,8 +214,14 @@ type packInfo struct {
 type packInfoWithID struct {
 	ID restic.ID
 	packInfo
+	small bool
 }
 
+// minRepackSmall is the minimum number of small packs for --repack-small to
+// repack them. This also prevents repacking the last, partially filled pack
+// of every run again.
+const minRepackSmall = 10
+
 // prune selects which files to rewrite and then does that. The map usedBlobs is
 // modified in the process.
 func prune(opts PruneOptions, gopts GlobalOptions, repo restic.Repository, usedBlobs restic.BlobSet) error {
@@ -293,6 +306,14 @@ func prune(opts PruneOptions, gopts GlobalOptions, repo restic.Repository, usedB
 		indexPack[blob.PackID] = ip
 	}
 
+	// sealed packs start with the sealed data key, which is always used
+	for id, ip := range indexPack {
+		if repo.Index().IsSealedPack(id) {
+			ip.usedSize += pack.SealedKeySize
+			indexPack[id] = ip
+		}
+	}
+
 	// Check if all used blobs have been found in index
 	if len(usedBlobs) != 0 {
 		Warnf("%v not found in the index\n\n"+
@@ -309,6 +330,7 @@ func prune(opts PruneOptions, gopts GlobalOptions, repo restic.Repository, usedB
 	repackPacks := restic.NewIDSet()
 
 	var repackCandidates []packInfoWithID
+	var repackSmallCandidates []packInfoWithID
 	repackAllPacksWithDuplicates := true
 
 	keep := func(p packInfo) {
@@ -319,6 +341,7 @@ func prune(opts PruneOptions, gopts GlobalOptions, repo restic.Repository, usedB
 	}
 
 	repoVersion := repo.Config().Version
+	targetPackSize := int64(repo.PackSize())
 
 	// loop over all packs and decide what to do
 	bar := newProgressMax(!gopts.Quiet, uint64(len(indexPack)), "packs processed")
@@ -374,6 +397,11 @@ func prune(opts PruneOptions, gopts GlobalOptions, repo restic.Repository, usedB
 			keep(p)
 
 		case p.unusedBlobs == 0 && p.duplicateBlobs == 0 && p.tpe != restic.InvalidBlob && !mustCompress:
+			if opts.RepackSmall && packSize < targetPackSize {
+				// pack is used completely, but smaller than the target size => consolidate it
+				repackSmallCandidates = append(repackSmallCandidates, packInfoWithID{ID: id, packInfo: p, small: true})
+				break
+			}
 			// All blobs in pack are used and not duplicates/mixed => keep pack!
 			keep(p)
 
@@ -391,6 +419,14 @@ func prune(opts PruneOptions, gopts GlobalOptions, repo restic.Repository, usedB
 		return err
 	}
 
+	if len(repackSmallCandidates) < minRepackSmall {
+		for _, p := range repackSmallCandidates {
+			keep(p.packInfo)
+		}
+	} else {
+		repackCandidates = append(repackCandidates, repackSmallCandidates...)
+	}
+
 	// At this point indexPacks contains only missing packs!
 
 	// missing packs that are not needed can be ignored
@@ -458,8 +494,8 @@ func prune(opts PruneOptions, gopts GlobalOptions, repo restic.Repository, usedB
 		case reachedRepackSize:
 			keep(p.packInfo)
 
-		case p.duplicateBlobs > 0, p.tpe != restic.DataBlob, p.uncompressed:
-			// repacking duplicates/non-data/uncompressed-trees is only limited by repackSize
+		case p.duplicateBlobs > 0, p.tpe != restic.DataBlob, p.uncompressed, p.small:
+			// repacking duplicates/non-data/uncompressed-trees/small packs is only limited by repackSize
 			repack(p.ID, p.packInfo)
 
 		case reachedUnusedSizeAfter:
@@ -549,15 +585,25 @@ func prune(opts PruneOptions, gopts GlobalOptions, repo restic.Repository, usedB
 		ignorePacks.Merge(removePacks)
 	}
 
+	var retainedIndexes restic.IDSet
 	if opts.unsafeRecovery {
 		Verbosef("deleting index files\n")
 		indexFiles := repo.Index().(*repository.MasterIndex).IDs()
-		err = DeleteFilesChecked(gopts, repo, indexFiles, restic.IndexFile)
+		retainedIndexes, err = deleteFiles(gopts, false, repo, indexFiles, restic.IndexFile)
 		if err != nil {
 			return errors.Fatalf("%s", err)
 		}
 	} else if len(ignorePacks) != 0 {
-		err = rebuildIndexFiles(gopts, repo, ignorePacks, nil)
+		retainedIndexes, err = rebuildIndexFiles(gopts, repo, ignorePacks, nil)
+		if err != nil {
+			return errors.Fatalf("%s", err)
+		}
+	}
+
+	if len(retainedIndexes) != 0 {
+		// the index files which could not be removed are still used, the
+		// packs they reference must be kept
+		err = keepIndexedPacks(ctx, repo, retainedIndexes, removePacks)
 		if err != nil {
 			return errors.Fatalf("%s", err)
 		}
@@ -590,14 +636,45 @@ func writeIndexFiles(gopts GlobalOptions, repo restic.Repository, removePacks re
 	return obsoleteIndexes, err
 }
 
-func rebuildIndexFiles(gopts GlobalOptions, repo restic.Repository, removePacks restic.IDSet, extraObsolete restic.IDs) error {
+// rebuildIndexFiles writes the index without removePacks and deletes the
+// obsolete index files. It returns the obsolete index files which are still
+// under retention.
+func rebuildIndexFiles(gopts GlobalOptions, repo restic.Repository, removePacks restic.IDSet, extraObsolete restic.IDs) (restic.IDSet, error) {
 	obsoleteIndexes, err := writeIndexFiles(gopts, repo, removePacks, extraObsolete)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	Verbosef("deleting obsolete index files\n")
-	return DeleteFilesChecked(gopts, repo, obsoleteIndexes, restic.IndexFile)
+	return deleteFiles(gopts, false, repo, obsoleteIndexes, restic.IndexFile)
+}
+
+// keepIndexedPacks removes the packs referenced by the index files from
+// removePacks.
+func keepIndexedPacks(ctx context.Context, repo restic.Repository, indexes restic.IDSet, removePacks restic.IDSet) error {
+	kept := 0
+	for id := range indexes {
+		buf, err := repo.LoadUnpacked(ctx, nil, restic.IndexFile, id)
+		if err != nil {
+			return err
+		}
+		idx, _, err := repository.DecodeIndex(buf, id)
+		if err != nil {
+			return err
+		}
+
+		for packID := range idx.Packs() {
+			if removePacks.Has(packID) {
+				removePacks.Delete(packID)
+				kept++
+			}
+		}
+	}
+
+	if kept > 0 {
+		Warnf("keeping %d packs referenced by index files under retention, they are removed by a later prune\n", kept)
+	}
+	return nil
 }
 
 func getUsedBlobs(gopts GlobalOptions, repo restic.Repository, ignoreSnapshots restic.IDSet) (usedBlobs restic.BlobSet, err error) {
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/restic/restic/internal/backend/location"
+	"github.com/restic/restic/internal/backend/mirror"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+var cmdResync = &cobra.Command{
+	Use:   "resync [flags]",
+	Short: "Copy missing files between the members of a mirror",
+	Long: `
+The "resync" command brings the members of a mirror repository ("mirror:"
+location) up to date. Files which are missing in a member, for example because
+it was not reachable during a backup or because it was newly added, are copied
+from another member. Files stored with different sizes in the members are
+reported, but not changed. Lock files are never copied.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runResync(resyncOptions, globalOptions, args)
+	},
+}
+
+// ResyncOptions bundles all options for the 'resync' command.
+type ResyncOptions struct {
+	DryRun bool
+}
+
+var resyncOptions ResyncOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdResync)
+
+	f := cmdResync.Flags()
+	f.BoolVarP(&resyncOptions.DryRun, "dry-run", "n", false, "only show which files would be copied")
+}
+
+// findMirror returns the mirror in the chain of wrapped backends.
+func findMirror(be restic.Backend) (*mirror.Backend, bool) {
+	for {
+		if m, ok := be.(*mirror.Backend); ok {
+			return m, true
+		}
+
+		u, ok := be.(restic.BackendUnwrapper)
+		if !ok {
+			return nil, false
+		}
+		be = u.Unwrap()
+	}
+}
+
+func runResync(opts ResyncOptions, gopts GlobalOptions, args []string) error {
+	if len(args) != 0 {
+		return errors.Fatal("the resync command expects no arguments")
+	}
+
+	repo, err := OpenRepository(gopts)
+	if err != nil {
+		return err
+	}
+
+	m, ok := findMirror(repo.Backend())
+	if !ok {
+		return errors.Fatal("resync requires a mirror repository location")
+	}
+
+	// files must not be removed while they are copied
+	if !opts.DryRun {
+		lock, err := lockRepoExclusive(gopts.ctx, repo)
+		defer unlockRepo(lock)
+		if err != nil {
+			return err
+		}
+	}
+
+	copied := 0
+	err = m.Resync(gopts.ctx, opts.DryRun, func(h restic.Handle, to restic.Backend) {
+		copied++
+		if opts.DryRun {
+			Verbosef("would copy %v to %v\n", h, location.StripPassword(to.Location()))
+		} else {
+			Verboseff("copied %v to %v\n", h, location.StripPassword(to.Location()))
+		}
+	}, func(h restic.Handle) {
+		Warnf("%v is stored with different sizes in the members\n", h)
+	})
+
+	if opts.DryRun {
+		Printf("would copy %d files\n", copied)
+	} else {
+		Printf("copied %d files\n", copied)
+	}
+
+	if errors.Is(err, mirror.ErrDiverged) {
+		return errors.Fatal("some files differ between the members, run check on each of them")
+	}
+	return err
+}
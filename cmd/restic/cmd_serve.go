@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/spf13/cobra"
+
+	"github.com/restic/restic/internal/backend/location"
+	"github.com/restic/restic/internal/backend/rest/server"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+var cmdServe = &cobra.Command{
+	Use:   "serve [flags]",
+	Short: "Serve the repository backend using the REST protocol",
+	Long: `
+The "serve" command publishes the backend of the repository given by --repo
+over HTTP using the REST protocol, so that other hosts can access it using a
+"rest:" repository location. The data stays encrypted, the server does not
+need the repository password. Any backend can be served, for example a NeoFS
+container, so that the clients do not need their own credentials for it.
+
+By default, the clients must authenticate with a user and password from the
+file given by --htpasswd-file, which can be created using the htpasswd tool
+with bcrypt hashes ("htpasswd -B"). In append-only mode, the clients cannot
+remove any files except for locks.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runServe(serveOptions, globalOptions, args)
+	},
+}
+
+// ServeOptions bundles all options for the 'serve' command.
+type ServeOptions struct {
+	Listen       string
+	AppendOnly   bool
+	HtpasswdFile string
+	NoAuth       bool
+	TLSCert      string
+	TLSKey       string
+}
+
+var serveOptions ServeOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdServe)
+
+	f := cmdServe.Flags()
+	f.StringVar(&serveOptions.Listen, "listen", ":8000", "listen on this `address`")
+	f.BoolVar(&serveOptions.AppendOnly, "append-only", false, "do not allow clients to remove files except for locks")
+	f.StringVar(&serveOptions.HtpasswdFile, "htpasswd-file", "", "read the users and passwords from `file`")
+	f.BoolVar(&serveOptions.NoAuth, "no-auth", false, "allow all clients to access the repository without a password")
+	f.StringVar(&serveOptions.TLSCert, "tls-cert", "", "serve HTTPS using the certificate from `file`")
+	f.StringVar(&serveOptions.TLSKey, "tls-key", "", "serve HTTPS using the private key from `file`")
+}
+
+func runServe(opts ServeOptions, gopts GlobalOptions, args []string) error {
+	if len(args) != 0 {
+		return errors.Fatal("the serve command expects no arguments")
+	}
+	if opts.HtpasswdFile == "" && !opts.NoAuth {
+		return errors.Fatal("either --htpasswd-file or --no-auth must be specified")
+	}
+	if opts.HtpasswdFile != "" && opts.NoAuth {
+		return errors.Fatal("--htpasswd-file and --no-auth are mutually exclusive")
+	}
+	if (opts.TLSCert == "") != (opts.TLSKey == "") {
+		return errors.Fatal("--tls-cert and --tls-key must be specified together")
+	}
+
+	srvOpts := server.Options{AppendOnly: opts.AppendOnly}
+	if opts.HtpasswdFile != "" {
+		auth, err := server.LoadHtpasswd(opts.HtpasswdFile)
+		if err != nil {
+			return errors.Fatalf("unable to load htpasswd file: %v", err)
+		}
+		srvOpts.Auth = auth
+	}
+
+	repo, err := ReadRepo(gopts)
+	if err != nil {
+		return err
+	}
+
+	// the storage of a new repository may not exist yet, it is created when a
+	// client runs "init" through the server
+	be, err := openBackend(repo, gopts, gopts.extended)
+	if err != nil {
+		Warnf("unable to open the backend, it is created by \"init\" through the server: %v\n", err)
+		be = nil
+	}
+
+	// "init" through the server prepares the storage the same way as
+	// "init" on the backend itself
+	srvOpts.Create = func(ctx context.Context) (restic.Backend, error) {
+		return create(repo, gopts.extended)
+	}
+	handler := server.New(be, srvOpts)
+	defer func() {
+		_ = handler.Close()
+	}()
+
+	ln, err := net.Listen("tcp", opts.Listen)
+	if err != nil {
+		return errors.Fatalf("unable to listen on %v: %v", opts.Listen, err)
+	}
+
+	srv := &http.Server{Handler: handler}
+	go func() {
+		<-gopts.ctx.Done()
+		_ = srv.Close()
+	}()
+
+	Verbosef("serving %v on %v\n", location.StripPassword(repo), ln.Addr())
+	if opts.TLSCert != "" {
+		err = srv.ServeTLS(ln, opts.TLSCert, opts.TLSKey)
+	} else {
+		err = srv.Serve(ln)
+	}
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
@@ -1,28 +1,37 @@
 package main
 
 import (
+	"sync"
+
 	"golang.org/x/sync/errgroup"
 
+	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/restic"
 )
 
 // DeleteFiles deletes the given fileList of fileType in parallel
 // it will print a warning if there is an error, but continue deleting the remaining files
 func DeleteFiles(gopts GlobalOptions, repo restic.Repository, fileList restic.IDSet, fileType restic.FileType) {
-	_ = deleteFiles(gopts, true, repo, fileList, fileType)
+	_, _ = deleteFiles(gopts, true, repo, fileList, fileType)
 }
 
 // DeleteFilesChecked deletes the given fileList of fileType in parallel
 // if an error occurs, it will cancel and return this error
 func DeleteFilesChecked(gopts GlobalOptions, repo restic.Repository, fileList restic.IDSet, fileType restic.FileType) error {
-	return deleteFiles(gopts, false, repo, fileList, fileType)
+	_, err := deleteFiles(gopts, false, repo, fileList, fileType)
+	return err
 }
 
 const numDeleteWorkers = 8
 
 // deleteFiles deletes the given fileList of fileType in parallel
 // if ignoreError=true, it will print a warning if there was an error, else it will abort.
-func deleteFiles(gopts GlobalOptions, ignoreError bool, repo restic.Repository, fileList restic.IDSet, fileType restic.FileType) error {
+// Files which the backend keeps until their retention period has expired are
+// skipped and returned.
+func deleteFiles(gopts GlobalOptions, ignoreError bool, repo restic.Repository, fileList restic.IDSet, fileType restic.FileType) (restic.IDSet, error) {
+	var m sync.Mutex
+	retained := restic.NewIDSet()
+
 	totalCount := len(fileList)
 	fileChan := make(chan restic.ID)
 	wg, ctx := errgroup.WithContext(gopts.ctx)
@@ -45,6 +54,16 @@ func deleteFiles(gopts GlobalOptions, ignoreError bool, repo restic.Repository,
 			for id := range fileChan {
 				h := restic.Handle{Type: fileType, Name: id.String()}
 				err := repo.Backend().Remove(ctx, h)
+				if errors.Is(err, restic.ErrRetained) {
+					if !gopts.JSON {
+						Verboseff("%v is still under retention, skipped\n", h)
+					}
+					m.Lock()
+					retained.Insert(id)
+					m.Unlock()
+					bar.Add(1)
+					continue
+				}
 				if err != nil {
 					if !gopts.JSON {
 						Warnf("unable to remove %v from the repository\n", h)
@@ -62,5 +81,9 @@ func deleteFiles(gopts GlobalOptions, ignoreError bool, repo restic.Repository,
 		})
 	}
 	err := wg.Wait()
-	return err
+
+	if len(retained) > 0 && !gopts.JSON {
+		Warnf("%d %v files are still under retention and were not removed\n", len(retained), fileType)
+	}
+	return retained, err
 }
@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -15,9 +16,11 @@ import (
 	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/backend/azure"
 	"github.com/restic/restic/internal/backend/b2"
+	"github.com/restic/restic/internal/backend/faulty"
 	"github.com/restic/restic/internal/backend/gs"
 	"github.com/restic/restic/internal/backend/local"
 	"github.com/restic/restic/internal/backend/location"
+	"github.com/restic/restic/internal/backend/mirror"
 	"github.com/restic/restic/internal/backend/neofs"
 	"github.com/restic/restic/internal/backend/rclone"
 	"github.com/restic/restic/internal/backend/rest"
@@ -28,6 +31,7 @@ import (
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/fs"
 	"github.com/restic/restic/internal/limiter"
+	"github.com/restic/restic/internal/metrics"
 	"github.com/restic/restic/internal/options"
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
@@ -66,10 +70,17 @@ type GlobalOptions struct {
 	TLSClientCert   string
 	CleanupCache    bool
 	Compression     repository.CompressionMode
+	PackSize        uint
+	DiskIndex       bool
 
 	LimitUploadKb   int
 	LimitDownloadKb int
 
+	LimitUploadSchedule   string
+	LimitDownloadSchedule string
+
+	MetricsFile string
+
 	ctx      context.Context
 	password string
 	stdout   io.Writer
@@ -77,6 +88,9 @@ type GlobalOptions struct {
 
 	backendTestHook, backendInnerTestHook backendWrapper
 
+	// metrics is set if the metrics are written to MetricsFile
+	metrics *metrics.Registry
+
 	// verbosity is set as follows:
 	//  0 means: don't print any messages except errors, this is used when --quiet is specified
 	//  1 is the default: print essential messages
@@ -123,8 +137,13 @@ func init() {
 	f.BoolVar(&globalOptions.InsecureTLS, "insecure-tls", false, "skip TLS certificate verification when connecting to the repo (insecure)")
 	f.BoolVar(&globalOptions.CleanupCache, "cleanup-cache", false, "auto remove old cache directories")
 	f.Var(&globalOptions.Compression, "compression", "compression mode (only available for repo format version 2), one of (auto|off|max)")
+	f.BoolVar(&globalOptions.DiskIndex, "disk-index", false, "keep the index in a memory-mapped file in the cache directory to reduce the memory usage")
+	f.UintVar(&globalOptions.PackSize, "pack-size", 0, "set the target pack `size` in MiB, stored in the repository by init (default: $RESTIC_PACK_SIZE or the size set by init)")
 	f.IntVar(&globalOptions.LimitUploadKb, "limit-upload", 0, "limits uploads to a maximum rate in KiB/s. (default: unlimited)")
 	f.IntVar(&globalOptions.LimitDownloadKb, "limit-download", 0, "limits downloads to a maximum rate in KiB/s. (default: unlimited)")
+	f.StringVar(&globalOptions.LimitUploadSchedule, "limit-upload-schedule", "", "limits uploads according to a `schedule` of daily time ranges and rates in KiB/s, e.g. 08:00-18:00=2048,18:00-08:00=0 (default: use --limit-upload)")
+	f.StringVar(&globalOptions.LimitDownloadSchedule, "limit-download-schedule", "", "limits downloads according to a `schedule` of daily time ranges and rates in KiB/s (default: use --limit-download)")
+	f.StringVar(&globalOptions.MetricsFile, "metrics-file", "", "write metrics in the Prometheus text format to `file` when the command finishes")
 	f.StringSliceVarP(&globalOptions.Options, "option", "o", []string{}, "set extended option (`key=value`, can be specified multiple times)")
 	// Use our "generate" command instead of the cobra provided "completion" command
 	cmdRoot.CompletionOptions.DisableDefaultCmd = true
@@ -414,6 +433,40 @@ func ReadRepo(opts GlobalOptions) (string, error) {
 
 const maxKeys = 20
 
+// parsePackSizeEnv sets the target pack size from $RESTIC_PACK_SIZE, unless it
+// is set with --pack-size.
+func parsePackSizeEnv(opts *GlobalOptions, flagSet bool) error {
+	env := os.Getenv("RESTIC_PACK_SIZE")
+	if env == "" || flagSet {
+		return nil
+	}
+
+	packSize, err := strconv.ParseUint(env, 10, 32)
+	if err != nil {
+		return errors.Fatalf("invalid RESTIC_PACK_SIZE %q, the size must be a number of MiB", env)
+	}
+	opts.PackSize = uint(packSize)
+	return nil
+}
+
+// repositoryOptions returns the options for a repository set in opts.
+func repositoryOptions(opts GlobalOptions) (repository.Options, error) {
+	repoOpts := repository.Options{
+		Compression: opts.Compression,
+		DiskIndex:   opts.DiskIndex,
+	}
+
+	if opts.PackSize != 0 {
+		// check the size in MiB, the size in bytes could overflow
+		if opts.PackSize < repository.MinPackSize/1024/1024 || opts.PackSize > repository.MaxPackSize/1024/1024 {
+			return repository.Options{}, errors.Fatalf("invalid --pack-size %d, only sizes between %d and %d MiB are allowed",
+				opts.PackSize, repository.MinPackSize/1024/1024, repository.MaxPackSize/1024/1024)
+		}
+		repoOpts.PackSize = opts.PackSize * 1024 * 1024
+	}
+	return repoOpts, nil
+}
+
 // OpenRepository reads the password and opens the repository.
 func OpenRepository(opts GlobalOptions) (*repository.Repository, error) {
 	repo, err := ReadRepo(opts)
@@ -426,9 +479,19 @@ func OpenRepository(opts GlobalOptions) (*repository.Repository, error) {
 		return nil, err
 	}
 
-	be = backend.NewRetryBackend(be, 10, func(msg string, err error, d time.Duration) {
+	var mbe *metrics.Backend
+	if opts.metrics != nil {
+		mbe = metrics.NewBackend(be, opts.metrics)
+		be = mbe
+	}
+
+	rbe := backend.NewRetryBackend(be, 10, func(msg string, err error, d time.Duration) {
 		Warnf("%v returned error, retrying after %v: %v\n", msg, d, err)
 	})
+	if mbe != nil {
+		rbe.Retried = mbe.Retried
+	}
+	be = rbe
 
 	// wrap backend if a test specified a hook
 	if opts.backendTestHook != nil {
@@ -438,7 +501,11 @@ func OpenRepository(opts GlobalOptions) (*repository.Repository, error) {
 		}
 	}
 
-	s := repository.New(be, repository.Options{Compression: opts.Compression})
+	repoOpts, err := repositoryOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	s := repository.New(be, repoOpts)
 
 	passwordTriesLeft := 1
 	if stdinIsTerminal() && opts.password == "" {
@@ -479,6 +546,9 @@ func OpenRepository(opts GlobalOptions) (*repository.Repository, error) {
 	}
 
 	if opts.NoCache {
+		if opts.DiskIndex {
+			Warnf("--disk-index requires the cache, keeping the index in memory\n")
+		}
 		return s, nil
 	}
 
@@ -677,13 +747,97 @@ func parseConfig(loc location.Location, opts options.Options) (interface{}, erro
 
 		debug.Log("opening neofs repository at %#v", cfg)
 		return cfg, nil
+	case "mirror":
+		cfg := loc.Config.(mirror.Config)
+
+		// the members are opened by their location, only check them here
+		for _, member := range cfg.Members {
+			memberLoc, err := location.Parse(member)
+			if err != nil {
+				return nil, errors.Fatalf("parsing mirror member location failed: %v", err)
+			}
+			if _, err := parseConfig(memberLoc, opts); err != nil {
+				return nil, err
+			}
+		}
+
+		debug.Log("opening mirror repository at %#v", cfg)
+		return cfg, nil
+	case "faulty":
+		cfg := loc.Config.(faulty.Config)
+		if err := opts.Apply(loc.Scheme, &cfg); err != nil {
+			return nil, err
+		}
+
+		// the wrapped backend is opened by its location, only check it here
+		innerLoc, err := location.Parse(cfg.Inner)
+		if err != nil {
+			return nil, errors.Fatalf("parsing location of the faulty backend failed: %v", err)
+		}
+		if _, err := parseConfig(innerLoc, opts); err != nil {
+			return nil, err
+		}
+
+		debug.Log("opening faulty repository at %#v", cfg)
+		return cfg, nil
 	}
 
 	return nil, errors.Fatalf("invalid backend: %q", loc.Scheme)
 }
 
+// neofsConfig places the neofs object cache into the cache directory, unless
+// the cache is disabled.
+func neofsConfig(cfg neofs.Config, gopts GlobalOptions) neofs.Config {
+	if gopts.NoCache {
+		cfg.ObjectCache = false
+	}
+	cfg.CacheDir = gopts.CacheDir
+	return cfg
+}
+
+// newLimiter returns the limiter for the rates and schedules set in gopts.
+func newLimiter(gopts GlobalOptions) (limiter.Limiter, error) {
+	upload, err := limiter.ParseSchedule(gopts.LimitUploadSchedule)
+	if err != nil {
+		return nil, errors.Fatalf("invalid --limit-upload-schedule: %v", err)
+	}
+
+	download, err := limiter.ParseSchedule(gopts.LimitDownloadSchedule)
+	if err != nil {
+		return nil, errors.Fatalf("invalid --limit-download-schedule: %v", err)
+	}
+
+	return limiter.NewScheduledLimiter(limiter.Limits{
+		UploadKb:         gopts.LimitUploadKb,
+		DownloadKb:       gopts.LimitDownloadKb,
+		UploadSchedule:   upload,
+		DownloadSchedule: download,
+	}, time.Now), nil
+}
+
 // Open the backend specified by a location config.
 func open(s string, gopts GlobalOptions, opts options.Options) (restic.Backend, error) {
+	be, err := openBackend(s, gopts, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// check if config is there
+	fi, err := be.Stat(globalOptions.ctx, restic.Handle{Type: restic.ConfigFile})
+	if err != nil {
+		return nil, errors.Fatalf("unable to open config file: %v\nIs there a repository at the following location?\n%v", err, location.StripPassword(s))
+	}
+
+	if fi.Size == 0 {
+		return nil, errors.New("config file has zero size, invalid repository?")
+	}
+
+	return be, nil
+}
+
+// openBackend opens the backend specified by a location config without
+// checking that it contains a repository.
+func openBackend(s string, gopts GlobalOptions, opts options.Options) (restic.Backend, error) {
 	debug.Log("parsing location %v", location.StripPassword(s))
 	loc, err := location.Parse(s)
 	if err != nil {
@@ -708,7 +862,10 @@ func open(s string, gopts GlobalOptions, opts options.Options) (restic.Backend,
 	}
 
 	// wrap the transport so that the throughput via HTTP is limited
-	lim := limiter.NewStaticLimiter(gopts.LimitUploadKb, gopts.LimitDownloadKb)
+	lim, err := newLimiter(gopts)
+	if err != nil {
+		return nil, err
+	}
 	rt = lim.Transport(rt)
 
 	switch loc.Scheme {
@@ -731,7 +888,11 @@ func open(s string, gopts GlobalOptions, opts options.Options) (restic.Backend,
 	case "rclone":
 		be, err = rclone.Open(cfg.(rclone.Config), lim)
 	case "neofs":
-		be, err = neofs.Open(globalOptions.ctx, cfg.(neofs.Config))
+		be, err = neofs.Open(globalOptions.ctx, neofsConfig(cfg.(neofs.Config), gopts))
+	case "mirror":
+		be, err = openMirror(cfg.(mirror.Config), gopts, opts)
+	case "faulty":
+		be, err = openFaulty(cfg.(faulty.Config), gopts, opts)
 
 	default:
 		return nil, errors.Fatalf("invalid backend: %q", loc.Scheme)
@@ -749,24 +910,53 @@ func open(s string, gopts GlobalOptions, opts options.Options) (restic.Backend,
 		}
 	}
 
-	if loc.Scheme == "local" || loc.Scheme == "sftp" {
+	if loc.Scheme == "local" || loc.Scheme == "sftp" || loc.Scheme == "neofs" {
 		// wrap the backend in a LimitBackend so that the throughput is limited
 		be = limiter.LimitBackend(be, lim)
 	}
 
-	// check if config is there
-	fi, err := be.Stat(globalOptions.ctx, restic.Handle{Type: restic.ConfigFile})
-	if err != nil {
-		return nil, errors.Fatalf("unable to open config file: %v\nIs there a repository at the following location?\n%v", err, location.StripPassword(s))
+	return be, nil
+}
+
+// openMirror opens the members of a mirror.
+func openMirror(cfg mirror.Config, gopts GlobalOptions, opts options.Options) (restic.Backend, error) {
+	var members []restic.Backend
+	for _, member := range cfg.Members {
+		be, err := openBackend(member, gopts, opts)
+		if err != nil {
+			closeBackends(members)
+			return nil, err
+		}
+		members = append(members, be)
 	}
+	return mirror.New(members, reportMirror), nil
+}
 
-	if fi.Size == 0 {
-		return nil, errors.New("config file has zero size, invalid repository?")
+// openFaulty opens the backend wrapped by faulty.
+func openFaulty(cfg faulty.Config, gopts GlobalOptions, opts options.Options) (restic.Backend, error) {
+	inner, err := openBackend(cfg.Inner, gopts, opts)
+	if err != nil {
+		return nil, err
 	}
 
+	be, err := faulty.New(inner, cfg)
+	if err != nil {
+		_ = inner.Close()
+		return nil, err
+	}
 	return be, nil
 }
 
+func reportMirror(msg string) {
+	Warnf("%v\n", msg)
+}
+
+func closeBackends(backends []restic.Backend) {
+	for _, be := range backends {
+		_ = be.Close()
+	}
+}
+
 // Create the backend specified by URI.
 func create(s string, opts options.Options) (restic.Backend, error) {
 	debug.Log("parsing location %v", s)
@@ -810,7 +1000,31 @@ func create(s string, opts options.Options) (restic.Backend, error) {
 	case "rclone":
 		return rclone.Create(globalOptions.ctx, cfg.(rclone.Config))
 	case "neofs":
-		return neofs.Create(globalOptions.ctx, cfg.(neofs.Config))
+		return neofs.Create(globalOptions.ctx, neofsConfig(cfg.(neofs.Config), globalOptions))
+	case "mirror":
+		var members []restic.Backend
+		for _, member := range cfg.(mirror.Config).Members {
+			be, err := create(member, opts)
+			if err != nil {
+				closeBackends(members)
+				return nil, errors.Fatalf("unable to create mirror member at %v: %v", location.StripPassword(member), err)
+			}
+			members = append(members, be)
+		}
+		return mirror.New(members, reportMirror), nil
+	case "faulty":
+		fcfg := cfg.(faulty.Config)
+		inner, err := create(fcfg.Inner, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		be, err := faulty.New(inner, fcfg)
+		if err != nil {
+			_ = inner.Close()
+			return nil, err
+		}
+		return be, nil
 	}
 
 	debug.Log("invalid repository scheme: %v", s)
@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -59,3 +60,29 @@ func TestReadRepo(t *testing.T) {
 		t.Fatal("must not read repository path from invalid file path")
 	}
 }
+
+func TestParsePackSizeEnv(t *testing.T) {
+	old, ok := os.LookupEnv("RESTIC_PACK_SIZE")
+	defer func() {
+		if ok {
+			_ = os.Setenv("RESTIC_PACK_SIZE", old)
+		} else {
+			_ = os.Unsetenv("RESTIC_PACK_SIZE")
+		}
+	}()
+
+	rtest.OK(t, os.Setenv("RESTIC_PACK_SIZE", "64"))
+	var opts GlobalOptions
+	rtest.OK(t, parsePackSizeEnv(&opts, false))
+	rtest.Equals(t, uint(64), opts.PackSize)
+
+	// the flag takes precedence
+	opts = GlobalOptions{PackSize: 32}
+	rtest.OK(t, parsePackSizeEnv(&opts, true))
+	rtest.Equals(t, uint(32), opts.PackSize)
+
+	rtest.OK(t, os.Setenv("RESTIC_PACK_SIZE", "16M"))
+	opts = GlobalOptions{}
+	err := parsePackSizeEnv(&opts, false)
+	rtest.Assert(t, err != nil, "invalid pack size was accepted")
+}
@@ -14,12 +14,15 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
 	"testing"
 	"time"
 
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/crypto"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/filter"
 	"github.com/restic/restic/internal/fs"
@@ -908,6 +911,55 @@ func TestInitCopyChunkerParams(t *testing.T) {
 		otherRepo.Config().ChunkerPolynomial)
 }
 
+func TestInitChunkerSizes(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+	env2, cleanup2 := withTestEnvironment(t)
+	defer cleanup2()
+
+	repository.TestUseLowSecurityKDFParameters(t)
+	restic.TestSetLockTimeout(t, 0)
+
+	for _, opts := range []InitOptions{
+		{ChunkerAvgSize: "3M"},
+		{ChunkerAvgSize: "2M", ChunkerMaxSize: "1M"},
+		{ChunkerMinSize: "1k"},
+		{ChunkerMaxSize: "1G"},
+	} {
+		rtest.Assert(t, runInit(opts, env.gopts, nil) != nil, "expected invalid chunk sizes %+v to fail", opts)
+	}
+
+	rtest.OK(t, runInit(InitOptions{ChunkerAvgSize: "2M"}, env.gopts, nil))
+
+	repo, err := OpenRepository(env.gopts)
+	rtest.OK(t, err)
+	params := repo.Config().ChunkerParams()
+	rtest.Equals(t, restic.ChunkerParams{
+		Polynomial:  params.Polynomial,
+		MinSize:     1024 * 1024,
+		MaxSize:     16 * 1024 * 1024,
+		AverageBits: 21,
+	}, params)
+
+	rtest.SetupTarTestFixture(t, env.testdata, filepath.Join("testdata", "backup-data.tar.gz"))
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	testRunCheck(t, env.gopts)
+
+	// the chunk sizes are copied along with the polynomial
+	initOpts := InitOptions{
+		secondaryRepoOptions: secondaryRepoOptions{
+			Repo:     env.gopts.Repo,
+			password: env.gopts.password,
+		},
+		CopyChunkerParameters: true,
+	}
+	rtest.OK(t, runInit(initOpts, env2.gopts, nil))
+
+	otherRepo, err := OpenRepository(env2.gopts)
+	rtest.OK(t, err)
+	rtest.Equals(t, params, otherRepo.Config().ChunkerParams())
+}
+
 func testRunTag(t testing.TB, opts TagOptions, gopts GlobalOptions) {
 	rtest.OK(t, runTag(opts, gopts, []string{}))
 }
@@ -1130,6 +1182,334 @@ func TestKeyProblems(t *testing.T) {
 	testRunCheck(t, env.gopts)
 }
 
+func testKeyRotateMasterSetup(t *testing.T, env *testEnvironment) (restic.IDSet, restic.IDs) {
+	testSetupBackupData(t, env)
+	opts := BackupOptions{}
+
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	return listPacks(env.gopts, t), testRunList(t, "snapshots", env.gopts)
+}
+
+func testKeyRotateMasterCheck(t *testing.T, env *testEnvironment, oldPacks restic.IDSet, oldSnapshots restic.IDs) {
+	testRunCheck(t, env.gopts)
+
+	for id := range listPacks(env.gopts, t) {
+		rtest.Assert(t, !oldPacks.Has(id), "pack %v was not re-encrypted", id.Str())
+	}
+
+	snapshotIDs := testRunList(t, "snapshots", env.gopts)
+	rtest.Equals(t, len(oldSnapshots), len(snapshotIDs))
+	old := restic.NewIDSet(oldSnapshots...)
+	for _, id := range snapshotIDs {
+		rtest.Assert(t, !old.Has(id), "snapshot %v was not re-encrypted", id.Str())
+	}
+
+	restoredir := filepath.Join(env.base, "restore")
+	testRunRestoreLatest(t, env.gopts, restoredir, nil, nil)
+	diff := directoriesContentsDiff(env.testdata, filepath.Join(restoredir, "testdata"))
+	rtest.Assert(t, diff == "", "directories are not equal %v", diff)
+}
+
+func TestKeyRotateMaster(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	// the rotation lists files more than once
+	env.gopts.backendTestHook = nil
+	defer cleanup()
+
+	oldPacks, oldSnapshots := testKeyRotateMasterSetup(t, env)
+	testRunKeyAddNewKey(t, "other password", env.gopts)
+	rtest.Equals(t, 1, len(testRunKeyListOtherIDs(t, env.gopts)))
+
+	// the key with the other password is only removed on request
+	err := runKey(env.gopts, []string{"rotate-master"})
+	rtest.Assert(t, err != nil, "rotation started although a key cannot be re-wrapped")
+	rtest.Equals(t, 1, len(testRunKeyListOtherIDs(t, env.gopts)))
+	rtest.Equals(t, oldPacks, listPacks(env.gopts, t))
+
+	repo, err := OpenRepository(env.gopts)
+	rtest.OK(t, err)
+	oldRecipient := *repo.Config().Recipient
+
+	keyRemoveOthers = true
+	defer func() {
+		keyRemoveOthers = false
+	}()
+
+	rtest.OK(t, runKey(env.gopts, []string{"rotate-master"}))
+	testKeyRotateMasterCheck(t, env, oldPacks, oldSnapshots)
+
+	// the rotation also replaces the private key and the recipient key
+	repo, err = OpenRepository(env.gopts)
+	rtest.OK(t, err)
+	rtest.Assert(t, *repo.Config().Recipient != oldRecipient, "recipient key was not rotated")
+	rtest.Equals(t, listPacks(env.gopts, t), listSealedPacks(env.gopts, t))
+
+	// the key with the other password cannot be re-wrapped
+	rtest.Equals(t, 0, len(testRunKeyListOtherIDs(t, env.gopts)))
+	env.gopts.password = "other password"
+	_, err = OpenRepository(env.gopts)
+	rtest.Assert(t, err != nil, "repository opened with removed key")
+}
+
+func TestKeyRotateMasterDamagedPack(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	env.gopts.backendTestHook = nil
+	defer cleanup()
+
+	oldPacks, oldSnapshots := testKeyRotateMasterSetup(t, env)
+
+	// re-encrypt the packs, then damage one of the new packs
+	repo, err := OpenRepository(env.gopts)
+	rtest.OK(t, err)
+	pending, err := repository.AddPendingKey(env.gopts.ctx, repo, env.gopts.password, "", "")
+	rtest.OK(t, err)
+	newRepo, err := loadRotationIndexes(env.gopts.ctx, repo, pending)
+	rtest.OK(t, err)
+
+	keepBlobs := restic.NewBlobSet()
+	for blob := range repo.Index().Each(env.gopts.ctx) {
+		keepBlobs.Insert(blob.BlobHandle)
+	}
+	_, err = repository.Repack(env.gopts.ctx, repo, newRepo, oldPacks, keepBlobs, nil)
+	rtest.OK(t, err)
+
+	var damaged restic.ID
+	for id := range listPacks(env.gopts, t) {
+		if !oldPacks.Has(id) {
+			damaged = id
+			break
+		}
+	}
+	h := restic.Handle{Type: restic.PackFile, Name: damaged.String()}
+	buf, err := backend.LoadAll(env.gopts.ctx, nil, repo.Backend(), h)
+	rtest.OK(t, err)
+	buf[len(buf)/2] ^= 0xff
+	rtest.OK(t, repo.Backend().Remove(env.gopts.ctx, h))
+	rtest.OK(t, repo.Backend().Save(env.gopts.ctx, h, restic.NewByteReader(buf, repo.Backend().Hasher())))
+
+	// the damage is detected before any old file is removed
+	err = runKey(env.gopts, []string{"rotate-master"})
+	rtest.Assert(t, err != nil, "damaged re-encrypted pack was not detected")
+
+	packs := listPacks(env.gopts, t)
+	for id := range oldPacks {
+		rtest.Assert(t, packs.Has(id), "old pack %v was removed", id.Str())
+	}
+	snapshots := restic.NewIDSet(testRunList(t, "snapshots", env.gopts)...)
+	for _, id := range oldSnapshots {
+		rtest.Assert(t, snapshots.Has(id), "old snapshot %v was removed", id.Str())
+	}
+
+	// the repository is still usable with the old master key
+	_, err = OpenRepository(env.gopts)
+	rtest.OK(t, err)
+}
+
+func TestKeyRotateMasterResume(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		interrupt func(gopts GlobalOptions, repo, newRepo *repository.Repository, rotation *repository.Rotation) error
+	}{
+		{
+			"snapshots",
+			func(gopts GlobalOptions, repo, newRepo *repository.Repository, rotation *repository.Rotation) error {
+				_, err := reencryptSnapshots(gopts.ctx, repo, newRepo, rotation)
+				return err
+			},
+		},
+		{
+			"config",
+			func(gopts GlobalOptions, repo, newRepo *repository.Repository, rotation *repository.Rotation) error {
+				err := reencryptRepository(gopts.ctx, gopts, repo, newRepo, rotation)
+				if err != nil {
+					return err
+				}
+				return switchConfig(gopts.ctx, repo, newRepo)
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			env, cleanup := withTestEnvironment(t)
+			env.gopts.backendTestHook = nil
+			defer cleanup()
+
+			oldPacks, oldSnapshots := testKeyRotateMasterSetup(t, env)
+
+			// start a rotation and stop it after the given step
+			repo, err := OpenRepository(env.gopts)
+			rtest.OK(t, err)
+			pending, err := repository.AddPendingKey(env.gopts.ctx, repo, env.gopts.password, "", "")
+			rtest.OK(t, err)
+			newRepo, err := loadRotationIndexes(env.gopts.ctx, repo, pending)
+			rtest.OK(t, err)
+			rtest.OK(t, test.interrupt(env.gopts, repo, newRepo, pending.Rotation))
+
+			rtest.OK(t, runKey(env.gopts, []string{"rotate-master"}))
+			testKeyRotateMasterCheck(t, env, oldPacks, oldSnapshots)
+			rtest.Equals(t, 0, len(testRunKeyListOtherIDs(t, env.gopts)))
+		})
+	}
+}
+
+func TestWriteOnlyKey(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	// adding a key lists the keys more than once
+	env.gopts.backendTestHook = nil
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+
+	keyWriteOnly = true
+	defer func() {
+		keyWriteOnly = false
+	}()
+	testRunKeyAddNewKey(t, "write-only", env.gopts)
+
+	woGopts := env.gopts
+	woGopts.password = "write-only"
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, woGopts)
+	rtest.Equals(t, 2, len(testRunList(t, "snapshots", env.gopts)))
+
+	// reading the repository fails with the write-only key
+	err := runRestore(RestoreOptions{Target: filepath.Join(env.base, "restore")}, woGopts, []string{"latest"})
+	rtest.Assert(t, errors.Is(err, repository.ErrWriteOnly), "wrong error for restore: %v", err)
+
+	err = runCheck(CheckOptions{ReadData: true}, woGopts, nil)
+	rtest.Assert(t, errors.Is(err, repository.ErrWriteOnly), "wrong error for check: %v", err)
+
+	err = runPrune(PruneOptions{MaxUnused: "5%"}, woGopts)
+	rtest.Assert(t, errors.Is(err, repository.ErrWriteOnly), "wrong error for prune: %v", err)
+
+	// the full key can read the snapshot created with the write-only key
+	testRunCheck(t, env.gopts)
+	restoredir := filepath.Join(env.base, "restore")
+	testRunRestoreLatest(t, env.gopts, restoredir, nil, nil)
+	diff := directoriesContentsDiff(env.testdata, filepath.Join(restoredir, "testdata"))
+	rtest.Assert(t, diff == "", "directories are not equal %v", diff)
+}
+
+func TestKeyRotateMasterWriteOnlyKey(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	env.gopts.backendTestHook = nil
+	defer cleanup()
+
+	oldPacks, oldSnapshots := testKeyRotateMasterSetup(t, env)
+
+	// add a write-only key with the same password, the full key must be used
+	// for the rotation
+	repo, err := OpenRepository(env.gopts)
+	rtest.OK(t, err)
+	env.gopts.KeyHint = repo.KeyName()
+
+	keyWriteOnly = true
+	defer func() {
+		keyWriteOnly = false
+	}()
+	testRunKeyAddNewKey(t, env.gopts.password, env.gopts)
+	keyWriteOnly = false
+
+	rtest.OK(t, runKey(env.gopts, []string{"rotate-master"}))
+
+	// both keys are re-wrapped, the write-only key stays write-only
+	repo, err = OpenRepository(env.gopts)
+	rtest.OK(t, err)
+	var full, writeOnly restic.IDs
+	rtest.OK(t, repo.List(env.gopts.ctx, restic.KeyFile, func(id restic.ID, size int64) error {
+		k, err := repository.OpenKey(env.gopts.ctx, repo, id.String(), env.gopts.password)
+		rtest.OK(t, err)
+		if k.HasPrivateKey() {
+			full = append(full, id)
+		} else {
+			writeOnly = append(writeOnly, id)
+		}
+		return nil
+	}))
+	rtest.Equals(t, 1, len(full))
+	rtest.Equals(t, 1, len(writeOnly))
+
+	env.gopts.KeyHint = full[0].String()
+	repo, err = OpenRepository(env.gopts)
+	rtest.OK(t, err)
+	rtest.Assert(t, !repo.WriteOnly(), "full key %v is write-only", full[0].Str())
+
+	// the write-only key contains a copy of the new config
+	woGopts := env.gopts
+	woGopts.KeyHint = writeOnly[0].String()
+	woRepo, err := OpenRepository(woGopts)
+	rtest.OK(t, err)
+	rtest.Assert(t, woRepo.WriteOnly(), "write-only key %v is not write-only", writeOnly[0].Str())
+	rtest.Equals(t, *repo.Config().Recipient, *woRepo.Config().Recipient)
+	rtest.Equals(t, *repo.Config().WriteKey, *woRepo.Config().WriteKey)
+
+	testKeyRotateMasterCheck(t, env, oldPacks, oldSnapshots)
+}
+
+// listSealedPacks returns the pack files which start with a sealed data key.
+func listSealedPacks(gopts GlobalOptions, t *testing.T) restic.IDSet {
+	r, err := OpenRepository(gopts)
+	rtest.OK(t, err)
+
+	sealed := restic.NewIDSet()
+	for id := range listPacks(gopts, t) {
+		buf := make([]byte, crypto.SealedKeySize)
+		h := restic.Handle{Type: restic.PackFile, Name: id.String()}
+		_, err := restic.ReadAt(gopts.ctx, r.Backend(), h, 0, buf)
+		rtest.OK(t, err)
+		if crypto.IsSealedKey(buf) {
+			sealed.Insert(id)
+		}
+	}
+	return sealed
+}
+
+func TestKeyRotateMasterUpgradedRepo(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	env.gopts.backendTestHook = nil
+	defer cleanup()
+
+	repository.TestUseLowSecurityKDFParameters(t)
+	restic.TestDisableCheckPolynomial(t)
+	restic.TestSetLockTimeout(t, 0)
+	rtest.OK(t, runInit(InitOptions{RepositoryVersion: "2"}, env.gopts, nil))
+	rtest.SetupTarTestFixture(t, env.testdata, filepath.Join("testdata", "backup-data.tar.gz"))
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+	oldPacks, oldSnapshots := listPacks(env.gopts, t), testRunList(t, "snapshots", env.gopts)
+	testRunKeyAddNewKey(t, "other password", env.gopts)
+
+	// the migration is only applied on request, as the other key loses read
+	// access
+	rtest.OK(t, runMigrate(MigrateOptions{}, env.gopts, []string{"upgrade_repo_v3"}))
+	repo, err := OpenRepository(env.gopts)
+	rtest.OK(t, err)
+	rtest.Equals(t, uint(2), repo.Config().Version)
+
+	rtest.OK(t, runMigrate(MigrateOptions{Force: true}, env.gopts, []string{"upgrade_repo_v3"}))
+	repo, err = OpenRepository(env.gopts)
+	rtest.OK(t, err)
+	rtest.Equals(t, uint(restic.SealedRepoVersion), repo.Config().Version)
+
+	otherGopts := env.gopts
+	otherGopts.password = "other password"
+	repo, err = OpenRepository(otherGopts)
+	rtest.OK(t, err)
+	rtest.Assert(t, repo.WriteOnly(), "other key was not demoted")
+
+	// the data from before the migration is not sealed until the master key
+	// is rotated
+	rtest.Equals(t, 0, len(listSealedPacks(env.gopts, t)))
+
+	keyRemoveOthers = true
+	defer func() {
+		keyRemoveOthers = false
+	}()
+
+	rtest.OK(t, runKey(env.gopts, []string{"rotate-master"}))
+	testKeyRotateMasterCheck(t, env, oldPacks, oldSnapshots)
+	rtest.Equals(t, listPacks(env.gopts, t), listSealedPacks(env.gopts, t))
+}
+
 func testFileSize(filename string, size int64) error {
 	fi, err := os.Stat(filename)
 	if err != nil {
@@ -1632,6 +2012,83 @@ func testPrune(t *testing.T, pruneOpts PruneOptions, checkOpts CheckOptions) {
 	rtest.OK(t, runCheck(checkOpts, env.gopts, nil))
 }
 
+func TestPruneRepackSmall(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	opts := BackupOptions{}
+
+	// each backup creates at least one small data and tree pack
+	for i := 0; i < 12; i++ {
+		testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", strconv.Itoa(i))}, opts, env.gopts)
+	}
+	oldPacks := listPacks(env.gopts, t)
+
+	// packs are only consolidated with --repack-small
+	testRunPrune(t, env.gopts, pruneDefaultOptions)
+	rtest.Equals(t, oldPacks, listPacks(env.gopts, t))
+
+	env.gopts.PackSize = 16
+	testRunPrune(t, env.gopts, PruneOptions{MaxUnused: "5%", RepackSmall: true})
+	newPacks := listPacks(env.gopts, t)
+	rtest.Assert(t, len(newPacks) < len(oldPacks),
+		"expected fewer than %d packs, got %d", len(oldPacks), len(newPacks))
+
+	// the remaining packs are not repacked again
+	testRunPrune(t, env.gopts, PruneOptions{MaxUnused: "5%", RepackSmall: true})
+	rtest.Equals(t, newPacks, listPacks(env.gopts, t))
+
+	rtest.OK(t, runCheck(CheckOptions{ReadData: true}, env.gopts, nil))
+}
+
+// retainingBackend refuses to remove data, index and snapshot files like a
+// backend which uses object lock.
+type retainingBackend struct {
+	restic.Backend
+}
+
+func (b *retainingBackend) Remove(ctx context.Context, h restic.Handle) error {
+	switch h.Type {
+	case restic.PackFile, restic.IndexFile, restic.SnapshotFile:
+		return fmt.Errorf("remove %v: %w", h, restic.ErrRetained)
+	}
+	return b.Backend.Remove(ctx, h)
+}
+
+func TestForgetPruneRetention(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	opts := BackupOptions{}
+
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9")}, opts, env.gopts)
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "2")}, opts, env.gopts)
+	snapshotIDs := testRunList(t, "snapshots", env.gopts)
+	rtest.Assert(t, len(snapshotIDs) == 2, "expected 2 snapshots, got %v", snapshotIDs)
+
+	// remove one snapshot for real to create unused data
+	testRunForget(t, env.gopts, snapshotIDs[0].String())
+	packs := listPacks(env.gopts, t)
+
+	env.gopts.backendTestHook = func(r restic.Backend) (restic.Backend, error) {
+		return &retainingBackend{r}, nil
+	}
+
+	// retained snapshots are skipped
+	testRunForget(t, env.gopts, snapshotIDs[1].String())
+	rtest.Equals(t, 1, len(testRunList(t, "snapshots", env.gopts)))
+
+	// the packs referenced by retained index files are kept
+	rtest.OK(t, runPrune(PruneOptions{MaxUnused: "0%"}, env.gopts))
+	remaining := listPacks(env.gopts, t)
+	for id := range packs {
+		rtest.Assert(t, remaining.Has(id), "pack %v was removed", id)
+	}
+	rtest.OK(t, runCheck(CheckOptions{ReadData: true}, env.gopts, nil))
+}
+
 var pruneDefaultOptions = PruneOptions{MaxUnused: "5%"}
 
 func listPacks(gopts GlobalOptions, t *testing.T) restic.IDSet {
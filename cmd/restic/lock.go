@@ -60,7 +60,7 @@ func lockRepository(ctx context.Context, repo *repository.Repository, exclusive
 	return lock, err
 }
 
-var refreshInterval = 5 * time.Minute
+var refreshInterval = restic.LockRefreshInterval
 
 func refreshLocks(wg *sync.WaitGroup, done <-chan struct{}) {
 	debug.Log("start")
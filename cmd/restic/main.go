@@ -10,6 +10,7 @@ import (
 
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/options"
+	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 
 	"github.com/spf13/cobra"
@@ -51,6 +52,10 @@ directories in an encrypted repository stored on different backends.
 			return err
 		}
 		globalOptions.extended = opts
+		if err := parsePackSizeEnv(&globalOptions, c.Flags().Changed("pack-size")); err != nil {
+			return err
+		}
+		setupMetrics(&globalOptions)
 		if !needsPassword(c.Name()) {
 			return nil
 		}
@@ -76,7 +81,7 @@ directories in an encrypted repository stored on different backends.
 // user for authentication).
 func needsPassword(cmd string) bool {
 	switch cmd {
-	case "cache", "generate", "help", "options", "self-update", "version":
+	case "cache", "generate", "help", "options", "self-update", "serve", "version":
 		return false
 	default:
 		return true
@@ -102,7 +107,7 @@ func main() {
 		fmt.Fprintf(os.Stderr, "%v\nthe `unlock` command can be used to remove stale locks\n", err)
 	case err == ErrInvalidSourceData:
 		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
-	case errors.IsFatal(err):
+	case errors.IsFatal(err), errors.Is(err, repository.ErrWriteOnly):
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 	case err != nil:
 		fmt.Fprintf(os.Stderr, "%+v\n", err)
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/metrics"
+)
+
+// setupMetrics collects metrics if a metrics file is set and writes them when
+// the command finishes.
+func setupMetrics(gopts *GlobalOptions) {
+	if gopts.MetricsFile == "" {
+		return
+	}
+
+	reg := metrics.NewRegistry()
+	gopts.metrics = reg
+	filename := gopts.MetricsFile
+	AddCleanupHandler(func() error {
+		return writeMetricsFile(filename, reg)
+	})
+}
+
+// writeMetricsFile replaces the file atomically, so that a collector never
+// reads a partially written file.
+func writeMetricsFile(filename string, reg *metrics.Registry) error {
+	f, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".tmp")
+	if err != nil {
+		return errors.Fatalf("unable to write metrics: %v", err)
+	}
+
+	_, err = reg.WriteTo(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	// TempFile creates files which only the owner can read
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filename)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return errors.Fatalf("unable to write metrics: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/restic/restic/internal/metrics"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestMetricsFile(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	env.gopts.metrics = metrics.NewRegistry()
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+
+	filename := filepath.Join(env.base, "restic.prom")
+	rtest.OK(t, writeMetricsFile(filename, env.gopts.metrics))
+
+	buf, err := ioutil.ReadFile(filename)
+	rtest.OK(t, err)
+	for _, prefix := range []string{
+		`restic_backend_requests_total{op="save",type="data",result="ok"} `,
+		`restic_backend_written_bytes_total{type="snapshot"} `,
+		`restic_backup_files{state="new"} `,
+		`restic_backup_duration_seconds `,
+	} {
+		rtest.Assert(t, strings.Contains(string(buf), "\n"+prefix), "metric %q not found in\n%s", prefix, buf)
+	}
+}
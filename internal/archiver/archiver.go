@@ -767,7 +767,7 @@ func (arch *Archiver) runWorkers(ctx context.Context, wg *errgroup.Group) {
 
 	arch.fileSaver = NewFileSaver(ctx, wg,
 		arch.blobSaver.Save,
-		arch.Repo.Config().ChunkerPolynomial,
+		arch.Repo.Config().ChunkerParams(),
 		arch.Options.FileReadConcurrency, arch.Options.SaveBlobConcurrency)
 	arch.fileSaver.CompleteBlob = arch.CompleteBlob
 	arch.fileSaver.NodeFromFileInfo = arch.nodeFromFileInfo
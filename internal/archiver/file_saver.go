@@ -56,7 +56,7 @@ type FileSaver struct {
 	saveFilePool *BufferPool
 	saveBlob     SaveBlobFn
 
-	pol chunker.Pol
+	chunkerParams restic.ChunkerParams
 
 	ch chan<- saveFileJob
 
@@ -67,7 +67,7 @@ type FileSaver struct {
 
 // NewFileSaver returns a new file saver. A worker pool with fileWorkers is
 // started, it is stopped when ctx is cancelled.
-func NewFileSaver(ctx context.Context, wg *errgroup.Group, save SaveBlobFn, pol chunker.Pol, fileWorkers, blobWorkers uint) *FileSaver {
+func NewFileSaver(ctx context.Context, wg *errgroup.Group, save SaveBlobFn, chunkerParams restic.ChunkerParams, fileWorkers, blobWorkers uint) *FileSaver {
 	ch := make(chan saveFileJob)
 
 	debug.Log("new file saver with %v file workers and %v blob workers", fileWorkers, blobWorkers)
@@ -75,10 +75,10 @@ func NewFileSaver(ctx context.Context, wg *errgroup.Group, save SaveBlobFn, pol
 	poolSize := fileWorkers + blobWorkers
 
 	s := &FileSaver{
-		saveBlob:     save,
-		saveFilePool: NewBufferPool(int(poolSize), chunker.MaxSize),
-		pol:          pol,
-		ch:           ch,
+		saveBlob:      save,
+		saveFilePool:  NewBufferPool(int(poolSize), int(chunkerParams.MaxChunkSize())),
+		chunkerParams: chunkerParams,
+		ch:            ch,
 
 		CompleteBlob: func(string, uint64) {},
 	}
@@ -159,7 +159,7 @@ func (s *FileSaver) saveFile(ctx context.Context, chnker *chunker.Chunker, snPat
 	}
 
 	// reuse the chunker
-	chnker.Reset(f, s.pol)
+	s.chunkerParams.ResetChunker(chnker, f)
 
 	var results []FutureBlob
 
@@ -225,7 +225,7 @@ func (s *FileSaver) saveFile(ctx context.Context, chnker *chunker.Chunker, snPat
 
 func (s *FileSaver) worker(ctx context.Context, jobs <-chan saveFileJob) {
 	// a worker has one chunker which is reused for each file (because it contains a rather large buffer)
-	chnker := chunker.New(nil, s.pol)
+	chnker := s.chunkerParams.NewChunker(nil)
 
 	for {
 		var job saveFileJob
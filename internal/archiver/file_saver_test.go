@@ -45,7 +45,7 @@ func startFileSaver(ctx context.Context, t testing.TB) (*FileSaver, context.Cont
 		t.Fatal(err)
 	}
 
-	s := NewFileSaver(ctx, wg, saveBlob, pol, workers, workers)
+	s := NewFileSaver(ctx, wg, saveBlob, restic.ChunkerParams{Polynomial: pol}, workers, workers)
 	s.NodeFromFileInfo = restic.NodeFromFileInfo
 
 	return s, ctx, wg
@@ -30,6 +30,9 @@ type Backend struct {
 	prefix       string
 	listMaxItems int
 	backend.Layout
+
+	accessTier     storage.BlobTier
+	dataAccessTier storage.BlobTier
 }
 
 const defaultListMaxItems = 5000
@@ -40,6 +43,16 @@ var _ restic.Backend = &Backend{}
 func open(cfg Config, rt http.RoundTripper) (*Backend, error) {
 	debug.Log("open, config %#v", cfg)
 
+	accessTier, err := parseAccessTier(cfg.AccessTier)
+	if err != nil {
+		return nil, err
+	}
+
+	dataAccessTier, err := parseAccessTier(cfg.DataAccessTier)
+	if err != nil {
+		return nil, err
+	}
+
 	client, err := storage.NewBasicClient(cfg.AccountName, cfg.AccountKey)
 	if err != nil {
 		return nil, errors.Wrap(err, "NewBasicClient")
@@ -64,12 +77,38 @@ func open(cfg Config, rt http.RoundTripper) (*Backend, error) {
 			Path: cfg.Prefix,
 			Join: path.Join,
 		},
-		listMaxItems: defaultListMaxItems,
+		listMaxItems:   defaultListMaxItems,
+		accessTier:     accessTier,
+		dataAccessTier: dataAccessTier,
 	}
 
 	return be, nil
 }
 
+// parseAccessTier returns the blob tier for the name s, an empty string
+// selects the default tier of the storage account. The archive tier is
+// rejected as blobs in it cannot be read directly.
+func parseAccessTier(s string) (storage.BlobTier, error) {
+	for _, tier := range []storage.BlobTier{storage.BlobTierHot, storage.BlobTierCool} {
+		if strings.EqualFold(s, string(tier)) {
+			return tier, nil
+		}
+	}
+
+	if s != "" {
+		return "", errors.Errorf("invalid access tier %q", s)
+	}
+	return "", nil
+}
+
+// accessTierFor returns the access tier for the file at h.
+func (be *Backend) accessTierFor(h restic.Handle) storage.BlobTier {
+	if h.IsDataPack() && be.dataAccessTier != "" {
+		return be.dataAccessTier
+	}
+	return be.accessTier
+}
+
 // Open opens the Azure backend at specified container.
 func Open(cfg Config, rt http.RoundTripper) (*Backend, error) {
 	return open(cfg, rt)
@@ -176,10 +215,22 @@ func (be *Backend) Save(ctx context.Context, h restic.Handle, rd restic.RewindRe
 
 	}
 
+	if err != nil {
+		be.sem.ReleaseToken()
+		debug.Log("%v, err %#v", objName, err)
+		return errors.Wrap(err, "CreateBlockBlobFromReader")
+	}
+
+	// new blobs always get the default tier of the account, move them to
+	// the configured one
+	if tier := be.accessTierFor(h); tier != "" {
+		err = be.container.GetBlobReference(objName).SetTier(tier, nil)
+	}
+
 	be.sem.ReleaseToken()
 	debug.Log("%v, err %#v", objName, err)
 
-	return errors.Wrap(err, "CreateBlockBlobFromReader")
+	return errors.Wrap(err, "SetTier")
 }
 
 func (be *Backend) saveLarge(ctx context.Context, objName string, rd restic.RewindReader) error {
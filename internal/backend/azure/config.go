@@ -17,6 +17,9 @@ type Config struct {
 	Prefix      string
 
 	Connections uint `option:"connections" help:"set a limit for the number of concurrent connections (default: 5)"`
+
+	AccessTier     string `option:"access-tier" help:"set access tier for new files (Hot or Cool, default: account default)"`
+	DataAccessTier string `option:"data-access-tier" help:"set access tier for packs containing file data (default: access-tier)"`
 }
 
 // NewConfig returns a new Config with the default values filled in.
@@ -1,6 +1,10 @@
 package azure
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/storage"
+)
 
 var configTests = []struct {
 	s   string
@@ -38,3 +42,31 @@ func TestParseConfig(t *testing.T) {
 		}
 	}
 }
+
+func TestParseAccessTier(t *testing.T) {
+	for _, test := range []struct {
+		s     string
+		tier  storage.BlobTier
+		valid bool
+	}{
+		{"", "", true},
+		{"Hot", storage.BlobTierHot, true},
+		{"cool", storage.BlobTierCool, true},
+		{"COOL", storage.BlobTierCool, true},
+		{"Archive", "", false},
+		{"glacier", "", false},
+	} {
+		tier, err := parseAccessTier(test.s)
+		if test.valid && err != nil {
+			t.Errorf("%q: unexpected error %v", test.s, err)
+			continue
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%q: no error", test.s)
+			continue
+		}
+		if tier != test.tier {
+			t.Errorf("%q: wrong tier, want %q, got %q", test.s, test.tier, tier)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/cenkalti/backoff/v4"
 	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/restic"
 )
 
@@ -17,10 +18,17 @@ type RetryBackend struct {
 	restic.Backend
 	MaxTries int
 	Report   func(string, error, time.Duration)
+
+	// Retried is called with the operation (one of the restic.Op constants)
+	// and the file type before a request is retried, if set.
+	Retried func(op string, t restic.FileType)
 }
 
-// statically ensure that RetryBackend implements restic.Backend.
-var _ restic.Backend = &RetryBackend{}
+// statically ensure that RetryBackend implements the interfaces.
+var (
+	_ restic.Backend         = &RetryBackend{}
+	_ restic.ChecksumBackend = &RetryBackend{}
+)
 
 // NewRetryBackend wraps be with a backend that retries operations after a
 // backoff. report is called with a description and the error, if one occurred.
@@ -32,7 +40,12 @@ func NewRetryBackend(be restic.Backend, maxTries int, report func(string, error,
 	}
 }
 
-func (be *RetryBackend) retry(ctx context.Context, msg string, f func() error) error {
+// Unwrap returns the wrapped backend.
+func (be *RetryBackend) Unwrap() restic.Backend {
+	return be.Backend
+}
+
+func (be *RetryBackend) retry(ctx context.Context, op string, t restic.FileType, msg string, f func() error) error {
 	// Don't do anything when called with an already cancelled context. There would be
 	// no retries in that case either, so be consistent and abort always.
 	// This enforces a strict contract for backend methods: Using a cancelled context
@@ -49,6 +62,9 @@ func (be *RetryBackend) retry(ctx context.Context, msg string, f func() error) e
 			if be.Report != nil {
 				be.Report(msg, err, d)
 			}
+			if be.Retried != nil {
+				be.Retried(op, t)
+			}
 		},
 	)
 
@@ -57,7 +73,7 @@ func (be *RetryBackend) retry(ctx context.Context, msg string, f func() error) e
 
 // Save stores the data in the backend under the given handle.
 func (be *RetryBackend) Save(ctx context.Context, h restic.Handle, rd restic.RewindReader) error {
-	return be.retry(ctx, fmt.Sprintf("Save(%v)", h), func() error {
+	return be.retry(ctx, restic.OpSave, h.Type, fmt.Sprintf("Save(%v)", h), func() error {
 		err := rd.Rewind()
 		if err != nil {
 			return err
@@ -84,7 +100,7 @@ func (be *RetryBackend) Save(ctx context.Context, h restic.Handle, rd restic.Rew
 // is returned. rd must be closed after use. If an error is returned, the
 // ReadCloser must be nil.
 func (be *RetryBackend) Load(ctx context.Context, h restic.Handle, length int, offset int64, consumer func(rd io.Reader) error) (err error) {
-	return be.retry(ctx, fmt.Sprintf("Load(%v, %v, %v)", h, length, offset),
+	return be.retry(ctx, restic.OpLoad, h.Type, fmt.Sprintf("Load(%v, %v, %v)", h, length, offset),
 		func() error {
 			return be.Backend.Load(ctx, h, length, offset, consumer)
 		})
@@ -92,7 +108,7 @@ func (be *RetryBackend) Load(ctx context.Context, h restic.Handle, length int, o
 
 // Stat returns information about the File identified by h.
 func (be *RetryBackend) Stat(ctx context.Context, h restic.Handle) (fi restic.FileInfo, err error) {
-	err = be.retry(ctx, fmt.Sprintf("Stat(%v)", h),
+	err = be.retry(ctx, restic.OpStat, h.Type, fmt.Sprintf("Stat(%v)", h),
 		func() error {
 			var innerError error
 			fi, innerError = be.Backend.Stat(ctx, h)
@@ -104,14 +120,14 @@ func (be *RetryBackend) Stat(ctx context.Context, h restic.Handle) (fi restic.Fi
 
 // Remove removes a File with type t and name.
 func (be *RetryBackend) Remove(ctx context.Context, h restic.Handle) (err error) {
-	return be.retry(ctx, fmt.Sprintf("Remove(%v)", h), func() error {
+	return be.retry(ctx, restic.OpRemove, h.Type, fmt.Sprintf("Remove(%v)", h), func() error {
 		return be.Backend.Remove(ctx, h)
 	})
 }
 
 // Test a boolean value whether a File with the name and type exists.
 func (be *RetryBackend) Test(ctx context.Context, h restic.Handle) (exists bool, err error) {
-	err = be.retry(ctx, fmt.Sprintf("Test(%v)", h), func() error {
+	err = be.retry(ctx, restic.OpTest, h.Type, fmt.Sprintf("Test(%v)", h), func() error {
 		var innerError error
 		exists, innerError = be.Backend.Test(ctx, h)
 
@@ -120,6 +136,23 @@ func (be *RetryBackend) Test(ctx context.Context, h restic.Handle) (exists bool,
 	return exists, err
 }
 
+// SHA256 returns the SHA-256 hash the wrapped backend recorded for the file
+// at h. It fails if the wrapped backend does not record checksums.
+func (be *RetryBackend) SHA256(ctx context.Context, h restic.Handle) (id restic.ID, err error) {
+	cb := restic.AsChecksumBackend(be.Backend)
+	if cb == nil {
+		return restic.ID{}, errors.New("the backend does not record checksums")
+	}
+
+	err = be.retry(ctx, restic.OpSHA256, h.Type, fmt.Sprintf("SHA256(%v)", h), func() error {
+		var innerError error
+		id, innerError = cb.SHA256(ctx, h)
+
+		return innerError
+	})
+	return id, err
+}
+
 // List runs fn for each file in the backend which has the type t. When an
 // error is returned by the underlying backend, the request is retried. When fn
 // returns an error, the operation is aborted and the error is returned to the
@@ -133,7 +166,7 @@ func (be *RetryBackend) List(ctx context.Context, t restic.FileType, fn func(res
 	listed := make(map[string]struct{}) // remember for which files we already ran fn
 	var innerErr error                  // remember when fn returned an error, so we can return that to the caller
 
-	err := be.retry(listCtx, fmt.Sprintf("List(%v)", t), func() error {
+	err := be.retry(listCtx, restic.OpList, t, fmt.Sprintf("List(%v)", t), func() error {
 		return be.Backend.List(ctx, t, func(fi restic.FileInfo) error {
 			if _, ok := listed[fi.Name]; ok {
 				return nil
@@ -271,3 +271,68 @@ func TestBackendCanceledContext(t *testing.T) {
 
 	// don't test "Delete" as it is not used by normal code
 }
+
+func TestBackendRetried(t *testing.T) {
+	attempts := 0
+	be := mock.NewBackend()
+	be.StatFn = func(ctx context.Context, h restic.Handle) (restic.FileInfo, error) {
+		attempts++
+		if attempts < 3 {
+			return restic.FileInfo{}, errors.New("stat error")
+		}
+		return restic.FileInfo{Name: h.Name}, nil
+	}
+
+	var retried []string
+	retryBackend := NewRetryBackend(be, 10, nil)
+	retryBackend.Retried = func(op string, t restic.FileType) {
+		retried = append(retried, op+" "+string(t))
+	}
+
+	_, err := retryBackend.Stat(context.TODO(), restic.Handle{Type: restic.IndexFile, Name: "foo"})
+	test.OK(t, err)
+	test.Equals(t, []string{"stat index", "stat index"}, retried)
+}
+
+// checksumBackend records checksums using sha256Fn.
+type checksumBackend struct {
+	restic.Backend
+	sha256Fn func(ctx context.Context, h restic.Handle) (restic.ID, error)
+}
+
+func (be *checksumBackend) SHA256(ctx context.Context, h restic.Handle) (restic.ID, error) {
+	return be.sha256Fn(ctx, h)
+}
+
+func TestBackendSHA256Retry(t *testing.T) {
+	id := restic.NewRandomID()
+	attempts := 0
+	be := &checksumBackend{
+		Backend: mock.NewBackend(),
+		sha256Fn: func(ctx context.Context, h restic.Handle) (restic.ID, error) {
+			attempts++
+			if attempts < 3 {
+				return restic.ID{}, errors.New("sha256 error")
+			}
+			return id, nil
+		},
+	}
+
+	var retried []string
+	retryBackend := NewRetryBackend(be, 10, nil)
+	retryBackend.Retried = func(op string, t restic.FileType) {
+		retried = append(retried, op+" "+string(t))
+	}
+
+	cb := restic.AsChecksumBackend(retryBackend)
+	test.Assert(t, cb == retryBackend, "checksum requests are not retried")
+
+	hash, err := cb.SHA256(context.TODO(), restic.Handle{Type: restic.PackFile, Name: id.String()})
+	test.OK(t, err)
+	test.Equals(t, id, hash)
+	test.Equals(t, []string{"sha256 data", "sha256 data"}, retried)
+
+	// the retry backend does not claim checksums the wrapped backend lacks
+	test.Assert(t, restic.AsChecksumBackend(NewRetryBackend(mock.NewBackend(), 10, nil)) == nil,
+		"checksums found for a backend without them")
+}
@@ -0,0 +1,218 @@
+package faulty
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/options"
+	"github.com/restic/restic/internal/restic"
+)
+
+// Config contains the location of the wrapped backend and the faults which
+// are injected. The faults are lists of selector=value pairs separated by
+// commas, see ParseRates and ParseDelays.
+type Config struct {
+	Inner string
+
+	Seed       int    `option:"seed"        help:"seed which selects the requests that fail (default: 0)"`
+	Errors     string `option:"errors"      help:"rates of requests which return an error, e.g. save/data=0.1,*=0.01"`
+	Latency    string `option:"latency"     help:"delays added to requests, e.g. load=200ms"`
+	Truncate   string `option:"truncate"    help:"rates of reads which end early without an error, e.g. load/index=0.5"`
+	ShortWrite string `option:"short-write" help:"rates of writes which are interrupted, e.g. save=0.1"`
+	Hang       string `option:"hang"        help:"rates of requests which hang until they are canceled, e.g. list/lock=0.01"`
+}
+
+func init() {
+	options.Register("faulty", Config{})
+}
+
+// ParseConfig parses the string s and extracts the location of the wrapped
+// backend. The format is faulty:location, e.g. faulty:s3:s3.amazonaws.com/bucket.
+func ParseConfig(s string) (interface{}, error) {
+	if !strings.HasPrefix(s, "faulty:") {
+		return nil, errors.New("invalid faulty backend specification")
+	}
+
+	inner := s[len("faulty:"):]
+	if inner == "" {
+		return nil, errors.New("the location of the wrapped backend is empty")
+	}
+	if strings.HasPrefix(inner, "faulty:") {
+		return nil, errors.New("faulty backends cannot be nested")
+	}
+
+	return Config{Inner: inner}, nil
+}
+
+// StripPassword removes the sensitive information from the location of the
+// wrapped backend using strip.
+func StripPassword(s string, strip func(string) string) string {
+	cfg, err := ParseConfig(s)
+	if err != nil {
+		return s
+	}
+	return "faulty:" + strip(cfg.(Config).Inner)
+}
+
+// ops are the operations which can be selected in a fault specification.
+var ops = []string{restic.OpSave, restic.OpLoad, restic.OpStat, restic.OpTest, restic.OpList, restic.OpRemove}
+
+var fileTypes = []restic.FileType{
+	restic.PackFile,
+	restic.KeyFile,
+	restic.LockFile,
+	restic.SnapshotFile,
+	restic.IndexFile,
+	restic.ConfigFile,
+}
+
+// selector matches the requests of an operation for a file type, empty
+// fields match everything.
+type selector struct {
+	op string
+	t  restic.FileType
+}
+
+// candidates returns the selectors matching a request, the most specific
+// first.
+func candidates(op string, t restic.FileType) []selector {
+	return []selector{{op, t}, {op, ""}, {"", t}, {"", ""}}
+}
+
+// parseSelector parses op, op/type, */type or *.
+func parseSelector(s string) (selector, error) {
+	var sel selector
+
+	op := s
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		op = s[:i]
+		t := restic.FileType(s[i+1:])
+		if !isFileType(t) {
+			return selector{}, errors.Errorf("unknown file type %q in %q", t, s)
+		}
+		sel.t = t
+	}
+
+	if op != "*" {
+		if !isOp(op) {
+			return selector{}, errors.Errorf("unknown operation %q in %q, valid are %v", op, s, strings.Join(ops, ", "))
+		}
+		sel.op = op
+	}
+
+	return sel, nil
+}
+
+func isOp(op string) bool {
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+func isFileType(t restic.FileType) bool {
+	for _, ft := range fileTypes {
+		if ft == t {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSpec calls fn for each selector=value pair in s.
+func parseSpec(s string, fn func(sel selector, value string) error) error {
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		data := strings.SplitN(item, "=", 2)
+		if len(data) != 2 {
+			return errors.Errorf("invalid fault %q, expected selector=value", item)
+		}
+
+		sel, err := parseSelector(strings.TrimSpace(data[0]))
+		if err != nil {
+			return err
+		}
+		if err := fn(sel, strings.TrimSpace(data[1])); err != nil {
+			return errors.Errorf("invalid fault %q: %v", item, err)
+		}
+	}
+	return nil
+}
+
+// Rates are the probabilities that a fault happens in a request.
+type Rates map[selector]float64
+
+// ParseRates parses a list like save/data=0.1,load=0.05,*=0.01. A selector
+// is an operation (save, load, stat, test, list or remove) optionally
+// followed by a slash and a file type, "*" matches all operations. The most
+// specific selector matching a request is used.
+func ParseRates(s string) (Rates, error) {
+	rates := make(Rates)
+	err := parseSpec(s, func(sel selector, value string) error {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		if rate < 0 || rate > 1 {
+			return errors.New("rate must be between 0 and 1")
+		}
+		rates[sel] = rate
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// Get returns the rate for the operation op on a file of type t.
+func (r Rates) Get(op string, t restic.FileType) float64 {
+	for _, sel := range candidates(op, t) {
+		if rate, ok := r[sel]; ok {
+			return rate
+		}
+	}
+	return 0
+}
+
+// Delays are the latencies added to requests.
+type Delays map[selector]time.Duration
+
+// ParseDelays parses a list like load/data=200ms,*=10ms, the selectors are
+// the same as for ParseRates.
+func ParseDelays(s string) (Delays, error) {
+	delays := make(Delays)
+	err := parseSpec(s, func(sel selector, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		if d < 0 {
+			return errors.New("delay must not be negative")
+		}
+		delays[sel] = d
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return delays, nil
+}
+
+// Get returns the delay for the operation op on a file of type t.
+func (d Delays) Get(op string, t restic.FileType) time.Duration {
+	for _, sel := range candidates(op, t) {
+		if delay, ok := d[sel]; ok {
+			return delay
+		}
+	}
+	return 0
+}
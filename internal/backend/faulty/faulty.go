@@ -0,0 +1,280 @@
+// Package faulty implements a backend wrapper which injects errors, delays,
+// truncated reads, interrupted writes and hanging requests into the requests
+// to another backend. It is used to reproduce problems with unreliable
+// storage and to test how restic handles them.
+package faulty
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// ErrInjected is wrapped by the errors returned for injected failures.
+var ErrInjected = errors.New("injected failure")
+
+// Backend passes all requests to the wrapped backend, some of them fail.
+//
+// Whether a request fails only depends on the seed, the operation, the file
+// and how often the operation was already run for the file, not on the order
+// of concurrent requests. Repeating the same requests with the same seed thus
+// yields the same failures, while a retried request may succeed.
+type Backend struct {
+	b    restic.Backend
+	seed int
+
+	errors     Rates
+	latency    Delays
+	truncate   Rates
+	shortWrite Rates
+	hang       Rates
+
+	m     sync.Mutex
+	calls map[string]uint64
+}
+
+// statically ensure that Backend implements the interfaces.
+var (
+	_ restic.Backend          = &Backend{}
+	_ restic.BackendUnwrapper = &Backend{}
+)
+
+// New wraps be and injects the faults configured in cfg.
+func New(be restic.Backend, cfg Config) (*Backend, error) {
+	b := &Backend{
+		b:     be,
+		seed:  cfg.Seed,
+		calls: make(map[string]uint64),
+	}
+
+	var err error
+	for _, r := range []struct {
+		name string
+		spec string
+		dst  *Rates
+	}{
+		{"errors", cfg.Errors, &b.errors},
+		{"truncate", cfg.Truncate, &b.truncate},
+		{"short-write", cfg.ShortWrite, &b.shortWrite},
+		{"hang", cfg.Hang, &b.hang},
+	} {
+		*r.dst, err = ParseRates(r.spec)
+		if err != nil {
+			return nil, errors.Fatalf("invalid faulty.%v option: %v", r.name, err)
+		}
+	}
+
+	b.latency, err = ParseDelays(cfg.Latency)
+	if err != nil {
+		return nil, errors.Fatalf("invalid faulty.latency option: %v", err)
+	}
+
+	debug.Log("created new faulty backend with %#v", cfg)
+	return b, nil
+}
+
+// Unwrap returns the wrapped backend.
+func (be *Backend) Unwrap() restic.Backend {
+	return be.b
+}
+
+// next returns how often op was run for h before.
+func (be *Backend) next(op string, h restic.Handle) uint64 {
+	key := op + " " + string(h.Type) + "/" + h.Name
+
+	be.m.Lock()
+	defer be.m.Unlock()
+
+	n := be.calls[key]
+	be.calls[key] = n + 1
+	return n
+}
+
+// roll returns a number in [0, 1) for the n-th run of op for h, which is
+// always the same for the same seed. The kind distinguishes the decisions
+// made for a request.
+func (be *Backend) roll(kind, op string, h restic.Handle, n uint64) float64 {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d %v %v %v/%v %d", be.seed, kind, op, h.Type, h.Name, n)))
+	return float64(binary.BigEndian.Uint64(sum[:8])>>11) / (1 << 53)
+}
+
+// inject runs the faults which happen before the request is passed to the
+// wrapped backend and returns the number of the request for further
+// decisions.
+func (be *Backend) inject(ctx context.Context, op string, h restic.Handle) (uint64, error) {
+	n := be.next(op, h)
+
+	if d := be.latency.Get(op, h.Type); d > 0 {
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return n, ctx.Err()
+		}
+	}
+
+	if be.roll("hang", op, h, n) < be.hang.Get(op, h.Type) {
+		debug.Log("%v %v hangs", op, h)
+		<-ctx.Done()
+		return n, ctx.Err()
+	}
+
+	if be.roll("error", op, h, n) < be.errors.Get(op, h.Type) {
+		debug.Log("%v %v fails", op, h)
+		return n, fmt.Errorf("%v %v: %w", op, h, ErrInjected)
+	}
+
+	return n, nil
+}
+
+// Location returns the location of the wrapped backend.
+func (be *Backend) Location() string {
+	return "faulty:" + be.b.Location()
+}
+
+// Connections returns the number of connections of the wrapped backend.
+func (be *Backend) Connections() uint {
+	return be.b.Connections()
+}
+
+// Hasher returns the hash function of the wrapped backend.
+func (be *Backend) Hasher() hash.Hash {
+	return be.b.Hasher()
+}
+
+// HasAtomicReplace returns whether the wrapped backend replaces files
+// atomically.
+func (be *Backend) HasAtomicReplace() bool {
+	return be.b.HasAtomicReplace()
+}
+
+// IsNotExist returns true if the error was caused by a missing file in the
+// wrapped backend.
+func (be *Backend) IsNotExist(err error) bool {
+	return be.b.IsNotExist(err)
+}
+
+// shortReader fails after limit bytes were read since the last rewind.
+type shortReader struct {
+	restic.RewindReader
+	limit, read int64
+}
+
+func (rd *shortReader) Read(p []byte) (int, error) {
+	if rd.read >= rd.limit {
+		return 0, fmt.Errorf("write interrupted after %d bytes: %w", rd.read, ErrInjected)
+	}
+	if int64(len(p)) > rd.limit-rd.read {
+		p = p[:rd.limit-rd.read]
+	}
+	n, err := rd.RewindReader.Read(p)
+	rd.read += int64(n)
+	return n, err
+}
+
+func (rd *shortReader) Rewind() error {
+	rd.read = 0
+	return rd.RewindReader.Rewind()
+}
+
+// Save stores the file in the wrapped backend. The upload is interrupted if a
+// short write is injected.
+func (be *Backend) Save(ctx context.Context, h restic.Handle, rd restic.RewindReader) error {
+	n, err := be.inject(ctx, restic.OpSave, h)
+	if err != nil {
+		return err
+	}
+
+	if be.roll("short-write", restic.OpSave, h, n) >= be.shortWrite.Get(restic.OpSave, h.Type) {
+		return be.b.Save(ctx, h, rd)
+	}
+
+	limit := int64(be.roll("short-write-at", restic.OpSave, h, n) * float64(rd.Length()))
+	debug.Log("interrupting save %v after %d bytes", h, limit)
+	err = be.b.Save(ctx, h, &shortReader{RewindReader: rd, limit: limit})
+	if err == nil {
+		// the wrapped backend did not notice, report it anyway
+		err = fmt.Errorf("save %v interrupted after %d bytes: %w", h, limit, ErrInjected)
+	}
+	return err
+}
+
+// Load runs fn with a reader of the file in the wrapped backend. If a
+// truncated read is injected, the reader ends early without an error.
+func (be *Backend) Load(ctx context.Context, h restic.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
+	n, err := be.inject(ctx, restic.OpLoad, h)
+	if err != nil {
+		return err
+	}
+
+	if be.roll("truncate", restic.OpLoad, h, n) >= be.truncate.Get(restic.OpLoad, h.Type) {
+		return be.b.Load(ctx, h, length, offset, fn)
+	}
+
+	size := int64(length)
+	if length <= 0 {
+		fi, err := be.b.Stat(ctx, h)
+		if err != nil {
+			return err
+		}
+		size = fi.Size - offset
+	}
+
+	limit := int64(be.roll("truncate-at", restic.OpLoad, h, n) * float64(size))
+	debug.Log("truncating load %v after %d bytes", h, limit)
+	return be.b.Load(ctx, h, length, offset, func(rd io.Reader) error {
+		return fn(io.LimitReader(rd, limit))
+	})
+}
+
+// Stat returns information about the file in the wrapped backend.
+func (be *Backend) Stat(ctx context.Context, h restic.Handle) (restic.FileInfo, error) {
+	if _, err := be.inject(ctx, restic.OpStat, h); err != nil {
+		return restic.FileInfo{}, err
+	}
+	return be.b.Stat(ctx, h)
+}
+
+// Test returns whether the file exists in the wrapped backend.
+func (be *Backend) Test(ctx context.Context, h restic.Handle) (bool, error) {
+	if _, err := be.inject(ctx, restic.OpTest, h); err != nil {
+		return false, err
+	}
+	return be.b.Test(ctx, h)
+}
+
+// Remove removes the file from the wrapped backend.
+func (be *Backend) Remove(ctx context.Context, h restic.Handle) error {
+	if _, err := be.inject(ctx, restic.OpRemove, h); err != nil {
+		return err
+	}
+	return be.b.Remove(ctx, h)
+}
+
+// List runs fn for each file of type t in the wrapped backend.
+func (be *Backend) List(ctx context.Context, t restic.FileType, fn func(restic.FileInfo) error) error {
+	if _, err := be.inject(ctx, restic.OpList, restic.Handle{Type: t}); err != nil {
+		return err
+	}
+	return be.b.List(ctx, t, fn)
+}
+
+// Close closes the wrapped backend.
+func (be *Backend) Close() error {
+	return be.b.Close()
+}
+
+// Delete removes all data in the wrapped backend.
+func (be *Backend) Delete(ctx context.Context) error {
+	return be.b.Delete(ctx)
+}
@@ -0,0 +1,218 @@
+package faulty_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/faulty"
+	"github.com/restic/restic/internal/backend/mem"
+	"github.com/restic/restic/internal/backend/test"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+type faultyConfig struct {
+	be restic.Backend
+}
+
+func newFaulty(t testing.TB, be restic.Backend, cfg faulty.Config) *faulty.Backend {
+	fb, err := faulty.New(be, cfg)
+	rtest.OK(t, err)
+	return fb
+}
+
+func newTestSuite(t testing.TB) *test.Suite {
+	return &test.Suite{
+		// NewConfig returns a config for a new temporary backend that will be used in tests.
+		NewConfig: func() (interface{}, error) {
+			return &faultyConfig{}, nil
+		},
+
+		// CreateFn is a function that creates a temporary repository for the tests.
+		Create: func(cfg interface{}) (restic.Backend, error) {
+			c := cfg.(*faultyConfig)
+			if c.be != nil {
+				ok, err := c.be.Test(context.TODO(), restic.Handle{Type: restic.ConfigFile})
+				if err != nil {
+					return nil, err
+				}
+
+				if ok {
+					return nil, errors.New("config already exists")
+				}
+			}
+
+			c.be = newFaulty(t, mem.New(), faulty.Config{})
+			return c.be, nil
+		},
+
+		// OpenFn is a function that opens a previously created temporary repository.
+		Open: func(cfg interface{}) (restic.Backend, error) {
+			c := cfg.(*faultyConfig)
+			if c.be == nil {
+				c.be = newFaulty(t, mem.New(), faulty.Config{})
+			}
+			return c.be, nil
+		},
+
+		// CleanupFn removes data created during the tests.
+		Cleanup: func(cfg interface{}) error {
+			// no cleanup needed
+			return nil
+		},
+	}
+}
+
+func TestSuiteBackendFaulty(t *testing.T) {
+	newTestSuite(t).RunTests(t)
+}
+
+func handle(data string) restic.Handle {
+	return restic.Handle{Type: restic.PackFile, Name: restic.Hash([]byte(data)).String()}
+}
+
+func save(ctx context.Context, be restic.Backend, h restic.Handle, data string) error {
+	return be.Save(ctx, h, restic.NewByteReader([]byte(data), be.Hasher()))
+}
+
+func TestSelectors(t *testing.T) {
+	ctx := context.TODO()
+	be := newFaulty(t, mem.New(), faulty.Config{Errors: "save/data=1,load=1"})
+
+	h := handle("data")
+	err := save(ctx, be, h, "data")
+	rtest.Assert(t, errors.Is(err, faulty.ErrInjected), "unexpected error %v", err)
+
+	idx := restic.Handle{Type: restic.IndexFile, Name: h.Name}
+	rtest.OK(t, save(ctx, be, idx, "data"))
+
+	_, err = backend.LoadAll(ctx, nil, be, idx)
+	rtest.Assert(t, errors.Is(err, faulty.ErrInjected), "unexpected error %v", err)
+
+	fi, err := be.Stat(ctx, idx)
+	rtest.OK(t, err)
+	rtest.Equals(t, int64(4), fi.Size)
+}
+
+func TestDeterministic(t *testing.T) {
+	ctx := context.TODO()
+	run := func() []bool {
+		be := newFaulty(t, mem.New(), faulty.Config{Seed: 1, Errors: "test=0.5"})
+
+		var failed []bool
+		for i := 0; i < 50; i++ {
+			_, err := be.Test(ctx, handle(fmt.Sprint(i)))
+			failed = append(failed, err != nil)
+		}
+		return failed
+	}
+
+	first := run()
+	rtest.Equals(t, first, run())
+
+	n := 0
+	for _, f := range first {
+		if f {
+			n++
+		}
+	}
+	rtest.Assert(t, n > 0 && n < len(first), "%d of %d requests failed", n, len(first))
+}
+
+func TestRetry(t *testing.T) {
+	ctx := context.TODO()
+	be := newFaulty(t, mem.New(), faulty.Config{Seed: 1, Errors: "save=0.5,load=0.5"})
+
+	retries := 0
+	rbe := backend.NewRetryBackend(be, 10, func(msg string, err error, d time.Duration) {
+		rtest.Assert(t, errors.Is(err, faulty.ErrInjected), "unexpected error %v", err)
+		retries++
+	})
+
+	h := handle("data")
+	rtest.OK(t, save(ctx, rbe, h, "data"))
+	buf, err := backend.LoadAll(ctx, nil, rbe, h)
+	rtest.OK(t, err)
+	rtest.Equals(t, "data", string(buf))
+
+	// with seed 1 the first save and the first load fail
+	rtest.Equals(t, 2, retries)
+}
+
+func TestTruncate(t *testing.T) {
+	ctx := context.TODO()
+	be := newFaulty(t, mem.New(), faulty.Config{Truncate: "load/data=1"})
+
+	data := string(rtest.Random(23, 1000))
+	h := handle(data)
+	rtest.OK(t, save(ctx, be, h, data))
+
+	buf, err := backend.LoadAll(ctx, nil, be, h)
+	rtest.OK(t, err)
+	rtest.Assert(t, len(buf) < len(data), "read %d bytes, expected less than %d", len(buf), len(data))
+	rtest.Equals(t, data[:len(buf)], string(buf))
+}
+
+func TestShortWrite(t *testing.T) {
+	ctx := context.TODO()
+	be := newFaulty(t, mem.New(), faulty.Config{ShortWrite: "save=1"})
+
+	h := handle("data")
+	err := save(ctx, be, h, "data")
+	rtest.Assert(t, errors.Is(err, faulty.ErrInjected), "unexpected error %v", err)
+
+	ok, err := be.Test(ctx, h)
+	rtest.OK(t, err)
+	rtest.Assert(t, !ok, "interrupted file was saved")
+}
+
+func TestHang(t *testing.T) {
+	be := newFaulty(t, mem.New(), faulty.Config{Hang: "list/lock=1", Latency: "stat=1ms"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := be.List(ctx, restic.LockFile, func(restic.FileInfo) error { return nil })
+	rtest.Assert(t, errors.Is(err, context.DeadlineExceeded), "unexpected error %v", err)
+
+	_, err = be.Stat(context.TODO(), handle("data"))
+	rtest.Assert(t, be.IsNotExist(err), "unexpected error %v", err)
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := faulty.ParseConfig("faulty:s3:s3.amazonaws.com/bucket")
+	rtest.OK(t, err)
+	rtest.Equals(t, "s3:s3.amazonaws.com/bucket", cfg.(faulty.Config).Inner)
+
+	for _, s := range []string{"s3:s3.amazonaws.com/bucket", "faulty:", "faulty:faulty:/srv/repo"} {
+		_, err := faulty.ParseConfig(s)
+		rtest.Assert(t, err != nil, "no error for %q", s)
+	}
+}
+
+func TestParseRates(t *testing.T) {
+	rates, err := faulty.ParseRates("save/data=0.5, save=0.25,*/lock=0.125,*=0.1")
+	rtest.OK(t, err)
+
+	for _, test := range []struct {
+		op   string
+		t    restic.FileType
+		rate float64
+	}{
+		{restic.OpSave, restic.PackFile, 0.5},
+		{restic.OpSave, restic.LockFile, 0.25},
+		{restic.OpLoad, restic.LockFile, 0.125},
+		{restic.OpList, restic.IndexFile, 0.1},
+	} {
+		rtest.Equals(t, test.rate, rates.Get(test.op, test.t))
+	}
+
+	for _, s := range []string{"save", "save=2", "write=0.1", "save/pack=0.1", "load=x"} {
+		_, err := faulty.ParseRates(s)
+		rtest.Assert(t, err != nil, "no error for %q", s)
+	}
+}
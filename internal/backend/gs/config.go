@@ -17,6 +17,9 @@ type Config struct {
 	Prefix    string
 
 	Connections uint `option:"connections" help:"set a limit for the number of concurrent connections (default: 5)"`
+
+	StorageClass     string `option:"storage-class" help:"set storage class for new files (STANDARD, NEARLINE, COLDLINE or ARCHIVE, default: bucket default)"`
+	DataStorageClass string `option:"data-storage-class" help:"set storage class for packs containing file data (default: storage-class)"`
 }
 
 // NewConfig returns a new Config with the default values filled in.
@@ -42,6 +42,9 @@ type Backend struct {
 	prefix       string
 	listMaxItems int
 	backend.Layout
+
+	storageClass     string
+	dataStorageClass string
 }
 
 // Ensure that *Backend implements restic.Backend.
@@ -115,7 +118,9 @@ func open(cfg Config, rt http.RoundTripper) (*Backend, error) {
 			Path: cfg.Prefix,
 			Join: path.Join,
 		},
-		listMaxItems: defaultListMaxItems,
+		listMaxItems:     defaultListMaxItems,
+		storageClass:     cfg.StorageClass,
+		dataStorageClass: cfg.DataStorageClass,
 	}
 
 	return be, nil
@@ -254,6 +259,7 @@ func (be *Backend) Save(ctx context.Context, h restic.Handle, rd restic.RewindRe
 	w := be.bucket.Object(objName).NewWriter(ctx)
 	w.ChunkSize = 0
 	w.MD5 = rd.Hash()
+	w.StorageClass = be.storageClassFor(h)
 	wbytes, err := io.Copy(w, rd)
 	cerr := w.Close()
 	if err == nil {
@@ -275,6 +281,15 @@ func (be *Backend) Save(ctx context.Context, h restic.Handle, rd restic.RewindRe
 	return nil
 }
 
+// storageClassFor returns the storage class for the file at h, an empty
+// string selects the default storage class of the bucket.
+func (be *Backend) storageClassFor(h restic.Handle) string {
+	if h.IsDataPack() && be.dataStorageClass != "" {
+		return be.dataStorageClass
+	}
+	return be.storageClass
+}
+
 // Load runs fn with a reader that yields the contents of the file at h at the
 // given offset.
 func (be *Backend) Load(ctx context.Context, h restic.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
@@ -6,8 +6,10 @@ import (
 
 	"github.com/restic/restic/internal/backend/azure"
 	"github.com/restic/restic/internal/backend/b2"
+	"github.com/restic/restic/internal/backend/faulty"
 	"github.com/restic/restic/internal/backend/gs"
 	"github.com/restic/restic/internal/backend/local"
+	"github.com/restic/restic/internal/backend/mirror"
 	"github.com/restic/restic/internal/backend/neofs"
 	"github.com/restic/restic/internal/backend/rclone"
 	"github.com/restic/restic/internal/backend/rest"
@@ -45,6 +47,20 @@ var parsers = []parser{
 	{"neofs", neofs.ParseConfig, noPassword},
 }
 
+func init() {
+	// the members of a mirror and the backend wrapped by faulty are
+	// locations themselves, their passwords are stripped using the parsers
+	// above
+	parsers = append(parsers,
+		parser{"mirror", mirror.ParseConfig, func(s string) string {
+			return mirror.StripPassword(s, StripPassword)
+		}},
+		parser{"faulty", faulty.ParseConfig, func(s string) string {
+			return faulty.StripPassword(s, StripPassword)
+		}},
+	)
+}
+
 // noPassword returns the repository location unchanged (there's no sensitive information there)
 func noPassword(s string) string {
 	return s
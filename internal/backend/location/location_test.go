@@ -6,7 +6,9 @@ import (
 	"testing"
 
 	"github.com/restic/restic/internal/backend/b2"
+	"github.com/restic/restic/internal/backend/faulty"
 	"github.com/restic/restic/internal/backend/local"
+	"github.com/restic/restic/internal/backend/mirror"
 	"github.com/restic/restic/internal/backend/rest"
 	"github.com/restic/restic/internal/backend/s3"
 	"github.com/restic/restic/internal/backend/sftp"
@@ -315,6 +317,20 @@ var parseTests = []struct {
 			},
 		},
 	},
+	{
+		"mirror:rest:http://hostname.foo:1234/|/srv/repo", Location{Scheme: "mirror",
+			Config: mirror.Config{
+				Members: []string{"rest:http://hostname.foo:1234/", "/srv/repo"},
+			},
+		},
+	},
+	{
+		"faulty:sftp:user@host:/srv/repo", Location{Scheme: "faulty",
+			Config: faulty.Config{
+				Inner: "sftp:user@host:/srv/repo",
+			},
+		},
+	},
 }
 
 func TestParse(t *testing.T) {
@@ -342,6 +358,11 @@ func TestInvalidScheme(t *testing.T) {
 	var invalidSchemes = []string{
 		"foobar:xxx",
 		"foobar:/dir/dir2",
+		"mirror:/srv/repo",
+		"mirror:/srv/repo|",
+		"mirror:/srv/repo|mirror:/srv/repo2|/srv/repo3",
+		"faulty:",
+		"faulty:faulty:/srv/repo",
 	}
 
 	for _, s := range invalidSchemes {
@@ -0,0 +1,56 @@
+package mirror
+
+import (
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// Separator separates the locations of the members in a mirror location.
+const Separator = "|"
+
+// Config contains the locations of the members of a mirror. The members are
+// parsed and opened like any other repository location.
+type Config struct {
+	Members []string
+}
+
+// ParseConfig parses the string s and extracts the member locations. The
+// format is mirror:location1|location2[|...], e.g.
+// mirror:neofs:grpcs://s01.neofs.devenv:8080/container|/srv/restic-repo.
+func ParseConfig(s string) (interface{}, error) {
+	if !strings.HasPrefix(s, "mirror:") {
+		return nil, errors.New("invalid mirror backend specification")
+	}
+
+	members := strings.Split(s[len("mirror:"):], Separator)
+	if len(members) < 2 {
+		return nil, errors.New("a mirror needs at least two member locations")
+	}
+
+	for i, m := range members {
+		if m == "" {
+			return nil, errors.Errorf("member %d of the mirror has an empty location", i+1)
+		}
+		if strings.HasPrefix(m, "mirror:") {
+			return nil, errors.New("mirrors cannot be nested")
+		}
+	}
+
+	return Config{Members: members}, nil
+}
+
+// StripPassword removes the sensitive information from the member locations
+// using strip.
+func StripPassword(s string, strip func(string) string) string {
+	cfg, err := ParseConfig(s)
+	if err != nil {
+		return s
+	}
+
+	members := cfg.(Config).Members
+	for i, m := range members {
+		members[i] = strip(m)
+	}
+	return "mirror:" + strings.Join(members, Separator)
+}
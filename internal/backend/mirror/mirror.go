@@ -0,0 +1,502 @@
+// Package mirror implements a backend which stores all files in several
+// member backends at once.
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/restic"
+)
+
+// Backend writes all files to every member and reads them from the first
+// member which works. Members which returned errors are tried last until they
+// work again.
+type Backend struct {
+	members []restic.Backend
+	report  func(msg string)
+
+	m      sync.Mutex
+	failed []bool
+	// saved records the members which stored a file whose Save failed in
+	// other members, so that a retry only saves it in the remaining ones
+	saved map[restic.Handle][]bool
+}
+
+// statically ensure that Backend implements the interfaces.
+var (
+	_ restic.Backend             = &Backend{}
+	_ restic.LockExpiringBackend = &Backend{}
+)
+
+// New returns a mirror of the members. The divergence between the members
+// found by List and the members skipped because of errors are passed to
+// report.
+func New(members []restic.Backend, report func(msg string)) *Backend {
+	if report == nil {
+		report = func(string) {}
+	}
+
+	return &Backend{
+		members: members,
+		report:  report,
+		failed:  make([]bool, len(members)),
+		saved:   make(map[restic.Handle][]bool),
+	}
+}
+
+// Members returns the member backends.
+func (be *Backend) Members() []restic.Backend {
+	return be.members
+}
+
+// order returns the indexes of the members in the order in which they are
+// read from, the members which failed last are tried after the others.
+func (be *Backend) order() []int {
+	be.m.Lock()
+	defer be.m.Unlock()
+
+	res := make([]int, 0, len(be.members))
+	for i := range be.members {
+		if !be.failed[i] {
+			res = append(res, i)
+		}
+	}
+	for i := range be.members {
+		if be.failed[i] {
+			res = append(res, i)
+		}
+	}
+	return res
+}
+
+// setFailed records whether the last request to member i failed. A missing
+// file is not a failure.
+func (be *Backend) setFailed(i int, err error) {
+	failed := err != nil && !be.members[i].IsNotExist(err)
+
+	be.m.Lock()
+	defer be.m.Unlock()
+
+	if failed && !be.failed[i] {
+		debug.Log("member %v failed: %v", be.members[i].Location(), err)
+	}
+	be.failed[i] = failed
+}
+
+// Location returns the locations of the members.
+func (be *Backend) Location() string {
+	locations := make([]string, 0, len(be.members))
+	for _, m := range be.members {
+		locations = append(locations, m.Location())
+	}
+	return "mirror:" + strings.Join(locations, Separator)
+}
+
+// Connections returns the lowest number of connections of the members, as
+// every write uses a connection to each member.
+func (be *Backend) Connections() uint {
+	res := be.members[0].Connections()
+	for _, m := range be.members[1:] {
+		if m.Connections() < res {
+			res = m.Connections()
+		}
+	}
+	return res
+}
+
+// Hasher returns nil, the hashes required by the members are calculated
+// separately when saving a file.
+func (be *Backend) Hasher() hash.Hash {
+	return nil
+}
+
+// HasAtomicReplace returns whether all members replace files atomically.
+func (be *Backend) HasAtomicReplace() bool {
+	for _, m := range be.members {
+		if !m.HasAtomicReplace() {
+			return false
+		}
+	}
+	return true
+}
+
+// IsNotExist returns true if the error was caused by a file missing in a
+// member.
+func (be *Backend) IsNotExist(err error) bool {
+	for _, m := range be.members {
+		if m.IsNotExist(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// LockExpiration returns the shortest time any member keeps lock files.
+func (be *Backend) LockExpiration() time.Duration {
+	res := time.Duration(math.MaxInt64)
+	for _, m := range be.members {
+		if exp := restic.AsLockExpiringBackend(m); exp != nil && exp.LockExpiration() < res {
+			res = exp.LockExpiration()
+		}
+	}
+	return res
+}
+
+// hashedReader returns the hash calculated for a member instead of the hash
+// of the underlying reader.
+type hashedReader struct {
+	restic.RewindReader
+	hash []byte
+}
+
+func (rd hashedReader) Hash() []byte {
+	return rd.hash
+}
+
+// readerFor returns rd with the hash required by member m.
+func readerFor(m restic.Backend, rd restic.RewindReader) (restic.RewindReader, error) {
+	if err := rd.Rewind(); err != nil {
+		return nil, err
+	}
+
+	hasher := m.Hasher()
+	if hasher == nil {
+		return rd, nil
+	}
+
+	if _, err := io.Copy(hasher, rd); err != nil {
+		return nil, err
+	}
+	if err := rd.Rewind(); err != nil {
+		return nil, err
+	}
+	return hashedReader{RewindReader: rd, hash: hasher.Sum(nil)}, nil
+}
+
+// savedIn returns which members stored the file during a previous Save
+// which failed in other members.
+func (be *Backend) savedIn(h restic.Handle) []bool {
+	be.m.Lock()
+	defer be.m.Unlock()
+
+	saved := make([]bool, len(be.members))
+	copy(saved, be.saved[h])
+	return saved
+}
+
+// setSaved records which members stored the file, the record is dropped once
+// all of them did.
+func (be *Backend) setSaved(h restic.Handle, saved []bool) {
+	be.m.Lock()
+	defer be.m.Unlock()
+
+	for _, ok := range saved {
+		if !ok {
+			be.saved[h] = saved
+			return
+		}
+	}
+	delete(be.saved, h)
+}
+
+// Save stores the file in all members. It returns the first error, but
+// still tries to save the file in the remaining members. When the file is
+// saved again after an error, only the members which failed are written to,
+// so that the retries don't create additional copies in the others.
+func (be *Backend) Save(ctx context.Context, h restic.Handle, rd restic.RewindReader) error {
+	saved := be.savedIn(h)
+	defer be.setSaved(h, saved)
+
+	var firstErr error
+	for i, m := range be.members {
+		if saved[i] {
+			continue
+		}
+
+		mrd, err := readerFor(m, rd)
+		if err == nil {
+			err = m.Save(ctx, h, mrd)
+		}
+		be.setFailed(i, err)
+		saved[i] = err == nil
+
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%v: %w", m.Location(), err)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return firstErr
+}
+
+// Remove removes the file from all members. Members which do not store the
+// file are ignored, unless no member stores it. After a failed Save, the file
+// is only removed from the members which failed to store it, as the removal
+// of partially uploaded files is followed by another Save.
+func (be *Backend) Remove(ctx context.Context, h restic.Handle) error {
+	saved := be.savedIn(h)
+
+	var firstErr, notExistErr error
+	removed := false
+	for i, m := range be.members {
+		if saved[i] {
+			removed = true
+			continue
+		}
+
+		err := m.Remove(ctx, h)
+		be.setFailed(i, err)
+
+		switch {
+		case err == nil:
+			removed = true
+		case m.IsNotExist(err):
+			if notExistErr == nil {
+				notExistErr = err
+			}
+		case firstErr == nil:
+			firstErr = fmt.Errorf("%v: %w", m.Location(), err)
+		}
+	}
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if !removed {
+		return notExistErr
+	}
+	return nil
+}
+
+// Load runs fn with a reader of the file from the first member which works.
+// If a member fails or does not store the file, the next one is tried.
+func (be *Backend) Load(ctx context.Context, h restic.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
+	var firstErr error
+	for _, i := range be.order() {
+		err := be.members[i].Load(ctx, h, length, offset, fn)
+		be.setFailed(i, err)
+		if err == nil {
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		debug.Log("loading %v from %v failed, trying next member: %v", h, be.members[i].Location(), err)
+	}
+	return firstErr
+}
+
+// Stat returns information about the file from the first member which works.
+func (be *Backend) Stat(ctx context.Context, h restic.Handle) (restic.FileInfo, error) {
+	var firstErr error
+	for _, i := range be.order() {
+		fi, err := be.members[i].Stat(ctx, h)
+		be.setFailed(i, err)
+		if err == nil {
+			return fi, nil
+		}
+
+		if ctx.Err() != nil {
+			return restic.FileInfo{}, ctx.Err()
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return restic.FileInfo{}, firstErr
+}
+
+// Test returns true if any member stores the file.
+func (be *Backend) Test(ctx context.Context, h restic.Handle) (bool, error) {
+	var firstErr error
+	for _, i := range be.order() {
+		ok, err := be.members[i].Test(ctx, h)
+		be.setFailed(i, err)
+		if err == nil && ok {
+			return true, nil
+		}
+
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return false, firstErr
+}
+
+// List runs fn for each file of type t stored in any member. Members which
+// fail to list the files are skipped unless all fail. The files missing in
+// some of the members or stored with different sizes are reported.
+func (be *Backend) List(ctx context.Context, t restic.FileType, fn func(restic.FileInfo) error) error {
+	files, listed, err := be.listMembers(ctx, t, false)
+	if err != nil {
+		return err
+	}
+
+	// lock files are created and removed all the time, the members are
+	// expected to disagree on them while they are listed
+	if t != restic.LockFile {
+		be.reportDivergence(t, files, listed)
+	}
+
+	for name, sizes := range files {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		fi := restic.FileInfo{Name: name, Size: -1}
+		for _, size := range sizes {
+			if size >= 0 {
+				fi.Size = size
+				break
+			}
+		}
+
+		if err := fn(fi); err != nil {
+			return err
+		}
+	}
+
+	return ctx.Err()
+}
+
+// listMembers returns the sizes of the files of type t in all members,
+// indexed like the members, -1 marks a missing file. Unless strict is set,
+// the members which failed are skipped, listed reports which members were
+// listed.
+func (be *Backend) listMembers(ctx context.Context, t restic.FileType, strict bool) (files map[string][]int64, listed []bool, err error) {
+	files = make(map[string][]int64)
+	listed = make([]bool, len(be.members))
+
+	var firstErr error
+	for i, m := range be.members {
+		err := m.List(ctx, t, func(fi restic.FileInfo) error {
+			sizes, ok := files[fi.Name]
+			if !ok {
+				sizes = make([]int64, len(be.members))
+				for j := range sizes {
+					sizes[j] = -1
+				}
+				files[fi.Name] = sizes
+			}
+			sizes[i] = fi.Size
+			return nil
+		})
+		be.setFailed(i, err)
+
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		if err != nil {
+			if strict {
+				return nil, nil, fmt.Errorf("%v: %w", m.Location(), err)
+			}
+			be.report(fmt.Sprintf("mirror: listing %v files of %v failed: %v", t, m.Location(), err))
+			if firstErr == nil {
+				firstErr = err
+			}
+
+			// remove the files seen in the failed member, it's unknown
+			// whether the list was complete
+			for name, sizes := range files {
+				if sizes[i] >= 0 {
+					sizes[i] = -1
+					if isMissingEverywhere(sizes) {
+						delete(files, name)
+					}
+				}
+			}
+			continue
+		}
+		listed[i] = true
+	}
+
+	for _, ok := range listed {
+		if ok {
+			return files, listed, nil
+		}
+	}
+	return nil, nil, firstErr
+}
+
+func isMissingEverywhere(sizes []int64) bool {
+	for _, size := range sizes {
+		if size >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// reportDivergence reports the number of files missing in a listed member or
+// stored with a size different from the other members.
+func (be *Backend) reportDivergence(t restic.FileType, files map[string][]int64, listed []bool) {
+	missing := make([]int, len(be.members))
+	differing := 0
+	for _, sizes := range files {
+		size := int64(-1)
+		differs := false
+		for i, s := range sizes {
+			if !listed[i] {
+				continue
+			}
+			if s < 0 {
+				missing[i]++
+				continue
+			}
+			if size >= 0 && s != size {
+				differs = true
+			}
+			size = s
+		}
+		if differs {
+			differing++
+		}
+	}
+
+	for i, n := range missing {
+		if n > 0 {
+			be.report(fmt.Sprintf("mirror: %d %v files are missing in %v, run resync to copy them", n, t, be.members[i].Location()))
+		}
+	}
+	if differing > 0 {
+		be.report(fmt.Sprintf("mirror: %d %v files have different sizes in the members", differing, t))
+	}
+}
+
+// Close closes all members.
+func (be *Backend) Close() error {
+	var firstErr error
+	for _, m := range be.members {
+		if err := m.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Delete removes all data in all members.
+func (be *Backend) Delete(ctx context.Context) error {
+	var firstErr error
+	for _, m := range be.members {
+		if err := m.Delete(ctx); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%v: %w", m.Location(), err)
+		}
+	}
+	return firstErr
+}
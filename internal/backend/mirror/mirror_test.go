@@ -0,0 +1,275 @@
+package mirror_test
+
+import (
+	"context"
+	"io"
+	"sort"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/mem"
+	"github.com/restic/restic/internal/backend/mirror"
+	"github.com/restic/restic/internal/backend/test"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/mock"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+type mirrorConfig struct {
+	be restic.Backend
+}
+
+func newTestSuite() *test.Suite {
+	return &test.Suite{
+		// NewConfig returns a config for a new temporary backend that will be used in tests.
+		NewConfig: func() (interface{}, error) {
+			return &mirrorConfig{}, nil
+		},
+
+		// CreateFn is a function that creates a temporary repository for the tests.
+		Create: func(cfg interface{}) (restic.Backend, error) {
+			c := cfg.(*mirrorConfig)
+			if c.be != nil {
+				ok, err := c.be.Test(context.TODO(), restic.Handle{Type: restic.ConfigFile})
+				if err != nil {
+					return nil, err
+				}
+
+				if ok {
+					return nil, errors.New("config already exists")
+				}
+			}
+
+			c.be = mirror.New([]restic.Backend{mem.New(), mem.New()}, nil)
+			return c.be, nil
+		},
+
+		// OpenFn is a function that opens a previously created temporary repository.
+		Open: func(cfg interface{}) (restic.Backend, error) {
+			c := cfg.(*mirrorConfig)
+			if c.be == nil {
+				c.be = mirror.New([]restic.Backend{mem.New(), mem.New()}, nil)
+			}
+			return c.be, nil
+		},
+
+		// CleanupFn removes data created during the tests.
+		Cleanup: func(cfg interface{}) error {
+			// no cleanup needed
+			return nil
+		},
+	}
+}
+
+func TestSuiteBackendMirror(t *testing.T) {
+	newTestSuite().RunTests(t)
+}
+
+func save(t testing.TB, be restic.Backend, tpe restic.FileType, data string) restic.Handle {
+	h := restic.Handle{Type: tpe, Name: restic.Hash([]byte(data)).String()}
+	rtest.OK(t, be.Save(context.TODO(), h, restic.NewByteReader([]byte(data), be.Hasher())))
+	return h
+}
+
+func list(t testing.TB, be restic.Backend, tpe restic.FileType) []string {
+	var names []string
+	rtest.OK(t, be.List(context.TODO(), tpe, func(fi restic.FileInfo) error {
+		names = append(names, fi.Name)
+		return nil
+	}))
+	sort.Strings(names)
+	return names
+}
+
+func TestFallback(t *testing.T) {
+	ctx := context.TODO()
+	first, second := mem.New(), mem.New()
+
+	var reports []string
+	be := mirror.New([]restic.Backend{first, second}, func(msg string) {
+		reports = append(reports, msg)
+	})
+
+	h1 := save(t, be, restic.PackFile, "both")
+	h2 := save(t, second, restic.PackFile, "second only")
+
+	// files missing in the first member are read from the second one
+	buf, err := backend.LoadAll(ctx, nil, be, h2)
+	rtest.OK(t, err)
+	rtest.Equals(t, "second only", string(buf))
+
+	fi, err := be.Stat(ctx, h2)
+	rtest.OK(t, err)
+	rtest.Equals(t, int64(len("second only")), fi.Size)
+
+	ok, err := be.Test(ctx, h2)
+	rtest.OK(t, err)
+	rtest.Assert(t, ok, "file stored in the second member not found")
+
+	// the list is merged and the missing file reported
+	expected := []string{h1.Name, h2.Name}
+	sort.Strings(expected)
+	rtest.Equals(t, expected, list(t, be, restic.PackFile))
+	rtest.Equals(t, 1, len(reports))
+
+	// removing a file stored in some members succeeds
+	rtest.OK(t, be.Remove(ctx, h2))
+	_, err = be.Stat(ctx, h2)
+	rtest.Assert(t, be.IsNotExist(err), "unexpected error %v", err)
+	rtest.Assert(t, be.IsNotExist(be.Remove(ctx, h2)), "removing a missing file did not fail")
+}
+
+func TestFailingMember(t *testing.T) {
+	ctx := context.TODO()
+	failing := mock.NewBackend()
+	failing.SaveFn = func(ctx context.Context, h restic.Handle, rd restic.RewindReader) error {
+		return errors.New("save failed")
+	}
+	failing.OpenReaderFn = func(ctx context.Context, h restic.Handle, length int, offset int64) (io.ReadCloser, error) {
+		return nil, errors.New("load failed")
+	}
+	failing.StatFn = func(ctx context.Context, h restic.Handle) (restic.FileInfo, error) {
+		return restic.FileInfo{}, errors.New("stat failed")
+	}
+	failing.ListFn = func(ctx context.Context, t restic.FileType, fn func(restic.FileInfo) error) error {
+		return errors.New("list failed")
+	}
+	working := mem.New()
+
+	var reports []string
+	be := mirror.New([]restic.Backend{failing, working}, func(msg string) {
+		reports = append(reports, msg)
+	})
+
+	// the file is still saved in the working member
+	h := restic.Handle{Type: restic.PackFile, Name: restic.Hash([]byte("data")).String()}
+	err := be.Save(ctx, h, restic.NewByteReader([]byte("data"), be.Hasher()))
+	rtest.Assert(t, err != nil, "no error from the failing member")
+
+	buf, err := backend.LoadAll(ctx, nil, be, h)
+	rtest.OK(t, err)
+	rtest.Equals(t, "data", string(buf))
+
+	rtest.Equals(t, []string{h.Name}, list(t, be, restic.PackFile))
+	rtest.Equals(t, 1, len(reports))
+}
+
+// countingBackend counts the calls to Save.
+type countingBackend struct {
+	restic.Backend
+	saves int
+}
+
+func (be *countingBackend) Save(ctx context.Context, h restic.Handle, rd restic.RewindReader) error {
+	be.saves++
+	return be.Backend.Save(ctx, h, rd)
+}
+
+func TestSaveRetry(t *testing.T) {
+	ctx := context.TODO()
+	first := &countingBackend{Backend: mem.New()}
+	second := mem.New()
+
+	flaky := mock.NewBackend()
+	fail := true
+	flaky.SaveFn = func(ctx context.Context, h restic.Handle, rd restic.RewindReader) error {
+		if fail {
+			return errors.New("save failed")
+		}
+		return second.Save(ctx, h, rd)
+	}
+	flaky.RemoveFn = second.Remove
+	flaky.IsNotExistFn = second.IsNotExist
+
+	be := mirror.New([]restic.Backend{first, flaky}, nil)
+
+	// the retry backend removes the file before saving it again, which
+	// must keep the file in the member which stored it
+	h := restic.Handle{Type: restic.PackFile, Name: restic.Hash([]byte("data")).String()}
+	err := be.Save(ctx, h, restic.NewByteReader([]byte("data"), be.Hasher()))
+	rtest.Assert(t, err != nil, "no error from the failing member")
+	rtest.OK(t, be.Remove(ctx, h))
+
+	fail = false
+	rtest.OK(t, be.Save(ctx, h, restic.NewByteReader([]byte("data"), be.Hasher())))
+	rtest.Equals(t, 1, first.saves)
+
+	for _, m := range []restic.Backend{first, second} {
+		buf, err := backend.LoadAll(ctx, nil, m, h)
+		rtest.OK(t, err)
+		rtest.Equals(t, "data", string(buf))
+	}
+
+	// once all members stored the file, it is removed from all of them
+	rtest.OK(t, be.Remove(ctx, h))
+	for _, m := range []restic.Backend{first, second} {
+		_, err := m.Stat(ctx, h)
+		rtest.Assert(t, m.IsNotExist(err), "unexpected error %v", err)
+	}
+}
+
+func TestResync(t *testing.T) {
+	ctx := context.TODO()
+	first, second := mem.New(), mem.New()
+	be := mirror.New([]restic.Backend{first, second}, nil)
+
+	config := restic.Handle{Type: restic.ConfigFile}
+	rtest.OK(t, second.Save(ctx, config, restic.NewByteReader([]byte("config"), second.Hasher())))
+	save(t, be, restic.PackFile, "both")
+	h1 := save(t, first, restic.PackFile, "first")
+	h2 := save(t, second, restic.SnapshotFile, "second")
+	save(t, first, restic.LockFile, "lock")
+
+	resync := func(dryRun bool) []string {
+		var copied []string
+		err := be.Resync(ctx, dryRun, func(h restic.Handle, to restic.Backend) {
+			copied = append(copied, h.String())
+		}, func(h restic.Handle) {
+			t.Errorf("unexpected divergence of %v", h)
+		})
+		rtest.OK(t, err)
+		sort.Strings(copied)
+		return copied
+	}
+
+	expected := []string{config.String(), h1.String(), h2.String()}
+	sort.Strings(expected)
+	rtest.Equals(t, expected, resync(true))
+	rtest.Equals(t, expected, resync(false))
+	rtest.Equals(t, 0, len(resync(false)))
+
+	for _, m := range []restic.Backend{first, second} {
+		buf, err := backend.LoadAll(ctx, nil, m, config)
+		rtest.OK(t, err)
+		rtest.Equals(t, "config", string(buf))
+		rtest.Equals(t, 2, len(list(t, m, restic.PackFile)))
+		rtest.Equals(t, 1, len(list(t, m, restic.SnapshotFile)))
+	}
+
+	// locks are not copied
+	rtest.Equals(t, 0, len(list(t, second, restic.LockFile)))
+
+	// files with different sizes are left alone
+	h := restic.Handle{Type: restic.IndexFile, Name: restic.Hash([]byte("index")).String()}
+	rtest.OK(t, first.Save(ctx, h, restic.NewByteReader([]byte("index"), first.Hasher())))
+	rtest.OK(t, second.Save(ctx, h, restic.NewByteReader([]byte("other index"), second.Hasher())))
+
+	var diverged []restic.Handle
+	err := be.Resync(ctx, false, func(restic.Handle, restic.Backend) {}, func(h restic.Handle) {
+		diverged = append(diverged, h)
+	})
+	rtest.Assert(t, errors.Is(err, mirror.ErrDiverged), "unexpected error %v", err)
+	rtest.Equals(t, []restic.Handle{h}, diverged)
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := mirror.ParseConfig("mirror:/srv/a|sftp:host:/srv/b|s3:s3.amazonaws.com/bucket")
+	rtest.OK(t, err)
+	rtest.Equals(t, []string{"/srv/a", "sftp:host:/srv/b", "s3:s3.amazonaws.com/bucket"}, cfg.(mirror.Config).Members)
+
+	for _, s := range []string{"/srv/a|/srv/b", "mirror:/srv/a", "mirror:/srv/a||/srv/b", "mirror:/srv/a|mirror:/srv/b|/srv/c"} {
+		_, err := mirror.ParseConfig(s)
+		rtest.Assert(t, err != nil, "no error for %q", s)
+	}
+}
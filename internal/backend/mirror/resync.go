@@ -0,0 +1,206 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// ErrDiverged is returned by Resync if some files are stored with different
+// sizes in the members.
+var ErrDiverged = errors.New("some files are stored with different sizes in the members")
+
+// resyncTypes are the file types copied by Resync. The files are copied in
+// this order, so that a member never references files it does not store yet.
+// Lock files are only valid for a short time and thus not copied.
+var resyncTypes = []restic.FileType{
+	restic.PackFile,
+	restic.IndexFile,
+	restic.SnapshotFile,
+	restic.KeyFile,
+	restic.ConfigFile,
+}
+
+// Resync copies the files which are missing in some of the members from a
+// member which stores them. Unless dryRun is set, copied is called after every
+// file which was copied, otherwise for every file which would be copied.
+// Files stored with different sizes are not touched, but passed to diverged,
+// and ErrDiverged is returned at the end.
+func (be *Backend) Resync(ctx context.Context, dryRun bool, copied func(h restic.Handle, to restic.Backend), diverged func(h restic.Handle)) error {
+	// the files are copied concurrently, but copied is called sequentially
+	var m sync.Mutex
+	copiedFn := copied
+	copied = func(h restic.Handle, to restic.Backend) {
+		m.Lock()
+		defer m.Unlock()
+		copiedFn(h, to)
+	}
+
+	foundDiverged := false
+	for _, t := range resyncTypes {
+		files, err := be.resyncList(ctx, t)
+		if err != nil {
+			return err
+		}
+
+		wg, wctx := errgroup.WithContext(ctx)
+		ch := make(chan restic.Handle)
+		wg.Go(func() error {
+			defer close(ch)
+			for name, sizes := range files {
+				h := restic.Handle{Type: t, Name: name}
+				if !sameSize(sizes) {
+					diverged(h)
+					foundDiverged = true
+					continue
+				}
+				if !isMissingSomewhere(sizes) {
+					continue
+				}
+
+				select {
+				case ch <- h:
+				case <-wctx.Done():
+					return wctx.Err()
+				}
+			}
+			return nil
+		})
+
+		for i := uint(0); i < be.Connections(); i++ {
+			wg.Go(func() error {
+				for h := range ch {
+					if err := be.resyncFile(wctx, h, files[h.Name], dryRun, copied); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		}
+
+		if err := wg.Wait(); err != nil {
+			return err
+		}
+	}
+
+	if foundDiverged {
+		return ErrDiverged
+	}
+	return nil
+}
+
+// resyncList returns the sizes of the files of type t like listMembers, all
+// members must be listed successfully.
+func (be *Backend) resyncList(ctx context.Context, t restic.FileType) (map[string][]int64, error) {
+	if t != restic.ConfigFile {
+		files, _, err := be.listMembers(ctx, t, true)
+		return files, err
+	}
+
+	// the config is not listed by all backends
+	h := restic.Handle{Type: restic.ConfigFile}
+	sizes := make([]int64, len(be.members))
+	found := false
+	for i, m := range be.members {
+		fi, err := m.Stat(ctx, h)
+		switch {
+		case err == nil:
+			sizes[i] = fi.Size
+			found = true
+		case m.IsNotExist(err):
+			sizes[i] = -1
+		default:
+			return nil, fmt.Errorf("%v: %w", m.Location(), err)
+		}
+	}
+
+	files := make(map[string][]int64)
+	if found {
+		files[h.Name] = sizes
+	}
+	return files, nil
+}
+
+// resyncFile copies the file h to the members which do not store it.
+func (be *Backend) resyncFile(ctx context.Context, h restic.Handle, sizes []int64, dryRun bool, copied func(h restic.Handle, to restic.Backend)) error {
+	var buf []byte
+	for i, m := range be.members {
+		if sizes[i] >= 0 {
+			continue
+		}
+
+		if dryRun {
+			copied(h, m)
+			continue
+		}
+
+		if buf == nil {
+			var err error
+			buf, err = be.loadVerified(ctx, h, sizes)
+			if err != nil {
+				return err
+			}
+		}
+
+		debug.Log("copying %v to %v", h, m.Location())
+		if err := m.Save(ctx, h, restic.NewByteReader(buf, m.Hasher())); err != nil {
+			return fmt.Errorf("saving %v to %v failed: %w", h, m.Location(), err)
+		}
+		copied(h, m)
+	}
+	return nil
+}
+
+// loadVerified loads the file from the first member which stores an intact
+// copy, the content of all files except the config must match their name.
+func (be *Backend) loadVerified(ctx context.Context, h restic.Handle, sizes []int64) ([]byte, error) {
+	var lastErr error
+	for i, m := range be.members {
+		if sizes[i] < 0 {
+			continue
+		}
+
+		buf, err := backend.LoadAll(ctx, nil, m, h)
+		if err == nil && h.Type != restic.ConfigFile && restic.Hash(buf).String() != h.Name {
+			err = errors.Errorf("content does not match the name")
+		}
+		if err == nil {
+			return buf, nil
+		}
+
+		lastErr = fmt.Errorf("loading %v from %v failed: %w", h, m.Location(), err)
+		debug.Log("%v", lastErr)
+	}
+	return nil, lastErr
+}
+
+// sameSize returns true if all members which store the file agree on its size.
+func sameSize(sizes []int64) bool {
+	size := int64(-1)
+	for _, s := range sizes {
+		if s < 0 {
+			continue
+		}
+		if size >= 0 && s != size {
+			return false
+		}
+		size = s
+	}
+	return true
+}
+
+func isMissingSomewhere(sizes []int64) bool {
+	for _, size := range sizes {
+		if size < 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,257 @@
+package neofs
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+
+	"github.com/nspcc-dev/neo-go/pkg/crypto/keys"
+	"github.com/nspcc-dev/neofs-sdk-go/bearer"
+	apistatus "github.com/nspcc-dev/neofs-sdk-go/client/status"
+	"github.com/nspcc-dev/neofs-sdk-go/container/acl"
+	cid "github.com/nspcc-dev/neofs-sdk-go/container/id"
+	"github.com/nspcc-dev/neofs-sdk-go/eacl"
+	"github.com/nspcc-dev/neofs-sdk-go/user"
+)
+
+// readOnlyOperations are the operations needed to read a repository.
+var readOnlyOperations = []eacl.Operation{
+	eacl.OperationGet,
+	eacl.OperationHead,
+	eacl.OperationSearch,
+	eacl.OperationRange,
+}
+
+// Admin manages the access to the container of a repository on behalf of
+// the container owner.
+type Admin struct {
+	client   Client
+	key      *keys.PrivateKey
+	cnrID    cid.ID
+	basicACL acl.Basic
+}
+
+// OpenAdmin connects to the network using the wallet from cfg, which must
+// belong to the owner of the container.
+func OpenAdmin(ctx context.Context, cfg Config) (*Admin, error) {
+	if cfg.BearerToken != "" || cfg.SessionToken != "" {
+		return nil, errors.New("managing the access requires the wallet of the container owner instead of tokens")
+	}
+
+	client, _, err := connect(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := newAdmin(ctx, cfg, client, client.key)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func newAdmin(ctx context.Context, cfg Config, client Client, key *keys.PrivateKey) (*Admin, error) {
+	var owner user.ID
+	user.IDFromKey(&owner, key.PrivateKey.PublicKey)
+
+	cnrID, err := getContainerID(ctx, client, owner, cfg.Container)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve container id: %w", err)
+	}
+
+	cnr, err := client.GetContainer(ctx, cnrID)
+	if err != nil {
+		return nil, fmt.Errorf("get container: %w", err)
+	}
+
+	cnrOwner := cnr.Owner()
+	if !cnrOwner.Equals(owner) {
+		return nil, fmt.Errorf("container %v is not owned by the wallet", cnrID)
+	}
+
+	return &Admin{
+		client:   client,
+		key:      key,
+		cnrID:    cnrID,
+		basicACL: cnr.BasicACL(),
+	}, nil
+}
+
+// Close releases the connections to the network.
+func (a *Admin) Close() {
+	a.client.Close()
+}
+
+// ContainerID returns the identifier of the container.
+func (a *Admin) ContainerID() cid.ID {
+	return a.cnrID
+}
+
+// EACL returns the extended ACL of the container, which is empty if none
+// was set.
+func (a *Admin) EACL(ctx context.Context) (eacl.Table, error) {
+	table, err := a.client.GetEACL(ctx, a.cnrID)
+	switch err.(type) {
+	case nil:
+		return table, nil
+	case apistatus.EACLNotFound, *apistatus.EACLNotFound:
+		table = *eacl.NewTable()
+		table.SetCID(a.cnrID)
+		return table, nil
+	default:
+		return eacl.Table{}, fmt.Errorf("get extended ACL: %w", err)
+	}
+}
+
+// GrantReadOnly allows the owner of key to read the repository.
+func (a *Admin) GrantReadOnly(ctx context.Context, key *keys.PublicKey) error {
+	if !a.basicACL.Extendable() {
+		return fmt.Errorf("basic ACL %s of container %v cannot be extended", a.basicACL.EncodeToString(), a.cnrID)
+	}
+
+	table, err := a.EACL(ctx)
+	if err != nil {
+		return err
+	}
+
+	records, _ := withoutReadOnlyRecords(table.Records(), key)
+
+	// the first matching record applies, so the access is granted before
+	// any other records
+	res := eacl.NewTable()
+	res.SetCID(a.cnrID)
+	for _, op := range readOnlyOperations {
+		res.AddRecord(readOnlyRecord(op, key))
+	}
+	for i := range records {
+		res.AddRecord(&records[i])
+	}
+
+	if err = a.client.SetEACL(ctx, *res); err != nil {
+		return fmt.Errorf("set extended ACL: %w", err)
+	}
+	return nil
+}
+
+// RevokeReadOnly removes the access granted by GrantReadOnly.
+func (a *Admin) RevokeReadOnly(ctx context.Context, key *keys.PublicKey) error {
+	table, err := a.EACL(ctx)
+	if err != nil {
+		return err
+	}
+
+	records, found := withoutReadOnlyRecords(table.Records(), key)
+	if !found {
+		return fmt.Errorf("no read-only access granted to key %s", key.StringCompressed())
+	}
+
+	res := eacl.NewTable()
+	res.SetCID(a.cnrID)
+	for i := range records {
+		res.AddRecord(&records[i])
+	}
+
+	if err = a.client.SetEACL(ctx, *res); err != nil {
+		return fmt.Errorf("set extended ACL: %w", err)
+	}
+	return nil
+}
+
+// ReadOnlyBearerToken returns a bearer token which allows the owner of key
+// to read the repository for the given number of epochs.
+func (a *Admin) ReadOnlyBearerToken(ctx context.Context, key *keys.PublicKey, lifetime uint64) (bearer.Token, error) {
+	epoch, err := a.client.CurrentEpoch(ctx)
+	if err != nil {
+		return bearer.Token{}, err
+	}
+
+	table := eacl.NewTable()
+	table.SetCID(a.cnrID)
+	for _, op := range []eacl.Operation{
+		eacl.OperationGet,
+		eacl.OperationHead,
+		eacl.OperationPut,
+		eacl.OperationDelete,
+		eacl.OperationSearch,
+		eacl.OperationRange,
+		eacl.OperationRangeHash,
+	} {
+		action := eacl.ActionDeny
+		for _, readOp := range readOnlyOperations {
+			if op == readOp {
+				action = eacl.ActionAllow
+			}
+		}
+
+		rec := eacl.NewRecord()
+		rec.SetOperation(op)
+		rec.SetAction(action)
+		eacl.AddFormedTarget(rec, eacl.RoleOthers)
+		table.AddRecord(rec)
+	}
+
+	var usr user.ID
+	user.IDFromKey(&usr, ecdsaKey(key))
+
+	var tok bearer.Token
+	tok.SetEACLTable(*table)
+	tok.ForUser(usr)
+	tok.SetIat(epoch)
+	tok.SetNbf(epoch)
+	tok.SetExp(epoch + lifetime)
+
+	if err = tok.Sign(a.key.PrivateKey); err != nil {
+		return bearer.Token{}, fmt.Errorf("sign bearer token: %w", err)
+	}
+	return tok, nil
+}
+
+// readOnlyRecord returns the record allowing the owner of key op.
+func readOnlyRecord(op eacl.Operation, key *keys.PublicKey) *eacl.Record {
+	rec := eacl.NewRecord()
+	rec.SetOperation(op)
+	rec.SetAction(eacl.ActionAllow)
+	eacl.AddFormedTarget(rec, eacl.RoleUnknown, ecdsaKey(key))
+	return rec
+}
+
+// withoutReadOnlyRecords returns the records without the ones added by
+// GrantReadOnly for key, and whether there were any.
+func withoutReadOnlyRecords(records []eacl.Record, key *keys.PublicKey) ([]eacl.Record, bool) {
+	res := make([]eacl.Record, 0, len(records))
+	found := false
+	for _, rec := range records {
+		if isReadOnlyRecord(rec, key) {
+			found = true
+			continue
+		}
+		res = append(res, rec)
+	}
+	return res, found
+}
+
+func isReadOnlyRecord(rec eacl.Record, key *keys.PublicKey) bool {
+	if rec.Action() != eacl.ActionAllow || len(rec.Filters()) != 0 || len(rec.Targets()) != 1 {
+		return false
+	}
+
+	readOp := false
+	for _, op := range readOnlyOperations {
+		readOp = readOp || rec.Operation() == op
+	}
+	if !readOp {
+		return false
+	}
+
+	target := rec.Targets()[0]
+	binKeys := target.BinaryKeys()
+	return target.Role() == eacl.RoleUnknown && len(binKeys) == 1 && bytes.Equal(binKeys[0], key.Bytes())
+}
+
+func ecdsaKey(key *keys.PublicKey) ecdsa.PublicKey {
+	return ecdsa.PublicKey(*key)
+}
@@ -0,0 +1,103 @@
+package neofs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nspcc-dev/neo-go/pkg/crypto/keys"
+	"github.com/nspcc-dev/neofs-sdk-go/bearer"
+	"github.com/nspcc-dev/neofs-sdk-go/eacl"
+	"github.com/nspcc-dev/neofs-sdk-go/user"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdmin(t *testing.T) {
+	ctx := context.TODO()
+
+	key, err := keys.NewPrivateKey()
+	require.NoError(t, err)
+	readerKey, err := keys.NewPrivateKey()
+	require.NoError(t, err)
+	reader := readerKey.PublicKey()
+
+	var owner user.ID
+	user.IDFromKey(&owner, key.PrivateKey.PublicKey)
+
+	client := newMemClient()
+	cfg := NewConfig()
+	cfg.Container = "container"
+	cfg.BasicACL = "eacl-private"
+	require.NoError(t, createContainer(ctx, client, owner, cfg))
+
+	// only the owner of the container can manage the access
+	_, err = newAdmin(ctx, cfg, client, readerKey)
+	require.Error(t, err)
+
+	admin, err := newAdmin(ctx, cfg, client, key)
+	require.NoError(t, err)
+
+	table, err := admin.EACL(ctx)
+	require.NoError(t, err)
+	require.Empty(t, table.Records())
+
+	// the records set by other means are kept
+	denyPut := eacl.NewRecord()
+	denyPut.SetOperation(eacl.OperationPut)
+	denyPut.SetAction(eacl.ActionDeny)
+	eacl.AddFormedTarget(denyPut, eacl.RoleOthers)
+	table.AddRecord(denyPut)
+	require.NoError(t, client.SetEACL(ctx, table))
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, admin.GrantReadOnly(ctx, reader))
+
+		table, err = admin.EACL(ctx)
+		require.NoError(t, err)
+		records := table.Records()
+		require.Len(t, records, len(readOnlyOperations)+1)
+		for j, op := range readOnlyOperations {
+			require.True(t, isReadOnlyRecord(records[j], reader))
+			require.Equal(t, op, records[j].Operation())
+		}
+		require.Equal(t, eacl.OperationPut, records[len(records)-1].Operation())
+	}
+
+	require.NoError(t, admin.RevokeReadOnly(ctx, reader))
+	table, err = admin.EACL(ctx)
+	require.NoError(t, err)
+	require.Len(t, table.Records(), 1)
+	require.Error(t, admin.RevokeReadOnly(ctx, reader))
+
+	client.tick(3)
+	tok, err := admin.ReadOnlyBearerToken(ctx, reader, 10)
+	require.NoError(t, err)
+	require.True(t, tok.VerifySignature())
+	require.Equal(t, owner, bearer.ResolveIssuer(tok))
+	require.False(t, tok.InvalidAt(13))
+	require.True(t, tok.InvalidAt(14))
+
+	granted := tokens{bearer: &tok}
+	require.NoError(t, granted.checkKey(readerKey))
+	require.Error(t, granted.checkKey(key))
+
+	tokTable := tok.EACLTable()
+	tokCnrID, ok := tokTable.CID()
+	require.True(t, ok)
+	require.Equal(t, admin.ContainerID(), tokCnrID)
+	for _, rec := range tokTable.Records() {
+		allowed := rec.Action() == eacl.ActionAllow
+		readOp := false
+		for _, op := range readOnlyOperations {
+			readOp = readOp || rec.Operation() == op
+		}
+		require.Equal(t, readOp, allowed, "operation %v", rec.Operation())
+	}
+
+	// the access cannot be granted if the basic ACL is final
+	cfg.Container = "private"
+	cfg.BasicACL = "private"
+	require.NoError(t, createContainer(ctx, client, owner, cfg))
+	admin, err = newAdmin(ctx, cfg, client, key)
+	require.NoError(t, err)
+	require.Error(t, admin.GrantReadOnly(ctx, reader))
+}
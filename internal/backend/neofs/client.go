@@ -0,0 +1,243 @@
+package neofs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nspcc-dev/neo-go/pkg/crypto/keys"
+	"github.com/nspcc-dev/neofs-sdk-go/bearer"
+	"github.com/nspcc-dev/neofs-sdk-go/container"
+	cid "github.com/nspcc-dev/neofs-sdk-go/container/id"
+	"github.com/nspcc-dev/neofs-sdk-go/eacl"
+	"github.com/nspcc-dev/neofs-sdk-go/object"
+	oid "github.com/nspcc-dev/neofs-sdk-go/object/id"
+	"github.com/nspcc-dev/neofs-sdk-go/pool"
+	"github.com/nspcc-dev/neofs-sdk-go/session"
+	"github.com/nspcc-dev/neofs-sdk-go/user"
+)
+
+// Client is the set of neofs operations the backend relies on. It is
+// implemented by poolClient on top of pool.Pool and by an in-memory
+// stand-in in the tests.
+type Client interface {
+	// SearchObjects calls fn for every object in the container that matches
+	// the filters. The iteration stops as soon as fn returns true.
+	SearchObjects(ctx context.Context, cnrID cid.ID, filters object.SearchFilters, fn func(oid.ID) bool) error
+	// HeadObject returns the header of the object without its payload.
+	HeadObject(ctx context.Context, addr oid.Address) (object.Object, error)
+	// PutObject stores the object with the given header and payload.
+	PutObject(ctx context.Context, hdr object.Object, payload io.Reader) (oid.ID, error)
+	// ObjectRange returns a reader for length bytes of the object payload
+	// starting at offset.
+	ObjectRange(ctx context.Context, addr oid.Address, offset, length uint64) (io.ReadCloser, error)
+	// DeleteObject removes the object.
+	DeleteObject(ctx context.Context, addr oid.Address) error
+
+	// ListContainers returns the identifiers of all containers of the owner.
+	ListContainers(ctx context.Context, owner user.ID) ([]cid.ID, error)
+	// PutContainer creates the container and waits until it is visible.
+	PutContainer(ctx context.Context, cnr container.Container) (cid.ID, error)
+	// GetContainer returns the container.
+	GetContainer(ctx context.Context, cnrID cid.ID) (container.Container, error)
+	// DeleteContainer removes the container.
+	DeleteContainer(ctx context.Context, cnrID cid.ID) error
+	// GetEACL returns the extended ACL of the container.
+	GetEACL(ctx context.Context, cnrID cid.ID) (eacl.Table, error)
+	// SetEACL replaces the extended ACL of the container the table refers
+	// to and waits until the change is visible.
+	SetEACL(ctx context.Context, table eacl.Table) error
+
+	// CurrentEpoch returns the current epoch of the network.
+	CurrentEpoch(ctx context.Context) (uint64, error)
+	// EpochDuration returns the approximate duration of an epoch.
+	EpochDuration(ctx context.Context) (time.Duration, error)
+
+	// Close releases all resources held by the client.
+	Close()
+}
+
+// poolClient implements Client using a neofs connection pool. The tokens are
+// attached to all object requests.
+type poolClient struct {
+	pool       *pool.Pool
+	waitParams pool.WaitParams
+	tokens     tokens
+	// key is the key of the pool, signer is the user it belongs to.
+	key    *keys.PrivateKey
+	signer user.ID
+}
+
+// tokenPrm is implemented by the parameters of all object requests.
+type tokenPrm interface {
+	UseBearer(bearer.Token)
+	UseSession(session.Object)
+}
+
+// useTokens attaches the tokens to the parameters of a request. The session
+// token is attached only if it covers the verb, since the nodes reject it
+// otherwise.
+func (c *poolClient) useTokens(prm tokenPrm, verb session.ObjectVerb) {
+	if c.tokens.bearer != nil {
+		prm.UseBearer(*c.tokens.bearer)
+	}
+	if c.tokens.session != nil && c.tokens.session.AssertVerb(verb) {
+		prm.UseSession(*c.tokens.session)
+	}
+}
+
+// tokenError explains err if the request was denied because of an expired
+// token. The current epoch is requested only in that case.
+func (c *poolClient) tokenError(ctx context.Context, err error) error {
+	if err == nil || c.tokens.empty() || classifyStatus(err) != statusAccessDenied {
+		return err
+	}
+
+	epoch, epochErr := c.CurrentEpoch(ctx)
+	if epochErr != nil {
+		return err
+	}
+
+	if tokErr := c.tokens.check(epoch); tokErr != nil {
+		return fmt.Errorf("%v: %w", tokErr, err)
+	}
+	return err
+}
+
+var _ Client = &poolClient{}
+
+func (c *poolClient) SearchObjects(ctx context.Context, cnrID cid.ID, filters object.SearchFilters, fn func(oid.ID) bool) error {
+	var prm pool.PrmObjectSearch
+	prm.SetContainerID(cnrID)
+	prm.SetFilters(filters)
+	c.useTokens(&prm, session.VerbObjectSearch)
+
+	res, err := c.pool.SearchObjects(ctx, prm)
+	if err != nil {
+		return fmt.Errorf("search objects: %w", c.tokenError(ctx, err))
+	}
+
+	defer res.Close()
+
+	if err = res.Iterate(fn); err != nil {
+		return fmt.Errorf("iterate objects: %w", c.tokenError(ctx, err))
+	}
+
+	return nil
+}
+
+func (c *poolClient) HeadObject(ctx context.Context, addr oid.Address) (object.Object, error) {
+	var prm pool.PrmObjectHead
+	prm.SetAddress(addr)
+	c.useTokens(&prm, session.VerbObjectHead)
+
+	obj, err := c.pool.HeadObject(ctx, prm)
+	return obj, c.tokenError(ctx, err)
+}
+
+func (c *poolClient) PutObject(ctx context.Context, hdr object.Object, payload io.Reader) (oid.ID, error) {
+	if c.tokens.session == nil {
+		// without a session token the object must be owned by the user
+		// signing it, which differs from the container owner when a
+		// bearer token is used
+		hdr.SetOwnerID(&c.signer)
+	}
+
+	var prm pool.PrmObjectPut
+	prm.SetHeader(hdr)
+	prm.SetPayload(payload)
+	c.useTokens(&prm, session.VerbObjectPut)
+
+	id, err := c.pool.PutObject(ctx, prm)
+	return id, c.tokenError(ctx, err)
+}
+
+func (c *poolClient) ObjectRange(ctx context.Context, addr oid.Address, offset, length uint64) (io.ReadCloser, error) {
+	var prm pool.PrmObjectRange
+	prm.SetAddress(addr)
+	prm.SetOffset(offset)
+	prm.SetLength(length)
+	c.useTokens(&prm, session.VerbObjectRange)
+
+	res, err := c.pool.ObjectRange(ctx, prm)
+	if err != nil {
+		return nil, c.tokenError(ctx, err)
+	}
+
+	return &res, nil
+}
+
+func (c *poolClient) DeleteObject(ctx context.Context, addr oid.Address) error {
+	var prm pool.PrmObjectDelete
+	prm.SetAddress(addr)
+	c.useTokens(&prm, session.VerbObjectDelete)
+
+	return c.tokenError(ctx, c.pool.DeleteObject(ctx, prm))
+}
+
+func (c *poolClient) ListContainers(ctx context.Context, owner user.ID) ([]cid.ID, error) {
+	var prm pool.PrmContainerList
+	prm.SetOwnerID(owner)
+
+	return c.pool.ListContainers(ctx, prm)
+}
+
+func (c *poolClient) PutContainer(ctx context.Context, cnr container.Container) (cid.ID, error) {
+	var prm pool.PrmContainerPut
+	prm.SetContainer(cnr)
+	prm.SetWaitParams(c.waitParams)
+
+	return c.pool.PutContainer(ctx, prm)
+}
+
+func (c *poolClient) GetContainer(ctx context.Context, cnrID cid.ID) (container.Container, error) {
+	var prm pool.PrmContainerGet
+	prm.SetContainerID(cnrID)
+
+	return c.pool.GetContainer(ctx, prm)
+}
+
+func (c *poolClient) DeleteContainer(ctx context.Context, cnrID cid.ID) error {
+	var prm pool.PrmContainerDelete
+	prm.SetContainerID(cnrID)
+
+	return c.pool.DeleteContainer(ctx, prm)
+}
+
+func (c *poolClient) GetEACL(ctx context.Context, cnrID cid.ID) (eacl.Table, error) {
+	var prm pool.PrmContainerEACL
+	prm.SetContainerID(cnrID)
+
+	return c.pool.GetEACL(ctx, prm)
+}
+
+func (c *poolClient) SetEACL(ctx context.Context, table eacl.Table) error {
+	var prm pool.PrmContainerSetEACL
+	prm.SetTable(table)
+	prm.SetWaitParams(c.waitParams)
+
+	return c.pool.SetEACL(ctx, prm)
+}
+
+func (c *poolClient) CurrentEpoch(ctx context.Context) (uint64, error) {
+	ni, err := c.pool.NetworkInfo(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("network info: %w", err)
+	}
+
+	return ni.CurrentEpoch(), nil
+}
+
+func (c *poolClient) EpochDuration(ctx context.Context) (time.Duration, error) {
+	ni, err := c.pool.NetworkInfo(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("network info: %w", err)
+	}
+
+	return time.Duration(ni.EpochDuration()) * time.Duration(ni.MsPerBlock()) * time.Millisecond, nil
+}
+
+func (c *poolClient) Close() {
+	c.pool.Close()
+}
@@ -2,6 +2,7 @@ package neofs
 
 import (
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,22 +12,35 @@ import (
 
 // Config contains all configuration necessary to connect to neofs.
 type Config struct {
-	Endpoint  string
+	Endpoint  string `option:"endpoint" help:"comma-separated list of endpoints, each may set ?priority=N&weight=W (overrides the location)"`
 	Container string
+	Prefix    string
 
 	Wallet            string        `option:"wallet" help:"path to the wallet"`
 	Address           string        `option:"address" help:"address of account (can be empty)"`
 	Password          string        `option:"password" help:"password to decrypt wallet"`
+	BearerToken       string        `option:"bearer-token" help:"path to a bearer token issued by the container owner, a random key is used if no wallet is given"`
+	SessionToken      string        `option:"session-token" help:"path to an object session token issued by the container owner to the wallet key"`
 	Timeout           time.Duration `option:"timeout" help:"timeout to connect and request (default 10s)"`
 	RebalanceInterval time.Duration `option:"rebalance" help:"interval between checking node healthy (default 15s)"`
 
 	Connections uint `option:"connections" help:"set a limit for the number of concurrent connections (default: 5)"`
+
+	Policy   string `option:"policy" help:"placement policy of the container created by init (default: REP 3)"`
+	BasicACL string `option:"basic-acl" help:"basic ACL of the container created by init (default: private)"`
+
+	ObjectCache bool `option:"object-cache" help:"persist the mapping of file names to object IDs in the cache directory"`
+	// CacheDir is the directory the object cache is persisted in, the
+	// default cache directory is used if it is empty.
+	CacheDir string
 }
 
 // NewConfig returns a new Config with the default values filled in.
 func NewConfig() Config {
 	return Config{
 		Connections: 5,
+		Policy:      "REP 3",
+		BasicACL:    "private",
 	}
 }
 
@@ -35,8 +49,13 @@ func init() {
 }
 
 // ParseConfig parses the string s and extracts the neofs config.
-// The configuration format is neofs:grpcs://s01.neofs.devenv:8080/container,
-// where 'container' is container name or container id.
+// The configuration format is neofs:grpcs://s01.neofs.devenv:8080/container[/prefix],
+// where 'container' is container name or container id. Several repositories
+// can share a container by using different prefixes. Several endpoints
+// can be given as a comma-separated list, the container follows the last
+// one: neofs:grpcs://s01.neofs.devenv:8080,grpcs://s02.neofs.devenv:8080/container.
+// Each endpoint may set its priority and weight in the pool using query
+// parameters, e.g. grpcs://s01.neofs.devenv:8080?priority=2&weight=3.
 func ParseConfig(s string) (interface{}, error) {
 	if !strings.HasPrefix(s, "neofs:") {
 		return nil, errors.New("neofs: invalid format")
@@ -44,13 +63,94 @@ func ParseConfig(s string) (interface{}, error) {
 
 	// strip prefix "neofs:"
 	s = s[6:]
-	u, err := url.Parse(s)
+	endpoints := strings.Split(s, ",")
+	u, err := url.Parse(endpoints[len(endpoints)-1])
 	if err != nil {
 		return nil, errors.Wrap(err, "url.Parse")
 	}
 
 	cfg := NewConfig()
-	cfg.Container = strings.TrimPrefix(u.Path, "/")
-	cfg.Endpoint = strings.TrimSuffix(s, u.Path)
+	p := strings.Trim(u.Path, "/")
+	if i := strings.Index(p, "/"); i >= 0 {
+		cfg.Container = p[:i]
+		cfg.Prefix = strings.Trim(p[i+1:], "/")
+	} else {
+		cfg.Container = p
+	}
+
+	u.Path = ""
+	u.RawPath = ""
+	endpoints[len(endpoints)-1] = u.String()
+	cfg.Endpoint = strings.Join(endpoints, ",")
+
+	if _, err = parseEndpoints(cfg.Endpoint); err != nil {
+		return nil, err
+	}
+
 	return cfg, nil
 }
+
+// endpoint is a storage node the pool connects to. Nodes with a lower
+// priority value are used first, the weight distributes the requests
+// between nodes with the same priority.
+type endpoint struct {
+	address  string
+	priority int
+	weight   float64
+}
+
+// parseEndpoints parses a comma-separated list of endpoints, each of them
+// may set its priority and weight in query parameters.
+func parseEndpoints(s string) ([]endpoint, error) {
+	var res []endpoint
+	for _, spec := range strings.Split(s, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		ep := endpoint{address: spec, priority: 1, weight: 1}
+
+		if i := strings.Index(spec, "?"); i >= 0 {
+			ep.address = spec[:i]
+			params, err := url.ParseQuery(spec[i+1:])
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid parameters for endpoint %q", ep.address)
+			}
+
+			for key, values := range params {
+				value := values[len(values)-1]
+				switch key {
+				case "priority":
+					ep.priority, err = strconv.Atoi(value)
+					if err == nil && ep.priority <= 0 {
+						err = errors.New("must be positive")
+					}
+				case "weight":
+					ep.weight, err = strconv.ParseFloat(value, 64)
+					if err == nil && ep.weight <= 0 {
+						err = errors.New("must be positive")
+					}
+				default:
+					err = errors.New("unknown parameter")
+				}
+
+				if err != nil {
+					return nil, errors.Wrapf(err, "invalid %v %q for endpoint %q", key, value, ep.address)
+				}
+			}
+		}
+
+		if ep.address == "" {
+			return nil, errors.Errorf("empty address in endpoint %q", spec)
+		}
+
+		res = append(res, ep)
+	}
+
+	if len(res) == 0 {
+		return nil, errors.New("no endpoint given")
+	}
+
+	return res, nil
+}
@@ -1,6 +1,9 @@
 package neofs
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func TestParseConfig(t *testing.T) {
 	for i, test := range []struct {
@@ -11,6 +14,30 @@ func TestParseConfig(t *testing.T) {
 			Endpoint:    "grpcs://s01.neofs.devenv:8080",
 			Container:   "container-name",
 			Connections: 5,
+			Policy:      "REP 3",
+			BasicACL:    "private",
+		}},
+		{"neofs:grpcs://s01.neofs.devenv:8080/container-name/some/prefix/", Config{
+			Endpoint:    "grpcs://s01.neofs.devenv:8080",
+			Container:   "container-name",
+			Prefix:      "some/prefix",
+			Connections: 5,
+			Policy:      "REP 3",
+			BasicACL:    "private",
+		}},
+		{"neofs:grpcs://s01.neofs.devenv:8080,grpcs://s02.neofs.devenv:8080/container-name", Config{
+			Endpoint:    "grpcs://s01.neofs.devenv:8080,grpcs://s02.neofs.devenv:8080",
+			Container:   "container-name",
+			Connections: 5,
+			Policy:      "REP 3",
+			BasicACL:    "private",
+		}},
+		{"neofs:grpcs://s01.neofs.devenv:8080?priority=1&weight=2,grpcs://s02.neofs.devenv:8080/container-name?priority=2", Config{
+			Endpoint:    "grpcs://s01.neofs.devenv:8080?priority=1&weight=2,grpcs://s02.neofs.devenv:8080?priority=2",
+			Container:   "container-name",
+			Connections: 5,
+			Policy:      "REP 3",
+			BasicACL:    "private",
 		}},
 	} {
 		cfg, err := ParseConfig(test.s)
@@ -26,3 +53,51 @@ func TestParseConfig(t *testing.T) {
 		}
 	}
 }
+
+func TestParseConfigInvalid(t *testing.T) {
+	for _, s := range []string{
+		"neofs:grpcs://s01.neofs.devenv:8080?priority=0/container-name",
+		"neofs:grpcs://s01.neofs.devenv:8080?weight=x,grpcs://s02.neofs.devenv:8080/container-name",
+		"neofs:grpcs://s01.neofs.devenv:8080?foo=bar,grpcs://s02.neofs.devenv:8080/container-name",
+		"neofs:?priority=1,grpcs://s02.neofs.devenv:8080/container-name",
+	} {
+		_, err := ParseConfig(s)
+		if err == nil {
+			t.Errorf("no error for invalid location %q", s)
+		}
+	}
+}
+
+func TestParseEndpoints(t *testing.T) {
+	for i, test := range []struct {
+		s         string
+		endpoints []endpoint
+	}{
+		{"localhost:8080", []endpoint{
+			{address: "localhost:8080", priority: 1, weight: 1},
+		}},
+		{"grpcs://s01.neofs.devenv:8080?priority=2&weight=0.5, grpcs://s02.neofs.devenv:8080?weight=3", []endpoint{
+			{address: "grpcs://s01.neofs.devenv:8080", priority: 2, weight: 0.5},
+			{address: "grpcs://s02.neofs.devenv:8080", priority: 1, weight: 3},
+		}},
+		{"grpcs://s01.neofs.devenv:8080,,grpcs://s02.neofs.devenv:8080?priority=3", []endpoint{
+			{address: "grpcs://s01.neofs.devenv:8080", priority: 1, weight: 1},
+			{address: "grpcs://s02.neofs.devenv:8080", priority: 3, weight: 1},
+		}},
+	} {
+		endpoints, err := parseEndpoints(test.s)
+		if err != nil {
+			t.Errorf("test %d:%s failed: %v", i, test.s, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(endpoints, test.endpoints) {
+			t.Errorf("test %d:\ninput:\n  %s\n wrong endpoints, want:\n  %v\ngot:\n  %v",
+				i, test.s, test.endpoints, endpoints)
+		}
+	}
+
+	if _, err := parseEndpoints(" , "); err == nil {
+		t.Errorf("no error for empty list of endpoints")
+	}
+}
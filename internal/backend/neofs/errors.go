@@ -0,0 +1,66 @@
+package neofs
+
+import (
+	"errors"
+
+	"github.com/cenkalti/backoff/v4"
+	apistatus "github.com/nspcc-dev/neofs-sdk-go/client/status"
+)
+
+// errFileNotFound is returned if no object stores the requested file.
+var errFileNotFound = errors.New("file not found")
+
+// statusClass groups the neofs statuses by the way the backend handles them.
+type statusClass int
+
+const (
+	// statusOther covers all errors not listed below, including transport
+	// errors. The requests are retried.
+	statusOther statusClass = iota
+	// statusNotFound is returned for missing or removed objects.
+	statusNotFound
+	// statusAccessDenied is returned if the request was rejected because of
+	// ACL rules or invalid tokens.
+	statusAccessDenied
+	// statusInvalid is returned for requests the network never accepts.
+	statusInvalid
+)
+
+// classifyStatus returns the class of the neofs status in the chain of err.
+// The SDK returns statuses by value or by reference, both are handled.
+func classifyStatus(err error) statusClass {
+	for ; err != nil; err = errors.Unwrap(err) {
+		switch err.(type) {
+		case apistatus.ObjectNotFound, *apistatus.ObjectNotFound,
+			apistatus.ObjectAlreadyRemoved, *apistatus.ObjectAlreadyRemoved:
+			return statusNotFound
+		case apistatus.ObjectAccessDenied, *apistatus.ObjectAccessDenied,
+			apistatus.SessionTokenExpired, *apistatus.SessionTokenExpired,
+			apistatus.SessionTokenNotFound, *apistatus.SessionTokenNotFound:
+			return statusAccessDenied
+		case apistatus.ContainerNotFound, *apistatus.ContainerNotFound,
+			apistatus.ObjectOutOfRange, *apistatus.ObjectOutOfRange,
+			apistatus.ObjectLocked, *apistatus.ObjectLocked,
+			apistatus.LockNonRegularObject, *apistatus.LockNonRegularObject,
+			apistatus.SignatureVerification, *apistatus.SignatureVerification,
+			apistatus.WrongMagicNumber, *apistatus.WrongMagicNumber:
+			return statusInvalid
+		}
+	}
+	return statusOther
+}
+
+// isNotFound returns true if err reports a missing file or object.
+func isNotFound(err error) bool {
+	return errors.Is(err, errFileNotFound) || classifyStatus(err) == statusNotFound
+}
+
+// permanentError marks err as permanent if retrying the request cannot
+// succeed, so that RetryBackend gives up immediately.
+func permanentError(err error) error {
+	switch classifyStatus(err) {
+	case statusAccessDenied, statusInvalid:
+		return backoff.Permanent(err)
+	}
+	return err
+}
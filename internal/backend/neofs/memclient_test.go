@@ -0,0 +1,437 @@
+package neofs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nspcc-dev/neofs-sdk-go/checksum"
+	apistatus "github.com/nspcc-dev/neofs-sdk-go/client/status"
+	"github.com/nspcc-dev/neofs-sdk-go/container"
+	cid "github.com/nspcc-dev/neofs-sdk-go/container/id"
+	"github.com/nspcc-dev/neofs-sdk-go/eacl"
+	"github.com/nspcc-dev/neofs-sdk-go/object"
+	oid "github.com/nspcc-dev/neofs-sdk-go/object/id"
+	"github.com/nspcc-dev/neofs-sdk-go/user"
+)
+
+// rootFilterKey is the header the root filter is matched against.
+var rootFilterKey = func() string {
+	filters := object.NewSearchFilters()
+	filters.AddRootFilter()
+	return filters[0].Header()
+}()
+
+type memContainer struct {
+	cnr     container.Container
+	eacl    *eacl.Table
+	objects map[string]*object.Object
+}
+
+// memClient is an in-memory stand-in for a neofs network. It implements the
+// semantics the backend relies on: attribute and root search filters, ranges
+// and status errors for missing objects and containers. Its epoch clock only
+// advances when tick is called, expired objects are removed then.
+type memClient struct {
+	m             sync.Mutex
+	containers    map[string]*memContainer
+	nonce         uint64
+	epoch         uint64
+	epochDuration time.Duration
+}
+
+var _ Client = &memClient{}
+
+func newMemClient() *memClient {
+	return &memClient{
+		containers:    make(map[string]*memContainer),
+		epochDuration: time.Minute,
+	}
+}
+
+// tick advances the epoch clock and removes the expired objects.
+func (c *memClient) tick(epochs uint64) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.epoch += epochs
+	for _, cnr := range c.containers {
+		for key, obj := range cnr.objects {
+			val, ok := attributeValue(obj, attrExpirationEpoch)
+			if !ok {
+				continue
+			}
+
+			exp, err := strconv.ParseUint(val, 10, 64)
+			if err == nil && exp < c.epoch {
+				delete(cnr.objects, key)
+			}
+		}
+	}
+}
+
+// nextSHA256 returns a unique value used for object and container IDs.
+func (c *memClient) nextSHA256(data []byte) [sha256.Size]byte {
+	var buf [8]byte
+	c.nonce++
+	binary.BigEndian.PutUint64(buf[:], c.nonce)
+	return sha256.Sum256(append(buf[:], data...))
+}
+
+// putContainer creates a container with the given name.
+func (c *memClient) putContainer(owner user.ID, name string) cid.ID {
+	var cnr container.Container
+	cnr.Init()
+	cnr.SetOwner(owner)
+	container.SetName(&cnr, name)
+	container.SetCreationTime(&cnr, time.Now())
+
+	cnrID, err := c.PutContainer(context.Background(), cnr)
+	if err != nil {
+		panic(err)
+	}
+	return cnrID
+}
+
+func (c *memClient) container(cnrID cid.ID) (*memContainer, error) {
+	cnr, ok := c.containers[cnrID.EncodeToString()]
+	if !ok {
+		return nil, apistatus.ContainerNotFound{}
+	}
+	return cnr, nil
+}
+
+func (c *memClient) object(addr oid.Address) (*object.Object, error) {
+	cnr, err := c.container(addr.Container())
+	if err != nil {
+		return nil, err
+	}
+
+	obj, ok := cnr.objects[addr.Object().EncodeToString()]
+	if !ok {
+		return nil, apistatus.ObjectNotFound{}
+	}
+	return obj, nil
+}
+
+// cloneObject returns a deep copy of obj, so that the stored objects are
+// never shared with the callers.
+func cloneObject(obj *object.Object) *object.Object {
+	data, err := obj.Marshal()
+	if err != nil {
+		panic(err)
+	}
+
+	res := object.New()
+	if err = res.Unmarshal(data); err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func attributeValue(obj *object.Object, key string) (string, bool) {
+	for _, attr := range obj.Attributes() {
+		if attr.Key() == key {
+			return attr.Value(), true
+		}
+	}
+	return "", false
+}
+
+func matchFilters(obj *object.Object, filters object.SearchFilters) bool {
+	for i := range filters {
+		f := &filters[i]
+		if f.Header() == rootFilterKey {
+			// payloads are never split into child objects here, so
+			// every stored object is a root object
+			continue
+		}
+
+		val, ok := attributeValue(obj, f.Header())
+		switch f.Operation() {
+		case object.MatchStringEqual:
+			if !ok || val != f.Value() {
+				return false
+			}
+		case object.MatchStringNotEqual:
+			if !ok || val == f.Value() {
+				return false
+			}
+		case object.MatchCommonPrefix:
+			if !ok || !strings.HasPrefix(val, f.Value()) {
+				return false
+			}
+		case object.MatchNotPresent:
+			if ok {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (c *memClient) SearchObjects(ctx context.Context, cnrID cid.ID, filters object.SearchFilters, fn func(oid.ID) bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.m.Lock()
+	cnr, err := c.container(cnrID)
+	if err != nil {
+		c.m.Unlock()
+		return err
+	}
+
+	var ids []oid.ID
+	for _, obj := range cnr.objects {
+		if matchFilters(obj, filters) {
+			id, _ := obj.ID()
+			ids = append(ids, id)
+		}
+	}
+	c.m.Unlock()
+
+	for _, id := range ids {
+		if fn(id) {
+			break
+		}
+	}
+	return nil
+}
+
+func (c *memClient) HeadObject(ctx context.Context, addr oid.Address) (object.Object, error) {
+	if err := ctx.Err(); err != nil {
+		return object.Object{}, err
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	obj, err := c.object(addr)
+	if err != nil {
+		return object.Object{}, err
+	}
+
+	hdr := cloneObject(obj)
+	hdr.SetPayload(nil)
+	return *hdr, nil
+}
+
+func (c *memClient) PutObject(ctx context.Context, hdr object.Object, payload io.Reader) (oid.ID, error) {
+	data, err := io.ReadAll(payload)
+	if err != nil {
+		return oid.ID{}, err
+	}
+
+	if err = ctx.Err(); err != nil {
+		return oid.ID{}, err
+	}
+
+	obj := cloneObject(&hdr)
+	obj.SetPayload(data)
+	obj.SetPayloadSize(uint64(len(data)))
+
+	var cs checksum.Checksum
+	cs.SetSHA256(sha256.Sum256(data))
+	obj.SetPayloadChecksum(cs)
+
+	cnrID, _ := obj.ContainerID()
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	cnr, err := c.container(cnrID)
+	if err != nil {
+		return oid.ID{}, err
+	}
+
+	var id oid.ID
+	id.SetSHA256(c.nextSHA256(data))
+	obj.SetID(id)
+
+	cnr.objects[id.EncodeToString()] = obj
+	return id, nil
+}
+
+func (c *memClient) ObjectRange(ctx context.Context, addr oid.Address, offset, length uint64) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	obj, err := c.object(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := obj.Payload()
+	if length == 0 || offset+length > uint64(len(payload)) {
+		return nil, apistatus.ObjectOutOfRange{}
+	}
+
+	data := make([]byte, length)
+	copy(data, payload[offset:offset+length])
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (c *memClient) DeleteObject(ctx context.Context, addr oid.Address) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	cnr, err := c.container(addr.Container())
+	if err != nil {
+		return err
+	}
+
+	// like neofs, removing a missing object just places a tombstone
+	delete(cnr.objects, addr.Object().EncodeToString())
+	return nil
+}
+
+func (c *memClient) ListContainers(ctx context.Context, owner user.ID) ([]cid.ID, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	var res []cid.ID
+	for key, cnr := range c.containers {
+		cnrOwner := cnr.cnr.Owner()
+		if cnrOwner.EncodeToString() != owner.EncodeToString() {
+			continue
+		}
+
+		var cnrID cid.ID
+		if err := cnrID.DecodeString(key); err != nil {
+			return nil, err
+		}
+		res = append(res, cnrID)
+	}
+	return res, nil
+}
+
+func (c *memClient) PutContainer(ctx context.Context, cnr container.Container) (cid.ID, error) {
+	if err := ctx.Err(); err != nil {
+		return cid.ID{}, err
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	var cnrID cid.ID
+	cnrID.SetSHA256(c.nextSHA256([]byte(container.Name(cnr))))
+	c.containers[cnrID.EncodeToString()] = &memContainer{
+		cnr:     cnr,
+		objects: make(map[string]*object.Object),
+	}
+
+	return cnrID, nil
+}
+
+func (c *memClient) GetContainer(ctx context.Context, cnrID cid.ID) (container.Container, error) {
+	if err := ctx.Err(); err != nil {
+		return container.Container{}, err
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	cnr, err := c.container(cnrID)
+	if err != nil {
+		return container.Container{}, err
+	}
+	return cnr.cnr, nil
+}
+
+func (c *memClient) DeleteContainer(ctx context.Context, cnrID cid.ID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if _, err := c.container(cnrID); err != nil {
+		return err
+	}
+
+	delete(c.containers, cnrID.EncodeToString())
+	return nil
+}
+
+func (c *memClient) GetEACL(ctx context.Context, cnrID cid.ID) (eacl.Table, error) {
+	if err := ctx.Err(); err != nil {
+		return eacl.Table{}, err
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	cnr, err := c.container(cnrID)
+	if err != nil {
+		return eacl.Table{}, err
+	}
+	if cnr.eacl == nil {
+		return eacl.Table{}, apistatus.EACLNotFound{}
+	}
+	return *cnr.eacl, nil
+}
+
+func (c *memClient) SetEACL(ctx context.Context, table eacl.Table) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	cnrID, ok := table.CID()
+	if !ok {
+		return errors.New("missing container ID in extended ACL")
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	cnr, err := c.container(cnrID)
+	if err != nil {
+		return err
+	}
+	cnr.eacl = &table
+	return nil
+}
+
+func (c *memClient) CurrentEpoch(ctx context.Context) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	return c.epoch, nil
+}
+
+func (c *memClient) EpochDuration(ctx context.Context) (time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return c.epochDuration, nil
+}
+
+func (c *memClient) Close() {}
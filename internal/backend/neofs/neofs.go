@@ -1,12 +1,21 @@
 package neofs
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"hash"
 	"io"
-	"strings"
-
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/nspcc-dev/neofs-sdk-go/checksum"
 	cid "github.com/nspcc-dev/neofs-sdk-go/container/id"
 	"github.com/nspcc-dev/neofs-sdk-go/object"
 	oid "github.com/nspcc-dev/neofs-sdk-go/object/id"
@@ -14,16 +23,20 @@ import (
 	"github.com/nspcc-dev/neofs-sdk-go/user"
 	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/backend/sema"
+	"github.com/restic/restic/internal/cache"
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/restic"
+	"golang.org/x/sync/errgroup"
 )
 
 type (
 	// Backend stores data on a neofs storage.
 	Backend struct {
-		client *pool.Pool
+		client Client
 		owner  *user.ID
 		cnrID  cid.ID
+		prefix string
+		cache  *objectCache
 
 		sem         sema.Semaphore
 		connections uint
@@ -36,51 +49,188 @@ type (
 	}
 )
 
-const attrResticType = "restic-type"
+const (
+	attrResticType   = "restic-type"
+	attrResticPrefix = "restic-prefix"
+	// attrExpirationEpoch is the system attribute holding the last epoch in
+	// which the network keeps the object.
+	attrExpirationEpoch = "__NEOFS__EXPIRATION_EPOCH"
+)
+
+// lockLifetime is the minimum duration lock files are kept. Their owner
+// replaces them more often, so only locks of dead clients expire.
+const lockLifetime = 3 * restic.LockRefreshInterval
+
+// make sure that *Backend implements the optional backend interfaces
+var (
+	_ restic.ChecksumBackend     = &Backend{}
+	_ restic.DuplicatesBackend   = &Backend{}
+	_ restic.LockExpiringBackend = &Backend{}
+)
 
 func Open(ctx context.Context, cfg Config) (restic.Backend, error) {
-	return open(ctx, cfg)
+	client, owner, err := connect(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	be, err := newBackend(ctx, cfg, client, owner)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return be, nil
 }
 
+// Create opens the backend, the container is created if it does not exist.
 func Create(ctx context.Context, cfg Config) (restic.Backend, error) {
-	return open(ctx, cfg)
+	client, owner, err := connect(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	be, err := create(ctx, cfg, client, owner)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return be, nil
 }
 
-func open(ctx context.Context, cfg Config) (restic.Backend, error) {
-	sem, err := sema.New(cfg.Connections)
+// connect returns a client for the network and the user owning the
+// container. That is the user the wallet belongs to, unless the access is
+// granted by tokens, then it is their issuer.
+func connect(ctx context.Context, cfg Config) (*poolClient, user.ID, error) {
+	tok, err := loadTokens(cfg)
+	if err != nil {
+		return nil, user.ID{}, err
+	}
+
+	key, err := getKey(cfg, tok)
+	if err != nil {
+		return nil, user.ID{}, err
+	}
+
+	if err = tok.checkKey(key); err != nil {
+		return nil, user.ID{}, err
+	}
+
+	var signer user.ID
+	user.IDFromKey(&signer, key.PrivateKey.PublicKey)
+
+	owner, ok := tok.issuer()
+	if !ok {
+		owner = signer
+	}
+
+	p, err := createPool(ctx, key, cfg)
+	if err != nil {
+		return nil, user.ID{}, err
+	}
+
+	var wp pool.WaitParams
+	wp.SetPollInterval(containerPollInterval)
+	wp.SetTimeout(containerWaitTimeout)
+
+	client := &poolClient{pool: p, waitParams: wp, tokens: tok, key: key, signer: signer}
+
+	if !tok.empty() {
+		epoch, err := client.CurrentEpoch(ctx)
+		if err == nil {
+			err = tok.check(epoch)
+		}
+		if err != nil {
+			client.Close()
+			return nil, user.ID{}, err
+		}
+	}
+
+	return client, owner, nil
+}
+
+// create creates the container from cfg unless it exists and returns a
+// backend for it.
+func create(ctx context.Context, cfg Config, client Client, owner user.ID) (*Backend, error) {
+	var cnrID cid.ID
+	if cnrID.DecodeString(cfg.Container) != nil {
+		_, err := findContainerID(ctx, client, owner, cfg.Container)
+		if errors.Is(err, errContainerNotFound) {
+			err = createContainer(ctx, client, owner, cfg)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	be, err := newBackend(ctx, cfg, client, owner)
 	if err != nil {
 		return nil, err
 	}
 
-	acc, err := getAccount(cfg)
+	exists, err := be.Test(ctx, restic.Handle{Type: restic.ConfigFile})
 	if err != nil {
 		return nil, err
 	}
 
-	var owner user.ID
-	user.IDFromKey(&owner, acc.PrivateKey().PrivateKey.PublicKey)
+	if exists {
+		return nil, errors.New("config file already exists")
+	}
 
-	p, err := createPool(ctx, acc, cfg)
+	return be, nil
+}
+
+// newBackend returns a backend which accesses the container from cfg on
+// behalf of owner using client.
+func newBackend(ctx context.Context, cfg Config, client Client, owner user.ID) (*Backend, error) {
+	sem, err := sema.New(cfg.Connections)
 	if err != nil {
 		return nil, err
 	}
 
-	containerID, err := getContainerID(ctx, p, owner, cfg.Container)
+	containerID, err := getContainerID(ctx, client, owner, cfg.Container)
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve container id: %w", err)
 	}
 	debug.Log("container repo: %s", containerID.String())
 
+	objCache := newObjectCache()
+	if cfg.ObjectCache {
+		dir := cfg.CacheDir
+		if dir == "" {
+			dir, err = cache.DefaultDir()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		name := containerID.EncodeToString()
+		if cfg.Prefix != "" {
+			name += "-" + restic.Hash([]byte(cfg.Prefix)).Str()
+		}
+		objCache.path = filepath.Join(dir, "neofs", name+".json")
+		if err = objCache.load(containerID); err != nil {
+			// the cache is only an optimization, start with an empty one
+			debug.Log("unable to load object cache: %v", err)
+		}
+	}
+
 	return &Backend{
-		client:      p,
+		client:      client,
 		owner:       &owner,
 		cnrID:       containerID,
+		prefix:      cfg.Prefix,
+		cache:       objCache,
 		sem:         sem,
 		connections: cfg.Connections,
 	}, nil
 }
 
 func (b *Backend) Location() string {
+	if b.prefix != "" {
+		return b.cnrID.String() + "/" + b.prefix
+	}
 	return b.cnrID.String()
 }
 
@@ -89,68 +239,159 @@ func (b *Backend) Hasher() hash.Hash {
 }
 
 func (b *Backend) Test(ctx context.Context, h restic.Handle) (bool, error) {
-	filters := object.NewSearchFilters()
-	filters.AddRootFilter()
-	filters.AddFilter(object.AttributeFileName, getName(h), object.MatchStringEqual)
-
-	var prmSearch pool.PrmObjectSearch
-	prmSearch.SetContainerID(b.cnrID)
-	prmSearch.SetFilters(filters)
-
 	b.sem.GetToken()
 	defer b.sem.ReleaseToken()
 
-	res, err := b.client.SearchObjects(ctx, prmSearch)
-	if err != nil {
-		return false, fmt.Errorf("search objects: %w", err)
-	}
-
-	defer res.Close()
-
-	err = res.Iterate(func(id oid.ID) bool {
+	var found bool
+	err := b.client.SearchObjects(ctx, b.cnrID, b.fileFilters(h), func(id oid.ID) bool {
+		found = true
 		return true
 	})
 	if err != nil {
-		return false, fmt.Errorf("iterate objects: %w", err)
+		return false, permanentError(err)
 	}
 
-	return true, nil
-
+	return found, nil
 }
 
+// Remove removes all objects storing the file at h.
 func (b *Backend) Remove(ctx context.Context, h restic.Handle) error {
 	b.sem.GetToken()
 	defer b.sem.ReleaseToken()
 
-	objInfo, err := b.stat(ctx, h)
+	b.cache.remove(h)
+
+	ids, err := b.searchObjects(ctx, b.fileFilters(h))
 	if err != nil {
-		return err
+		return permanentError(err)
 	}
 
-	var prm pool.PrmObjectDelete
-	prm.SetAddress(objInfo.address)
+	if len(ids) == 0 {
+		return fmt.Errorf("%w: '%s'", errFileNotFound, getName(h))
+	}
 
-	return b.client.DeleteObject(ctx, prm)
+	for _, id := range ids {
+		if err = b.client.DeleteObject(ctx, newAddress(b.cnrID, id)); err != nil {
+			return permanentError(err)
+		}
+	}
+
+	return nil
 }
 
 func (b *Backend) Close() error {
+	err := b.cache.save()
 	b.client.Close()
-	return nil
+	return err
 }
 
 func (b *Backend) Save(ctx context.Context, h restic.Handle, rd restic.RewindReader) error {
-	name := getName(h)
-	obj := formRawObject(b.owner, b.cnrID, name, map[string]string{attrResticType: string(h.Type)})
+	if err := h.Valid(); err != nil {
+		return backoff.Permanent(err)
+	}
 
-	var prm pool.PrmObjectPut
-	prm.SetHeader(*obj)
-	prm.SetPayload(rd)
+	name := getName(h)
+	header := map[string]string{attrResticType: string(h.Type)}
+	if b.prefix != "" {
+		header[attrResticPrefix] = b.prefix
+	}
 
 	b.sem.GetToken()
 	defer b.sem.ReleaseToken()
 
-	_, err := b.client.PutObject(ctx, prm)
-	return err
+	if h.Type == restic.LockFile {
+		// let the network remove the locks of crashed clients
+		epoch, err := b.lockExpirationEpoch(ctx)
+		if err != nil {
+			return permanentError(err)
+		}
+		header[attrExpirationEpoch] = strconv.FormatUint(epoch, 10)
+	}
+
+	obj := formRawObject(b.owner, b.cnrID, name, header)
+
+	b.cache.remove(h)
+
+	hasher := sha256.New()
+	payload := &lengthReader{rd: io.TeeReader(rd, hasher), remaining: rd.Length()}
+	objID, err := b.client.PutObject(ctx, *obj, payload)
+	if err != nil {
+		return permanentError(err)
+	}
+
+	b.cache.add(h, newAddress(b.cnrID, objID), rd.Length())
+
+	// the file is saved, so a failure to clean up must not make the caller
+	// retry and leave yet another copy
+	var sum [sha256.Size]byte
+	copy(sum[:], hasher.Sum(nil))
+	if err = b.removeCopies(ctx, h, objID, sum); err != nil {
+		debug.Log("removing copies of %v failed: %v", h, err)
+	}
+	return nil
+}
+
+// lockExpirationEpoch returns the last epoch a lock file saved now must be
+// kept in to live for at least lockLifetime.
+func (b *Backend) lockExpirationEpoch(ctx context.Context) (uint64, error) {
+	epoch, err := b.client.CurrentEpoch(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	d, err := b.client.EpochDuration(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid epoch duration %v", d)
+	}
+
+	// the current epoch may be about to end, so it is not counted
+	return epoch + 1 + uint64((lockLifetime+d-1)/d), nil
+}
+
+// LockExpiration returns the minimum duration lock files are kept, the
+// network removes them afterwards.
+func (b *Backend) LockExpiration() time.Duration {
+	return lockLifetime
+}
+
+// removeCopies removes the objects storing h besides keep which have the
+// payload checksum sum. They are left behind by interrupted or retried
+// uploads.
+func (b *Backend) removeCopies(ctx context.Context, h restic.Handle, keep oid.ID, sum [sha256.Size]byte) error {
+	ids, err := b.searchObjects(ctx, b.fileFilters(h))
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if id.Equals(keep) {
+			continue
+		}
+
+		addr := newAddress(b.cnrID, id)
+		obj, err := b.client.HeadObject(ctx, addr)
+		if isNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		cs, ok := obj.PayloadChecksum()
+		if !ok || cs.Type() != checksum.SHA256 || !bytes.Equal(cs.Value(), sum[:]) {
+			continue
+		}
+
+		debug.Log("removing copy %v of %v", id, h)
+		if err = b.client.DeleteObject(ctx, addr); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (b *Backend) Load(ctx context.Context, h restic.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
@@ -158,163 +399,465 @@ func (b *Backend) Load(ctx context.Context, h restic.Handle, length int, offset
 }
 
 func (b *Backend) openReader(ctx context.Context, h restic.Handle, length int, offset int64) (io.ReadCloser, error) {
+	if err := h.Valid(); err != nil {
+		return nil, backoff.Permanent(err)
+	}
+
+	if offset < 0 {
+		return nil, backoff.Permanent(fmt.Errorf("offset is negative"))
+	}
+
+	if length < 0 {
+		return nil, backoff.Permanent(fmt.Errorf("invalid length %d", length))
+	}
+
 	b.sem.GetToken()
 	ctx, cancel := context.WithCancel(ctx)
 
-	objInfo, err := b.stat(ctx, h)
+	_, cached := b.cache.get(h)
+	objInfo, err := b.cachedStat(ctx, h, false)
 	if err != nil {
 		cancel()
 		b.sem.ReleaseToken()
-		return nil, err
+		return nil, permanentError(err)
 	}
 
-	ln := uint64(length)
-	if ln == 0 {
-		ln = uint64(objInfo.Size - offset)
+	if offset > objInfo.Size {
+		cancel()
+		b.sem.ReleaseToken()
+		return nil, fmt.Errorf("offset %d exceeds size %d of file '%s'", offset, objInfo.Size, objInfo.Name)
+	}
+
+	// neofs rejects ranges beyond the end of the payload, so cut them down
+	ln := uint64(objInfo.Size - offset)
+	if length > 0 && uint64(length) < ln {
+		ln = uint64(length)
 	}
 
-	var prm pool.PrmObjectRange
-	prm.SetAddress(objInfo.address)
-	prm.SetOffset(uint64(offset))
-	prm.SetLength(ln)
+	// as well as empty ranges
+	if ln == 0 {
+		return b.sem.ReleaseTokenOnClose(io.NopCloser(bytes.NewReader(nil)), cancel), nil
+	}
 
-	res, err := b.client.ObjectRange(ctx, prm)
+	rd, err := b.client.ObjectRange(ctx, objInfo.address, uint64(offset), ln)
+	if err != nil && cached && b.IsNotExist(err) {
+		// the cached object is gone, look for the file once more
+		b.cache.remove(h)
+		objInfo, err = b.stat(ctx, h)
+		if err == nil {
+			rd, err = b.client.ObjectRange(ctx, objInfo.address, uint64(offset), ln)
+		}
+	}
 	if err != nil {
 		cancel()
 		b.sem.ReleaseToken()
-		return nil, err
+		return nil, permanentError(err)
 	}
 
-	return b.sem.ReleaseTokenOnClose(&res, cancel), nil
+	return b.sem.ReleaseTokenOnClose(rd, cancel), nil
 }
 
-func (b *Backend) stat(ctx context.Context, h restic.Handle) (*ObjInfo, error) {
-	name := getName(h)
-	filters := object.NewSearchFilters()
-	filters.AddRootFilter()
-	filters.AddFilter(object.AttributeFileName, name, object.MatchStringEqual)
-	filters.AddFilter(attrResticType, string(h.Type), object.MatchStringEqual)
+// cachedStat returns the object storing h, it consults the object cache
+// first. If verify is set, entries which were not seen by this process are
+// checked to still exist.
+func (b *Backend) cachedStat(ctx context.Context, h restic.Handle, verify bool) (*ObjInfo, error) {
+	e, ok := b.cache.get(h)
+	if !ok {
+		return b.stat(ctx, h)
+	}
 
-	var prmSearch pool.PrmObjectSearch
-	prmSearch.SetContainerID(b.cnrID)
-	prmSearch.SetFilters(filters)
+	if verify && !e.verified {
+		obj, err := b.client.HeadObject(ctx, e.addr)
+		if err != nil {
+			if !b.IsNotExist(err) {
+				return nil, err
+			}
+			b.cache.remove(h)
+			return b.stat(ctx, h)
+		}
 
-	res, err := b.client.SearchObjects(ctx, prmSearch)
-	if err != nil {
-		return nil, fmt.Errorf("search objects: %w", err)
+		e.size = int64(obj.PayloadSize())
+		b.cache.add(h, e.addr, e.size)
 	}
 
-	defer res.Close()
+	return &ObjInfo{
+		FileInfo: restic.FileInfo{
+			Name: getName(h),
+			Size: e.size,
+		},
+		address: e.addr,
+	}, nil
+}
 
-	var objID oid.ID
-	var found bool
+// stat searches for the object storing h and updates the object cache.
+// Identical copies of the file are tolerated.
+func (b *Backend) stat(ctx context.Context, h restic.Handle) (*ObjInfo, error) {
+	name := getName(h)
 
-	var inErr error
-	err = res.Iterate(func(id oid.ID) bool {
-		if found {
-			inErr = fmt.Errorf("found more than one object for file: '%s'", name)
-			return true
-		}
-		objID = id
-		found = true
-		return false
-	})
-	if err == nil {
-		err = inErr
-	}
+	ids, err := b.searchObjects(ctx, b.fileFilters(h))
 	if err != nil {
-		return nil, fmt.Errorf("iterate objects: %w", err)
+		return nil, err
 	}
 
-	if !found {
-		return nil, fmt.Errorf("not found file: '%s'", name)
+	addrs, hdrs, err := b.headCopies(ctx, ids)
+	if err != nil {
+		return nil, err
 	}
 
-	addr := newAddress(b.cnrID, objID)
-	var prm pool.PrmObjectHead
-	prm.SetAddress(addr)
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("%w: '%s'", errFileNotFound, name)
+	}
 
-	obj, err := b.client.HeadObject(ctx, prm)
-	if err != nil {
-		return nil, err
+	if !identicalCopies(hdrs) {
+		return nil, fmt.Errorf("found %d objects with different content for file: '%s'", len(addrs), name)
 	}
 
+	size := int64(hdrs[0].PayloadSize())
+	b.cache.add(h, addrs[0], size)
+
 	return &ObjInfo{
 		FileInfo: restic.FileInfo{
 			Name: name,
-			Size: int64(obj.PayloadSize()),
+			Size: size,
 		},
-		address: addr,
+		address: addrs[0],
 	}, nil
 }
 
+// headCopies returns the addresses and headers of the objects, the objects
+// which have been removed in the meantime are skipped.
+func (b *Backend) headCopies(ctx context.Context, ids []oid.ID) ([]oid.Address, []object.Object, error) {
+	addrs := make([]oid.Address, 0, len(ids))
+	hdrs := make([]object.Object, 0, len(ids))
+	for _, id := range ids {
+		addr := newAddress(b.cnrID, id)
+		obj, err := b.client.HeadObject(ctx, addr)
+		if isNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		addrs = append(addrs, addr)
+		hdrs = append(hdrs, obj)
+	}
+	return addrs, hdrs, nil
+}
+
+// identicalCopies returns true if all objects have the same payload.
+func identicalCopies(hdrs []object.Object) bool {
+	if len(hdrs) < 2 {
+		return true
+	}
+
+	first, ok := hdrs[0].PayloadChecksum()
+	if !ok {
+		return false
+	}
+
+	for _, hdr := range hdrs[1:] {
+		cs, ok := hdr.PayloadChecksum()
+		if !ok || cs.Type() != first.Type() || !bytes.Equal(cs.Value(), first.Value()) ||
+			hdr.PayloadSize() != hdrs[0].PayloadSize() {
+			return false
+		}
+	}
+	return true
+}
+
+// searchObjects returns the IDs of all objects matching the filters.
+func (b *Backend) searchObjects(ctx context.Context, filters object.SearchFilters) ([]oid.ID, error) {
+	var ids []oid.ID
+	err := b.client.SearchObjects(ctx, b.cnrID, filters, func(id oid.ID) bool {
+		ids = append(ids, id)
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
 func (b *Backend) Stat(ctx context.Context, h restic.Handle) (restic.FileInfo, error) {
 	b.sem.GetToken()
 	defer b.sem.ReleaseToken()
 
-	objInfo, err := b.stat(ctx, h)
+	objInfo, err := b.cachedStat(ctx, h, true)
 	if err != nil {
-		return restic.FileInfo{}, err
+		return restic.FileInfo{}, permanentError(err)
 	}
 	return objInfo.FileInfo, nil
 }
 
-func (b *Backend) List(ctx context.Context, t restic.FileType, fn func(restic.FileInfo) error) error {
-	filters := object.NewSearchFilters()
-	filters.AddRootFilter()
-	filters.AddFilter(attrResticType, string(t), object.MatchStringEqual)
-
-	var prmSearch pool.PrmObjectSearch
-	prmSearch.SetContainerID(b.cnrID)
-	prmSearch.SetFilters(filters)
+// SHA256 returns the payload checksum the storage nodes recorded in the header
+// of the object storing the file, so that the file need not be downloaded to
+// verify its integrity.
+func (b *Backend) SHA256(ctx context.Context, h restic.Handle) (restic.ID, error) {
+	if err := h.Valid(); err != nil {
+		return restic.ID{}, backoff.Permanent(err)
+	}
 
 	b.sem.GetToken()
 	defer b.sem.ReleaseToken()
 
-	res, err := b.client.SearchObjects(ctx, prmSearch)
+	_, cached := b.cache.get(h)
+	objInfo, err := b.cachedStat(ctx, h, false)
 	if err != nil {
-		return fmt.Errorf("search objects: %w", err)
+		return restic.ID{}, permanentError(err)
+	}
+
+	obj, err := b.client.HeadObject(ctx, objInfo.address)
+	if err != nil && cached && b.IsNotExist(err) {
+		// the cached object is gone, look for the file once more
+		b.cache.remove(h)
+		objInfo, err = b.stat(ctx, h)
+		if err == nil {
+			obj, err = b.client.HeadObject(ctx, objInfo.address)
+		}
+	}
+	if err != nil {
+		return restic.ID{}, permanentError(err)
+	}
+
+	cs, ok := obj.PayloadChecksum()
+	if !ok {
+		return restic.ID{}, fmt.Errorf("no payload checksum for file '%s'", objInfo.Name)
 	}
 
-	defer res.Close()
+	var id restic.ID
+	if cs.Type() != checksum.SHA256 || len(cs.Value()) != len(id) {
+		return restic.ID{}, fmt.Errorf("unsupported payload checksum %v for file '%s'", cs.Type(), objInfo.Name)
+	}
 
-	var addr oid.Address
-	addr.SetContainer(b.cnrID)
+	copy(id[:], cs.Value())
+	return id, nil
+}
+
+func (b *Backend) List(ctx context.Context, t restic.FileType, fn func(restic.FileInfo) error) error {
+	// copies of a file are reported only once
+	seen := make(map[string]struct{})
+	return b.listObjects(ctx, t, func(_ oid.ID, fi restic.FileInfo) error {
+		if _, ok := seen[fi.Name]; ok {
+			return nil
+		}
+		seen[fi.Name] = struct{}{}
 
-	var inErr error
-	err = res.Iterate(func(id oid.ID) bool {
-		addr.SetObject(id)
+		return fn(fi)
+	})
+}
 
-		var prm pool.PrmObjectHead
-		prm.SetAddress(addr)
+// listObjects runs fn for every object storing a file of type t. fn is
+// called from the goroutine listObjects is called from.
+func (b *Backend) listObjects(ctx context.Context, t restic.FileType, fn func(oid.ID, restic.FileInfo) error) error {
+	filters := b.filters()
+	filters.AddFilter(attrResticType, string(t), object.MatchStringEqual)
 
-		obj, err := b.client.HeadObject(ctx, prm)
-		if err != nil {
-			inErr = fmt.Errorf("head object: %w", err)
-			return true
+	// collect the IDs first, so that the search does not hold a connection
+	// while the objects are inspected
+	b.sem.GetToken()
+	ids, err := b.searchObjects(ctx, filters)
+	b.sem.ReleaseToken()
+	if err != nil {
+		return permanentError(err)
+	}
+
+	if len(ids) == 0 {
+		return ctx.Err()
+	}
+
+	// track spawned goroutines using wg, create a new context which is
+	// cancelled as soon as an error occurs or listObjects returns.
+	listCtx, cancel := context.WithCancel(ctx)
+	wg, wgCtx := errgroup.WithContext(listCtx)
+
+	idCh := make(chan oid.ID)
+	wg.Go(func() error {
+		defer close(idCh)
+		for _, id := range ids {
+			select {
+			case idCh <- id:
+			case <-wgCtx.Done():
+				return wgCtx.Err()
+			}
+		}
+		return nil
+	})
+
+	workers := int(b.connections)
+	if workers > len(ids) {
+		workers = len(ids)
+	}
+
+	type listedObject struct {
+		id oid.ID
+		fi restic.FileInfo
+	}
+
+	// the workers resolve the object IDs to file infos, fn is only called
+	// from this goroutine
+	objCh := make(chan listedObject)
+	var workerWg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		workerWg.Add(1)
+		wg.Go(func() error {
+			defer workerWg.Done()
+			for id := range idCh {
+				fi, err := b.objectInfo(wgCtx, t, id)
+				if err != nil {
+					return err
+				}
+
+				select {
+				case objCh <- listedObject{id: id, fi: fi}:
+				case <-wgCtx.Done():
+					return wgCtx.Err()
+				}
+			}
+			return nil
+		})
+	}
+
+	go func() {
+		workerWg.Wait()
+		close(objCh)
+	}()
+
+	// make sure all goroutines terminate before returning
+	defer func() {
+		cancel()
+		for range objCh {
 		}
+		_ = wg.Wait()
+	}()
 
-		fileInfo := restic.FileInfo{
-			Size: int64(obj.PayloadSize()),
-			Name: getNameAttr(obj),
+	for obj := range objCh {
+		if err = fn(obj.id, obj.fi); err != nil {
+			return fmt.Errorf("handle fileInfo: %w", err)
 		}
-		if err = fn(fileInfo); err != nil {
-			inErr = fmt.Errorf("handle fileInfo: %w", err)
-			return true
+
+		if err = ctx.Err(); err != nil {
+			return err
 		}
+	}
 
-		return false
+	if err = wg.Wait(); err != nil {
+		return permanentError(err)
+	}
+
+	return ctx.Err()
+}
+
+// Duplicates runs fn for every file of type t which is stored in more than
+// one object.
+func (b *Backend) Duplicates(ctx context.Context, t restic.FileType, fn func(restic.DuplicateFile) error) error {
+	copies := make(map[string][]oid.ID)
+	err := b.listObjects(ctx, t, func(id oid.ID, fi restic.FileInfo) error {
+		copies[fi.Name] = append(copies[fi.Name], id)
+		return nil
 	})
-	if err == nil {
-		err = inErr
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(copies))
+	for name, ids := range copies {
+		if len(ids) > 1 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		b.sem.GetToken()
+		addrs, hdrs, err := b.headCopies(ctx, copies[name])
+		b.sem.ReleaseToken()
+		if err != nil {
+			return permanentError(err)
+		}
+
+		if len(addrs) < 2 {
+			continue
+		}
+
+		err = fn(restic.DuplicateFile{
+			Handle:    restic.Handle{Type: t, Name: name},
+			Copies:    len(addrs),
+			Identical: identicalCopies(hdrs),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemoveDuplicates removes all objects storing the file at h but one. They
+// are kept if their content differs.
+func (b *Backend) RemoveDuplicates(ctx context.Context, h restic.Handle) error {
+	if err := h.Valid(); err != nil {
+		return backoff.Permanent(err)
+	}
+
+	b.sem.GetToken()
+	defer b.sem.ReleaseToken()
+
+	ids, err := b.searchObjects(ctx, b.fileFilters(h))
+	if err != nil {
+		return permanentError(err)
 	}
+
+	addrs, hdrs, err := b.headCopies(ctx, ids)
 	if err != nil {
-		return fmt.Errorf("iterate objects: %w", err)
+		return permanentError(err)
+	}
+
+	if !identicalCopies(hdrs) {
+		return backoff.Permanent(fmt.Errorf("the %d objects storing file '%s' differ", len(addrs), getName(h)))
+	}
+
+	if len(addrs) < 2 {
+		return nil
+	}
+
+	b.cache.add(h, addrs[0], int64(hdrs[0].PayloadSize()))
+	for _, addr := range addrs[1:] {
+		debug.Log("removing copy %v of %v", addr.Object(), h)
+		if err = b.client.DeleteObject(ctx, addr); err != nil {
+			return permanentError(err)
+		}
 	}
 
 	return nil
 }
 
+// objectInfo returns the file info for the object id of type t. It avoids
+// a HEAD request if the object is known to the cache.
+func (b *Backend) objectInfo(ctx context.Context, t restic.FileType, id oid.ID) (restic.FileInfo, error) {
+	if key, e, ok := b.cache.getObject(id); ok && key.Type == t {
+		b.cache.add(restic.Handle{Type: t, Name: key.Name}, e.addr, e.size)
+		return restic.FileInfo{Name: key.Name, Size: e.size}, nil
+	}
+
+	addr := newAddress(b.cnrID, id)
+
+	b.sem.GetToken()
+	obj, err := b.client.HeadObject(ctx, addr)
+	b.sem.ReleaseToken()
+	if err != nil {
+		return restic.FileInfo{}, fmt.Errorf("head object: %w", err)
+	}
+
+	fi := restic.FileInfo{
+		Size: int64(obj.PayloadSize()),
+		Name: getNameAttr(obj),
+	}
+	b.cache.add(restic.Handle{Type: t, Name: fi.Name}, addr, fi.Size)
+
+	return fi, nil
+}
+
 func (b *Backend) Connections() uint {
 	return b.connections
 }
@@ -324,21 +867,34 @@ func (b *Backend) HasAtomicReplace() bool {
 }
 
 func (b *Backend) IsNotExist(err error) bool {
-	return strings.Contains(err.Error(), "not found")
+	return isNotFound(err)
 }
 
+// Delete removes the repository. Without a prefix, the whole container is
+// removed, otherwise only the objects with the prefix.
 func (b *Backend) Delete(ctx context.Context) error {
-	var prm pool.PrmContainerDelete
-	prm.SetContainerID(b.cnrID)
-
 	b.sem.GetToken()
 	defer b.sem.ReleaseToken()
 
-	if err := b.client.DeleteContainer(ctx, prm); err != nil {
-		return fmt.Errorf("delete container: %w", err)
+	if b.prefix == "" {
+		if err := b.client.DeleteContainer(ctx, b.cnrID); err != nil {
+			return permanentError(fmt.Errorf("delete container: %w", err))
+		}
+	} else {
+		ids, err := b.searchObjects(ctx, b.filters())
+		if err != nil {
+			return permanentError(err)
+		}
+
+		for _, id := range ids {
+			if err = b.client.DeleteObject(ctx, newAddress(b.cnrID, id)); err != nil {
+				return permanentError(fmt.Errorf("delete object: %w", err))
+			}
+		}
 	}
 
-	return nil
+	b.cache.clear()
+	return b.cache.drop()
 }
 
 func getName(h restic.Handle) string {
@@ -348,3 +904,25 @@ func getName(h restic.Handle) string {
 	}
 	return name
 }
+
+// filters returns the search filters which select the objects of the
+// repository. Objects of repositories without a prefix carry no prefix
+// attribute at all.
+func (b *Backend) filters() object.SearchFilters {
+	filters := object.NewSearchFilters()
+	filters.AddRootFilter()
+	if b.prefix != "" {
+		filters.AddFilter(attrResticPrefix, b.prefix, object.MatchStringEqual)
+	} else {
+		filters.AddFilter(attrResticPrefix, "", object.MatchNotPresent)
+	}
+	return filters
+}
+
+// fileFilters returns the search filters which select the object stored for h.
+func (b *Backend) fileFilters(h restic.Handle) object.SearchFilters {
+	filters := b.filters()
+	filters.AddFilter(object.AttributeFileName, getName(h), object.MatchStringEqual)
+	filters.AddFilter(attrResticType, string(h.Type), object.MatchStringEqual)
+	return filters
+}
@@ -0,0 +1,549 @@
+package neofs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/nspcc-dev/neo-go/pkg/crypto/keys"
+	apistatus "github.com/nspcc-dev/neofs-sdk-go/client/status"
+	"github.com/nspcc-dev/neofs-sdk-go/container"
+	"github.com/nspcc-dev/neofs-sdk-go/container/acl"
+	cid "github.com/nspcc-dev/neofs-sdk-go/container/id"
+	"github.com/nspcc-dev/neofs-sdk-go/object"
+	oid "github.com/nspcc-dev/neofs-sdk-go/object/id"
+	"github.com/nspcc-dev/neofs-sdk-go/user"
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/test"
+	"github.com/restic/restic/internal/checker"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	"github.com/stretchr/testify/require"
+)
+
+// memTestConfig describes a repository in the in-memory neofs stand-in.
+type memTestConfig struct {
+	cfg    Config
+	client *memClient
+	owner  user.ID
+}
+
+func newMemTestConfig(t testing.TB) *memTestConfig {
+	key, err := keys.NewPrivateKey()
+	require.NoError(t, err)
+
+	var owner user.ID
+	user.IDFromKey(&owner, key.PrivateKey.PublicKey)
+
+	client := newMemClient()
+	client.putContainer(owner, "container")
+
+	cfg := NewConfig()
+	cfg.Container = "container"
+
+	return &memTestConfig{
+		cfg:    cfg,
+		client: client,
+		owner:  owner,
+	}
+}
+
+func (c *memTestConfig) open() (*Backend, error) {
+	return newBackend(context.TODO(), c.cfg, c.client, c.owner)
+}
+
+func newMemTestSuite(t testing.TB) *test.Suite {
+	return &test.Suite{
+		// NewConfig returns a config for a new temporary backend that will be used in tests.
+		NewConfig: func() (interface{}, error) {
+			return newMemTestConfig(t), nil
+		},
+
+		// CreateFn is a function that creates a temporary repository for the tests.
+		Create: func(config interface{}) (restic.Backend, error) {
+			c := config.(*memTestConfig)
+			return create(context.TODO(), c.cfg, c.client, c.owner)
+		},
+
+		// OpenFn is a function that opens a previously created temporary repository.
+		Open: func(config interface{}) (restic.Backend, error) {
+			return config.(*memTestConfig).open()
+		},
+
+		// CleanupFn removes data created during the tests.
+		Cleanup: func(config interface{}) error {
+			// no cleanup needed
+			return nil
+		},
+	}
+}
+
+func TestSuiteBackendMemClient(t *testing.T) {
+	newMemTestSuite(t).RunTests(t)
+}
+
+func TestRepositoryMemClient(t *testing.T) {
+	be, err := newMemTestConfig(t).open()
+	require.NoError(t, err)
+
+	repo, cleanup := repository.TestRepositoryWithBackend(t, be, 0)
+	defer cleanup()
+
+	restic.TestCreateSnapshot(t, repo, time.Unix(1460289341, 207401672), 3, 0)
+	checker.TestCheckRepo(t, repo)
+}
+
+// countingClient counts the searches and HEAD requests sent to the network.
+type countingClient struct {
+	Client
+	searches int32
+	heads    int32
+}
+
+func (c *countingClient) SearchObjects(ctx context.Context, cnrID cid.ID, filters object.SearchFilters, fn func(oid.ID) bool) error {
+	atomic.AddInt32(&c.searches, 1)
+	return c.Client.SearchObjects(ctx, cnrID, filters, fn)
+}
+
+func (c *countingClient) HeadObject(ctx context.Context, addr oid.Address) (object.Object, error) {
+	atomic.AddInt32(&c.heads, 1)
+	return c.Client.HeadObject(ctx, addr)
+}
+
+func (c *countingClient) reset() {
+	atomic.StoreInt32(&c.searches, 0)
+	atomic.StoreInt32(&c.heads, 0)
+}
+
+func loadAll(t testing.TB, be restic.Backend, h restic.Handle) []byte {
+	var data []byte
+	err := be.Load(context.TODO(), h, 0, 0, func(rd io.Reader) (err error) {
+		data, err = io.ReadAll(rd)
+		return err
+	})
+	require.NoError(t, err)
+	return data
+}
+
+func TestObjectCache(t *testing.T) {
+	ctx := context.TODO()
+	memCfg := newMemTestConfig(t)
+	memCfg.cfg.ObjectCache = true
+	memCfg.cfg.CacheDir = t.TempDir()
+
+	client := &countingClient{Client: memCfg.client}
+	be, err := newBackend(ctx, memCfg.cfg, client, memCfg.owner)
+	require.NoError(t, err)
+
+	data := []byte("pack content")
+	h := restic.Handle{Type: restic.PackFile, Name: restic.Hash(data).String()}
+	require.NoError(t, be.Save(ctx, h, restic.NewByteReader(data, nil)))
+
+	// a saved file is accessed without searching for it
+	client.reset()
+	require.Equal(t, data, loadAll(t, be, h))
+	fi, err := be.Stat(ctx, h)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(data)), fi.Size)
+	require.Zero(t, atomic.LoadInt32(&client.searches))
+	require.Zero(t, atomic.LoadInt32(&client.heads))
+	require.NoError(t, be.Close())
+
+	// the persisted cache is used after reopening, List refreshes it
+	be, err = newBackend(ctx, memCfg.cfg, client, memCfg.owner)
+	require.NoError(t, err)
+	client.reset()
+	require.Equal(t, data, loadAll(t, be, h))
+	require.Zero(t, atomic.LoadInt32(&client.searches))
+
+	require.NoError(t, be.List(ctx, restic.PackFile, func(restic.FileInfo) error { return nil }))
+	client.reset()
+	_, err = be.Stat(ctx, h)
+	require.NoError(t, err)
+	require.Zero(t, atomic.LoadInt32(&client.heads))
+
+	// objects removed behind the back of the cache are looked up again
+	other, err := memCfg.open()
+	require.NoError(t, err)
+	require.NoError(t, other.Remove(ctx, h))
+	require.NoError(t, other.Save(ctx, h, restic.NewByteReader(data, nil)))
+	require.Equal(t, data, loadAll(t, be, h))
+
+	require.NoError(t, be.Remove(ctx, h))
+	_, err = be.Stat(ctx, h)
+	require.Error(t, err)
+	require.True(t, be.IsNotExist(err))
+	require.NoError(t, be.Close())
+}
+
+// slowHeadClient delays HEAD requests and records how many of them were in
+// flight at the same time.
+type slowHeadClient struct {
+	Client
+	inFlight    int32
+	maxInFlight int32
+}
+
+func (c *slowHeadClient) HeadObject(ctx context.Context, addr oid.Address) (object.Object, error) {
+	n := atomic.AddInt32(&c.inFlight, 1)
+	defer atomic.AddInt32(&c.inFlight, -1)
+
+	for {
+		max := atomic.LoadInt32(&c.maxInFlight)
+		if n <= max || atomic.CompareAndSwapInt32(&c.maxInFlight, max, n) {
+			break
+		}
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	return c.Client.HeadObject(ctx, addr)
+}
+
+func TestListConcurrent(t *testing.T) {
+	ctx := context.TODO()
+	memCfg := newMemTestConfig(t)
+	memCfg.cfg.Connections = 4
+
+	// save the files through a different backend, so that they are not cached
+	writer, err := memCfg.open()
+	require.NoError(t, err)
+
+	want := make(map[string]int64)
+	for i := 0; i < 20; i++ {
+		data := []byte(fmt.Sprintf("pack %d", i))
+		h := restic.Handle{Type: restic.PackFile, Name: restic.Hash(data).String()}
+		require.NoError(t, writer.Save(ctx, h, restic.NewByteReader(data, nil)))
+		want[h.Name] = int64(len(data))
+	}
+
+	client := &slowHeadClient{Client: memCfg.client}
+	be, err := newBackend(ctx, memCfg.cfg, client, memCfg.owner)
+	require.NoError(t, err)
+
+	var running int32
+	list := func() map[string]int64 {
+		got := make(map[string]int64)
+		err := be.List(ctx, restic.PackFile, func(fi restic.FileInfo) error {
+			require.Equal(t, int32(1), atomic.AddInt32(&running, 1), "fn called concurrently")
+			defer atomic.AddInt32(&running, -1)
+			got[fi.Name] = fi.Size
+			return nil
+		})
+		require.NoError(t, err)
+		return got
+	}
+
+	require.Equal(t, want, list())
+	require.Greater(t, atomic.LoadInt32(&client.maxInFlight), int32(1))
+
+	// the second listing is answered from the object cache
+	atomic.StoreInt32(&client.maxInFlight, 0)
+	require.Equal(t, want, list())
+	require.Zero(t, atomic.LoadInt32(&client.maxInFlight))
+}
+
+func TestCreateContainer(t *testing.T) {
+	ctx := context.TODO()
+	memCfg := newMemTestConfig(t)
+	memCfg.cfg.Container = "new-container"
+	memCfg.cfg.BasicACL = "public-read"
+
+	be, err := create(ctx, memCfg.cfg, memCfg.client, memCfg.owner)
+	require.NoError(t, err)
+
+	cnr, err := memCfg.client.GetContainer(ctx, be.cnrID)
+	require.NoError(t, err)
+	require.Equal(t, "new-container", container.Name(cnr))
+
+	var basicACL acl.Basic
+	require.NoError(t, basicACL.DecodeString("public-read"))
+	require.Equal(t, basicACL, cnr.BasicACL())
+
+	// the existing container is reused, but not an existing repository
+	_, err = create(ctx, memCfg.cfg, memCfg.client, memCfg.owner)
+	require.NoError(t, err)
+
+	require.NoError(t, be.Save(ctx, restic.Handle{Type: restic.ConfigFile}, restic.NewByteReader([]byte("config"), nil)))
+	_, err = create(ctx, memCfg.cfg, memCfg.client, memCfg.owner)
+	require.Error(t, err)
+
+	memCfg.cfg.Container = "other-container"
+	memCfg.cfg.Policy = "INVALID POLICY"
+	_, err = create(ctx, memCfg.cfg, memCfg.client, memCfg.owner)
+	require.Error(t, err)
+}
+
+func TestPrefix(t *testing.T) {
+	ctx := context.TODO()
+	memCfg := newMemTestConfig(t)
+
+	open := func(prefix string) *Backend {
+		cfg := memCfg.cfg
+		cfg.Prefix = prefix
+		be, err := newBackend(ctx, cfg, memCfg.client, memCfg.owner)
+		require.NoError(t, err)
+		return be
+	}
+
+	list := func(be restic.Backend) []string {
+		var names []string
+		err := be.List(ctx, restic.PackFile, func(fi restic.FileInfo) error {
+			names = append(names, fi.Name)
+			return nil
+		})
+		require.NoError(t, err)
+		return names
+	}
+
+	backends := map[string]*Backend{
+		"":         open(""),
+		"repo1":    open("repo1"),
+		"repo/two": open("repo/two"),
+	}
+
+	data := []byte("shared pack")
+	h := restic.Handle{Type: restic.PackFile, Name: restic.Hash(data).String()}
+	for _, be := range backends {
+		require.NoError(t, be.Save(ctx, h, restic.NewByteReader(data, nil)))
+	}
+
+	// every repository sees exactly its own copy of the file
+	for prefix, be := range backends {
+		require.Equal(t, []string{h.Name}, list(be), "prefix %q", prefix)
+
+		fi, err := be.Stat(ctx, h)
+		require.NoError(t, err)
+		require.Equal(t, int64(len(data)), fi.Size)
+	}
+
+	require.NoError(t, backends["repo1"].Remove(ctx, h))
+	found, err := backends["repo1"].Test(ctx, h)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	for _, prefix := range []string{"", "repo/two"} {
+		found, err = backends[prefix].Test(ctx, h)
+		require.NoError(t, err)
+		require.True(t, found, "prefix %q", prefix)
+	}
+
+	// deleting a repository with a prefix keeps the container
+	require.NoError(t, backends["repo/two"].Delete(ctx))
+	require.Empty(t, list(backends["repo/two"]))
+	require.Equal(t, []string{h.Name}, list(backends[""]))
+}
+
+func TestSHA256(t *testing.T) {
+	ctx := context.TODO()
+	be, err := newMemTestConfig(t).open()
+	require.NoError(t, err)
+
+	data := []byte("pack content")
+	h := restic.Handle{Type: restic.PackFile, Name: restic.Hash(data).String()}
+	require.NoError(t, be.Save(ctx, h, restic.NewByteReader(data, nil)))
+
+	id, err := be.SHA256(ctx, h)
+	require.NoError(t, err)
+	require.Equal(t, restic.Hash(data), id)
+
+	require.NoError(t, be.Remove(ctx, h))
+	_, err = be.SHA256(ctx, h)
+	require.Error(t, err)
+	require.True(t, be.IsNotExist(err))
+}
+
+// deniedClient rejects all searches like a container with an ACL which
+// forbids them.
+type deniedClient struct {
+	Client
+	searches int32
+}
+
+func (c *deniedClient) SearchObjects(ctx context.Context, cnrID cid.ID, filters object.SearchFilters, fn func(oid.ID) bool) error {
+	atomic.AddInt32(&c.searches, 1)
+	return fmt.Errorf("search objects: %w", apistatus.ObjectAccessDenied{})
+}
+
+func TestErrors(t *testing.T) {
+	ctx := context.TODO()
+	memCfg := newMemTestConfig(t)
+	be, err := memCfg.open()
+	require.NoError(t, err)
+
+	h := restic.Handle{Type: restic.PackFile, Name: restic.NewRandomID().String()}
+	_, err = be.Stat(ctx, h)
+	require.Error(t, err)
+	require.True(t, be.IsNotExist(err))
+
+	var permanent *backoff.PermanentError
+	require.False(t, errors.As(err, &permanent), "missing files are retried")
+
+	require.True(t, be.IsNotExist(fmt.Errorf("head object: %w", apistatus.ObjectNotFound{})))
+	require.True(t, be.IsNotExist(&apistatus.ObjectAlreadyRemoved{}))
+	require.False(t, be.IsNotExist(errors.New("container not found")))
+
+	client := &deniedClient{Client: memCfg.client}
+	denied, err := newBackend(ctx, memCfg.cfg, client, memCfg.owner)
+	require.NoError(t, err)
+
+	// access errors are not retried
+	retry := backend.NewRetryBackend(denied, 10, nil)
+	_, err = retry.Stat(ctx, h)
+	require.Error(t, err)
+	require.False(t, retry.IsNotExist(err))
+	require.Equal(t, int32(1), atomic.LoadInt32(&client.searches))
+}
+
+func TestDuplicates(t *testing.T) {
+	ctx := context.TODO()
+	memCfg := newMemTestConfig(t)
+	be, err := memCfg.open()
+	require.NoError(t, err)
+
+	data := []byte("pack content")
+	h := restic.Handle{Type: restic.PackFile, Name: restic.Hash(data).String()}
+
+	// putCopy stores a copy of the file like an interrupted upload
+	putCopy := func(data []byte) {
+		obj := formRawObject(be.owner, be.cnrID, getName(h), map[string]string{attrResticType: string(h.Type)})
+		_, err := memCfg.client.PutObject(ctx, *obj, bytes.NewReader(data))
+		require.NoError(t, err)
+	}
+
+	objects := func() int {
+		ids, err := be.searchObjects(ctx, be.fileFilters(h))
+		require.NoError(t, err)
+		return len(ids)
+	}
+
+	duplicates := func() []restic.DuplicateFile {
+		var res []restic.DuplicateFile
+		require.NoError(t, be.Duplicates(ctx, restic.PackFile, func(df restic.DuplicateFile) error {
+			res = append(res, df)
+			return nil
+		}))
+		return res
+	}
+
+	// identical copies are tolerated
+	putCopy(data)
+	putCopy(data)
+	require.Equal(t, data, loadAll(t, be, h))
+	fi, err := be.Stat(ctx, h)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(data)), fi.Size)
+
+	var names []string
+	require.NoError(t, be.List(ctx, restic.PackFile, func(fi restic.FileInfo) error {
+		names = append(names, fi.Name)
+		return nil
+	}))
+	require.Equal(t, []string{h.Name}, names)
+
+	require.Equal(t, []restic.DuplicateFile{{Handle: h, Copies: 2, Identical: true}}, duplicates())
+	require.NoError(t, be.RemoveDuplicates(ctx, h))
+	require.Equal(t, 1, objects())
+	require.Empty(t, duplicates())
+
+	// saving the file removes the identical copies left behind
+	putCopy(data)
+	require.NoError(t, be.Save(ctx, h, restic.NewByteReader(data, nil)))
+	require.Equal(t, 1, objects())
+
+	// copies with different content are reported, but kept
+	putCopy([]byte("damaged"))
+	other, err := memCfg.open()
+	require.NoError(t, err)
+	_, err = other.Stat(ctx, h)
+	require.Error(t, err)
+	require.False(t, other.IsNotExist(err))
+	require.Equal(t, []restic.DuplicateFile{{Handle: h, Copies: 2, Identical: false}}, duplicates())
+	require.Error(t, be.RemoveDuplicates(ctx, h))
+	require.Equal(t, 2, objects())
+
+	// removing the file removes all copies
+	require.NoError(t, be.Remove(ctx, h))
+	require.Equal(t, 0, objects())
+
+	// saving the config removes the identical copies left behind
+	h = restic.Handle{Type: restic.ConfigFile}
+	putCopy(data)
+	require.NoError(t, be.Save(ctx, h, restic.NewByteReader(data, nil)))
+	require.Equal(t, 1, objects())
+}
+
+func TestSaveSearches(t *testing.T) {
+	ctx := context.TODO()
+	memCfg := newMemTestConfig(t)
+	client := &countingClient{Client: memCfg.client}
+	be, err := newBackend(ctx, memCfg.cfg, client, memCfg.owner)
+	require.NoError(t, err)
+
+	// uploading a pack searches once for copies of it, the new object itself
+	// is not requested
+	data := []byte("pack content")
+	client.reset()
+	require.NoError(t, be.Save(ctx, restic.Handle{Type: restic.PackFile, Name: restic.Hash(data).String()}, restic.NewByteReader(data, nil)))
+	require.Equal(t, int32(1), atomic.LoadInt32(&client.searches))
+	require.Zero(t, atomic.LoadInt32(&client.heads))
+}
+
+func TestLockExpiration(t *testing.T) {
+	ctx := context.TODO()
+	memCfg := newMemTestConfig(t)
+	be, err := memCfg.open()
+	require.NoError(t, err)
+
+	repo, cleanup := repository.TestRepositoryWithBackend(t, be, 0)
+	defer cleanup()
+
+	locks := func() int {
+		n := 0
+		require.NoError(t, repo.List(ctx, restic.LockFile, func(restic.ID, int64) error {
+			n++
+			return nil
+		}))
+		return n
+	}
+
+	restic.TestSetLockTimeout(t, time.Millisecond)
+	lock, err := restic.NewLock(ctx, repo)
+	require.NoError(t, err)
+
+	// the lock expires 15 epochs of a minute after the current epoch 0,
+	// the refreshed one after epoch 10
+	memCfg.client.tick(10)
+	require.NoError(t, lock.Refresh(ctx))
+	memCfg.client.tick(10)
+	require.Equal(t, 1, locks())
+
+	// the lock is removed once it was not refreshed for too long
+	memCfg.client.tick(7)
+	require.Equal(t, 0, locks())
+
+	// locks which outlived the expiration are stale on other hosts, too
+	for _, test := range []struct {
+		age   time.Duration
+		stale bool
+	}{
+		{lockLifetime - time.Minute, false},
+		{lockLifetime + time.Minute, true},
+	} {
+		id, err := repo.SaveJSONUnpacked(ctx, restic.LockFile, &restic.Lock{
+			Time:     time.Now().Add(-test.age),
+			Hostname: "other-host",
+			PID:      1,
+		})
+		require.NoError(t, err)
+
+		other, err := restic.LoadLock(ctx, repo, id)
+		require.NoError(t, err)
+		require.Equal(t, test.stale, other.Stale(), "lock age %v", test.age)
+	}
+}
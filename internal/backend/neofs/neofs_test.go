@@ -2,7 +2,6 @@ package neofs
 
 import (
 	"context"
-	"fmt"
 	"io"
 	"os"
 	"testing"
@@ -10,12 +9,6 @@ import (
 
 	"github.com/nspcc-dev/neo-go/pkg/crypto/keys"
 	"github.com/nspcc-dev/neo-go/pkg/wallet"
-	"github.com/nspcc-dev/neofs-sdk-go/container"
-	"github.com/nspcc-dev/neofs-sdk-go/container/acl"
-	cid "github.com/nspcc-dev/neofs-sdk-go/container/id"
-	"github.com/nspcc-dev/neofs-sdk-go/netmap"
-	"github.com/nspcc-dev/neofs-sdk-go/pool"
-	"github.com/nspcc-dev/neofs-sdk-go/user"
 	"github.com/restic/restic/internal/restic"
 	"github.com/stretchr/testify/require"
 	"github.com/testcontainers/testcontainers-go"
@@ -42,28 +35,20 @@ func TestIntegration(t *testing.T) {
 		Timeout:           10 * time.Second,
 		RebalanceInterval: 20 * time.Second,
 		Connections:       1,
+		Policy:            "REP 1",
+		BasicACL:          "private",
 	}
 
-	acc, err := getAccount(cfg)
-	require.NoError(t, err)
-
-	var owner user.ID
-	user.IDFromKey(&owner, acc.PrivateKey().PrivateKey.PublicKey)
-
 	for _, version := range versions {
 		ctx, cancel := context.WithCancel(rootCtx)
 		aioContainer := createDockerContainer(ctx, t, aioImage+version)
 
-		p, err := createPool(ctx, acc, cfg)
-		require.NoError(t, err)
-		_, err = createContainer(ctx, p, owner, cfg.Container, "REP 1")
-		require.NoError(t, err)
-
-		backend, err := Open(ctx, cfg)
+		backend, err := Create(ctx, cfg)
 		require.NoError(t, err)
 
 		t.Run("simple store load delete "+version, func(t *testing.T) { simpleStoreLoadDelete(ctx, t, backend) })
 		t.Run("list "+version, func(t *testing.T) { simpleList(ctx, t, backend) })
+		t.Run("failover "+version, func(t *testing.T) { failover(ctx, t, cfg) })
 
 		err = aioContainer.Terminate(ctx)
 		require.NoError(t, err)
@@ -117,34 +102,18 @@ func simpleList(ctx context.Context, t *testing.T, backend restic.Backend) {
 	require.Equal(t, 1, count)
 }
 
-func createContainer(ctx context.Context, client *pool.Pool, owner user.ID, containerName, placementPolicy string) (cid.ID, error) {
-	var pp netmap.PlacementPolicy
-	if err := pp.DecodeString(placementPolicy); err != nil {
-		return cid.ID{}, fmt.Errorf("decode policy: %w", err)
-	}
+func failover(ctx context.Context, t *testing.T, cfg Config) {
+	// nothing listens on the preferred endpoint, so the pool has to switch
+	// to the node with the lower priority
+	cfg.Endpoint = "localhost:1?priority=1," + cfg.Endpoint + "?priority=2"
 
-	var cnr container.Container
-	cnr.Init()
-	cnr.SetPlacementPolicy(pp)
-	cnr.SetBasicACL(acl.Private)
-	cnr.SetOwner(owner)
-
-	container.SetName(&cnr, containerName)
-	container.SetCreationTime(&cnr, time.Now())
-
-	var wp pool.WaitParams
-	wp.SetPollInterval(5 * time.Second)
-	wp.SetTimeout(30 * time.Second)
-	var prm pool.PrmContainerPut
-	prm.SetContainer(cnr)
-	prm.SetWaitParams(wp)
-
-	containerID, err := client.PutContainer(ctx, prm)
-	if err != nil {
-		return cid.ID{}, fmt.Errorf("put container: %w", err)
-	}
+	backend, err := Open(ctx, cfg)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, backend.Close())
+	}()
 
-	return containerID, nil
+	simpleStoreLoadDelete(ctx, t, backend)
 }
 
 func createWallet(t *testing.T) string {
@@ -0,0 +1,241 @@
+package neofs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	cid "github.com/nspcc-dev/neofs-sdk-go/container/id"
+	oid "github.com/nspcc-dev/neofs-sdk-go/object/id"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/restic"
+)
+
+type cacheKey struct {
+	Type restic.FileType
+	Name string
+}
+
+type cacheEntry struct {
+	addr oid.Address
+	size int64
+	// verified is set when the object was seen by this process, entries
+	// loaded from disk may refer to objects removed in the meantime.
+	verified bool
+}
+
+// objectCache maps restic files to the neofs objects they are stored in, so
+// that accessing a file does not require searching for it first.
+type objectCache struct {
+	m       sync.Mutex
+	entries map[cacheKey]cacheEntry
+	// objects maps encoded object IDs back to the files stored in them
+	objects map[string]cacheKey
+
+	// path is the file the cache is persisted in, it is empty if the cache
+	// is kept in memory only.
+	path    string
+	changed bool
+}
+
+// persistedEntry is the on-disk representation of a cache entry.
+type persistedEntry struct {
+	Type   restic.FileType `json:"type"`
+	Name   string          `json:"name"`
+	Object string          `json:"object"`
+	Size   int64           `json:"size"`
+}
+
+func newObjectCache() *objectCache {
+	return &objectCache{
+		entries: make(map[cacheKey]cacheEntry),
+		objects: make(map[string]cacheKey),
+	}
+}
+
+// cacheable returns true if files of type t are stored in the cache. Lock
+// files are created and removed all the time by all clients, caching them
+// is not worth it.
+func cacheable(t restic.FileType) bool {
+	return t != restic.LockFile
+}
+
+func newCacheKey(h restic.Handle) cacheKey {
+	return cacheKey{Type: h.Type, Name: getName(h)}
+}
+
+func (c *objectCache) get(h restic.Handle) (cacheEntry, bool) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	e, ok := c.entries[newCacheKey(h)]
+	return e, ok
+}
+
+// getObject returns the file stored in the object objID. As objects are
+// immutable, the result is valid even for entries not verified yet.
+func (c *objectCache) getObject(objID oid.ID) (cacheKey, cacheEntry, bool) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	key, ok := c.objects[objID.EncodeToString()]
+	if !ok {
+		return cacheKey{}, cacheEntry{}, false
+	}
+	return key, c.entries[key], true
+}
+
+// set stores e for key, the caller must hold the lock.
+func (c *objectCache) set(key cacheKey, e cacheEntry) {
+	if old, ok := c.entries[key]; ok {
+		delete(c.objects, old.addr.Object().EncodeToString())
+	}
+	c.entries[key] = e
+	c.objects[e.addr.Object().EncodeToString()] = key
+}
+
+func (c *objectCache) add(h restic.Handle, addr oid.Address, size int64) {
+	if !cacheable(h.Type) {
+		return
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.set(newCacheKey(h), cacheEntry{addr: addr, size: size, verified: true})
+	c.changed = true
+}
+
+func (c *objectCache) remove(h restic.Handle) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	key := newCacheKey(h)
+	if e, ok := c.entries[key]; ok {
+		delete(c.objects, e.addr.Object().EncodeToString())
+		delete(c.entries, key)
+		c.changed = true
+	}
+}
+
+func (c *objectCache) clear() {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.entries = make(map[cacheKey]cacheEntry)
+	c.objects = make(map[string]cacheKey)
+	c.changed = true
+}
+
+// load reads the entries persisted for the container cnrID from disk.
+func (c *objectCache) load(cnrID cid.ID) error {
+	if c.path == "" {
+		return nil
+	}
+
+	f, err := os.Open(c.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var list []persistedEntry
+	if err = json.NewDecoder(f).Decode(&list); err != nil {
+		return fmt.Errorf("decode %v: %w", c.path, err)
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	for _, pe := range list {
+		var objID oid.ID
+		if err = objID.DecodeString(pe.Object); err != nil {
+			return fmt.Errorf("decode object id: %w", err)
+		}
+
+		key := cacheKey{Type: pe.Type, Name: pe.Name}
+		if _, ok := c.entries[key]; ok {
+			continue
+		}
+		c.set(key, cacheEntry{addr: newAddress(cnrID, objID), size: pe.Size})
+	}
+
+	debug.Log("loaded %d entries from %v", len(list), c.path)
+	return nil
+}
+
+// save writes the cache to disk if it has been modified.
+func (c *objectCache) save() error {
+	if c.path == "" {
+		return nil
+	}
+
+	c.m.Lock()
+	if !c.changed {
+		c.m.Unlock()
+		return nil
+	}
+
+	list := make([]persistedEntry, 0, len(c.entries))
+	for key, e := range c.entries {
+		list = append(list, persistedEntry{
+			Type:   key.Type,
+			Name:   key.Name,
+			Object: e.addr.Object().EncodeToString(),
+			Size:   e.size,
+		})
+	}
+	c.changed = false
+	c.m.Unlock()
+
+	buf, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return err
+	}
+
+	// write to a temporary file first, so that concurrent restic processes
+	// never see a partially written cache
+	f, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+"-")
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(buf)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), c.path)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	debug.Log("saved %d entries to %v", len(list), c.path)
+	return nil
+}
+
+// drop removes the persisted cache file.
+func (c *objectCache) drop() error {
+	if c.path == "" {
+		return nil
+	}
+
+	err := os.Remove(c.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
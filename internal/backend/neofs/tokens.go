@@ -0,0 +1,107 @@
+package neofs
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/nspcc-dev/neo-go/pkg/crypto/keys"
+	"github.com/nspcc-dev/neofs-sdk-go/bearer"
+	neofsecdsa "github.com/nspcc-dev/neofs-sdk-go/crypto/ecdsa"
+	"github.com/nspcc-dev/neofs-sdk-go/session"
+	"github.com/nspcc-dev/neofs-sdk-go/user"
+)
+
+// tokens are the credentials the container owner issued to grant another key
+// access to the container. Both of them are optional.
+type tokens struct {
+	bearer  *bearer.Token
+	session *session.Object
+}
+
+// loadTokens reads the token files from cfg.
+func loadTokens(cfg Config) (tokens, error) {
+	var res tokens
+
+	if cfg.BearerToken != "" {
+		var tok bearer.Token
+		if err := readToken(cfg.BearerToken, tok.Unmarshal, tok.UnmarshalJSON); err != nil {
+			return tokens{}, fmt.Errorf("read bearer token: %w", err)
+		}
+		if !tok.VerifySignature() {
+			return tokens{}, fmt.Errorf("invalid signature of bearer token '%s'", cfg.BearerToken)
+		}
+		res.bearer = &tok
+	}
+
+	if cfg.SessionToken != "" {
+		var tok session.Object
+		if err := readToken(cfg.SessionToken, tok.Unmarshal, tok.UnmarshalJSON); err != nil {
+			return tokens{}, fmt.Errorf("read session token: %w", err)
+		}
+		if !tok.VerifySignature() {
+			return tokens{}, fmt.Errorf("invalid signature of session token '%s'", cfg.SessionToken)
+		}
+		res.session = &tok
+	}
+
+	return res, nil
+}
+
+// readToken decodes the token from the file, which may hold either the
+// binary or the JSON encoding like the files created by neofs-cli.
+func readToken(filename string, unmarshal, unmarshalJSON func([]byte) error) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	if errBin := unmarshal(data); errBin != nil {
+		if errJSON := unmarshalJSON(data); errJSON != nil {
+			return fmt.Errorf("neither binary (%v) nor JSON (%v) token", errBin, errJSON)
+		}
+	}
+
+	return nil
+}
+
+func (t tokens) empty() bool {
+	return t.bearer == nil && t.session == nil
+}
+
+// issuer returns the user who issued the tokens, who is expected to own the
+// container.
+func (t tokens) issuer() (user.ID, bool) {
+	if t.bearer != nil {
+		return bearer.ResolveIssuer(*t.bearer), true
+	}
+	if t.session != nil {
+		return t.session.Issuer(), true
+	}
+	return user.ID{}, false
+}
+
+// checkKey returns an error if the tokens cannot be used with key.
+func (t tokens) checkKey(key *keys.PrivateKey) error {
+	var signer user.ID
+	user.IDFromKey(&signer, key.PrivateKey.PublicKey)
+
+	if t.bearer != nil && !t.bearer.AssertUser(signer) {
+		return errors.New("the bearer token was issued to another user")
+	}
+	if t.session != nil && !t.session.AssertAuthKey((*neofsecdsa.PublicKey)(&key.PrivateKey.PublicKey)) {
+		return errors.New("the session token was issued to another key")
+	}
+	return nil
+}
+
+// check returns an error if a token is not valid at the epoch.
+func (t tokens) check(epoch uint64) error {
+	if t.bearer != nil && t.bearer.InvalidAt(epoch) {
+		return fmt.Errorf("bearer token is not valid at the current epoch %d, it has probably expired", epoch)
+	}
+	if t.session != nil && t.session.InvalidAt(epoch) {
+		return fmt.Errorf("session token is not valid at the current epoch %d, it has probably expired", epoch)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package neofs
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/nspcc-dev/neo-go/pkg/crypto/keys"
+	"github.com/nspcc-dev/neofs-sdk-go/bearer"
+	cidtest "github.com/nspcc-dev/neofs-sdk-go/container/id/test"
+	neofsecdsa "github.com/nspcc-dev/neofs-sdk-go/crypto/ecdsa"
+	"github.com/nspcc-dev/neofs-sdk-go/eacl"
+	"github.com/nspcc-dev/neofs-sdk-go/session"
+	"github.com/nspcc-dev/neofs-sdk-go/user"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTokenFile(t testing.TB, name string, data []byte) string {
+	filename := filepath.Join(t.TempDir(), name)
+	require.NoError(t, ioutil.WriteFile(filename, data, 0600))
+	return filename
+}
+
+func TestLoadTokens(t *testing.T) {
+	ownerKey, err := keys.NewPrivateKey()
+	require.NoError(t, err)
+	hostKey, err := keys.NewPrivateKey()
+	require.NoError(t, err)
+	otherKey, err := keys.NewPrivateKey()
+	require.NoError(t, err)
+
+	var owner user.ID
+	user.IDFromKey(&owner, ownerKey.PrivateKey.PublicKey)
+
+	cnrID := cidtest.ID()
+
+	var table eacl.Table
+	table.SetCID(cnrID)
+
+	var bt bearer.Token
+	bt.SetEACLTable(table)
+	bt.SetExp(10)
+	require.NoError(t, bt.Sign(ownerKey.PrivateKey))
+
+	var st session.Object
+	st.SetID(uuid.New())
+	st.SetExp(5)
+	st.BindContainer(cnrID)
+	st.ForVerb(session.VerbObjectPut)
+	st.SetAuthKey((*neofsecdsa.PublicKey)(&hostKey.PrivateKey.PublicKey))
+	require.NoError(t, st.Sign(ownerKey.PrivateKey))
+	stJSON, err := st.MarshalJSON()
+	require.NoError(t, err)
+
+	cfg := NewConfig()
+	cfg.BearerToken = writeTokenFile(t, "bearer", bt.Marshal())
+	cfg.SessionToken = writeTokenFile(t, "session.json", stJSON)
+
+	tok, err := loadTokens(cfg)
+	require.NoError(t, err)
+	require.False(t, tok.empty())
+
+	issuer, ok := tok.issuer()
+	require.True(t, ok)
+	require.Equal(t, owner, issuer)
+
+	require.NoError(t, tok.checkKey(hostKey))
+	require.Error(t, tok.checkKey(otherKey))
+
+	require.NoError(t, tok.check(5))
+	require.Error(t, tok.check(6))
+	require.Error(t, tok.check(11))
+
+	// a session token is bound to the key of the wallet
+	_, err = getKey(cfg, tok)
+	require.Error(t, err)
+
+	// a bearer token can be used with a random key
+	cfg.SessionToken = ""
+	tok, err = loadTokens(cfg)
+	require.NoError(t, err)
+	key, err := getKey(cfg, tok)
+	require.NoError(t, err)
+	require.NoError(t, tok.checkKey(key))
+	require.NoError(t, tok.check(10))
+	require.Error(t, tok.check(11))
+
+	cfg.BearerToken = writeTokenFile(t, "garbage", []byte("garbage"))
+	_, err = loadTokens(cfg)
+	require.Error(t, err)
+
+	cfg.BearerToken = ""
+	tok, err = loadTokens(cfg)
+	require.NoError(t, err)
+	require.True(t, tok.empty())
+	_, err = getKey(cfg, tok)
+	require.Error(t, err)
+}
@@ -2,19 +2,24 @@ package neofs
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"strconv"
 	"time"
 
 	"github.com/nspcc-dev/neo-go/cli/flags"
+	"github.com/nspcc-dev/neo-go/pkg/crypto/keys"
 	"github.com/nspcc-dev/neo-go/pkg/wallet"
 	"github.com/nspcc-dev/neofs-sdk-go/container"
+	"github.com/nspcc-dev/neofs-sdk-go/container/acl"
 	cid "github.com/nspcc-dev/neofs-sdk-go/container/id"
+	"github.com/nspcc-dev/neofs-sdk-go/netmap"
 	"github.com/nspcc-dev/neofs-sdk-go/object"
 	oid "github.com/nspcc-dev/neofs-sdk-go/object/id"
 	"github.com/nspcc-dev/neofs-sdk-go/pool"
 	"github.com/nspcc-dev/neofs-sdk-go/user"
+	"github.com/restic/restic/internal/debug"
 )
 
 // BuffCloser is wrapper to load files from neofs.
@@ -26,13 +31,20 @@ func (bc *BuffCloser) Close() error {
 	return nil
 }
 
-func createPool(ctx context.Context, acc *wallet.Account, cfg Config) (*pool.Pool, error) {
+func createPool(ctx context.Context, key *keys.PrivateKey, cfg Config) (*pool.Pool, error) {
+	endpoints, err := parseEndpoints(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
 	var prm pool.InitParameters
-	prm.SetKey(&acc.PrivateKey().PrivateKey)
+	prm.SetKey(&key.PrivateKey)
 	prm.SetNodeDialTimeout(cfg.Timeout)
 	prm.SetHealthcheckTimeout(cfg.Timeout)
 	prm.SetClientRebalanceInterval(cfg.RebalanceInterval)
-	prm.AddNode(pool.NewNodeParam(1, cfg.Endpoint, 1))
+	for _, ep := range endpoints {
+		prm.AddNode(pool.NewNodeParam(ep.priority, ep.address, ep.weight))
+	}
 
 	p, err := pool.NewPool(prm)
 	if err != nil {
@@ -46,6 +58,23 @@ func createPool(ctx context.Context, acc *wallet.Account, cfg Config) (*pool.Poo
 	return p, nil
 }
 
+// getKey returns the key requests are signed with. It is taken from the
+// wallet, a random key is used if there is no wallet, but a bearer token.
+func getKey(cfg Config, tok tokens) (*keys.PrivateKey, error) {
+	if cfg.Wallet == "" {
+		if tok.bearer == nil || tok.session != nil {
+			return nil, errors.New("no wallet given")
+		}
+		return keys.NewPrivateKey()
+	}
+
+	acc, err := getAccount(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return acc.PrivateKey(), nil
+}
+
 func getAccount(cfg Config) (*wallet.Account, error) {
 	w, err := wallet.NewWalletFromFile(cfg.Wallet)
 	if err != nil {
@@ -68,7 +97,7 @@ func getAccount(cfg Config) (*wallet.Account, error) {
 	return acc, nil
 }
 
-func getContainerID(ctx context.Context, client *pool.Pool, owner user.ID, container string) (cid.ID, error) {
+func getContainerID(ctx context.Context, client Client, owner user.ID, container string) (cid.ID, error) {
 	var cnrID cid.ID
 	if err := cnrID.DecodeString(container); err != nil {
 		return findContainerID(ctx, client, owner, container)
@@ -76,20 +105,14 @@ func getContainerID(ctx context.Context, client *pool.Pool, owner user.ID, conta
 	return cnrID, nil
 }
 
-func findContainerID(ctx context.Context, client *pool.Pool, owner user.ID, containerName string) (cid.ID, error) {
-	var prm pool.PrmContainerList
-	prm.SetOwnerID(owner)
-
-	containerIDs, err := client.ListContainers(ctx, prm)
+func findContainerID(ctx context.Context, client Client, owner user.ID, containerName string) (cid.ID, error) {
+	containerIDs, err := client.ListContainers(ctx, owner)
 	if err != nil {
 		return cid.ID{}, fmt.Errorf("list containers: %w", err)
 	}
 
 	for _, cnrID := range containerIDs {
-		var prmGet pool.PrmContainerGet
-		prmGet.SetContainerID(cnrID)
-
-		cnr, err := client.GetContainer(ctx, prmGet)
+		cnr, err := client.GetContainer(ctx, cnrID)
 		if err != nil {
 			return cid.ID{}, fmt.Errorf("get container: %w", err)
 		}
@@ -99,7 +122,46 @@ func findContainerID(ctx context.Context, client *pool.Pool, owner user.ID, cont
 		}
 	}
 
-	return cid.ID{}, fmt.Errorf("container '%s' not found", containerName)
+	return cid.ID{}, fmt.Errorf("%w: '%s'", errContainerNotFound, containerName)
+}
+
+const (
+	containerPollInterval = 5 * time.Second
+	containerWaitTimeout  = 2 * time.Minute
+)
+
+var errContainerNotFound = errors.New("container not found")
+
+// createContainer creates a container named after cfg.Container with the
+// placement policy and basic ACL from cfg. It returns when the container is
+// visible in the network.
+func createContainer(ctx context.Context, client Client, owner user.ID, cfg Config) error {
+	var pp netmap.PlacementPolicy
+	if err := pp.DecodeString(cfg.Policy); err != nil {
+		return fmt.Errorf("invalid placement policy %q: %w", cfg.Policy, err)
+	}
+
+	var basicACL acl.Basic
+	if err := basicACL.DecodeString(cfg.BasicACL); err != nil {
+		return fmt.Errorf("invalid basic ACL %q: %w", cfg.BasicACL, err)
+	}
+
+	var cnr container.Container
+	cnr.Init()
+	cnr.SetPlacementPolicy(pp)
+	cnr.SetBasicACL(basicACL)
+	cnr.SetOwner(owner)
+
+	container.SetName(&cnr, cfg.Container)
+	container.SetCreationTime(&cnr, time.Now())
+
+	cnrID, err := client.PutContainer(ctx, cnr)
+	if err != nil {
+		return fmt.Errorf("put container: %w", err)
+	}
+	debug.Log("created container %s for %q", cnrID, cfg.Container)
+
+	return nil
 }
 
 func formRawObject(own *user.ID, cnrID cid.ID, name string, header map[string]string) *object.Object {
@@ -146,3 +208,20 @@ func getNameAttr(obj object.Object) string {
 	objID, _ := obj.ID()
 	return objID.EncodeToString()
 }
+
+// lengthReader fails with an error instead of io.EOF if the payload turns
+// out to be shorter or longer than announced, so that incomplete uploads are
+// not stored.
+type lengthReader struct {
+	rd        io.Reader
+	remaining int64
+}
+
+func (r *lengthReader) Read(p []byte) (int, error) {
+	n, err := r.rd.Read(p)
+	r.remaining -= int64(n)
+	if err == io.EOF && r.remaining != 0 {
+		return n, fmt.Errorf("payload length differs from the announced size by %d bytes", r.remaining)
+	}
+	return n, err
+}
@@ -3,6 +3,7 @@ package rest_test
 import (
 	"context"
 	"net"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"os/exec"
@@ -10,7 +11,9 @@ import (
 	"time"
 
 	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/mem"
 	"github.com/restic/restic/internal/backend/rest"
+	"github.com/restic/restic/internal/backend/rest/server"
 	"github.com/restic/restic/internal/backend/test"
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
@@ -121,6 +124,28 @@ func TestBackendREST(t *testing.T) {
 	newTestSuite(ctx, t, serverURL, false).RunTests(t)
 }
 
+// runServeServer serves an in-memory backend the same way as "restic serve".
+func runServeServer(t testing.TB) (*url.URL, func()) {
+	srv := httptest.NewServer(server.New(mem.New(), server.Options{}))
+
+	url, err := url.Parse(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return url, srv.Close
+}
+
+func TestBackendRESTServe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	serverURL, cleanup := runServeServer(t)
+	defer cleanup()
+
+	newTestSuite(ctx, t, serverURL, false).RunTests(t)
+}
+
 func TestBackendRESTExternalServer(t *testing.T) {
 	repostr := os.Getenv("RESTIC_TEST_REST_REPOSITORY")
 	if repostr == "" {
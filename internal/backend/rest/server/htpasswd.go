@@ -0,0 +1,93 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// Htpasswd holds the users and password hashes read from an htpasswd file.
+// The bcrypt and SHA-1 ({SHA}) hashes created by the htpasswd tool are
+// supported.
+type Htpasswd struct {
+	users map[string]string
+}
+
+// LoadHtpasswd reads the htpasswd file.
+func LoadHtpasswd(filename string) (*Htpasswd, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "Open")
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	h, err := ParseHtpasswd(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse %v", filename)
+	}
+	return h, nil
+}
+
+// ParseHtpasswd parses the content of an htpasswd file.
+func ParseHtpasswd(rd io.Reader) (*Htpasswd, error) {
+	h := &Htpasswd{users: make(map[string]string)}
+
+	sc := bufio.NewScanner(rd)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(text, ":", 2)
+		if len(fields) != 2 || fields[0] == "" {
+			return nil, errors.Errorf("line %d: invalid entry", line)
+		}
+
+		user, hash := fields[0], fields[1]
+		if !isBcrypt(hash) && !strings.HasPrefix(hash, "{SHA}") {
+			return nil, errors.Errorf("line %d: unsupported password hash for user %q, use bcrypt", line, user)
+		}
+		h.users[user] = hash
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func isBcrypt(hash string) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if strings.HasPrefix(hash, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate returns true if the password of user is correct.
+func (h *Htpasswd) Validate(user, password string) bool {
+	hash, ok := h.users[user]
+	if !ok {
+		return false
+	}
+
+	if isBcrypt(hash) {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	}
+
+	sum := sha1.Sum([]byte(password))
+	expected := "{SHA}" + base64.StdEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(expected)) == 1
+}
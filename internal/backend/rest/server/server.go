@@ -0,0 +1,401 @@
+// Package server implements the server side of the REST backend protocol on
+// top of any restic.Backend.
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/restic/restic/internal/backend/rest"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// Options configure the server.
+type Options struct {
+	// AppendOnly rejects requests to remove files, except for locks.
+	AppendOnly bool
+	// Auth restricts the access to the users listed in the htpasswd file.
+	Auth *Htpasswd
+	// Create is run for requests to create the repository, if set. The
+	// returned backend is served from then on.
+	Create func(ctx context.Context) (restic.Backend, error)
+}
+
+// Server serves the files of a backend using the same URL layout as the
+// rest-server, with the repository at the root.
+type Server struct {
+	m    sync.Mutex
+	be   restic.Backend
+	opts Options
+}
+
+// make sure the server can be used as an http.Handler
+var _ http.Handler = &Server{}
+
+// New returns a server for be. If be is nil, all files are reported as
+// missing until the repository is created.
+func New(be restic.Backend, opts Options) *Server {
+	return &Server{be: be, opts: opts}
+}
+
+// backend returns the served backend, it is nil until a backend exists.
+func (s *Server) backend() restic.Backend {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.be
+}
+
+// Close closes the served backend.
+func (s *Server) Close() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.be == nil {
+		return nil
+	}
+	err := s.be.Close()
+	s.be = nil
+	return err
+}
+
+// fileTypes maps the directories of the REST layout to the file types.
+var fileTypes = map[string]restic.FileType{
+	"data":      restic.PackFile,
+	"keys":      restic.KeyFile,
+	"locks":     restic.LockFile,
+	"snapshots": restic.SnapshotFile,
+	"index":     restic.IndexFile,
+}
+
+// ServeHTTP dispatches the requests by method and path.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	debug.Log("%v %v", r.Method, r.URL.Path)
+
+	if s.opts.Auth != nil {
+		user, password, ok := r.BasicAuth()
+		if !ok || !s.opts.Auth.Validate(user, password) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="restic"`)
+			httpError(w, http.StatusUnauthorized)
+			return
+		}
+	}
+
+	elems := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.URL.Path == "/":
+		if r.Method != http.MethodPost || r.URL.Query().Get("create") != "true" {
+			httpError(w, http.StatusMethodNotAllowed)
+			return
+		}
+		s.createRepo(w, r)
+	case len(elems) == 1 && elems[0] == "config":
+		s.serveFile(w, r, restic.Handle{Type: restic.ConfigFile})
+	case len(elems) == 1 && strings.HasSuffix(r.URL.Path, "/"):
+		t, ok := fileTypes[elems[0]]
+		if !ok {
+			httpError(w, http.StatusNotFound)
+			return
+		}
+		if r.Method != http.MethodGet {
+			httpError(w, http.StatusMethodNotAllowed)
+			return
+		}
+		s.listFiles(w, r, t)
+	case len(elems) == 2:
+		t, ok := fileTypes[elems[0]]
+		if !ok {
+			httpError(w, http.StatusNotFound)
+			return
+		}
+		if _, err := restic.ParseID(elems[1]); err != nil {
+			httpError(w, http.StatusBadRequest)
+			return
+		}
+		s.serveFile(w, r, restic.Handle{Type: t, Name: elems[1]})
+	default:
+		httpError(w, http.StatusNotFound)
+	}
+}
+
+func (s *Server) serveFile(w http.ResponseWriter, r *http.Request, h restic.Handle) {
+	be := s.backend()
+	if be == nil {
+		debug.Log("no backend for %v, the repository was not created yet", h)
+		httpError(w, http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead:
+		s.statFile(w, r, be, h)
+	case http.MethodGet:
+		s.loadFile(w, r, be, h)
+	case http.MethodPost:
+		s.saveFile(w, r, be, h)
+	case http.MethodDelete:
+		s.removeFile(w, r, be, h)
+	default:
+		httpError(w, http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) createRepo(w http.ResponseWriter, r *http.Request) {
+	if s.opts.Create == nil {
+		return
+	}
+
+	be, err := s.opts.Create(r.Context())
+	if err != nil {
+		debug.Log("create repository failed: %v", err)
+		httpError(w, http.StatusInternalServerError)
+		return
+	}
+
+	s.m.Lock()
+	old := s.be
+	s.be = be
+	s.m.Unlock()
+
+	if old != nil {
+		if err := old.Close(); err != nil {
+			debug.Log("closing the previous backend failed: %v", err)
+		}
+	}
+}
+
+func (s *Server) statFile(w http.ResponseWriter, r *http.Request, be restic.Backend, h restic.Handle) {
+	fi, err := be.Stat(r.Context(), h)
+	if err != nil {
+		backendError(w, be, "stat "+h.String(), err)
+		return
+	}
+
+	w.Header().Set("Content-Length", strconv.FormatInt(fi.Size, 10))
+}
+
+func (s *Server) loadFile(w http.ResponseWriter, r *http.Request, be restic.Backend, h restic.Handle) {
+	fi, err := be.Stat(r.Context(), h)
+	if err != nil {
+		backendError(w, be, "stat "+h.String(), err)
+		return
+	}
+
+	offset, length, err := parseRange(r.Header.Get("Range"), fi.Size)
+	if err != nil {
+		debug.Log("invalid range for %v: %v", h, err)
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", fi.Size))
+		httpError(w, http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+
+	status := http.StatusOK
+	if offset != 0 || length != fi.Size {
+		status = http.StatusPartialContent
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, fi.Size))
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+
+	if length == 0 {
+		w.WriteHeader(status)
+		return
+	}
+
+	// the status is only sent once the backend returned the first data, so
+	// that failures to open the file can still be reported
+	written := false
+	err = be.Load(r.Context(), h, int(length), offset, func(rd io.Reader) error {
+		if written {
+			return errors.New("file was already partially sent")
+		}
+		written = true
+		w.WriteHeader(status)
+		_, err := io.Copy(w, rd)
+		return err
+	})
+	if err != nil && !written {
+		backendError(w, be, "load "+h.String(), err)
+		return
+	}
+	if err != nil {
+		debug.Log("sending %v failed: %v", h, err)
+	}
+}
+
+func (s *Server) saveFile(w http.ResponseWriter, r *http.Request, be restic.Backend, h restic.Handle) {
+	ok, err := be.Test(r.Context(), h)
+	if err != nil {
+		backendError(w, be, "test "+h.String(), err)
+		return
+	}
+	if ok {
+		debug.Log("refusing to overwrite %v", h)
+		httpError(w, http.StatusForbidden)
+		return
+	}
+
+	f, err := ioutil.TempFile("", "restic-serve-")
+	if err != nil {
+		backendError(w, be, "create temporary file", err)
+		return
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+
+	// the names of all files except the config are the SHA-256 hashes of
+	// their content
+	id := sha256.New()
+	hashers := []io.Writer{f, id}
+	beHash := be.Hasher()
+	if beHash != nil {
+		hashers = append(hashers, beHash)
+	}
+
+	if _, err = io.Copy(io.MultiWriter(hashers...), r.Body); err != nil {
+		debug.Log("receiving %v failed: %v", h, err)
+		httpError(w, http.StatusBadRequest)
+		return
+	}
+
+	if h.Type != restic.ConfigFile && h.Name != restic.IDFromHash(id.Sum(nil)).String() {
+		debug.Log("content of %v does not match its name", h)
+		httpError(w, http.StatusBadRequest)
+		return
+	}
+
+	rd, err := restic.NewFileReader(f, sum(beHash))
+	if err != nil {
+		backendError(w, be, "read temporary file", err)
+		return
+	}
+
+	if err = be.Save(r.Context(), h, rd); err != nil {
+		backendError(w, be, "save "+h.String(), err)
+		return
+	}
+}
+
+func sum(h hash.Hash) []byte {
+	if h == nil {
+		return nil
+	}
+	return h.Sum(nil)
+}
+
+func (s *Server) removeFile(w http.ResponseWriter, r *http.Request, be restic.Backend, h restic.Handle) {
+	if s.opts.AppendOnly && h.Type != restic.LockFile {
+		debug.Log("refusing to remove %v in append-only mode", h)
+		httpError(w, http.StatusForbidden)
+		return
+	}
+
+	if err := be.Remove(r.Context(), h); err != nil {
+		backendError(w, be, "remove "+h.String(), err)
+		return
+	}
+}
+
+func (s *Server) listFiles(w http.ResponseWriter, r *http.Request, t restic.FileType) {
+	type file struct {
+		Name string `json:"name"`
+		Size int64  `json:"size"`
+	}
+
+	be := s.backend()
+	if be == nil {
+		debug.Log("no backend for listing %v, the repository was not created yet", t)
+		httpError(w, http.StatusNotFound)
+		return
+	}
+
+	files := []file{}
+	err := be.List(r.Context(), t, func(fi restic.FileInfo) error {
+		files = append(files, file{Name: fi.Name, Size: fi.Size})
+		return nil
+	})
+	if err != nil {
+		backendError(w, be, "list "+string(t), err)
+		return
+	}
+
+	// clients of protocol version 1 only expect the names
+	var res interface{} = files
+	contentType := rest.ContentTypeV2
+	if r.Header.Get("Accept") != rest.ContentTypeV2 {
+		names := make([]string, 0, len(files))
+		for _, f := range files {
+			names = append(names, f.Name)
+		}
+		res = names
+		contentType = rest.ContentTypeV1
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if err = json.NewEncoder(w).Encode(res); err != nil {
+		debug.Log("sending list of %v failed: %v", t, err)
+	}
+}
+
+// backendError replies with the status matching err.
+func backendError(w http.ResponseWriter, be restic.Backend, msg string, err error) {
+	debug.Log("%v failed: %v", msg, err)
+	if be.IsNotExist(err) {
+		httpError(w, http.StatusNotFound)
+		return
+	}
+	httpError(w, http.StatusInternalServerError)
+}
+
+func httpError(w http.ResponseWriter, code int) {
+	http.Error(w, http.StatusText(code), code)
+}
+
+// parseRange returns the offset and length of the byte range requested by
+// the Range header for a file of the given size. Only single ranges are
+// supported, which is all the REST backend requests.
+func parseRange(header string, size int64) (offset, length int64, err error) {
+	if header == "" {
+		return 0, size, nil
+	}
+
+	if !strings.HasPrefix(header, "bytes=") || strings.Contains(header, ",") {
+		return 0, 0, errors.Errorf("unsupported range %q", header)
+	}
+
+	spec := strings.SplitN(strings.TrimPrefix(header, "bytes="), "-", 2)
+	if len(spec) != 2 || spec[0] == "" {
+		return 0, 0, errors.Errorf("unsupported range %q", header)
+	}
+
+	offset, err = strconv.ParseInt(spec[0], 10, 64)
+	if err != nil || offset < 0 || offset > size {
+		return 0, 0, errors.Errorf("invalid range start in %q", header)
+	}
+
+	end := size - 1
+	if spec[1] != "" {
+		end, err = strconv.ParseInt(spec[1], 10, 64)
+		if err != nil || end < offset {
+			return 0, 0, errors.Errorf("invalid range end in %q", header)
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+
+	return offset, end - offset + 1, nil
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/mem"
+	"github.com/restic/restic/internal/backend/rest"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func newTestClient(t testing.TB, opts Options) (*rest.Backend, func()) {
+	srv := httptest.NewServer(New(mem.New(), opts))
+
+	cfg := rest.NewConfig()
+	u, err := url.Parse(srv.URL + "/")
+	rtest.OK(t, err)
+	cfg.URL = u
+
+	tr, err := backend.Transport(backend.TransportOptions{})
+	rtest.OK(t, err)
+
+	be, err := rest.Create(context.TODO(), cfg, tr)
+	rtest.OK(t, err)
+	return be, srv.Close
+}
+
+func save(t testing.TB, be restic.Backend, tpe restic.FileType, data []byte) restic.Handle {
+	h := restic.Handle{Type: tpe, Name: restic.Hash(data).String()}
+	rtest.OK(t, be.Save(context.TODO(), h, restic.NewByteReader(data, be.Hasher())))
+	return h
+}
+
+func TestAppendOnly(t *testing.T) {
+	be, cleanup := newTestClient(t, Options{AppendOnly: true})
+	defer cleanup()
+
+	h := save(t, be, restic.PackFile, []byte("pack"))
+	rtest.Assert(t, be.Remove(context.TODO(), h) != nil, "pack was removed in append-only mode")
+
+	// files are never overwritten
+	err := be.Save(context.TODO(), h, restic.NewByteReader([]byte("pack"), be.Hasher()))
+	rtest.Assert(t, err != nil, "pack was overwritten")
+
+	// locks are removed by all commands
+	h = save(t, be, restic.LockFile, []byte("lock"))
+	rtest.OK(t, be.Remove(context.TODO(), h))
+}
+
+func TestSaveVerifiesName(t *testing.T) {
+	be, cleanup := newTestClient(t, Options{})
+	defer cleanup()
+
+	h := restic.Handle{Type: restic.PackFile, Name: restic.Hash([]byte("other")).String()}
+	err := be.Save(context.TODO(), h, restic.NewByteReader([]byte("pack"), be.Hasher()))
+	rtest.Assert(t, err != nil, "file with wrong name was saved")
+
+	ok, err := be.Test(context.TODO(), h)
+	rtest.OK(t, err)
+	rtest.Assert(t, !ok, "file with wrong name was saved")
+}
+
+func TestAuth(t *testing.T) {
+	// the SHA-1 hash of "password"
+	auth, err := ParseHtpasswd(strings.NewReader("user:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=\n"))
+	rtest.OK(t, err)
+
+	srv := httptest.NewServer(New(mem.New(), Options{Auth: auth}))
+	defer srv.Close()
+
+	for _, test := range []struct {
+		user, password string
+		status         int
+	}{
+		{"", "", http.StatusUnauthorized},
+		{"user", "wrong", http.StatusUnauthorized},
+		{"other", "password", http.StatusUnauthorized},
+		{"user", "password", http.StatusOK},
+	} {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/data/", nil)
+		rtest.OK(t, err)
+		if test.user != "" {
+			req.SetBasicAuth(test.user, test.password)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		rtest.OK(t, err)
+		rtest.OK(t, resp.Body.Close())
+		rtest.Equals(t, test.status, resp.StatusCode)
+	}
+}
+
+func TestParseHtpasswd(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	rtest.OK(t, err)
+
+	var buf bytes.Buffer
+	buf.WriteString("# comment\n\n")
+	buf.WriteString("alice:" + string(hash) + "\n")
+	buf.WriteString("bob:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=\n")
+
+	h, err := ParseHtpasswd(&buf)
+	rtest.OK(t, err)
+	rtest.Assert(t, h.Validate("alice", "secret"), "bcrypt password rejected")
+	rtest.Assert(t, !h.Validate("alice", "password"), "wrong bcrypt password accepted")
+	rtest.Assert(t, h.Validate("bob", "password"), "SHA-1 password rejected")
+	rtest.Assert(t, !h.Validate("bob", "secret"), "wrong SHA-1 password accepted")
+
+	for _, data := range []string{
+		"alice\n",
+		":{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=\n",
+		"alice:$apr1$salt$hash\n",
+	} {
+		_, err := ParseHtpasswd(strings.NewReader(data))
+		rtest.Assert(t, err != nil, "no error for %q", data)
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	for _, test := range []struct {
+		header         string
+		size           int64
+		offset, length int64
+		err            bool
+	}{
+		{"", 10, 0, 10, false},
+		{"bytes=0-", 10, 0, 10, false},
+		{"bytes=2-", 10, 2, 8, false},
+		{"bytes=2-4", 10, 2, 3, false},
+		{"bytes=2-100", 10, 2, 8, false},
+		{"bytes=10-", 10, 10, 0, false},
+		{"bytes=11-", 10, 0, 0, true},
+		{"bytes=4-2", 10, 0, 0, true},
+		{"bytes=-2", 10, 0, 0, true},
+		{"bytes=0-1,4-5", 10, 0, 0, true},
+		{"items=0-1", 10, 0, 0, true},
+	} {
+		offset, length, err := parseRange(test.header, test.size)
+		if test.err {
+			rtest.Assert(t, err != nil, "no error for %q", test.header)
+			continue
+		}
+		rtest.OK(t, err)
+		rtest.Equals(t, test.offset, offset)
+		rtest.Equals(t, test.length, length)
+	}
+}
+
+func TestCreateBackend(t *testing.T) {
+	// the backend does not exist before the repository is created
+	created := 0
+	srv := httptest.NewServer(New(nil, Options{
+		Create: func(ctx context.Context) (restic.Backend, error) {
+			created++
+			return mem.New(), nil
+		},
+	}))
+	defer srv.Close()
+
+	cfg := rest.NewConfig()
+	u, err := url.Parse(srv.URL + "/")
+	rtest.OK(t, err)
+	cfg.URL = u
+
+	tr, err := backend.Transport(backend.TransportOptions{})
+	rtest.OK(t, err)
+
+	be, err := rest.Create(context.TODO(), cfg, tr)
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, created)
+
+	h := save(t, be, restic.PackFile, []byte("pack"))
+	ok, err := be.Test(context.TODO(), h)
+	rtest.OK(t, err)
+	rtest.Assert(t, ok, "file was not saved in the created backend")
+}
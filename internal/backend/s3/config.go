@@ -4,6 +4,7 @@ import (
 	"net/url"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/options"
@@ -20,11 +21,16 @@ type Config struct {
 	Layout        string `option:"layout" help:"use this backend layout (default: auto-detect)"`
 	StorageClass  string `option:"storage-class" help:"set S3 storage class (STANDARD, STANDARD_IA, ONEZONE_IA, INTELLIGENT_TIERING or REDUCED_REDUNDANCY)"`
 
+	DataStorageClass string `option:"data-storage-class" help:"set S3 storage class for packs containing file data (default: storage-class)"`
+
 	Connections   uint   `option:"connections" help:"set a limit for the number of concurrent connections (default: 5)"`
 	MaxRetries    uint   `option:"retries" help:"set the number of retries attempted"`
 	Region        string `option:"region" help:"set region"`
 	BucketLookup  string `option:"bucket-lookup" help:"bucket lookup style: 'auto', 'dns', or 'path'"`
 	ListObjectsV1 bool   `option:"list-objects-v1" help:"use deprecated V1 api for ListObjects calls"`
+
+	ObjectLockMode string        `option:"object-lock-mode" help:"object lock retention mode for data, index and snapshot files (governance or compliance)"`
+	Retention      time.Duration `option:"retention" help:"retention period of data, index and snapshot files when object-lock-mode is set, e.g. 720h"`
 }
 
 // NewConfig returns a new Config with the default values filled in.
@@ -29,6 +29,10 @@ type Backend struct {
 	sem    sema.Semaphore
 	cfg    Config
 	backend.Layout
+
+	// lockMode is the object lock retention mode, if set. Then object
+	// versions are removed instead of adding delete markers.
+	lockMode minio.RetentionMode
 }
 
 // make sure that *Backend implements backend.Backend
@@ -86,6 +90,11 @@ func open(ctx context.Context, cfg Config, rt http.RoundTripper) (*Backend, erro
 		Transport: rt,
 	}
 
+	lockMode, err := retentionMode(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	switch strings.ToLower(cfg.BucketLookup) {
 	case "", "auto":
 		options.BucketLookup = minio.BucketLookupAuto
@@ -108,9 +117,10 @@ func open(ctx context.Context, cfg Config, rt http.RoundTripper) (*Backend, erro
 	}
 
 	be := &Backend{
-		client: client,
-		sem:    sem,
-		cfg:    cfg,
+		client:   client,
+		sem:      sem,
+		cfg:      cfg,
+		lockMode: lockMode,
 	}
 
 	l, err := backend.ParseLayout(ctx, be, cfg.Layout, defaultLayout, cfg.Prefix)
@@ -149,8 +159,9 @@ func Create(ctx context.Context, cfg Config, rt http.RoundTripper) (restic.Backe
 	}
 
 	if !found {
-		// create new bucket with default ACL in default region
-		err = be.client.MakeBucket(ctx, cfg.Bucket, minio.MakeBucketOptions{})
+		// create new bucket with default ACL in default region, object lock
+		// can only be enabled when a bucket is created
+		err = be.client.MakeBucket(ctx, cfg.Bucket, minio.MakeBucketOptions{ObjectLocking: be.lockMode != ""})
 		if err != nil {
 			return nil, errors.Wrap(err, "client.MakeBucket")
 		}
@@ -159,6 +170,40 @@ func Create(ctx context.Context, cfg Config, rt http.RoundTripper) (restic.Backe
 	return be, nil
 }
 
+// retentionMode checks the object lock options and returns the retention
+// mode, which is empty if object lock is not used.
+func retentionMode(cfg Config) (minio.RetentionMode, error) {
+	switch strings.ToLower(cfg.ObjectLockMode) {
+	case "":
+		if cfg.Retention != 0 {
+			return "", errors.New("s3.retention requires s3.object-lock-mode")
+		}
+		return "", nil
+	case "governance":
+		if cfg.Retention <= 0 {
+			return "", errors.New("s3.object-lock-mode requires a positive s3.retention")
+		}
+		return minio.Governance, nil
+	case "compliance":
+		if cfg.Retention <= 0 {
+			return "", errors.New("s3.object-lock-mode requires a positive s3.retention")
+		}
+		return minio.Compliance, nil
+	default:
+		return "", errors.Errorf(`bad object lock mode %q, must be "governance" or "compliance"`, cfg.ObjectLockMode)
+	}
+}
+
+// isRetained returns true if the error is caused by removing an object
+// version which is protected by a retention period or a legal hold. The
+// servers respond with ObjectLocked or, like MinIO, with InvalidRequest, which
+// is also used for other errors. So this must only be checked after removing
+// a version in a bucket with object lock.
+func isRetained(err error) bool {
+	var e minio.ErrorResponse
+	return errors.As(err, &e) && (e.Code == "ObjectLocked" || e.Code == "InvalidRequest")
+}
+
 // isAccessDenied returns true if the error is caused by Access Denied.
 func isAccessDenied(err error) bool {
 	debug.Log("isAccessDenied(%T, %#v)", err, err)
@@ -287,8 +332,14 @@ func (be *Backend) Save(ctx context.Context, h restic.Handle, rd restic.RewindRe
 	be.sem.GetToken()
 	defer be.sem.ReleaseToken()
 
-	opts := minio.PutObjectOptions{StorageClass: be.cfg.StorageClass}
+	opts := minio.PutObjectOptions{StorageClass: storageClass(be.cfg, h)}
 	opts.ContentType = "application/octet-stream"
+	// lock files are removed all the time and must not be retained, the
+	// config and keys are kept mutable so that keys can be revoked
+	if be.lockMode != "" && (h.Type == restic.PackFile || h.Type == restic.IndexFile || h.Type == restic.SnapshotFile) {
+		opts.Mode = be.lockMode
+		opts.RetainUntilDate = time.Now().Add(be.cfg.Retention).UTC()
+	}
 	// the only option with the high-level api is to let the library handle the checksum computation
 	opts.SendContentMd5 = true
 
@@ -305,6 +356,16 @@ func (be *Backend) Save(ctx context.Context, h restic.Handle, rd restic.RewindRe
 	return errors.Wrap(err, "client.PutObject")
 }
 
+// storageClass returns the storage class for the file at h. Packs with file
+// data may be stored in a different class than the metadata of the
+// repository, which is needed for nearly every operation.
+func storageClass(cfg Config, h restic.Handle) string {
+	if h.IsDataPack() && cfg.DataStorageClass != "" {
+		return cfg.DataStorageClass
+	}
+	return cfg.StorageClass
+}
+
 // Load runs fn with a reader that yields the contents of the file at h at the
 // given offset.
 func (be *Backend) Load(ctx context.Context, h restic.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
@@ -412,15 +473,36 @@ func (be *Backend) Remove(ctx context.Context, h restic.Handle) error {
 	objName := be.Filename(h)
 
 	be.sem.GetToken()
-	err := be.client.RemoveObject(ctx, be.cfg.Bucket, objName, minio.RemoveObjectOptions{})
-	be.sem.ReleaseToken()
+	defer be.sem.ReleaseToken()
 
-	debug.Log("Remove(%v) at %v -> err %v", h, objName, err)
+	var opts minio.RemoveObjectOptions
+	if be.lockMode != "" {
+		// removing an object without a version only adds a delete marker,
+		// which the retention does not prevent. The version itself has to be
+		// removed to find out whether it is retained.
+		info, err := be.client.StatObject(ctx, be.cfg.Bucket, objName, minio.StatObjectOptions{})
+		if be.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "client.StatObject")
+		}
+		opts.VersionID = info.VersionID
+	}
+
+	err := be.client.RemoveObject(ctx, be.cfg.Bucket, objName, opts)
+
+	debug.Log("Remove(%v) at %v, version %q -> err %v", h, objName, opts.VersionID, err)
 
 	if be.IsNotExist(err) {
 		err = nil
 	}
 
+	// retrying does not help before the retention period has expired
+	if err != nil && opts.VersionID != "" && isRetained(err) {
+		return backoff.Permanent(fmt.Errorf("client.RemoveObject: %v: %w", err, restic.ErrRetained))
+	}
+
 	return errors.Wrap(err, "client.RemoveObject")
 }
 
@@ -0,0 +1,80 @@
+package s3
+
+import (
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/restic/restic/internal/restic"
+)
+
+func TestRetentionMode(t *testing.T) {
+	for _, test := range []struct {
+		mode      string
+		retention time.Duration
+		want      minio.RetentionMode
+		valid     bool
+	}{
+		{"", 0, "", true},
+		{"governance", time.Hour, minio.Governance, true},
+		{"COMPLIANCE", 720 * time.Hour, minio.Compliance, true},
+		{"", time.Hour, "", false},
+		{"governance", 0, "", false},
+		{"legal-hold", time.Hour, "", false},
+	} {
+		mode, err := retentionMode(Config{ObjectLockMode: test.mode, Retention: test.retention})
+		if test.valid && err != nil {
+			t.Errorf("%q, %v: unexpected error %v", test.mode, test.retention, err)
+			continue
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%q, %v: no error", test.mode, test.retention)
+			continue
+		}
+		if mode != test.want {
+			t.Errorf("%q, %v: wrong mode, want %q, got %q", test.mode, test.retention, test.want, mode)
+		}
+	}
+}
+
+func TestIsRetained(t *testing.T) {
+	for _, test := range []struct {
+		err  error
+		want bool
+	}{
+		{minio.ErrorResponse{Code: "ObjectLocked", Message: "Object is locked."}, true},
+		{minio.ErrorResponse{Code: "AccessDenied", Message: "Access Denied because object protected by object lock."}, false},
+		{minio.ErrorResponse{Code: "InvalidRequest", Message: "Object is WORM protected and cannot be overwritten"}, true},
+		{minio.ErrorResponse{Code: "AccessDenied", Message: "Access Denied."}, false},
+		{minio.ErrorResponse{Code: "NoSuchKey", Message: "The specified key does not exist."}, false},
+	} {
+		if got := isRetained(test.err); got != test.want {
+			t.Errorf("isRetained(%v) = %v, want %v", test.err, got, test.want)
+		}
+	}
+}
+
+func TestStorageClass(t *testing.T) {
+	data := restic.Handle{Type: restic.PackFile, ContainedBlobType: restic.DataBlob, Name: "foo"}
+	tree := restic.Handle{Type: restic.PackFile, ContainedBlobType: restic.TreeBlob, Name: "foo"}
+	index := restic.Handle{Type: restic.IndexFile, Name: "foo"}
+
+	for _, test := range []struct {
+		cfg  Config
+		h    restic.Handle
+		want string
+	}{
+		{Config{}, data, ""},
+		{Config{StorageClass: "STANDARD_IA"}, data, "STANDARD_IA"},
+		{Config{StorageClass: "STANDARD_IA"}, index, "STANDARD_IA"},
+		{Config{DataStorageClass: "STANDARD_IA"}, data, "STANDARD_IA"},
+		{Config{DataStorageClass: "STANDARD_IA"}, tree, ""},
+		{Config{DataStorageClass: "STANDARD_IA"}, index, ""},
+		{Config{StorageClass: "STANDARD", DataStorageClass: "ONEZONE_IA"}, data, "ONEZONE_IA"},
+		{Config{StorageClass: "STANDARD", DataStorageClass: "ONEZONE_IA"}, tree, "STANDARD"},
+	} {
+		if got := storageClass(test.cfg, test.h); got != test.want {
+			t.Errorf("%+v, %v (%v): wrong storage class, want %q, got %q", test.cfg, test.h, test.h.ContainedBlobType, test.want, got)
+		}
+	}
+}
@@ -15,6 +15,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
 	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/backend/s3"
 	"github.com/restic/restic/internal/backend/test"
@@ -220,6 +223,87 @@ func BenchmarkBackendMinio(t *testing.B) {
 	newMinioTestSuite(ctx, t).RunBenchmarks(t)
 }
 
+func TestObjectLockMinio(t *testing.T) {
+	// try to find a minio binary
+	_, err := exec.LookPath("minio")
+	if err != nil {
+		t.Skip(err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tempdir, removeTempdir := rtest.TempDir(t)
+	defer removeTempdir()
+	key, secret := newRandomCredentials(t)
+	stopServer := runMinio(ctx, t, tempdir, key, secret)
+	defer stopServer()
+
+	tr, err := backend.Transport(backend.TransportOptions{})
+	rtest.OK(t, err)
+
+	cfg := s3.NewConfig()
+	cfg.Endpoint = "localhost:9000"
+	cfg.Bucket = "restictestlockbucket"
+	cfg.Prefix = "test"
+	cfg.UseHTTP = true
+	cfg.KeyID = key
+	cfg.Secret = secret
+	cfg.ObjectLockMode = "governance"
+	cfg.Retention = time.Hour
+
+	be, err := createS3(t, MinioTestConfig{Config: cfg}, tr)
+	rtest.OK(t, err)
+	defer func() {
+		rtest.OK(t, be.Close())
+	}()
+
+	client, err := minio.New(cfg.Endpoint, &minio.Options{Creds: credentials.NewStaticV4(key, secret, "")})
+	rtest.OK(t, err)
+
+	for _, test := range []struct {
+		t        restic.FileType
+		retained bool
+	}{
+		{restic.PackFile, true},
+		{restic.IndexFile, true},
+		{restic.SnapshotFile, true},
+		{restic.LockFile, false},
+		{restic.KeyFile, false},
+	} {
+		data := []byte(test.t)
+		h := restic.Handle{Type: test.t, Name: restic.Hash(data).String()}
+		rtest.OK(t, be.Save(ctx, h, restic.NewByteReader(data, be.Hasher())))
+
+		objName := be.(*s3.Backend).Filename(h)
+		mode, until, err := client.GetObjectRetention(ctx, cfg.Bucket, objName, "")
+		if !test.retained {
+			rtest.Assert(t, err != nil || mode == nil, "%v has retention mode %v", h, mode)
+			rtest.OK(t, be.Remove(ctx, h))
+
+			// no version of the file is left behind
+			for obj := range client.ListObjects(ctx, cfg.Bucket, minio.ListObjectsOptions{Prefix: objName, WithVersions: true}) {
+				rtest.OK(t, obj.Err)
+				t.Errorf("version %v of removed file %v still exists", obj.VersionID, h)
+			}
+			continue
+		}
+
+		rtest.OK(t, err)
+		rtest.Equals(t, minio.Governance, *mode)
+		rtest.Assert(t, until.After(time.Now().Add(50*time.Minute)), "%v is only retained until %v", h, until)
+
+		// removing the file must fail instead of hiding it behind a delete marker
+		err = be.Remove(ctx, h)
+		rtest.Assert(t, errors.Is(err, restic.ErrRetained), "wrong error for removing retained %v: %v", h, err)
+
+		fi, err := be.Stat(ctx, h)
+		rtest.OK(t, err)
+		rtest.Equals(t, int64(len(data)), fi.Size)
+	}
+}
+
 func newS3TestSuite(t testing.TB) *test.Suite {
 	tr, err := backend.Transport(backend.TransportOptions{})
 	if err != nil {
@@ -4,6 +4,7 @@ import (
 	"net/url"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/options"
@@ -17,6 +18,12 @@ type Config struct {
 	Command string `option:"command" help:"specify command to create sftp connection"`
 
 	Connections uint `option:"connections" help:"set a limit for the number of concurrent connections (default: 5)"`
+
+	BuiltinSSH   bool          `option:"builtin-ssh" help:"connect with the built-in SSH client instead of running ssh"`
+	KnownHosts   string        `option:"known-hosts" help:"known_hosts file for the built-in SSH client (default: ~/.ssh/known_hosts)"`
+	IdentityFile string        `option:"identity-file" help:"unencrypted private key for the built-in SSH client, used in addition to ssh-agent (default: ~/.ssh/id_ed25519, id_ecdsa or id_rsa)"`
+	KeepAlive    time.Duration `option:"keepalive" help:"interval of keepalive messages sent by the built-in SSH client (default: 30s)"`
+	Sessions     uint          `option:"sessions" help:"number of SSH connections opened by the built-in SSH client, each with an SFTP session (default: 2)"`
 }
 
 // NewConfig returns a new config with default options applied.
@@ -29,6 +29,7 @@ type SFTP struct {
 	p string
 
 	cmd    *exec.Cmd
+	ssh    *sshClient
 	result <-chan error
 
 	posixRename bool
@@ -115,8 +116,41 @@ func (r *SFTP) clientError() error {
 	return nil
 }
 
+// connect starts the sftp session, either with the built-in SSH client or by
+// running "ssh" with the appropriate arguments (or cfg.Command, if set).
+func connect(ctx context.Context, cfg Config) (*SFTP, error) {
+	if cfg.BuiltinSSH {
+		if cfg.Command != "" {
+			return nil, errors.Fatal("the options sftp.command and sftp.builtin-ssh cannot be combined")
+		}
+		return startBuiltinClient(ctx, cfg)
+	}
+
+	cmd, args, err := buildSSHCommand(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	sftp, err := startClient(cmd, args...)
+	if err != nil {
+		debug.Log("unable to start program: %v", err)
+		return nil, err
+	}
+	return sftp, nil
+}
+
+// client returns the sftp session for the next request. The built-in SSH
+// client distributes the requests over all its sessions.
+func (r *SFTP) client() *sftp.Client {
+	if r.ssh != nil {
+		return r.ssh.client()
+	}
+	return r.c
+}
+
 // Open opens an sftp backend as described by the config by running
-// "ssh" with the appropriate arguments (or cfg.Command, if set). The function
+// "ssh" with the appropriate arguments (or cfg.Command, if set), or with the
+// built-in SSH client if cfg.BuiltinSSH is set. The function
 // preExec is run just before, postExec just after starting a program.
 func Open(ctx context.Context, cfg Config) (*SFTP, error) {
 	debug.Log("open backend with config %#v", cfg)
@@ -126,17 +160,11 @@ func Open(ctx context.Context, cfg Config) (*SFTP, error) {
 		return nil, err
 	}
 
-	cmd, args, err := buildSSHCommand(cfg)
+	sftp, err := connect(ctx, cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	sftp, err := startClient(cmd, args...)
-	if err != nil {
-		debug.Log("unable to start program: %v", err)
-		return nil, err
-	}
-
 	sftp.Layout, err = backend.ParseLayout(ctx, sftp, cfg.Layout, defaultLayout, cfg.Path)
 	if err != nil {
 		return nil, err
@@ -214,20 +242,15 @@ func buildSSHCommand(cfg Config) (cmd string, args []string, err error) {
 }
 
 // Create creates an sftp backend as described by the config by running "ssh"
-// with the appropriate arguments (or cfg.Command, if set). The function
+// with the appropriate arguments (or cfg.Command, if set), or with the
+// built-in SSH client if cfg.BuiltinSSH is set. The function
 // preExec is run just before, postExec just after starting a program.
 func Create(ctx context.Context, cfg Config) (*SFTP, error) {
-	cmd, args, err := buildSSHCommand(cfg)
+	sftp, err := connect(ctx, cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	sftp, err := startClient(cmd, args...)
-	if err != nil {
-		debug.Log("unable to start program: %v", err)
-		return nil, err
-	}
-
 	sftp.Layout, err = backend.ParseLayout(ctx, sftp, cfg.Layout, defaultLayout, cfg.Path)
 	if err != nil {
 		return nil, err
@@ -309,17 +332,19 @@ func (r *SFTP) Save(ctx context.Context, h restic.Handle, rd restic.RewindReader
 	r.sem.GetToken()
 	defer r.sem.ReleaseToken()
 
+	c := r.client()
+
 	// create new file
-	f, err := r.c.OpenFile(tmpFilename, os.O_CREATE|os.O_EXCL|os.O_WRONLY)
+	f, err := c.OpenFile(tmpFilename, os.O_CREATE|os.O_EXCL|os.O_WRONLY)
 
 	if r.IsNotExist(err) {
 		// error is caused by a missing directory, try to create it
-		mkdirErr := r.c.MkdirAll(r.Dirname(h))
+		mkdirErr := c.MkdirAll(r.Dirname(h))
 		if mkdirErr != nil {
 			debug.Log("error creating dir %v: %v", r.Dirname(h), mkdirErr)
 		} else {
 			// try again
-			f, err = r.c.OpenFile(tmpFilename, os.O_CREATE|os.O_EXCL|os.O_WRONLY)
+			f, err = c.OpenFile(tmpFilename, os.O_CREATE|os.O_EXCL|os.O_WRONLY)
 		}
 	}
 
@@ -338,7 +363,7 @@ func (r *SFTP) Save(ctx context.Context, h restic.Handle, rd restic.RewindReader
 		}
 
 		// Try not to leave a partial file behind.
-		rmErr := r.c.Remove(f.Name())
+		rmErr := c.Remove(f.Name())
 		if rmErr != nil {
 			debug.Log("sftp: failed to remove broken file %v: %v",
 				f.Name(), rmErr)
@@ -367,9 +392,9 @@ func (r *SFTP) Save(ctx context.Context, h restic.Handle, rd restic.RewindReader
 
 	// Prefer POSIX atomic rename if available.
 	if r.posixRename {
-		err = r.c.PosixRename(tmpFilename, filename)
+		err = c.PosixRename(tmpFilename, filename)
 	} else {
-		err = r.c.Rename(tmpFilename, filename)
+		err = c.Rename(tmpFilename, filename)
 	}
 	return errors.Wrap(err, "Rename")
 }
@@ -429,7 +454,7 @@ func (r *SFTP) openReader(ctx context.Context, h restic.Handle, length int, offs
 	}
 
 	r.sem.GetToken()
-	f, err := r.c.Open(r.Filename(h))
+	f, err := r.client().Open(r.Filename(h))
 	if err != nil {
 		r.sem.ReleaseToken()
 		return nil, err
@@ -476,7 +501,7 @@ func (r *SFTP) Stat(ctx context.Context, h restic.Handle) (restic.FileInfo, erro
 	r.sem.GetToken()
 	defer r.sem.ReleaseToken()
 
-	fi, err := r.c.Lstat(r.Filename(h))
+	fi, err := r.client().Lstat(r.Filename(h))
 	if err != nil {
 		return restic.FileInfo{}, errors.Wrap(err, "Lstat")
 	}
@@ -494,7 +519,7 @@ func (r *SFTP) Test(ctx context.Context, h restic.Handle) (bool, error) {
 	r.sem.GetToken()
 	defer r.sem.ReleaseToken()
 
-	_, err := r.c.Lstat(r.Filename(h))
+	_, err := r.client().Lstat(r.Filename(h))
 	if errors.Is(err, os.ErrNotExist) {
 		return false, nil
 	}
@@ -516,7 +541,7 @@ func (r *SFTP) Remove(ctx context.Context, h restic.Handle) error {
 	r.sem.GetToken()
 	defer r.sem.ReleaseToken()
 
-	return r.c.Remove(r.Filename(h))
+	return r.client().Remove(r.Filename(h))
 }
 
 // List runs fn for each file in the backend which has the type t. When an
@@ -589,6 +614,10 @@ func (r *SFTP) Close() error {
 		return nil
 	}
 
+	if r.ssh != nil {
+		return r.ssh.Close()
+	}
+
 	err := r.c.Close()
 	debug.Log("Close returned error %v", err)
 
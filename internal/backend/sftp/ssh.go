@@ -0,0 +1,371 @@
+package sftp
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	defaultSessions  = 2
+	defaultKeepAlive = 30 * time.Second
+	sshTimeout       = 30 * time.Second
+)
+
+// defaultIdentityFiles are tried in the directory ~/.ssh when no identity file
+// is configured.
+var defaultIdentityFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// sshClient is the built-in SSH transport. It holds several SSH connections
+// with one sftp session each.
+type sshClient struct {
+	conns   []*ssh.Client
+	clients []*sftp.Client
+	next    uint32
+
+	result chan error
+	done   chan struct{}
+}
+
+// startBuiltinClient connects to the server with the built-in SSH client and
+// opens cfg.Sessions sftp sessions.
+func startBuiltinClient(ctx context.Context, cfg Config) (*SFTP, error) {
+	sshCfg, closeAgent, err := clientConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAgent()
+
+	hosts, err := loadKnownHosts(sshCfg.knownHosts)
+	if err != nil {
+		return nil, err
+	}
+
+	port := cfg.Port
+	if port == "" {
+		port = "22"
+	}
+	addr := net.JoinHostPort(cfg.Host, port)
+
+	sessions := cfg.Sessions
+	if sessions == 0 {
+		sessions = defaultSessions
+	}
+	keepAlive := cfg.KeepAlive
+	if keepAlive == 0 {
+		keepAlive = defaultKeepAlive
+	}
+
+	c := &sshClient{
+		result: make(chan error, 1),
+		done:   make(chan struct{}),
+	}
+
+	for i := uint(0); i < sessions; i++ {
+		conn, err := dialSSH(ctx, addr, sshCfg.ClientConfig, hosts)
+		if err != nil {
+			_ = c.Close()
+			return nil, err
+		}
+		c.conns = append(c.conns, conn)
+
+		client, err := sftp.NewClient(conn)
+		if err != nil {
+			_ = c.Close()
+			return nil, errors.Errorf("unable to start the sftp session, error: %v", err)
+		}
+		c.clients = append(c.clients, client)
+
+		go c.wait(conn)
+		go c.keepAlive(conn, keepAlive)
+	}
+
+	debug.Log("opened %d sftp sessions to %v", len(c.clients), addr)
+
+	_, posixRename := c.clients[0].HasExtension("posix-rename@openssh.com")
+	return &SFTP{c: c.clients[0], ssh: c, result: c.result, posixRename: posixRename}, nil
+}
+
+// sshConfig is the configuration of the built-in SSH client.
+type sshConfig struct {
+	*ssh.ClientConfig
+	knownHosts string
+}
+
+// clientConfig returns the SSH client configuration for cfg. The returned
+// function closes the connection to ssh-agent, it must be called after all
+// connections are established.
+func clientConfig(cfg Config) (sshConfig, func(), error) {
+	home, err := os.UserHomeDir()
+	if err != nil && (cfg.KnownHosts == "" || cfg.IdentityFile == "") {
+		return sshConfig{}, nil, errors.Wrap(err, "UserHomeDir")
+	}
+
+	knownHosts := cfg.KnownHosts
+	if knownHosts == "" {
+		knownHosts = filepath.Join(home, ".ssh", "known_hosts")
+	}
+
+	username := cfg.User
+	if username == "" {
+		u, err := user.Current()
+		if err != nil {
+			return sshConfig{}, nil, errors.Wrap(err, "user.Current")
+		}
+		username = u.Username
+	}
+
+	var signers []ssh.Signer
+	if cfg.IdentityFile != "" {
+		signer, err := loadIdentityFile(cfg.IdentityFile)
+		if err != nil {
+			return sshConfig{}, nil, err
+		}
+		signers = append(signers, signer)
+	}
+
+	closeAgent := func() {}
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			debug.Log("unable to connect to ssh-agent: %v", err)
+		} else {
+			closeAgent = func() { _ = conn.Close() }
+
+			agentSigners, err := agent.NewClient(conn).Signers()
+			if err != nil {
+				debug.Log("unable to list the keys of ssh-agent: %v", err)
+			}
+			signers = append(signers, agentSigners...)
+		}
+	}
+
+	if cfg.IdentityFile == "" {
+		for _, name := range defaultIdentityFiles {
+			signer, err := loadIdentityFile(filepath.Join(home, ".ssh", name))
+			if err != nil {
+				debug.Log("skipping identity file %v: %v", name, err)
+				continue
+			}
+			signers = append(signers, signer)
+		}
+	}
+
+	if len(signers) == 0 {
+		closeAgent()
+		return sshConfig{}, nil, errors.Fatal("no SSH keys found, start ssh-agent or set the option sftp.identity-file")
+	}
+
+	return sshConfig{
+		ClientConfig: &ssh.ClientConfig{
+			User: username,
+			Auth: []ssh.AuthMethod{ssh.PublicKeys(signers...)},
+		},
+		knownHosts: knownHosts,
+	}, closeAgent, nil
+}
+
+// loadIdentityFile reads the unencrypted private key in filename. Encrypted
+// keys must be added to ssh-agent instead.
+func loadIdentityFile(filename string) (ssh.Signer, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "ReadFile")
+	}
+
+	signer, err := ssh.ParsePrivateKey(buf)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		return nil, errors.Fatalf("SSH key %v is encrypted, add it to ssh-agent instead", filename)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse SSH key %v", filename)
+	}
+
+	return signer, nil
+}
+
+// knownHosts verifies host keys against a known_hosts file.
+type knownHosts struct {
+	filename string
+	check    ssh.HostKeyCallback
+}
+
+func loadKnownHosts(filename string) (*knownHosts, error) {
+	check, err := knownhosts.New(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "known_hosts")
+	}
+	return &knownHosts{filename: filename, check: check}, nil
+}
+
+// verify is an ssh.HostKeyCallback which accepts only the keys listed for
+// hostname.
+func (k *knownHosts) verify(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	err := k.check(hostname, remote, key)
+
+	var keyErr *knownhosts.KeyError
+	if errors.As(err, &keyErr) {
+		if len(keyErr.Want) == 0 {
+			return errors.Fatalf("host key %v %v of %v is unknown, add it to %v",
+				key.Type(), ssh.FingerprintSHA256(key), hostname, k.filename)
+		}
+		return errors.Fatalf("host key %v of %v does not match the key in %v:%d, the connection may be intercepted",
+			ssh.FingerprintSHA256(key), hostname, keyErr.Want[0].Filename, keyErr.Want[0].Line)
+	}
+	return err
+}
+
+// noKey is a public key which is never found in known_hosts.
+type noKey struct{}
+
+func (noKey) Type() string                        { return "none" }
+func (noKey) Marshal() []byte                     { return []byte("none") }
+func (noKey) Verify([]byte, *ssh.Signature) error { return errors.New("invalid key") }
+
+// algorithms returns the algorithms of the host keys listed for addr, so
+// that the server presents one of them instead of a key of another type.
+func (k *knownHosts) algorithms(addr string, remote net.Addr) []string {
+	var keyErr *knownhosts.KeyError
+	if !errors.As(k.check(addr, remote, noKey{}), &keyErr) {
+		return nil
+	}
+
+	var algos []string
+	for _, known := range keyErr.Want {
+		switch t := known.Key.Type(); t {
+		case ssh.KeyAlgoRSA:
+			algos = append(algos, ssh.SigAlgoRSASHA2512, ssh.SigAlgoRSASHA2256, ssh.SigAlgoRSA)
+		default:
+			algos = append(algos, t)
+		}
+	}
+	return algos
+}
+
+// dialSSH opens a new SSH connection to addr.
+func dialSSH(ctx context.Context, addr string, cfg *ssh.ClientConfig, hosts *knownHosts) (*ssh.Client, error) {
+	var dialer net.Dialer
+	dialCtx, cancel := context.WithTimeout(ctx, sshTimeout)
+	defer cancel()
+
+	conn, err := dialer.DialContext(dialCtx, "tcp", addr)
+	if err != nil {
+		return nil, errors.Wrap(err, "Dial")
+	}
+
+	connCfg := *cfg
+	connCfg.HostKeyCallback = hosts.verify
+	connCfg.HostKeyAlgorithms = hosts.algorithms(addr, conn.RemoteAddr())
+
+	// abort the handshake with an unresponsive server
+	err = conn.SetDeadline(time.Now().Add(sshTimeout))
+	if err != nil {
+		_ = conn.Close()
+		return nil, errors.Wrap(err, "SetDeadline")
+	}
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, &connCfg)
+	if err != nil {
+		_ = conn.Close()
+		return nil, errors.Wrap(err, "ssh handshake")
+	}
+
+	err = conn.SetDeadline(time.Time{})
+	if err != nil {
+		_ = sshConn.Close()
+		return nil, errors.Wrap(err, "SetDeadline")
+	}
+
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
+
+// client returns the next sftp session.
+func (c *sshClient) client() *sftp.Client {
+	n := atomic.AddUint32(&c.next, 1)
+	return c.clients[n%uint32(len(c.clients))]
+}
+
+// wait reports on c.result when conn is closed before Close was called.
+func (c *sshClient) wait(conn *ssh.Client) {
+	err := conn.Wait()
+	debug.Log("ssh connection closed, err %v", err)
+
+	if err == nil {
+		err = errors.New("connection closed by the server")
+	}
+	for {
+		select {
+		case c.result <- errors.Wrap(err, "ssh connection closed"):
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// keepAlive sends a keepalive request every interval and closes the
+// connection when the server does not reply in time.
+func (c *sshClient) keepAlive(conn *ssh.Client, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.done:
+			return
+		}
+
+		reply := make(chan error, 1)
+		go func() {
+			_, _, err := conn.SendRequest("keepalive@openssh.com", true, nil)
+			reply <- err
+		}()
+
+		select {
+		case err := <-reply:
+			if err != nil {
+				// the connection is gone, which is reported by wait
+				return
+			}
+		case <-time.After(interval):
+			debug.Log("no reply to keepalive within %v, closing the connection", interval)
+			_ = conn.Close()
+			return
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// Close closes all sftp sessions and SSH connections.
+func (c *sshClient) Close() error {
+	close(c.done)
+
+	var firstErr error
+	for _, client := range c.clients {
+		if err := client.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, conn := range c.conns {
+		// the connection may already be closed after a failed keepalive
+		if err := conn.Close(); err != nil {
+			debug.Log("closing ssh connection failed: %v", err)
+		}
+	}
+	return firstErr
+}
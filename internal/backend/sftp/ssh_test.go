@@ -0,0 +1,333 @@
+package sftp_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/backend/sftp"
+	"github.com/restic/restic/internal/backend/test"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+
+	pkgsftp "github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshServer is an in-process SSH server which serves the local file system
+// via the sftp subsystem.
+type sshServer struct {
+	host, port string
+
+	// knownHosts lists the host key of the server, identity contains the
+	// only client key accepted by the server.
+	knownHosts string
+	identity   string
+	clientKey  *ecdsa.PrivateKey
+
+	conns      int32
+	keepAlives int32
+}
+
+func newSSHServer(t testing.TB) (*sshServer, func()) {
+	if runtime.GOOS == "windows" {
+		t.Skip("in-process sftp server is not supported on Windows")
+	}
+
+	dir, cleanup := rtest.TempDir(t)
+
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	rtest.OK(t, err)
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	rtest.OK(t, err)
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rtest.OK(t, err)
+	clientPub, err := ssh.NewPublicKey(&clientKey.PublicKey)
+	rtest.OK(t, err)
+
+	buf, err := x509.MarshalECPrivateKey(clientKey)
+	rtest.OK(t, err)
+	identity := filepath.Join(dir, "id_ecdsa")
+	rtest.OK(t, ioutil.WriteFile(identity, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: buf}), 0600))
+
+	cfg := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(key.Marshal(), clientPub.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unknown key")
+		},
+	}
+	cfg.AddHostKey(hostSigner)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	rtest.OK(t, err)
+
+	host, port, err := net.SplitHostPort(l.Addr().String())
+	rtest.OK(t, err)
+
+	srv := &sshServer{
+		host:       host,
+		port:       port,
+		knownHosts: filepath.Join(dir, "known_hosts"),
+		identity:   identity,
+		clientKey:  clientKey,
+	}
+
+	line := knownhosts.Line([]string{knownhosts.Normalize(l.Addr().String())}, hostSigner.PublicKey())
+	rtest.OK(t, ioutil.WriteFile(srv.knownHosts, []byte(line+"\n"), 0600))
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serve(conn, cfg)
+		}
+	}()
+
+	return srv, func() {
+		_ = l.Close()
+		cleanup()
+	}
+}
+
+func (srv *sshServer) serve(conn net.Conn, cfg *ssh.ServerConfig) {
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	defer func() { _ = sconn.Close() }()
+	atomic.AddInt32(&srv.conns, 1)
+
+	go func() {
+		for req := range reqs {
+			ok := req.Type == "keepalive@openssh.com"
+			if ok {
+				atomic.AddInt32(&srv.keepAlives, 1)
+			}
+			if req.WantReply {
+				_ = req.Reply(ok, nil)
+			}
+		}
+	}()
+
+	for newChan := range chans {
+		if newChan.ChannelType() != "session" {
+			_ = newChan.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+
+		ch, chReqs, err := newChan.Accept()
+		if err != nil {
+			continue
+		}
+
+		go func() {
+			for req := range chReqs {
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				_ = req.Reply(ok, nil)
+				if !ok {
+					continue
+				}
+
+				go func() {
+					server, err := pkgsftp.NewServer(ch)
+					if err == nil {
+						_ = server.Serve()
+					}
+					_ = ch.Close()
+				}()
+			}
+		}()
+	}
+}
+
+// config returns a config for the built-in SSH client connecting to srv.
+func (srv *sshServer) config(dir string) sftp.Config {
+	cfg := sftp.NewConfig()
+	cfg.User = "restic"
+	cfg.Host = srv.host
+	cfg.Port = srv.port
+	cfg.Path = dir
+	cfg.BuiltinSSH = true
+	cfg.KnownHosts = srv.knownHosts
+	cfg.IdentityFile = srv.identity
+	return cfg
+}
+
+func TestBackendBuiltinSSH(t *testing.T) {
+	srv, cleanupServer := newSSHServer(t)
+	defer cleanupServer()
+
+	suite := newTestSuite(t)
+	suite.NewConfig = func() (interface{}, error) {
+		dir, err := ioutil.TempDir(rtest.TestTempDir, "restic-test-sftp-")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		t.Logf("create new backend at %v", dir)
+
+		cfg := srv.config(dir)
+		cfg.Sessions = 3
+		return cfg, nil
+	}
+
+	suite.RunTests(t)
+}
+
+func TestBuiltinSSHSessions(t *testing.T) {
+	srv, cleanupServer := newSSHServer(t)
+	defer cleanupServer()
+	dir, cleanup := rtest.TempDir(t)
+	defer cleanup()
+
+	cfg := srv.config(dir)
+	cfg.Sessions = 3
+	be, err := sftp.Create(context.TODO(), cfg)
+	rtest.OK(t, err)
+	defer func() { rtest.OK(t, be.Close()) }()
+
+	// Create closes its connections and opens new ones
+	rtest.Equals(t, int32(6), atomic.LoadInt32(&srv.conns))
+
+	data := rtest.Random(23, 5000)
+	for i := 0; i < 6; i++ {
+		h := restic.Handle{Type: restic.PackFile, Name: restic.Hash(append(data, byte(i))).String()}
+		rtest.OK(t, be.Save(context.TODO(), h, restic.NewByteReader(data, be.Hasher())))
+		fi, err := be.Stat(context.TODO(), h)
+		rtest.OK(t, err)
+		rtest.Equals(t, int64(len(data)), fi.Size)
+	}
+}
+
+func TestBuiltinSSHKeepAlive(t *testing.T) {
+	srv, cleanupServer := newSSHServer(t)
+	defer cleanupServer()
+	dir, cleanup := rtest.TempDir(t)
+	defer cleanup()
+
+	cfg := srv.config(dir)
+	cfg.Sessions = 1
+	cfg.KeepAlive = 10 * time.Millisecond
+	be, err := sftp.Create(context.TODO(), cfg)
+	rtest.OK(t, err)
+	defer func() { rtest.OK(t, be.Close()) }()
+
+	for start := time.Now(); atomic.LoadInt32(&srv.keepAlives) < 3; {
+		if time.Since(start) > 10*time.Second {
+			t.Fatalf("only %d keepalive requests received", atomic.LoadInt32(&srv.keepAlives))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBuiltinSSHAgent(t *testing.T) {
+	srv, cleanupServer := newSSHServer(t)
+	defer cleanupServer()
+	dir, cleanup := rtest.TempDir(t)
+	defer cleanup()
+
+	keyring := agent.NewKeyring()
+	rtest.OK(t, keyring.Add(agent.AddedKey{PrivateKey: srv.clientKey}))
+
+	sock := filepath.Join(dir, "agent.sock")
+	l, err := net.Listen("unix", sock)
+	rtest.OK(t, err)
+	defer func() { _ = l.Close() }()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_ = agent.ServeAgent(keyring, conn)
+				_ = conn.Close()
+			}()
+		}
+	}()
+
+	oldSock := os.Getenv("SSH_AUTH_SOCK")
+	rtest.OK(t, os.Setenv("SSH_AUTH_SOCK", sock))
+	defer func() { rtest.OK(t, os.Setenv("SSH_AUTH_SOCK", oldSock)) }()
+
+	cfg := srv.config(filepath.Join(dir, "repo"))
+	cfg.IdentityFile = ""
+	be, err := sftp.Create(context.TODO(), cfg)
+	rtest.OK(t, err)
+	rtest.OK(t, be.Close())
+}
+
+func TestBuiltinSSHErrors(t *testing.T) {
+	srv, cleanupServer := newSSHServer(t)
+	defer cleanupServer()
+	dir, cleanup := rtest.TempDir(t)
+	defer cleanup()
+
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	rtest.OK(t, err)
+	otherSigner, err := ssh.NewSignerFromKey(otherKey)
+	rtest.OK(t, err)
+
+	emptyKnownHosts := filepath.Join(dir, "known_hosts_empty")
+	rtest.OK(t, ioutil.WriteFile(emptyKnownHosts, nil, 0600))
+
+	wrongKnownHosts := filepath.Join(dir, "known_hosts_wrong")
+	line := knownhosts.Line([]string{knownhosts.Normalize(net.JoinHostPort(srv.host, srv.port))}, otherSigner.PublicKey())
+	rtest.OK(t, ioutil.WriteFile(wrongKnownHosts, []byte(line+"\n"), 0600))
+
+	otherIdentity := filepath.Join(dir, "id_other")
+	otherClientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rtest.OK(t, err)
+	buf, err := x509.MarshalECPrivateKey(otherClientKey)
+	rtest.OK(t, err)
+	rtest.OK(t, ioutil.WriteFile(otherIdentity, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: buf}), 0600))
+
+	for _, test := range []struct {
+		name   string
+		modify func(cfg *sftp.Config)
+		err    string
+	}{
+		{"unknown host", func(cfg *sftp.Config) { cfg.KnownHosts = emptyKnownHosts }, "is unknown"},
+		{"wrong host key", func(cfg *sftp.Config) { cfg.KnownHosts = wrongKnownHosts }, "does not match"},
+		{"wrong client key", func(cfg *sftp.Config) { cfg.IdentityFile = otherIdentity }, "unable to authenticate"},
+		{"command", func(cfg *sftp.Config) { cfg.Command = "ssh host -s sftp" }, "cannot be combined"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			// do not use the keys of a running ssh-agent
+			oldSock := os.Getenv("SSH_AUTH_SOCK")
+			rtest.OK(t, os.Unsetenv("SSH_AUTH_SOCK"))
+			defer func() { rtest.OK(t, os.Setenv("SSH_AUTH_SOCK", oldSock)) }()
+
+			cfg := srv.config(filepath.Join(dir, "repo"))
+			test.modify(&cfg)
+
+			_, err := sftp.Create(context.TODO(), cfg)
+			rtest.Assert(t, err != nil && strings.Contains(err.Error(), test.err),
+				"expected error containing %q, got %v", test.err, err)
+		})
+	}
+}
@@ -32,6 +32,11 @@ func newBackend(be restic.Backend, c *Cache) *Backend {
 	}
 }
 
+// Unwrap returns the wrapped backend.
+func (b *Backend) Unwrap() restic.Backend {
+	return b.Backend
+}
+
 // Remove deletes a file from the backend and the cache if it has been cached.
 func (b *Backend) Remove(ctx context.Context, h restic.Handle) error {
 	debug.Log("cache Remove(%v)", h)
@@ -249,3 +249,8 @@ func (c *Cache) Wrap(be restic.Backend) restic.Backend {
 func (c *Cache) BaseDir() string {
 	return c.Base
 }
+
+// Dir returns the cache directory of the repository.
+func (c *Cache) Dir() string {
+	return c.path
+}
@@ -159,6 +159,12 @@ func (c *Cache) remove(h restic.Handle) error {
 // the set valid.
 func (c *Cache) Clear(t restic.FileType, valid restic.IDSet) error {
 	debug.Log("Clearing cache for %v: %v valid files", t, len(valid))
+	return c.ClearFunc(t, valid.Has)
+}
+
+// ClearFunc removes all files of type t from the cache for which valid
+// returns false.
+func (c *Cache) ClearFunc(t restic.FileType, valid func(restic.ID) bool) error {
 	if !c.canBeCached(t) {
 		return nil
 	}
@@ -169,7 +175,7 @@ func (c *Cache) Clear(t restic.FileType, valid restic.IDSet) error {
 	}
 
 	for id := range list {
-		if valid.Has(id) {
+		if valid(id) {
 			continue
 		}
 
@@ -67,6 +67,19 @@ func (e *ErrDuplicatePacks) Error() string {
 	return fmt.Sprintf("pack %v contained in several indexes: %v", e.PackID.Str(), e.Indexes)
 }
 
+// ErrDuplicateFile is returned when the backend stores a file more than once.
+type ErrDuplicateFile struct {
+	restic.DuplicateFile
+}
+
+func (e *ErrDuplicateFile) Error() string {
+	content := "identical"
+	if !e.Identical {
+		content = "different"
+	}
+	return fmt.Sprintf("%v stored %d times with %s content", e.Handle, e.Copies, content)
+}
+
 // ErrOldIndexFormat is returned when an index with the old format is
 // found.
 type ErrOldIndexFormat struct {
@@ -226,6 +239,36 @@ func (c *Checker) Packs(ctx context.Context, errChan chan<- error) {
 	}
 }
 
+// Duplicates reports the files which the backend stores more than once as
+// *ErrDuplicateFile via errChan, which is closed afterwards. Nothing is
+// reported for backends which never store a file twice.
+func (c *Checker) Duplicates(ctx context.Context, errChan chan<- error) {
+	defer close(errChan)
+
+	be := restic.AsDuplicatesBackend(c.repo.Backend())
+	if be == nil {
+		return
+	}
+
+	for _, t := range []restic.FileType{restic.ConfigFile, restic.KeyFile, restic.SnapshotFile, restic.IndexFile, restic.PackFile} {
+		err := be.Duplicates(ctx, t, func(df restic.DuplicateFile) error {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case errChan <- &ErrDuplicateFile{df}:
+			}
+			return nil
+		})
+		if err != nil {
+			select {
+			case <-ctx.Done():
+			case errChan <- err:
+			}
+			return
+		}
+	}
+}
+
 // Error is an error that occurred while checking a repository.
 type Error struct {
 	TreeID restic.ID
@@ -458,7 +501,12 @@ func checkPack(ctx context.Context, r restic.Repository, id restic.ID, blobs []r
 		return blobs[i].Offset < blobs[j].Offset
 	})
 	idxHdrSize := pack.CalculateHeaderSize(blobs)
+	// sealed packs start with the sealed data key
+	idxSealed := r.Index().IsSealedPack(id)
 	lastBlobEnd := 0
+	if idxSealed {
+		lastBlobEnd = pack.SealedKeySize
+	}
 	nonContinuousPack := false
 	for _, blob := range blobs {
 		if lastBlobEnd != int(blob.Offset) {
@@ -477,12 +525,19 @@ func checkPack(ctx context.Context, r restic.Repository, id restic.ID, blobs []r
 	// calculate hash on-the-fly while reading the pack and capture pack header
 	var hash restic.ID
 	var hdrBuf []byte
+	// the header of sealed packs is encrypted with the data key at the start
+	var packStart []byte
 	hashingLoader := func(ctx context.Context, h restic.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
 		return r.Backend().Load(ctx, h, int(size), 0, func(rd io.Reader) error {
 			hrd := hashing.NewReader(rd, sha256.New())
 			bufRd.Reset(hrd)
 
-			// skip to start of first blob, offset == 0 for correct pack files
+			// a short pack file is reported by the checks below
+			start, _ := bufRd.Peek(pack.SealedKeySize)
+			packStart = append(packStart[:0], start...)
+
+			// skip to start of first blob, offset == 0 for correct pack files and
+			// for sealed packs, which are read including the sealed data key
 			_, err := bufRd.Discard(int(offset))
 			if err != nil {
 				return err
@@ -514,7 +569,7 @@ func checkPack(ctx context.Context, r restic.Repository, id restic.ID, blobs []r
 		})
 	}
 
-	err := repository.StreamPack(ctx, hashingLoader, r.Key(), id, blobs, func(blob restic.BlobHandle, buf []byte, err error) error {
+	err := repository.StreamPack(ctx, hashingLoader, r.Key(), id, idxSealed, blobs, func(blob restic.BlobHandle, buf []byte, err error) error {
 		debug.Log("  check blob %v: %v", blob.ID, blob)
 		if err != nil {
 			debug.Log("  error verifying blob %v: %v", blob.ID, err)
@@ -532,11 +587,17 @@ func checkPack(ctx context.Context, r restic.Repository, id restic.ID, blobs []r
 		return errors.Errorf("Pack ID does not match, want %v, got %v", id.Str(), hash.Str())
 	}
 
-	blobs, hdrSize, err := pack.List(r.Key(), bytes.NewReader(hdrBuf), int64(len(hdrBuf)))
+	hdrRd := bytes.NewReader(append(packStart, hdrBuf...))
+	blobs, hdrSize, sealed, err := pack.ListSealed(r.Key(), hdrRd, hdrRd.Size())
 	if err != nil {
 		return err
 	}
 
+	if idxSealed != sealed {
+		debug.Log("Pack sealed state does not match, index %v, header %v", idxSealed, sealed)
+		errs = append(errs, errors.Errorf("Pack sealed state does not match, index %v, header %v", idxSealed, sealed))
+	}
+
 	if uint32(idxHdrSize) != hdrSize {
 		debug.Log("Pack header size does not match, want %v, got %v", idxHdrSize, hdrSize)
 		errs = append(errs, errors.Errorf("Pack header size does not match, want %v, got %v", idxHdrSize, hdrSize))
@@ -641,3 +702,60 @@ func (c *Checker) ReadPacks(ctx context.Context, packs map[restic.ID]int64, p *p
 		}
 	}
 }
+
+// VerifyPackChecksums compares the IDs of the packs with the SHA-256 hashes
+// the backend recorded for them, which avoids downloading the packs. Unlike
+// ReadPacks, this neither decrypts the blobs nor checks the pack headers.
+// Damaged or missing packs are reported as *PackError via errChan, which is
+// closed afterwards.
+func (c *Checker) VerifyPackChecksums(ctx context.Context, packs map[restic.ID]int64, p *progress.Counter, errChan chan<- error) {
+	defer close(errChan)
+
+	be := restic.AsChecksumBackend(c.repo.Backend())
+	if be == nil {
+		select {
+		case <-ctx.Done():
+		case errChan <- errors.New("the repository backend does not record checksums"):
+		}
+		return
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	ch := make(chan restic.ID)
+
+	// run workers
+	for i := 0; i < defaultParallelism; i++ {
+		g.Go(func() error {
+			for id := range ch {
+				h := restic.Handle{Type: restic.PackFile, Name: id.String()}
+				hash, err := be.SHA256(ctx, h)
+				p.Add(1)
+				if err == nil && hash != id {
+					err = errors.Errorf("checksum does not match, want %v, got %v", id.Str(), hash.Str())
+				}
+				if err == nil {
+					continue
+				}
+
+				select {
+				case <-ctx.Done():
+					return nil
+				case errChan <- &PackError{ID: id, Err: err}:
+				}
+			}
+			return nil
+		})
+	}
+
+	// push packs to ch
+	for id := range packs {
+		select {
+		case ch <- id:
+		case <-ctx.Done():
+		}
+	}
+	close(ch)
+
+	// the workers report all errors via errChan
+	_ = g.Wait()
+}
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/restic/restic/internal/archiver"
+	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/checker"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/hashing"
@@ -384,6 +385,116 @@ func TestCheckerModifiedData(t *testing.T) {
 	}
 }
 
+// checksumBackend returns the pack IDs as the recorded checksums, except for
+// the damaged pack.
+type checksumBackend struct {
+	restic.Backend
+	damaged restic.ID
+}
+
+func (b *checksumBackend) SHA256(ctx context.Context, h restic.Handle) (restic.ID, error) {
+	id, err := restic.ParseID(h.Name)
+	if err != nil {
+		return restic.ID{}, err
+	}
+	if id == b.damaged {
+		id[0] ^= 0xff
+	}
+	return id, nil
+}
+
+func TestCheckerVerifyPackChecksums(t *testing.T) {
+	repo, cleanup := repository.TestRepository(t)
+	defer cleanup()
+
+	archiver.TestSnapshot(t, repo, ".", nil)
+
+	verify := func(be restic.Backend) []error {
+		checkRepo := repository.New(be, repository.Options{})
+		test.OK(t, checkRepo.SearchKey(context.TODO(), test.TestPassword, 5, ""))
+
+		chkr := checker.New(checkRepo, false)
+		_, errs := chkr.LoadIndex(context.TODO())
+		test.Equals(t, 0, len(errs))
+
+		return collectErrors(context.TODO(), func(ctx context.Context, errChan chan<- error) {
+			chkr.VerifyPackChecksums(ctx, chkr.GetPacks(), nil, errChan)
+		})
+	}
+
+	// backends without checksums are rejected
+	errs := verify(repo.Backend())
+	test.Equals(t, 1, len(errs))
+
+	var damaged restic.ID
+	test.OK(t, repo.List(context.TODO(), restic.PackFile, func(id restic.ID, size int64) error {
+		damaged = id
+		return nil
+	}))
+
+	// the checksum backend is found behind wrapping backends
+	be := backend.NewRetryBackend(&checksumBackend{Backend: repo.Backend(), damaged: damaged}, 2, nil)
+	errs = verify(be)
+	test.Equals(t, 1, len(errs))
+
+	packErr, ok := errs[0].(*checker.PackError)
+	test.Assert(t, ok, "expected a *checker.PackError, got %T: %v", errs[0], errs[0])
+	test.Equals(t, damaged, packErr.ID)
+}
+
+// duplicatesBackend reports every pack as stored twice.
+type duplicatesBackend struct {
+	restic.Backend
+}
+
+func (b *duplicatesBackend) Duplicates(ctx context.Context, t restic.FileType, fn func(restic.DuplicateFile) error) error {
+	if t != restic.PackFile {
+		return nil
+	}
+	return b.List(ctx, t, func(fi restic.FileInfo) error {
+		return fn(restic.DuplicateFile{
+			Handle:    restic.Handle{Type: t, Name: fi.Name},
+			Copies:    2,
+			Identical: true,
+		})
+	})
+}
+
+func (b *duplicatesBackend) RemoveDuplicates(ctx context.Context, h restic.Handle) error {
+	return nil
+}
+
+func TestCheckerDuplicates(t *testing.T) {
+	repo, cleanup := repository.TestRepository(t)
+	defer cleanup()
+
+	archiver.TestSnapshot(t, repo, ".", nil)
+
+	duplicates := func(be restic.Backend) []error {
+		chkr := checker.New(repository.New(be, repository.Options{}), false)
+		return collectErrors(context.TODO(), chkr.Duplicates)
+	}
+
+	// backends which never store a file twice are skipped
+	test.Equals(t, 0, len(duplicates(repo.Backend())))
+
+	packs := restic.NewIDSet()
+	test.OK(t, repo.List(context.TODO(), restic.PackFile, func(id restic.ID, size int64) error {
+		packs.Insert(id)
+		return nil
+	}))
+
+	errs := duplicates(backend.NewRetryBackend(&duplicatesBackend{Backend: repo.Backend()}, 2, nil))
+	test.Equals(t, len(packs), len(errs))
+	for _, err := range errs {
+		e, ok := err.(*checker.ErrDuplicateFile)
+		test.Assert(t, ok, "expected a *checker.ErrDuplicateFile, got %T: %v", err, err)
+		id, err := restic.ParseID(e.Handle.Name)
+		test.OK(t, err)
+		test.Assert(t, packs.Has(id), "unexpected duplicate %v", e.Handle)
+	}
+}
+
 // loadTreesOnceRepository allows each tree to be loaded only once
 type loadTreesOnceRepository struct {
 	restic.Repository
@@ -36,6 +36,10 @@ var (
 type Key struct {
 	MACKey        `json:"mac"`
 	EncryptionKey `json:"encrypt"`
+
+	// Private is the private key for data which is sealed to the recipient
+	// of the repository. It is missing for write-only keys.
+	Private *PrivateKey `json:"private,omitempty"`
 }
 
 // EncryptionKey is key used for encryption
@@ -0,0 +1,186 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+
+	"github.com/restic/restic/internal/errors"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// sealedKeyMagic is written in front of a sealed data key, it allows telling
+// sealed data apart from data which is encrypted with the master key.
+const sealedKeyMagic = "RSTCSDK1"
+
+// SealedKeySize is the size of a data key which is sealed to a public key,
+// the magic is followed by an ephemeral public key.
+const SealedKeySize = 8 + curve25519.PointSize
+
+// dataKeyInfo is used as the info parameter when deriving data keys.
+var dataKeyInfo = []byte("restic sealed data key")
+
+// PrivateKey is the private part of an X25519 recipient key. It is needed to
+// open data keys which were sealed to the corresponding public key.
+type PrivateKey [curve25519.ScalarSize]byte
+
+// PublicKey is the public part of an X25519 recipient key. Data keys are
+// sealed to it by clients which cannot read the repository.
+type PublicKey [curve25519.PointSize]byte
+
+// NewRandomPrivateKey returns a new random private key.
+func NewRandomPrivateKey() *PrivateKey {
+	k := &PrivateKey{}
+
+	n, err := rand.Read(k[:])
+	if n != len(k) || err != nil {
+		panic("unable to read enough random bytes for private key")
+	}
+
+	return k
+}
+
+// PublicKey returns the public key for k.
+func (k *PrivateKey) PublicKey() *PublicKey {
+	buf, err := curve25519.X25519(k[:], curve25519.Basepoint)
+	if err != nil {
+		panic(err)
+	}
+
+	pub := &PublicKey{}
+	copy(pub[:], buf)
+	return pub
+}
+
+// NewDataKey returns a new random key which can only be opened with the
+// private key for k. The data key is returned together with its sealed form,
+// which is SealedKeySize bytes long.
+func (k *PublicKey) NewDataKey() (*Key, []byte, error) {
+	ephemeral := NewRandomPrivateKey()
+	ephemeralPub := ephemeral.PublicKey()
+
+	shared, err := curve25519.X25519(ephemeral[:], k[:])
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "X25519")
+	}
+
+	key, err := deriveDataKey(shared, ephemeralPub, k)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sealed := make([]byte, 0, SealedKeySize)
+	sealed = append(sealed, sealedKeyMagic...)
+	sealed = append(sealed, ephemeralPub[:]...)
+
+	return key, sealed, nil
+}
+
+// IsSealedKey returns true if buf starts with a sealed data key.
+func IsSealedKey(buf []byte) bool {
+	return len(buf) >= SealedKeySize && string(buf[:len(sealedKeyMagic)]) == sealedKeyMagic
+}
+
+// OpenDataKey returns the data key which was sealed to the public key for k.
+func (k *PrivateKey) OpenDataKey(sealed []byte) (*Key, error) {
+	if !IsSealedKey(sealed) {
+		return nil, errors.New("invalid sealed data key")
+	}
+
+	ephemeralPub := &PublicKey{}
+	copy(ephemeralPub[:], sealed[len(sealedKeyMagic):SealedKeySize])
+
+	shared, err := curve25519.X25519(k[:], ephemeralPub[:])
+	if err != nil {
+		return nil, errors.Wrap(err, "X25519")
+	}
+
+	return deriveDataKey(shared, ephemeralPub, k.PublicKey())
+}
+
+// deriveDataKey derives the encryption and MAC keys of a data key from the
+// shared secret and both public keys of the key exchange.
+func deriveDataKey(shared []byte, ephemeralPub, pub *PublicKey) (*Key, error) {
+	salt := make([]byte, 0, 2*len(pub))
+	salt = append(salt, ephemeralPub[:]...)
+	salt = append(salt, pub[:]...)
+
+	buf := make([]byte, aesKeySize+macKeySize)
+	_, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, dataKeyInfo), buf)
+	if err != nil {
+		return nil, errors.Wrap(err, "hkdf")
+	}
+
+	k := &Key{}
+	copy(k.EncryptionKey[:], buf[:aesKeySize])
+	macKeyFromSlice(&k.MACKey, buf[aesKeySize:])
+
+	return k, nil
+}
+
+// Seal encrypts plaintext with a new data key and appends the sealed data
+// key, a new random nonce and the ciphertext to dst.
+func (k *PublicKey) Seal(dst, plaintext []byte) ([]byte, error) {
+	key, sealed, err := k.NewDataKey()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := NewRandomNonce()
+	dst = append(dst, sealed...)
+	dst = append(dst, nonce...)
+	return key.Seal(dst, nonce, plaintext, nil), nil
+}
+
+// Open decrypts data which was sealed to the public key for k by Seal and
+// appends the plaintext to dst.
+func (k *PrivateKey) Open(dst, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < SealedKeySize+ivSize {
+		return nil, errors.New("trying to decrypt invalid data: ciphertext too small")
+	}
+
+	key, err := k.OpenDataKey(ciphertext[:SealedKeySize])
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, ciphertext := ciphertext[SealedKeySize:SealedKeySize+ivSize], ciphertext[SealedKeySize+ivSize:]
+	return key.Open(dst, nonce, ciphertext, nil)
+}
+
+// MarshalJSON converts the PrivateKey to JSON.
+func (k *PrivateKey) MarshalJSON() ([]byte, error) {
+	return json.Marshal(k[:])
+}
+
+// UnmarshalJSON fills the key k with data from the JSON representation.
+func (k *PrivateKey) UnmarshalJSON(data []byte) error {
+	return unmarshalRecipientKey(data, k[:])
+}
+
+// MarshalJSON converts the PublicKey to JSON.
+func (k *PublicKey) MarshalJSON() ([]byte, error) {
+	return json.Marshal(k[:])
+}
+
+// UnmarshalJSON fills the key k with data from the JSON representation.
+func (k *PublicKey) UnmarshalJSON(data []byte) error {
+	return unmarshalRecipientKey(data, k[:])
+}
+
+func unmarshalRecipientKey(data []byte, k []byte) error {
+	var d []byte
+	err := json.Unmarshal(data, &d)
+	if err != nil {
+		return errors.Wrap(err, "Unmarshal")
+	}
+	if len(d) != len(k) {
+		return errors.Errorf("invalid key length %d", len(d))
+	}
+	copy(k, d)
+
+	return nil
+}
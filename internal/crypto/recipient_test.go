@@ -0,0 +1,89 @@
+package crypto_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/restic/restic/internal/crypto"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestDataKey(t *testing.T) {
+	priv := crypto.NewRandomPrivateKey()
+	pub := priv.PublicKey()
+
+	key, sealed, err := pub.NewDataKey()
+	rtest.OK(t, err)
+	rtest.Equals(t, crypto.SealedKeySize, len(sealed))
+	rtest.Assert(t, crypto.IsSealedKey(sealed), "sealed data key not detected")
+
+	opened, err := priv.OpenDataKey(sealed)
+	rtest.OK(t, err)
+	rtest.Equals(t, key.EncryptionKey, opened.EncryptionKey)
+	rtest.Equals(t, key.MACKey.K, opened.MACKey.K)
+	rtest.Equals(t, key.MACKey.R, opened.MACKey.R)
+
+	// a second data key must differ
+	key2, sealed2, err := pub.NewDataKey()
+	rtest.OK(t, err)
+	rtest.Assert(t, key.EncryptionKey != key2.EncryptionKey, "data keys are equal")
+	rtest.Assert(t, string(sealed) != string(sealed2), "sealed data keys are equal")
+
+	// a different private key derives a different data key
+	other, err := crypto.NewRandomPrivateKey().OpenDataKey(sealed)
+	rtest.OK(t, err)
+	rtest.Assert(t, key.EncryptionKey != other.EncryptionKey, "data key opened with wrong private key")
+
+	_, err = priv.OpenDataKey(sealed[:crypto.SealedKeySize-1])
+	rtest.Assert(t, err != nil, "short sealed data key accepted")
+	rtest.Assert(t, !crypto.IsSealedKey(crypto.NewRandomNonce()), "nonce detected as sealed data key")
+}
+
+func TestSealOpen(t *testing.T) {
+	priv := crypto.NewRandomPrivateKey()
+	pub := priv.PublicKey()
+
+	for _, size := range []int{5, 23, 2<<18 + 23} {
+		data := rtest.Random(42, size)
+
+		ciphertext, err := pub.Seal(nil, data)
+		rtest.OK(t, err)
+		rtest.Equals(t, size+crypto.SealedKeySize+crypto.Extension, len(ciphertext))
+
+		plaintext, err := priv.Open(nil, ciphertext)
+		rtest.OK(t, err)
+		rtest.Equals(t, data, plaintext)
+
+		_, err = crypto.NewRandomPrivateKey().Open(nil, ciphertext)
+		rtest.Assert(t, err == crypto.ErrUnauthenticated, "wrong error for wrong private key: %v", err)
+
+		ciphertext[len(ciphertext)-1] ^= 0x01
+		_, err = priv.Open(nil, ciphertext)
+		rtest.Assert(t, err == crypto.ErrUnauthenticated, "wrong error for modified ciphertext: %v", err)
+	}
+}
+
+func TestRecipientKeyJSON(t *testing.T) {
+	k := crypto.NewRandomKey()
+	k.Private = crypto.NewRandomPrivateKey()
+
+	buf, err := json.Marshal(k)
+	rtest.OK(t, err)
+
+	var k2 crypto.Key
+	rtest.OK(t, json.Unmarshal(buf, &k2))
+	rtest.Assert(t, k2.Private != nil, "private key missing")
+	rtest.Equals(t, *k.Private, *k2.Private)
+	rtest.Equals(t, *k.Private.PublicKey(), *k2.Private.PublicKey())
+
+	// write-only keys do not contain a private key
+	k.Private = nil
+	buf, err = json.Marshal(k)
+	rtest.OK(t, err)
+
+	var k3 crypto.Key
+	rtest.OK(t, json.Unmarshal(buf, &k3))
+	rtest.Assert(t, k3.Private == nil, "unexpected private key")
+
+	rtest.Assert(t, json.Unmarshal([]byte(`{"private":"AAAA"}`), &k3) != nil, "short private key accepted")
+}
@@ -21,6 +21,10 @@ type rateLimitedBackend struct {
 	limiter Limiter
 }
 
+func (r rateLimitedBackend) Unwrap() restic.Backend {
+	return r.Backend
+}
+
 func (r rateLimitedBackend) Save(ctx context.Context, h restic.Handle, rd restic.RewindReader) error {
 	limited := limitedRewindReader{
 		RewindReader: rd,
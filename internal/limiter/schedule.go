@@ -0,0 +1,126 @@
+package limiter
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// Schedule sets the rate limit in KiB/s depending on the time of day.
+type Schedule []ScheduleEntry
+
+// ScheduleEntry limits the rate to Kb KiB/s from Start until End, both given
+// as offsets from midnight. If End is before Start, the range wraps around
+// midnight. A rate of zero means unlimited.
+type ScheduleEntry struct {
+	Start, End time.Duration
+	Kb         int
+}
+
+// ParseSchedule parses a comma separated list of time ranges with a rate,
+// for example "08:00-18:00=2048,18:00-08:00=0". Ranges include the start and
+// exclude the end time, and "24:00" can be used as the end of the day.
+func ParseSchedule(s string) (Schedule, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var sched Schedule
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+
+		data := strings.SplitN(item, "=", 2)
+		if len(data) != 2 {
+			return nil, errors.Errorf("invalid schedule entry %q, missing rate", item)
+		}
+
+		kb, err := strconv.Atoi(data[1])
+		if err != nil || kb < 0 {
+			return nil, errors.Errorf("invalid rate %q in schedule entry %q", data[1], item)
+		}
+
+		times := strings.SplitN(data[0], "-", 2)
+		if len(times) != 2 {
+			return nil, errors.Errorf("invalid time range %q in schedule entry %q", data[0], item)
+		}
+
+		start, err := parseTimeOfDay(times[0])
+		if err != nil {
+			return nil, errors.Wrapf(err, "schedule entry %q", item)
+		}
+		end, err := parseTimeOfDay(times[1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "schedule entry %q", item)
+		}
+
+		if start == 24*time.Hour || start == end {
+			return nil, errors.Errorf("empty time range in schedule entry %q", item)
+		}
+
+		sched = append(sched, ScheduleEntry{Start: start, End: end, Kb: kb})
+	}
+
+	return sched, nil
+}
+
+// parseTimeOfDay parses a time in the format "hh:mm" and returns the offset
+// from midnight.
+func parseTimeOfDay(s string) (time.Duration, error) {
+	data := strings.SplitN(s, ":", 2)
+	if len(data) != 2 || len(data[0]) != 2 || len(data[1]) != 2 {
+		return 0, errors.Errorf("invalid time %q, expected hh:mm", s)
+	}
+
+	hours, err := strconv.Atoi(data[0])
+	if err != nil {
+		return 0, errors.Errorf("invalid time %q, expected hh:mm", s)
+	}
+	minutes, err := strconv.Atoi(data[1])
+	if err != nil {
+		return 0, errors.Errorf("invalid time %q, expected hh:mm", s)
+	}
+
+	d := time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute
+	if hours < 0 || minutes < 0 || minutes > 59 || d > 24*time.Hour {
+		return 0, errors.Errorf("invalid time %q", s)
+	}
+
+	return d, nil
+}
+
+// Rate returns the rate in KiB/s at the local time t. The first entry
+// containing t wins, kb is returned if no entry contains t.
+func (s Schedule) Rate(t time.Time, kb int) int {
+	// use the wall clock, which is not affected by daylight saving time
+	hour, min, sec := t.Clock()
+	offset := time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute + time.Duration(sec)*time.Second
+
+	for _, entry := range s {
+		if entry.contains(offset) {
+			return entry.Kb
+		}
+	}
+
+	return kb
+}
+
+func (e ScheduleEntry) contains(offset time.Duration) bool {
+	if e.Start < e.End {
+		return offset >= e.Start && offset < e.End
+	}
+
+	// the range wraps around midnight
+	return offset >= e.Start || offset < e.End
+}
+
+// limits returns whether the schedule limits the rate at any time.
+func (s Schedule) limits() bool {
+	for _, entry := range s {
+		if entry.Kb > 0 {
+			return true
+		}
+	}
+	return false
+}
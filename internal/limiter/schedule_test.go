@@ -0,0 +1,74 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/test"
+)
+
+func TestParseSchedule(t *testing.T) {
+	for _, tc := range []struct {
+		s     string
+		sched Schedule
+	}{
+		{"", nil},
+		{"08:00-18:00=2048", Schedule{{8 * time.Hour, 18 * time.Hour, 2048}}},
+		{"08:00-18:00=2048,18:00-08:00=0", Schedule{
+			{8 * time.Hour, 18 * time.Hour, 2048},
+			{18 * time.Hour, 8 * time.Hour, 0},
+		}},
+		{"22:30-24:00=100, 00:00-06:15=200", Schedule{
+			{22*time.Hour + 30*time.Minute, 24 * time.Hour, 100},
+			{0, 6*time.Hour + 15*time.Minute, 200},
+		}},
+	} {
+		sched, err := ParseSchedule(tc.s)
+		test.OK(t, err)
+		test.Equals(t, tc.sched, sched)
+	}
+}
+
+func TestParseScheduleInvalid(t *testing.T) {
+	for _, s := range []string{
+		"08:00-18:00",
+		"08:00-18:00=",
+		"08:00-18:00=-1",
+		"08:00-18:00=fast",
+		"08:00=100",
+		"8:00-18:00=100",
+		"08:00-18:60=100",
+		"08:00-25:00=100",
+		"24:00-08:00=100",
+		"08:00-08:00=100",
+		"08:00-18:00=100,",
+	} {
+		_, err := ParseSchedule(s)
+		test.Assert(t, err != nil, "no error for %q", s)
+	}
+}
+
+func TestScheduleRate(t *testing.T) {
+	sched, err := ParseSchedule("08:00-18:00=2048,22:00-06:00=0,18:00-23:00=512")
+	test.OK(t, err)
+
+	for _, tc := range []struct {
+		hour, min int
+		kb        int
+	}{
+		{0, 0, 0},
+		{5, 59, 0},
+		{6, 0, 100},
+		{7, 59, 100},
+		{8, 0, 2048},
+		{17, 59, 2048},
+		{18, 0, 512},
+		{21, 59, 512},
+		// the first matching entry wins
+		{22, 0, 0},
+		{23, 59, 0},
+	} {
+		now := time.Date(2022, 3, 27, tc.hour, tc.min, 0, 0, time.Local)
+		test.Equals(t, tc.kb, sched.Rate(now, 100))
+	}
+}
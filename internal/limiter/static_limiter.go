@@ -3,35 +3,77 @@ package limiter
 import (
 	"io"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/juju/ratelimit"
 )
 
 type staticLimiter struct {
-	upstream   *ratelimit.Bucket
-	downstream *ratelimit.Bucket
+	upstream   *bucket
+	downstream *bucket
+}
+
+// Limits configures the rates of a limiter in KiB/s, zero means unlimited.
+// The schedules override the fixed rates at the times of day they cover.
+type Limits struct {
+	UploadKb, DownloadKb             int
+	UploadSchedule, DownloadSchedule Schedule
 }
 
 // NewStaticLimiter constructs a Limiter with a fixed (static) upload and
 // download rate cap
 func NewStaticLimiter(uploadKb, downloadKb int) Limiter {
-	var (
-		upstreamBucket   *ratelimit.Bucket
-		downstreamBucket *ratelimit.Bucket
-	)
+	return NewScheduledLimiter(Limits{UploadKb: uploadKb, DownloadKb: downloadKb}, time.Now)
+}
 
-	if uploadKb > 0 {
-		upstreamBucket = ratelimit.NewBucketWithRate(toByteRate(uploadKb), int64(toByteRate(uploadKb)))
+// NewScheduledLimiter constructs a Limiter which re-evaluates the schedules
+// in l while running, using now to get the current time.
+func NewScheduledLimiter(l Limits, now func() time.Time) Limiter {
+	return staticLimiter{
+		upstream:   newBucket(l.UploadKb, l.UploadSchedule, now),
+		downstream: newBucket(l.DownloadKb, l.DownloadSchedule, now),
 	}
+}
+
+// bucket is a token bucket whose rate follows a schedule.
+type bucket struct {
+	kb       int
+	schedule Schedule
+	now      func() time.Time
 
-	if downloadKb > 0 {
-		downstreamBucket = ratelimit.NewBucketWithRate(toByteRate(downloadKb), int64(toByteRate(downloadKb)))
+	m      sync.Mutex
+	rate   int
+	bucket *ratelimit.Bucket
+}
+
+// newBucket returns a bucket limiting the rate to kb KiB/s, except when the
+// schedule sets another rate. It returns nil if the rate is never limited.
+func newBucket(kb int, schedule Schedule, now func() time.Time) *bucket {
+	if kb <= 0 && !schedule.limits() {
+		return nil
 	}
 
-	return staticLimiter{
-		upstream:   upstreamBucket,
-		downstream: downstreamBucket,
+	return &bucket{kb: kb, schedule: schedule, now: now, rate: -1}
+}
+
+// current returns the token bucket for the rate at the current time, or nil
+// if the rate is currently unlimited.
+func (b *bucket) current() *ratelimit.Bucket {
+	rate := b.schedule.Rate(b.now(), b.kb)
+
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	if rate != b.rate {
+		b.rate = rate
+		b.bucket = nil
+		if rate > 0 {
+			b.bucket = ratelimit.NewBucketWithRate(toByteRate(rate), int64(toByteRate(rate)))
+		}
 	}
+
+	return b.bucket
 }
 
 func (l staticLimiter) Upstream(r io.Reader) io.Reader {
@@ -88,18 +130,50 @@ func (l staticLimiter) Transport(rt http.RoundTripper) http.RoundTripper {
 	})
 }
 
-func (l staticLimiter) limitReader(r io.Reader, b *ratelimit.Bucket) io.Reader {
+func (l staticLimiter) limitReader(r io.Reader, b *bucket) io.Reader {
 	if b == nil {
 		return r
 	}
-	return ratelimit.Reader(r, b)
+	return &limitReader{r: r, b: b}
 }
 
-func (l staticLimiter) limitWriter(w io.Writer, b *ratelimit.Bucket) io.Writer {
+func (l staticLimiter) limitWriter(w io.Writer, b *bucket) io.Writer {
 	if b == nil {
 		return w
 	}
-	return ratelimit.Writer(w, b)
+	return &limitWriter{w: w, b: b}
+}
+
+// limitReader limits the rate of reads with the token bucket which is
+// current at the time of each read.
+type limitReader struct {
+	r io.Reader
+	b *bucket
+}
+
+func (r *limitReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n <= 0 {
+		return n, err
+	}
+	if b := r.b.current(); b != nil {
+		b.Wait(int64(n))
+	}
+	return n, err
+}
+
+// limitWriter limits the rate of writes with the token bucket which is
+// current at the time of each write.
+type limitWriter struct {
+	w io.Writer
+	b *bucket
+}
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	if b := w.b.current(); b != nil {
+		b.Wait(int64(len(p)))
+	}
+	return w.w.Write(p)
 }
 
 func toByteRate(val int) float64 {
@@ -5,8 +5,10 @@ import (
 	"crypto/rand"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/restic/restic/internal/test"
 )
@@ -106,3 +108,37 @@ func TestRoundTripperCornerCases(t *testing.T) {
 	_, err = rt.RoundTrip(&http.Request{})
 	test.Assert(t, err != nil, "round tripper lost an error")
 }
+
+func TestScheduledLimiter(t *testing.T) {
+	sched, err := ParseSchedule("08:00-18:00=2048,18:00-08:00=0")
+	test.OK(t, err)
+
+	now := time.Date(2022, 3, 28, 9, 0, 0, 0, time.Local)
+	clock := func() time.Time { return now }
+
+	limiter := NewScheduledLimiter(Limits{UploadSchedule: sched, DownloadKb: 42}, clock).(staticLimiter)
+
+	test.Equals(t, 2048*1024., limiter.upstream.current().Rate())
+	test.Equals(t, 42*1024., limiter.downstream.current().Rate())
+
+	// the rate changes while running
+	now = now.Add(10 * time.Hour)
+	test.Assert(t, limiter.upstream.current() == nil, "upload is limited in the night")
+	test.Equals(t, 42*1024., limiter.downstream.current().Rate())
+
+	// reads are not delayed without a limit
+	data := make([]byte, 10*1024*1024)
+	start := time.Now()
+	n, err := io.Copy(ioutil.Discard, limiter.Upstream(bytes.NewReader(data)))
+	test.OK(t, err)
+	test.Equals(t, int64(len(data)), n)
+	test.Assert(t, time.Since(start) < 5*time.Second, "unlimited read took %v", time.Since(start))
+
+	now = now.Add(14 * time.Hour)
+	test.Equals(t, 2048*1024., limiter.upstream.current().Rate())
+
+	// without any limit the limiter does not wrap readers at all
+	limiter = NewScheduledLimiter(Limits{UploadSchedule: Schedule{{0, 24 * time.Hour, 0}}}, clock).(staticLimiter)
+	reader := bytes.NewReader(data)
+	test.Assert(t, limiter.Upstream(reader) == reader, "reader was wrapped")
+}
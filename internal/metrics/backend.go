@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/restic/restic/internal/restic"
+)
+
+// Backend records the requests to the wrapped backend.
+type Backend struct {
+	restic.Backend
+
+	requests Counter
+	duration Histogram
+	read     Counter
+	written  Counter
+	retries  Counter
+}
+
+// statically ensure that Backend implements the interfaces.
+var (
+	_ restic.Backend          = &Backend{}
+	_ restic.BackendUnwrapper = &Backend{}
+)
+
+// NewBackend wraps be and records the number, duration and result of all
+// requests as well as the bytes transferred in reg, all by file type.
+func NewBackend(be restic.Backend, reg *Registry) *Backend {
+	return &Backend{
+		Backend: be,
+
+		requests: reg.NewCounter("restic_backend_requests_total",
+			"Number of requests to the backend.", "op", "type", "result"),
+		duration: reg.NewHistogram("restic_backend_request_duration_seconds",
+			"Duration of requests to the backend.", DefaultBuckets, "op", "type"),
+		read: reg.NewCounter("restic_backend_read_bytes_total",
+			"Bytes read from the backend.", "type"),
+		written: reg.NewCounter("restic_backend_written_bytes_total",
+			"Bytes written to the backend, including failed writes.", "type"),
+		retries: reg.NewCounter("restic_backend_retries_total",
+			"Number of retried requests to the backend.", "op", "type"),
+	}
+}
+
+// Unwrap returns the wrapped backend.
+func (be *Backend) Unwrap() restic.Backend {
+	return be.Backend
+}
+
+// Retried records that the operation op for a file of type t is retried, it
+// can be used as backend.RetryBackend.Retried.
+func (be *Backend) Retried(op string, t restic.FileType) {
+	be.retries.Add(1, op, string(t))
+}
+
+// record records a finished request which was started at start.
+func (be *Backend) record(op string, t restic.FileType, start time.Time, err error) {
+	result := "ok"
+	switch {
+	case err == nil:
+	case be.Backend.IsNotExist(err):
+		result = "not_found"
+	default:
+		result = "error"
+	}
+
+	be.requests.Add(1, op, string(t), result)
+	be.duration.Observe(time.Since(start).Seconds(), op, string(t))
+}
+
+// countingReader counts the bytes read from the reader.
+type countingReader struct {
+	io.Reader
+	n *int64
+}
+
+func (rd countingReader) Read(p []byte) (int, error) {
+	n, err := rd.Reader.Read(p)
+	*rd.n += int64(n)
+	return n, err
+}
+
+// countingRewindReader counts the bytes read from the reader, including the
+// bytes read again after a rewind.
+type countingRewindReader struct {
+	restic.RewindReader
+	n int64
+}
+
+func (rd *countingRewindReader) Read(p []byte) (int, error) {
+	n, err := rd.RewindReader.Read(p)
+	rd.n += int64(n)
+	return n, err
+}
+
+// Save stores the file in the wrapped backend.
+func (be *Backend) Save(ctx context.Context, h restic.Handle, rd restic.RewindReader) error {
+	start := time.Now()
+	crd := &countingRewindReader{RewindReader: rd}
+	err := be.Backend.Save(ctx, h, crd)
+	be.written.Add(float64(crd.n), string(h.Type))
+	be.record(restic.OpSave, h.Type, start, err)
+	return err
+}
+
+// Load runs fn with a reader of the file in the wrapped backend.
+func (be *Backend) Load(ctx context.Context, h restic.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
+	start := time.Now()
+	var n int64
+	err := be.Backend.Load(ctx, h, length, offset, func(rd io.Reader) error {
+		return fn(countingReader{Reader: rd, n: &n})
+	})
+	be.read.Add(float64(n), string(h.Type))
+	be.record(restic.OpLoad, h.Type, start, err)
+	return err
+}
+
+// Stat returns information about the file in the wrapped backend.
+func (be *Backend) Stat(ctx context.Context, h restic.Handle) (restic.FileInfo, error) {
+	start := time.Now()
+	fi, err := be.Backend.Stat(ctx, h)
+	be.record(restic.OpStat, h.Type, start, err)
+	return fi, err
+}
+
+// Test returns whether the file exists in the wrapped backend.
+func (be *Backend) Test(ctx context.Context, h restic.Handle) (bool, error) {
+	start := time.Now()
+	ok, err := be.Backend.Test(ctx, h)
+	be.record(restic.OpTest, h.Type, start, err)
+	return ok, err
+}
+
+// Remove removes the file from the wrapped backend.
+func (be *Backend) Remove(ctx context.Context, h restic.Handle) error {
+	start := time.Now()
+	err := be.Backend.Remove(ctx, h)
+	be.record(restic.OpRemove, h.Type, start, err)
+	return err
+}
+
+// List runs fn for each file of type t in the wrapped backend.
+func (be *Backend) List(ctx context.Context, t restic.FileType, fn func(restic.FileInfo) error) error {
+	start := time.Now()
+	err := be.Backend.List(ctx, t, fn)
+	be.record(restic.OpList, t, start, err)
+	return err
+}
@@ -0,0 +1,53 @@
+package metrics_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/mem"
+	"github.com/restic/restic/internal/metrics"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestBackend(t *testing.T) {
+	ctx := context.TODO()
+	reg := metrics.NewRegistry()
+	be := metrics.NewBackend(mem.New(), reg)
+
+	data := []byte("data")
+	h := restic.Handle{Type: restic.PackFile, Name: restic.Hash(data).String()}
+	rtest.OK(t, be.Save(ctx, h, restic.NewByteReader(data, be.Hasher())))
+
+	// saving again fails
+	rtest.Assert(t, be.Save(ctx, h, restic.NewByteReader(data, be.Hasher())) != nil, "no error saving the file again")
+
+	buf, err := backend.LoadAll(ctx, nil, be, h)
+	rtest.OK(t, err)
+	rtest.Equals(t, data, buf)
+
+	_, err = be.Stat(ctx, restic.Handle{Type: restic.IndexFile, Name: h.Name})
+	rtest.Assert(t, be.IsNotExist(err), "unexpected error %v", err)
+
+	be.Retried(restic.OpLoad, restic.PackFile)
+
+	var out bytes.Buffer
+	_, err = reg.WriteTo(&out)
+	rtest.OK(t, err)
+
+	for _, line := range []string{
+		`restic_backend_requests_total{op="save",type="data",result="ok"} 1`,
+		`restic_backend_requests_total{op="save",type="data",result="error"} 1`,
+		`restic_backend_requests_total{op="load",type="data",result="ok"} 1`,
+		`restic_backend_requests_total{op="stat",type="index",result="not_found"} 1`,
+		`restic_backend_request_duration_seconds_count{op="save",type="data"} 2`,
+		`restic_backend_read_bytes_total{type="data"} 4`,
+		`restic_backend_written_bytes_total{type="data"} 4`,
+		`restic_backend_retries_total{op="load",type="data"} 1`,
+	} {
+		rtest.Assert(t, strings.Contains(out.String(), line+"\n"), "line %q not found in\n%v", line, out.String())
+	}
+}
@@ -0,0 +1,262 @@
+// Package metrics collects counters, gauges and histograms and writes them in
+// the Prometheus text exposition format, e.g. for the textfile collector of
+// node_exporter.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the upper bounds of the buckets of histograms measuring
+// durations in seconds.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+const (
+	kindCounter   = "counter"
+	kindGauge     = "gauge"
+	kindHistogram = "histogram"
+)
+
+// Registry holds all metrics.
+type Registry struct {
+	m        sync.Mutex
+	families map[string]*family
+}
+
+type family struct {
+	name, help, kind string
+	labels           []string
+	buckets          []float64
+	series           map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+
+	// histograms only, counts are per bucket and not cumulative
+	counts []uint64
+	count  uint64
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+func (r *Registry) register(name, help, kind string, buckets []float64, labels []string) *family {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if f, ok := r.families[name]; ok {
+		if f.kind != kind || len(f.labels) != len(labels) {
+			panic(fmt.Sprintf("metric %v registered twice with different types", name))
+		}
+		return f
+	}
+
+	f := &family{
+		name:    name,
+		help:    help,
+		kind:    kind,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+	r.families[name] = f
+	return f
+}
+
+// get returns the series for the label values, the registry must be locked.
+func (f *family) get(labelValues []string) *series {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metric %v needs %d label values, got %d", f.name, len(f.labels), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		if f.kind == kindHistogram {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Counter is a value which only increases.
+type Counter struct {
+	r *Registry
+	f *family
+}
+
+// NewCounter registers a counter with the given label names. Registering the
+// same name again returns the existing counter.
+func (r *Registry) NewCounter(name, help string, labels ...string) Counter {
+	return Counter{r: r, f: r.register(name, help, kindCounter, nil, labels)}
+}
+
+// Add increases the counter for the label values by v.
+func (c Counter) Add(v float64, labelValues ...string) {
+	c.r.m.Lock()
+	defer c.r.m.Unlock()
+
+	c.f.get(labelValues).value += v
+}
+
+// Gauge is a value which can be set arbitrarily.
+type Gauge struct {
+	r *Registry
+	f *family
+}
+
+// NewGauge registers a gauge with the given label names. Registering the same
+// name again returns the existing gauge.
+func (r *Registry) NewGauge(name, help string, labels ...string) Gauge {
+	return Gauge{r: r, f: r.register(name, help, kindGauge, nil, labels)}
+}
+
+// Set sets the gauge for the label values to v.
+func (g Gauge) Set(v float64, labelValues ...string) {
+	g.r.m.Lock()
+	defer g.r.m.Unlock()
+
+	g.f.get(labelValues).value = v
+}
+
+// Histogram counts observations in buckets.
+type Histogram struct {
+	r *Registry
+	f *family
+}
+
+// NewHistogram registers a histogram with the given bucket upper bounds, which
+// must be sorted, and label names. Registering the same name again returns the
+// existing histogram.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) Histogram {
+	return Histogram{r: r, f: r.register(name, help, kindHistogram, buckets, labels)}
+}
+
+// Observe adds v to the histogram for the label values.
+func (h Histogram) Observe(v float64, labelValues ...string) {
+	h.r.m.Lock()
+	defer h.r.m.Unlock()
+
+	s := h.f.get(labelValues)
+	s.value += v
+	s.count++
+	if i := sort.SearchFloat64s(h.f.buckets, v); i < len(h.f.buckets) {
+		s.counts[i]++
+	}
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format, sorted
+// by name and label values.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, name := range names {
+		f := r.families[name]
+		if len(f.series) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(bw, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.name, f.kind)
+
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			s := f.series[key]
+			if f.kind != kindHistogram {
+				fmt.Fprintf(bw, "%s%s %s\n", f.name, formatLabels(f.labels, s.labelValues, "", 0), formatValue(s.value))
+				continue
+			}
+
+			var cumulative uint64
+			for i, bound := range f.buckets {
+				cumulative += s.counts[i]
+				fmt.Fprintf(bw, "%s_bucket%s %d\n", f.name, formatLabels(f.labels, s.labelValues, "le", bound), cumulative)
+			}
+			fmt.Fprintf(bw, "%s_bucket%s %d\n", f.name, formatLabels(f.labels, s.labelValues, "le", math.Inf(1)), s.count)
+			fmt.Fprintf(bw, "%s_sum%s %s\n", f.name, formatLabels(f.labels, s.labelValues, "", 0), formatValue(s.value))
+			fmt.Fprintf(bw, "%s_count%s %d\n", f.name, formatLabels(f.labels, s.labelValues, "", 0), s.count)
+		}
+	}
+
+	err := bw.Flush()
+	return cw.n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// formatLabels returns the label set, an additional label named extra is
+// appended unless extra is empty.
+func formatLabels(names, values []string, extra string, extraValue float64) string {
+	var parts []string
+	for i, name := range names {
+		parts = append(parts, name+`="`+escapeLabel(values[i])+`"`)
+	}
+	if extra != "" {
+		parts = append(parts, extra+`="`+formatValue(extraValue)+`"`)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
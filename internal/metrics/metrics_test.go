@@ -0,0 +1,57 @@
+package metrics_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/restic/restic/internal/metrics"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestWriteTo(t *testing.T) {
+	reg := metrics.NewRegistry()
+
+	c := reg.NewCounter("test_requests_total", "Number of requests.", "op")
+	c.Add(1, "save")
+	c.Add(2, "load")
+	c.Add(1, "save")
+
+	g := reg.NewGauge("test_files", "Number of \"files\".", "state")
+	g.Set(3, `new "quoted"`)
+	g.Set(4.5, "changed")
+
+	h := reg.NewHistogram("test_duration_seconds", "Duration\nof requests.", []float64{0.1, 1})
+	h.Observe(0.05)
+	h.Observe(0.1)
+	h.Observe(0.5)
+	h.Observe(2)
+
+	// unused metrics are not written
+	reg.NewCounter("test_unused", "Unused.")
+
+	// registering again returns the same metric
+	reg.NewCounter("test_requests_total", "Number of requests.", "op").Add(1, "load")
+
+	var buf bytes.Buffer
+	n, err := reg.WriteTo(&buf)
+	rtest.OK(t, err)
+	rtest.Equals(t, int64(buf.Len()), n)
+
+	expected := `# HELP test_duration_seconds Duration\nof requests.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{le="0.1"} 2
+test_duration_seconds_bucket{le="1"} 3
+test_duration_seconds_bucket{le="+Inf"} 4
+test_duration_seconds_sum 2.65
+test_duration_seconds_count 4
+# HELP test_files Number of "files".
+# TYPE test_files gauge
+test_files{state="changed"} 4.5
+test_files{state="new \"quoted\""} 3
+# HELP test_requests_total Number of requests.
+# TYPE test_requests_total counter
+test_requests_total{op="load"} 3
+test_requests_total{op="save"} 2
+`
+	rtest.Equals(t, expected, buf.String())
+}
@@ -3,6 +3,7 @@ package migrations
 import (
 	"context"
 
+	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 )
 
@@ -22,3 +23,11 @@ type Migration interface {
 	// Descr returns a description what the migration does.
 	Desc() string
 }
+
+// KeyDemotion is implemented by migrations which turn existing keys into
+// write-only keys.
+type KeyDemotion interface {
+	// DemotedKeys returns the keys which cannot read the repository anymore
+	// once the migration has been applied.
+	DemotedKeys(context.Context, restic.Repository) ([]*repository.Key, error)
+}
@@ -15,6 +15,8 @@ func init() {
 	register(&UpgradeRepoV2{})
 }
 
+// UpgradeRepoV2Error is returned when saving the upgraded config fails. It is
+// also used by later repository upgrades.
 type UpgradeRepoV2Error struct {
 	UploadNewConfigError   error
 	ReuploadOldConfigError error
@@ -53,7 +55,16 @@ func (*UpgradeRepoV2) Check(ctx context.Context, repo restic.Repository) (bool,
 func (*UpgradeRepoV2) RepoCheck() bool {
 	return true
 }
-func (*UpgradeRepoV2) upgrade(ctx context.Context, repo restic.Repository) error {
+
+func (m *UpgradeRepoV2) Apply(ctx context.Context, repo restic.Repository) error {
+	// upgrade config
+	cfg := repo.Config()
+	cfg.Version = 2
+
+	return upgradeConfig(ctx, repo, "restic-migrate-upgrade-repo-v2-", cfg)
+}
+
+func saveConfig(ctx context.Context, repo restic.Repository, cfg restic.Config) error {
 	h := restic.Handle{Type: restic.ConfigFile}
 
 	if !repo.Backend().HasAtomicReplace() {
@@ -64,10 +75,6 @@ func (*UpgradeRepoV2) upgrade(ctx context.Context, repo restic.Repository) error
 		}
 	}
 
-	// upgrade config
-	cfg := repo.Config()
-	cfg.Version = 2
-
 	_, err := repo.SaveJSONUnpacked(ctx, restic.ConfigFile, cfg)
 	if err != nil {
 		return fmt.Errorf("save new config file failed: %w", err)
@@ -76,8 +83,11 @@ func (*UpgradeRepoV2) upgrade(ctx context.Context, repo restic.Repository) error
 	return nil
 }
 
-func (m *UpgradeRepoV2) Apply(ctx context.Context, repo restic.Repository) error {
-	tempdir, err := ioutil.TempDir("", "restic-migrate-upgrade-repo-v2-")
+// upgradeConfig replaces the config of repo by cfg. A backup of the original
+// config is stored in a temporary directory whose name starts with prefix, the
+// original config is uploaded again if saving cfg fails.
+func upgradeConfig(ctx context.Context, repo restic.Repository, prefix string, cfg restic.Config) error {
+	tempdir, err := ioutil.TempDir("", prefix)
 	if err != nil {
 		return fmt.Errorf("create temp dir failed: %w", err)
 	}
@@ -101,7 +111,7 @@ func (m *UpgradeRepoV2) Apply(ctx context.Context, repo restic.Repository) error
 	}
 
 	// run the upgrade
-	err = m.upgrade(ctx, repo)
+	err = saveConfig(ctx, repo, cfg)
 	if err != nil {
 
 		// build an error we can return to the caller
@@ -0,0 +1,90 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/restic/restic/internal/crypto"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+)
+
+func init() {
+	register(&UpgradeRepoV3{})
+}
+
+// UpgradeRepoV3 upgrades a repository to version 3, which seals new pack files
+// and snapshots to a recipient key. This allows adding write-only keys.
+type UpgradeRepoV3 struct{}
+
+func (*UpgradeRepoV3) Name() string {
+	return "upgrade_repo_v3"
+}
+
+func (*UpgradeRepoV3) Desc() string {
+	return "upgrade a repository to version 3 (allows write-only keys)"
+}
+
+func (*UpgradeRepoV3) Check(ctx context.Context, repo restic.Repository) (bool, error) {
+	isV2 := repo.Config().Version == 2
+	return isV2, nil
+}
+
+func (*UpgradeRepoV3) RepoCheck() bool {
+	return true
+}
+
+// DemotedKeys returns all keys except the one used to open the repository.
+// They do not receive the private key and become write-only keys.
+func (*UpgradeRepoV3) DemotedKeys(ctx context.Context, repo restic.Repository) ([]*repository.Key, error) {
+	r, ok := repo.(*repository.Repository)
+	if !ok {
+		return nil, errors.New("repository does not support listing keys")
+	}
+
+	var names []string
+	err := repo.List(ctx, restic.KeyFile, func(id restic.ID, size int64) error {
+		if id.String() != r.KeyName() {
+			names = append(names, id.String())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*repository.Key
+	for _, name := range names {
+		k, err := repository.LoadKey(ctx, r, name)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+func (*UpgradeRepoV3) Apply(ctx context.Context, repo restic.Repository) error {
+	r, ok := repo.(*repository.Repository)
+	if !ok {
+		return errors.New("repository does not support adding a private key")
+	}
+
+	// store the private key before the config refers to its public key, only
+	// the key used to open the repository contains it
+	priv := crypto.NewRandomPrivateKey()
+	err := r.AddPrivateKey(ctx, priv)
+	if err != nil {
+		return fmt.Errorf("adding the private key failed: %w", err)
+	}
+
+	// upgrade config
+	cfg := repo.Config()
+	cfg.Version = 3
+	cfg.Recipient = priv.PublicKey()
+	cfg.WriteKey = crypto.NewRandomKey()
+
+	return upgradeConfig(ctx, repo, "restic-migrate-upgrade-repo-v3-", cfg)
+}
@@ -0,0 +1,81 @@
+package migrations
+
+import (
+	"context"
+	"testing"
+
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/test"
+)
+
+func TestUpgradeRepoV3(t *testing.T) {
+	repo, cleanup := repository.TestRepositoryWithVersion(t, 2)
+	defer cleanup()
+
+	if repo.Config().Version != 2 {
+		t.Fatal("test repo has wrong version")
+	}
+
+	other, err := repository.AddKey(context.Background(), repo.(*repository.Repository), "other password", "user", "host", repo.Key())
+	test.OK(t, err)
+
+	m := &UpgradeRepoV3{}
+
+	// the key with the other password does not receive the private key
+	demoted, err := m.DemotedKeys(context.Background(), repo)
+	test.OK(t, err)
+	test.Equals(t, 1, len(demoted))
+	test.Equals(t, other.Name(), demoted[0].Name())
+	test.Equals(t, "user", demoted[0].Username)
+
+	ok, err := m.Check(context.Background(), repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ok {
+		t.Fatal("migration check returned false")
+	}
+
+	err = m.Apply(context.Background(), repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the password still opens the repository, and the key now contains the
+	// private key for the recipient in the config
+	r := repository.New(repo.Backend(), repository.Options{})
+	test.OK(t, r.SearchKey(context.Background(), test.TestPassword, 0, ""))
+	repo = r
+	test.Equals(t, uint(restic.SealedRepoVersion), repo.Config().Version)
+	if repo.Key().Private == nil {
+		t.Fatal("private key is missing")
+	}
+	test.Equals(t, *repo.Key().Private.PublicKey(), *repo.Config().Recipient)
+	if repo.Config().WriteKey == nil {
+		t.Fatal("write key is missing")
+	}
+
+	keys := 0
+	test.OK(t, repo.List(context.Background(), restic.KeyFile, func(id restic.ID, size int64) error {
+		keys++
+		return nil
+	}))
+	test.Equals(t, 2, keys)
+
+	r = repository.New(repo.Backend(), repository.Options{})
+	test.OK(t, r.SearchKey(context.Background(), "other password", 0, ""))
+	if r.Key().Private != nil {
+		t.Fatal("demoted key contains the private key")
+	}
+
+	// snapshots are now sealed
+	sn := restic.Snapshot{Hostname: "foobar"}
+	id, err := repo.SaveJSONUnpacked(context.Background(), restic.SnapshotFile, &sn)
+	test.OK(t, err)
+
+	var sn2 restic.Snapshot
+	test.OK(t, repo.LoadJSONUnpacked(context.Background(), restic.SnapshotFile, id, &sn2))
+	test.Equals(t, sn.Hostname, sn2.Hostname)
+}
@@ -18,9 +18,10 @@ import (
 type Packer struct {
 	blobs []restic.Blob
 
-	bytes uint
-	k     *crypto.Key
-	wr    io.Writer
+	bytes  uint
+	k      *crypto.Key
+	wr     io.Writer
+	sealed bool
 
 	m sync.Mutex
 }
@@ -30,6 +31,22 @@ func NewPacker(k *crypto.Key, wr io.Writer) *Packer {
 	return &Packer{k: k, wr: wr}
 }
 
+// NewSealedPacker returns a new Packer for a sealed pack, which starts with
+// sealedKey. The blobs must be encrypted with the data key k in sealedKey,
+// which also encrypts the header.
+func NewSealedPacker(k *crypto.Key, sealedKey []byte, wr io.Writer) (*Packer, error) {
+	if len(sealedKey) != crypto.SealedKeySize {
+		return nil, errors.Errorf("invalid sealed key size %d", len(sealedKey))
+	}
+
+	n, err := wr.Write(sealedKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "Write")
+	}
+
+	return &Packer{k: k, wr: wr, bytes: uint(n), sealed: true}, nil
+}
+
 // Add saves the data read from rd as a new blob to the packer. Returned is the
 // number of bytes written to the pack.
 func (p *Packer) Add(t restic.BlobType, id restic.ID, data []byte, uncompressedLength int) (int, error) {
@@ -100,7 +117,11 @@ func (p *Packer) Finalize() (uint, error) {
 	bytesWritten += uint(hdrBytes)
 
 	// write length
-	err = binary.Write(p.wr, binary.LittleEndian, uint32(hdrBytes))
+	hdrLength := uint32(hdrBytes)
+	if p.sealed {
+		hdrLength |= sealedFlag
+	}
+	err = binary.Write(p.wr, binary.LittleEndian, hdrLength)
 	if err != nil {
 		return 0, errors.Wrap(err, "binary.Write")
 	}
@@ -157,6 +178,11 @@ func (p *Packer) Count() int {
 	return len(p.blobs)
 }
 
+// Sealed returns true if the pack starts with a sealed data key.
+func (p *Packer) Sealed() bool {
+	return p.sealed
+}
+
 // Blobs returns the slice of blobs that have been written.
 func (p *Packer) Blobs() []restic.Blob {
 	p.m.Lock()
@@ -177,9 +203,16 @@ var (
 const (
 	// size of the header-length field at the end of the file; it is a uint32
 	headerLengthSize = 4
+	// sealedFlag is set in the header-length field of sealed packs, which
+	// start with a sealed data key
+	sealedFlag = 1 << 31
 	// HeaderSize is the header's constant overhead (independent of #entries)
 	HeaderSize = headerLengthSize + crypto.Extension
 
+	// SealedKeySize is the size of the sealed data key at the start of
+	// sealed packs
+	SealedKeySize = crypto.SealedKeySize
+
 	// MaxHeaderSize is the max size of header including header-length field
 	MaxHeaderSize = 16*1024*1024 + headerLengthSize
 	// number of header enries to download as part of header-length request
@@ -187,10 +220,11 @@ const (
 )
 
 // readRecords reads up to bufsize bytes from the underlying ReaderAt, returning
-// the raw header, the total number of bytes in the header, and any error.
+// the raw header, the total number of bytes in the header, whether the pack
+// is sealed, and any error.
 // If the header contains fewer than bufsize bytes, the header is truncated to
 // the appropriate size.
-func readRecords(rd io.ReaderAt, size int64, bufsize int) ([]byte, int, error) {
+func readRecords(rd io.ReaderAt, size int64, bufsize int) ([]byte, int, bool, error) {
 	if bufsize > int(size) {
 		bufsize = int(size)
 	}
@@ -198,12 +232,14 @@ func readRecords(rd io.ReaderAt, size int64, bufsize int) ([]byte, int, error) {
 	b := make([]byte, bufsize)
 	off := size - int64(bufsize)
 	if _, err := rd.ReadAt(b, off); err != nil {
-		return nil, 0, err
+		return nil, 0, false, err
 	}
 
 	hlen := binary.LittleEndian.Uint32(b[len(b)-headerLengthSize:])
 	b = b[:len(b)-headerLengthSize]
-	debug.Log("header length: %v", hlen)
+	sealed := hlen&sealedFlag != 0
+	hlen &^= sealedFlag
+	debug.Log("header length: %v, sealed: %v", hlen, sealed)
 
 	var err error
 	switch {
@@ -217,7 +253,7 @@ func readRecords(rd io.ReaderAt, size int64, bufsize int) ([]byte, int, error) {
 		err = InvalidFileError{Message: "header is larger than maxHeaderSize"}
 	}
 	if err != nil {
-		return nil, 0, errors.Wrap(err, "readHeader")
+		return nil, 0, false, errors.Wrap(err, "readHeader")
 	}
 
 	total := int(hlen + headerLengthSize)
@@ -226,16 +262,16 @@ func readRecords(rd io.ReaderAt, size int64, bufsize int) ([]byte, int, error) {
 		b = b[len(b)-int(hlen):]
 	}
 
-	return b, total, nil
+	return b, total, sealed, nil
 }
 
 // readHeader reads the header at the end of rd. size is the length of the
-// whole data accessible in rd.
-func readHeader(rd io.ReaderAt, size int64) ([]byte, error) {
+// whole data accessible in rd. It also returns whether the pack is sealed.
+func readHeader(rd io.ReaderAt, size int64) ([]byte, bool, error) {
 	debug.Log("size: %v", size)
 	if size < int64(minFileSize) {
 		err := InvalidFileError{Message: "file is too small"}
-		return nil, errors.Wrap(err, "readHeader")
+		return nil, false, errors.Wrap(err, "readHeader")
 	}
 
 	// assuming extra request is significantly slower than extra bytes download,
@@ -243,19 +279,19 @@ func readHeader(rd io.ReaderAt, size int64) ([]byte, error) {
 	// only make second request if actual number of entries is greater than eagerEntries
 
 	eagerSize := eagerEntries*int(entrySize) + HeaderSize
-	b, c, err := readRecords(rd, size, eagerSize)
+	b, c, sealed, err := readRecords(rd, size, eagerSize)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	if c <= eagerSize {
 		// eager read sufficed, return what we got
-		return b, nil
+		return b, sealed, nil
 	}
-	b, _, err = readRecords(rd, size, c)
+	b, _, sealed, err = readRecords(rd, size, c)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
-	return b, nil
+	return b, sealed, nil
 }
 
 // InvalidFileError is return when a file is found that is not a pack file.
@@ -270,13 +306,29 @@ func (e InvalidFileError) Error() string {
 // List returns the list of entries found in a pack file and the length of the
 // header (including header size and crypto overhead)
 func List(k *crypto.Key, rd io.ReaderAt, size int64) (entries []restic.Blob, hdrSize uint32, err error) {
-	buf, err := readHeader(rd, size)
+	entries, hdrSize, _, err = ListSealed(k, rd, size)
+	return entries, hdrSize, err
+}
+
+// ListSealed works like List, it also returns whether the pack is sealed, as
+// recorded in the header-length field. The header of a sealed pack is
+// encrypted with the data key at the start of rd, which is opened with the
+// private key of k.
+func ListSealed(k *crypto.Key, rd io.ReaderAt, size int64) (entries []restic.Blob, hdrSize uint32, sealed bool, err error) {
+	buf, sealed, err := readHeader(rd, size)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, false, err
+	}
+
+	if sealed {
+		k, err = openDataKey(k, rd)
+		if err != nil {
+			return nil, 0, false, err
+		}
 	}
 
 	if len(buf) < restic.CiphertextLength(0) {
-		return nil, 0, errors.New("invalid header, too small")
+		return nil, 0, false, errors.New("invalid header, too small")
 	}
 
 	hdrSize = headerLengthSize + uint32(len(buf))
@@ -284,17 +336,21 @@ func List(k *crypto.Key, rd io.ReaderAt, size int64) (entries []restic.Blob, hdr
 	nonce, buf := buf[:k.NonceSize()], buf[k.NonceSize():]
 	buf, err = k.Open(buf[:0], nonce, buf, nil)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, false, err
 	}
 
 	// might over allocate a bit if all blobs have EntrySize but only by a few percent
 	entries = make([]restic.Blob, 0, uint(len(buf))/plainEntrySize)
 
+	// the blobs of sealed packs start after the sealed data key
 	pos := uint(0)
+	if sealed {
+		pos = SealedKeySize
+	}
 	for len(buf) > 0 {
 		entry, headerSize, err := parseHeaderEntry(buf)
 		if err != nil {
-			return nil, 0, err
+			return nil, 0, false, err
 		}
 		entry.Offset = pos
 
@@ -303,7 +359,21 @@ func List(k *crypto.Key, rd io.ReaderAt, size int64) (entries []restic.Blob, hdr
 		buf = buf[headerSize:]
 	}
 
-	return entries, hdrSize, nil
+	return entries, hdrSize, sealed, nil
+}
+
+// openDataKey returns the data key at the start of the sealed pack rd.
+func openDataKey(k *crypto.Key, rd io.ReaderAt) (*crypto.Key, error) {
+	if k.Private == nil {
+		return nil, errors.New("the private key is required to read a sealed pack")
+	}
+
+	buf := make([]byte, SealedKeySize)
+	if _, err := rd.ReadAt(buf, 0); err != nil {
+		return nil, errors.Wrap(err, "ReadAt")
+	}
+
+	return k.Private.OpenDataKey(buf)
 }
 
 func parseHeaderEntry(p []byte) (b restic.Blob, size uint, err error) {
@@ -371,5 +441,12 @@ func Size(ctx context.Context, mi restic.MasterIndex) map[restic.ID]int64 {
 		packSize[blob.PackID] = size + int64(CalculateEntrySize(blob.Blob))
 	}
 
+	// sealed packs start with the sealed data key
+	for id := range packSize {
+		if mi.IsSealedPack(id) {
+			packSize[id] += SealedKeySize
+		}
+	}
+
 	return packSize
 }
@@ -100,7 +100,7 @@ func TestReadHeaderEagerLoad(t *testing.T) {
 
 		rd := &countingReaderAt{delegate: bytes.NewReader(buf.Bytes())}
 
-		header, err := readHeader(rd, int64(buf.Len()))
+		header, _, err := readHeader(rd, int64(buf.Len()))
 		rtest.OK(t, err)
 
 		rtest.Equals(t, expectedHeader, header)
@@ -144,7 +144,7 @@ func TestReadRecords(t *testing.T) {
 
 		rd := bytes.NewReader(buf.Bytes())
 
-		header, count, err := readRecords(rd, int64(rd.Len()), bufSize+4)
+		header, count, _, err := readRecords(rd, int64(rd.Len()), bufSize+4)
 		rtest.OK(t, err)
 		rtest.Equals(t, len(totalHeader)+4, count)
 		rtest.Equals(t, expectedHeader, header)
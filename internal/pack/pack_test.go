@@ -94,6 +94,52 @@ func TestCreatePack(t *testing.T) {
 	verifyBlobs(t, bufs, k, bytes.NewReader(packData), packSize)
 }
 
+func TestCreateSealedPack(t *testing.T) {
+	k := crypto.NewRandomKey()
+	k.Private = crypto.NewRandomPrivateKey()
+	dataKey, sealedKey, err := k.Private.PublicKey().NewDataKey()
+	rtest.OK(t, err)
+
+	var buf bytes.Buffer
+	p, err := pack.NewSealedPacker(dataKey, sealedKey, &buf)
+	rtest.OK(t, err)
+
+	written := 0
+	var data [][]byte
+	for _, l := range testLens {
+		b := rtest.Random(l, l)
+		_, err := p.Add(restic.DataBlob, restic.Hash(b), b, 0)
+		rtest.OK(t, err)
+		data = append(data, b)
+		written += l
+	}
+
+	_, err = p.Finalize()
+	rtest.OK(t, err)
+	packData := buf.Bytes()
+	rtest.Equals(t, uint(len(packData)), p.Size())
+	rtest.Equals(t, sealedKey, packData[:crypto.SealedKeySize])
+
+	entries, hdrSize, sealed, err := pack.ListSealed(k, bytes.NewReader(packData), int64(len(packData)))
+	rtest.OK(t, err)
+	rtest.Equals(t, len(testLens), len(entries))
+	rtest.Equals(t, pack.CalculateHeaderSize(entries), int(hdrSize))
+	rtest.Assert(t, sealed, "pack is not marked as sealed in the header")
+	rtest.Assert(t, p.Sealed(), "packer is not marked as sealed")
+	rtest.Equals(t, crypto.SealedKeySize+written+int(hdrSize), len(packData))
+
+	offset := uint(pack.SealedKeySize)
+	for i, e := range entries {
+		rtest.Equals(t, offset, e.Offset)
+		rtest.Equals(t, data[i], packData[e.Offset:e.Offset+e.Length])
+		offset += e.Length
+	}
+
+	// the header is encrypted with the data key
+	_, _, err = pack.List(&crypto.Key{MACKey: k.MACKey, EncryptionKey: k.EncryptionKey}, bytes.NewReader(packData), int64(len(packData)))
+	rtest.Assert(t, err != nil, "sealed pack listed without the private key")
+}
+
 var blobTypeJSON = []struct {
 	t   restic.BlobType
 	res string
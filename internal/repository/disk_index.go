@@ -0,0 +1,758 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/restic"
+)
+
+// A DiskIndex holds the entries of many index files in a sorted file, which
+// is memory-mapped. Lookups use a binary search, so only the pages touched by
+// the search are loaded into memory and the operating system can evict them
+// at any time. This keeps the resident memory small even for repositories with
+// hundreds of millions of blobs, at the cost of slower lookups.
+//
+// The file starts with a header, followed by these sections:
+//
+//	entries:  sorted by blob type and ID, see diskEntrySize
+//	packs:    sorted list of the unique pack IDs referenced by the entries
+//	indexes:  IDs of the index files contained in the disk index
+//	mixed:    sorted list of the packs which contain data and tree blobs
+//	sealed:   sorted list of the packs which start with a sealed data key
+//
+// All integers are stored in little endian byte order.
+type DiskIndex struct {
+	f    *os.File
+	data []byte
+
+	entries []byte
+	packs   []byte
+	indexes []byte
+	mixed   []byte
+	sealed  []byte
+
+	counts [restic.NumBlobTypes]uint
+}
+
+const (
+	diskIndexMagic   = "rstcdidx"
+	diskIndexVersion = 2
+
+	// number of sections following the header
+	diskIndexSections = 5
+
+	// magic, version, reserved, number of entries, packs, indexes, mixed and
+	// sealed packs, followed by the number of blobs per type
+	diskIndexHeaderSize = 8 + 4 + 4 + diskIndexSections*8 + int(restic.NumBlobTypes)*8
+
+	// type (1), ID (32), padding (3), pack index, offset, length and
+	// uncompressed length (4 each)
+	diskEntrySize = 52
+	diskKeySize   = 1 + 32
+)
+
+// diskEntry is a decoded entry of a DiskIndex.
+type diskEntry struct {
+	tpe                restic.BlobType
+	id                 restic.ID
+	packIndex          uint32
+	offset             uint32
+	length             uint32
+	uncompressedLength uint32
+}
+
+// less orders entries by blob type and ID.
+func (e *diskEntry) less(other *diskEntry) bool {
+	if e.tpe != other.tpe {
+		return e.tpe < other.tpe
+	}
+	return bytes.Compare(e.id[:], other.id[:]) < 0
+}
+
+func (e *diskEntry) sameKey(other *diskEntry) bool {
+	return e.tpe == other.tpe && e.id == other.id
+}
+
+func (e *diskEntry) encode(buf []byte) {
+	buf[0] = byte(e.tpe)
+	copy(buf[1:diskKeySize], e.id[:])
+	buf[33], buf[34], buf[35] = 0, 0, 0
+	binary.LittleEndian.PutUint32(buf[36:], e.packIndex)
+	binary.LittleEndian.PutUint32(buf[40:], e.offset)
+	binary.LittleEndian.PutUint32(buf[44:], e.length)
+	binary.LittleEndian.PutUint32(buf[48:], e.uncompressedLength)
+}
+
+func decodeDiskEntry(buf []byte) (e diskEntry) {
+	e.tpe = restic.BlobType(buf[0])
+	copy(e.id[:], buf[1:diskKeySize])
+	e.packIndex = binary.LittleEndian.Uint32(buf[36:])
+	e.offset = binary.LittleEndian.Uint32(buf[40:])
+	e.length = binary.LittleEndian.Uint32(buf[44:])
+	e.uncompressedLength = binary.LittleEndian.Uint32(buf[48:])
+	return e
+}
+
+// OpenDiskIndex maps the disk index in filename into memory.
+func OpenDiskIndex(filename string) (*DiskIndex, error) {
+	f, err := fs.Open(filename)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, errors.WithStack(err)
+	}
+
+	if fi.Size() < int64(diskIndexHeaderSize) || int64(int(fi.Size())) != fi.Size() {
+		_ = f.Close()
+		return nil, errors.Errorf("disk index %v has invalid size %d", filename, fi.Size())
+	}
+
+	data, err := mmapFile(f, int(fi.Size()))
+	if err != nil {
+		_ = f.Close()
+		return nil, errors.Wrap(err, "mmap")
+	}
+
+	idx := &DiskIndex{f: f, data: data}
+	err = idx.parse()
+	if err != nil {
+		_ = idx.Close()
+		return nil, errors.Wrapf(err, "disk index %v", filename)
+	}
+
+	return idx, nil
+}
+
+// parse checks the header and sets the sections.
+func (idx *DiskIndex) parse() error {
+	hdr := idx.data[:diskIndexHeaderSize]
+	if string(hdr[:8]) != diskIndexMagic {
+		return errors.New("invalid header")
+	}
+	if v := binary.LittleEndian.Uint32(hdr[8:]); v != diskIndexVersion {
+		return errors.Errorf("unsupported version %d", v)
+	}
+
+	var sizes [diskIndexSections]uint64
+	for i := range sizes {
+		sizes[i] = binary.LittleEndian.Uint64(hdr[16+8*i:])
+	}
+	for i := range idx.counts {
+		idx.counts[i] = uint(binary.LittleEndian.Uint64(hdr[16+diskIndexSections*8+8*i:]))
+	}
+
+	rest := idx.data[diskIndexHeaderSize:]
+	var sections [diskIndexSections][]byte
+	for i, elemSize := range []uint64{diskEntrySize, 32, 32, 32, 32} {
+		if sizes[i] > uint64(len(rest))/elemSize {
+			return errors.New("file is truncated")
+		}
+		size := int(sizes[i] * elemSize)
+		sections[i], rest = rest[:size], rest[size:]
+	}
+	if len(rest) != 0 {
+		return errors.New("file has trailing data")
+	}
+
+	idx.entries, idx.packs, idx.indexes, idx.mixed, idx.sealed = sections[0], sections[1], sections[2], sections[3], sections[4]
+	return nil
+}
+
+// Close unmaps and closes the file.
+func (idx *DiskIndex) Close() error {
+	err := munmap(idx.data)
+	idx.data, idx.entries, idx.packs, idx.indexes, idx.mixed, idx.sealed = nil, nil, nil, nil, nil, nil
+
+	if cerr := idx.f.Close(); err == nil {
+		err = cerr
+	}
+	return errors.WithStack(err)
+}
+
+func (idx *DiskIndex) len() int {
+	return len(idx.entries) / diskEntrySize
+}
+
+func (idx *DiskIndex) entry(i int) diskEntry {
+	return decodeDiskEntry(idx.entries[i*diskEntrySize:])
+}
+
+func (idx *DiskIndex) numPacks() int {
+	return len(idx.packs) / 32
+}
+
+func (idx *DiskIndex) pack(i uint32) (id restic.ID) {
+	copy(id[:], idx.packs[int(i)*32:])
+	return id
+}
+
+func (idx *DiskIndex) indexIDs() restic.IDs {
+	return decodeIDs(idx.indexes)
+}
+
+func (idx *DiskIndex) mixedPacks() restic.IDs {
+	return decodeIDs(idx.mixed)
+}
+
+func (idx *DiskIndex) sealedPacks() restic.IDs {
+	return decodeIDs(idx.sealed)
+}
+
+func decodeIDs(buf []byte) restic.IDs {
+	ids := make(restic.IDs, len(buf)/32)
+	for i := range ids {
+		copy(ids[i][:], buf[i*32:])
+	}
+	return ids
+}
+
+// searchIDs returns whether the sorted list of IDs in buf contains id.
+func searchIDs(buf []byte, id restic.ID) bool {
+	n := len(buf) / 32
+	i := sort.Search(n, func(i int) bool {
+		return bytes.Compare(buf[i*32:i*32+32], id[:]) >= 0
+	})
+	return i < n && bytes.Equal(buf[i*32:i*32+32], id[:])
+}
+
+// search returns the position of the first entry for bh.
+func (idx *DiskIndex) search(bh restic.BlobHandle) int {
+	var key [diskKeySize]byte
+	key[0] = byte(bh.Type)
+	copy(key[1:], bh.ID[:])
+
+	return sort.Search(idx.len(), func(i int) bool {
+		return bytes.Compare(idx.entries[i*diskEntrySize:i*diskEntrySize+diskKeySize], key[:]) >= 0
+	})
+}
+
+// foreachWithID calls fn for all entries of bh.
+func (idx *DiskIndex) foreachWithID(bh restic.BlobHandle, fn func(e diskEntry)) {
+	for i := idx.search(bh); i < idx.len(); i++ {
+		e := idx.entry(i)
+		if e.tpe != bh.Type || e.id != bh.ID {
+			return
+		}
+		fn(e)
+	}
+}
+
+func (idx *DiskIndex) toPackedBlob(e diskEntry) restic.PackedBlob {
+	return restic.PackedBlob{
+		Blob: restic.Blob{
+			BlobHandle:         restic.BlobHandle{ID: e.id, Type: e.tpe},
+			Length:             uint(e.length),
+			Offset:             uint(e.offset),
+			UncompressedLength: uint(e.uncompressedLength),
+		},
+		PackID: idx.pack(e.packIndex),
+	}
+}
+
+// Has returns true iff the blob is listed in the index.
+func (idx *DiskIndex) Has(bh restic.BlobHandle) bool {
+	found := false
+	idx.foreachWithID(bh, func(diskEntry) { found = true })
+	return found
+}
+
+// Lookup returns all entries for the blob including duplicates. Adds found
+// entries to pbs and returns the result.
+func (idx *DiskIndex) Lookup(bh restic.BlobHandle, pbs []restic.PackedBlob) []restic.PackedBlob {
+	idx.foreachWithID(bh, func(e diskEntry) {
+		pbs = append(pbs, idx.toPackedBlob(e))
+	})
+	return pbs
+}
+
+// LookupSize returns the length of the plaintext content of the blob.
+func (idx *DiskIndex) LookupSize(bh restic.BlobHandle) (plaintextLength uint, found bool) {
+	i := idx.search(bh)
+	if i >= idx.len() {
+		return 0, false
+	}
+
+	e := idx.entry(i)
+	if e.tpe != bh.Type || e.id != bh.ID {
+		return 0, false
+	}
+	if e.uncompressedLength != 0 {
+		return uint(e.uncompressedLength), true
+	}
+	return uint(restic.PlaintextLength(int(e.length))), true
+}
+
+// Count returns the number of blobs of type t in the index.
+func (idx *DiskIndex) Count(t restic.BlobType) uint {
+	return idx.counts[t]
+}
+
+// IDs returns the IDs of the index files contained in the disk index.
+func (idx *DiskIndex) IDs() restic.IDs {
+	return idx.indexIDs()
+}
+
+// HasPack returns true iff the index contains blobs of the pack.
+func (idx *DiskIndex) HasPack(id restic.ID) bool {
+	return searchIDs(idx.packs, id)
+}
+
+// IsMixedPack returns true iff the pack contains data and tree blobs.
+func (idx *DiskIndex) IsMixedPack(id restic.ID) bool {
+	return searchIDs(idx.mixed, id)
+}
+
+// IsSealedPack returns true iff the pack starts with a sealed data key.
+func (idx *DiskIndex) IsSealedPack(id restic.ID) bool {
+	return searchIDs(idx.sealed, id)
+}
+
+// Packs returns all packs in this index.
+func (idx *DiskIndex) Packs() restic.IDSet {
+	packs := restic.NewIDSet()
+	for i := 0; i < idx.numPacks(); i++ {
+		packs.Insert(idx.pack(uint32(i)))
+	}
+	return packs
+}
+
+// Each returns a channel that yields all blobs known to the index. When the
+// context is cancelled, the background goroutine terminates.
+func (idx *DiskIndex) Each(ctx context.Context) <-chan restic.PackedBlob {
+	ch := make(chan restic.PackedBlob)
+
+	go func() {
+		defer close(ch)
+
+		for i := 0; i < idx.len(); i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case ch <- idx.toPackedBlob(idx.entry(i)):
+			}
+		}
+	}()
+
+	return ch
+}
+
+// EachByPack returns a channel that yields all blobs known to the index
+// grouped by pack ID, blobs in packs contained in packBlacklist are skipped.
+// When the context is cancelled, the background goroutine terminates.
+func (idx *DiskIndex) EachByPack(ctx context.Context, packBlacklist restic.IDSet) <-chan EachByPackResult {
+	ch := make(chan EachByPackResult)
+
+	go func() {
+		defer close(ch)
+
+		// the entries are sorted by type, collect the packs of one type at a time
+		for start := 0; start < idx.len(); {
+			tpe := idx.entry(start).tpe
+			byPack := make(map[uint32][]restic.Blob)
+
+			end := start
+			for ; end < idx.len(); end++ {
+				e := idx.entry(end)
+				if e.tpe != tpe {
+					break
+				}
+				if packBlacklist.Has(idx.pack(e.packIndex)) {
+					continue
+				}
+				byPack[e.packIndex] = append(byPack[e.packIndex], idx.toPackedBlob(e).Blob)
+			}
+			start = end
+
+			for packIndex, blobs := range byPack {
+				packID := idx.pack(packIndex)
+				select {
+				case <-ctx.Done():
+					return
+				case ch <- EachByPackResult{packID: packID, blobs: blobs, sealed: idx.IsSealedPack(packID)}:
+				}
+			}
+		}
+	}()
+
+	return ch
+}
+
+// diskIndexSource is a sorted list of index entries which can be merged into
+// a disk index. The pack IDs must be sorted and unique.
+type diskIndexSource interface {
+	len() int
+	entry(i int) diskEntry
+	numPacks() int
+	pack(i uint32) restic.ID
+	indexIDs() restic.IDs
+	mixedPacks() restic.IDs
+	sealedPacks() restic.IDs
+}
+
+// memDiskIndex collects the entries of index files in memory, so that they
+// can be written to a disk index.
+type memDiskIndex struct {
+	entries []diskEntry
+	packs   restic.IDs
+	ids     restic.IDs
+	mixed   restic.IDs
+	sealed  restic.IDs
+	sorted  bool
+}
+
+// add appends the entries of idx.
+func (m *memDiskIndex) add(idx *Index) {
+	idx.m.Lock()
+	defer idx.m.Unlock()
+
+	base := len(m.packs)
+	m.packs = append(m.packs, idx.packs...)
+
+	for typ := range idx.byType {
+		idx.byType[typ].foreach(func(e *indexEntry) bool {
+			m.entries = append(m.entries, diskEntry{
+				tpe:                restic.BlobType(typ),
+				id:                 e.id,
+				packIndex:          uint32(base + e.packIndex),
+				offset:             e.offset,
+				length:             e.length,
+				uncompressedLength: e.uncompressedLength,
+			})
+			return true
+		})
+	}
+
+	m.ids = append(m.ids, idx.ids...)
+	for id := range idx.mixedPacks {
+		m.mixed = append(m.mixed, id)
+	}
+	for id := range idx.sealedPacks {
+		m.sealed = append(m.sealed, id)
+	}
+	m.sorted = false
+}
+
+// sort sorts the entries and removes duplicate pack IDs.
+func (m *memDiskIndex) sort() {
+	if m.sorted {
+		return
+	}
+
+	order := make([]uint32, len(m.packs))
+	for i := range order {
+		order[i] = uint32(i)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return bytes.Compare(m.packs[order[i]][:], m.packs[order[j]][:]) < 0
+	})
+
+	// map the old pack indexes to the positions in the sorted list
+	remap := make([]uint32, len(m.packs))
+	packs := make(restic.IDs, 0, len(m.packs))
+	for _, i := range order {
+		if len(packs) == 0 || packs[len(packs)-1] != m.packs[i] {
+			packs = append(packs, m.packs[i])
+		}
+		remap[i] = uint32(len(packs) - 1)
+	}
+	m.packs = packs
+
+	for i := range m.entries {
+		m.entries[i].packIndex = remap[m.entries[i].packIndex]
+	}
+	sort.Slice(m.entries, func(i, j int) bool {
+		return m.entries[i].less(&m.entries[j])
+	})
+
+	m.sorted = true
+}
+
+func (m *memDiskIndex) len() int                { return len(m.entries) }
+func (m *memDiskIndex) entry(i int) diskEntry   { return m.entries[i] }
+func (m *memDiskIndex) numPacks() int           { return len(m.packs) }
+func (m *memDiskIndex) pack(i uint32) restic.ID { return m.packs[i] }
+func (m *memDiskIndex) indexIDs() restic.IDs    { return m.ids }
+func (m *memDiskIndex) mixedPacks() restic.IDs  { return m.mixed }
+func (m *memDiskIndex) sealedPacks() restic.IDs { return m.sealed }
+
+// mergePacks calls fn for all unique pack IDs of the sources in sorted order.
+// The index of the pack in the source s is i, n is the index of the pack in
+// the merged list.
+func mergePacks(sources []diskIndexSource, fn func(s int, i uint32, n uint32, id restic.ID) error) error {
+	pos := make([]int, len(sources))
+	var n uint32
+
+	for {
+		next := -1
+		var nextID restic.ID
+		for s, src := range sources {
+			if pos[s] >= src.numPacks() {
+				continue
+			}
+			id := src.pack(uint32(pos[s]))
+			if next == -1 || bytes.Compare(id[:], nextID[:]) < 0 {
+				next, nextID = s, id
+			}
+		}
+		if next == -1 {
+			return nil
+		}
+
+		// consume the pack in all sources
+		for s, src := range sources {
+			if pos[s] < src.numPacks() && src.pack(uint32(pos[s])) == nextID {
+				if err := fn(s, uint32(pos[s]), n, nextID); err != nil {
+					return err
+				}
+				pos[s]++
+			}
+		}
+		n++
+	}
+}
+
+// writeDiskIndex merges the sources into a new disk index file in dir and
+// returns its name. Exact duplicates of entries are removed.
+func writeDiskIndex(dir string, sources []diskIndexSource) (filename string, err error) {
+	f, err := ioutil.TempFile(dir, "disk-index-tmp-")
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = fs.Remove(f.Name())
+		}
+	}()
+
+	// the pack indexes of the sources must be translated to the merged list
+	remap := make([][]uint32, len(sources))
+	for s, src := range sources {
+		remap[s] = make([]uint32, src.numPacks())
+	}
+	var numPacks uint64
+	err = mergePacks(sources, func(s int, i uint32, n uint32, _ restic.ID) error {
+		remap[s][i] = n
+		numPacks = uint64(n) + 1
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	wr := bufio.NewWriterSize(f, 1<<20)
+	// the header is written at the end
+	if _, err = wr.Write(make([]byte, diskIndexHeaderSize)); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	var counts [restic.NumBlobTypes]uint64
+	var numEntries uint64
+	buf := make([]byte, diskEntrySize)
+	pos := make([]int, len(sources))
+	// entries with the key of the last written entry, used to skip duplicates
+	var last []diskEntry
+
+	for {
+		next := -1
+		var e diskEntry
+		for s, src := range sources {
+			if pos[s] >= src.len() {
+				continue
+			}
+			cand := src.entry(pos[s])
+			if next == -1 || cand.less(&e) {
+				next, e = s, cand
+			}
+		}
+		if next == -1 {
+			break
+		}
+		pos[next]++
+		e.packIndex = remap[next][e.packIndex]
+
+		if len(last) > 0 && !last[0].sameKey(&e) {
+			last = last[:0]
+		}
+		duplicate := false
+		for _, other := range last {
+			if other == e {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+		last = append(last, e)
+
+		e.encode(buf)
+		if _, err = wr.Write(buf); err != nil {
+			return "", errors.WithStack(err)
+		}
+		counts[e.tpe]++
+		numEntries++
+	}
+
+	// write each pack once
+	written := int64(-1)
+	err = mergePacks(sources, func(s int, i uint32, n uint32, id restic.ID) error {
+		if int64(n) == written {
+			return nil
+		}
+		written = int64(n)
+		_, err := wr.Write(id[:])
+		return errors.WithStack(err)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	ids := restic.NewIDSet()
+	mixed := restic.NewIDSet()
+	sealed := restic.NewIDSet()
+	for _, src := range sources {
+		ids.Merge(restic.NewIDSet(src.indexIDs()...))
+		mixed.Merge(restic.NewIDSet(src.mixedPacks()...))
+		sealed.Merge(restic.NewIDSet(src.sealedPacks()...))
+	}
+	for _, set := range []restic.IDSet{ids, mixed, sealed} {
+		for _, id := range set.List() {
+			if _, err = wr.Write(id[:]); err != nil {
+				return "", errors.WithStack(err)
+			}
+		}
+	}
+
+	if err = wr.Flush(); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	hdr := make([]byte, diskIndexHeaderSize)
+	copy(hdr, diskIndexMagic)
+	binary.LittleEndian.PutUint32(hdr[8:], diskIndexVersion)
+	for i, v := range []uint64{numEntries, numPacks, uint64(len(ids)), uint64(len(mixed)), uint64(len(sealed))} {
+		binary.LittleEndian.PutUint64(hdr[16+8*i:], v)
+	}
+	for i, v := range counts {
+		binary.LittleEndian.PutUint64(hdr[16+diskIndexSections*8+8*i:], v)
+	}
+	if _, err = f.WriteAt(hdr, 0); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	if err = f.Sync(); err != nil {
+		return "", errors.WithStack(err)
+	}
+	if err = f.Close(); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	debug.Log("wrote disk index %v with %d entries and %d packs", f.Name(), numEntries, numPacks)
+	return f.Name(), nil
+}
+
+// diskIndexBatchSize is the number of entries which are collected in memory
+// before they are written to a temporary disk index.
+var diskIndexBatchSize = 2 * 1024 * 1024
+
+// diskIndexFilename is the name of the disk index in the cache directory.
+const diskIndexFilename = "disk-index"
+
+// UpdateDiskIndex adds the index files in ids to the disk index at filename
+// and returns the updated disk index. If old is not nil, it must be the disk
+// index stored at filename, it is closed by UpdateDiskIndex.
+func UpdateDiskIndex(ctx context.Context, repo restic.Repository, filename string, old *DiskIndex, ids restic.IDs) (idx *DiskIndex, err error) {
+	dir := filepath.Dir(filename)
+
+	var sources []diskIndexSource
+	var runs []*DiskIndex
+	if old != nil {
+		sources = append(sources, old)
+	}
+
+	// close the temporary indexes and remove their files
+	defer func() {
+		for _, run := range runs {
+			_ = run.Close()
+			_ = fs.Remove(run.f.Name())
+		}
+		if err != nil && old != nil {
+			_ = old.Close()
+		}
+	}()
+
+	mem := &memDiskIndex{}
+	// writeRun writes the collected entries to a temporary disk index
+	writeRun := func() error {
+		mem.sort()
+		name, err := writeDiskIndex(dir, []diskIndexSource{mem})
+		if err != nil {
+			return err
+		}
+
+		run, err := OpenDiskIndex(name)
+		if err != nil {
+			_ = fs.Remove(name)
+			return err
+		}
+		runs = append(runs, run)
+		sources = append(sources, run)
+		mem = &memDiskIndex{}
+		return nil
+	}
+
+	err = ForIndexes(ctx, repo, ids, func(id restic.ID, index *Index, oldFormat bool, err error) error {
+		if err != nil {
+			return err
+		}
+
+		mem.add(index)
+		if mem.len() >= diskIndexBatchSize {
+			return writeRun()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	mem.sort()
+	sources = append(sources, mem)
+
+	name, err := writeDiskIndex(dir, sources)
+	if err != nil {
+		return nil, err
+	}
+
+	// the old file must be closed before it can be replaced on Windows
+	if old != nil {
+		closeErr := old.Close()
+		old = nil
+		if closeErr != nil {
+			_ = fs.Remove(name)
+			return nil, closeErr
+		}
+	}
+
+	if err = fs.Rename(name, filename); err != nil {
+		_ = fs.Remove(name)
+		return nil, errors.WithStack(err)
+	}
+
+	return OpenDiskIndex(filename)
+}
@@ -0,0 +1,237 @@
+package repository
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/cache"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func listIndexIDs(t testing.TB, repo restic.Repository) restic.IDs {
+	var ids restic.IDs
+	rtest.OK(t, repo.List(context.TODO(), restic.IndexFile, func(id restic.ID, size int64) error {
+		ids = append(ids, id)
+		return nil
+	}))
+	return ids
+}
+
+func checkDiskIndex(t *testing.T, repo restic.Repository, idx *DiskIndex, ids restic.IDs) {
+	blobs := make(map[restic.PackedBlob]struct{})
+	packs := restic.NewIDSet()
+	sealed := restic.NewIDSet()
+	counts := make(map[restic.BlobType]uint)
+	rtest.OK(t, ForIndexes(context.TODO(), repo, ids, func(id restic.ID, index *Index, oldFormat bool, err error) error {
+		rtest.OK(t, err)
+		for pb := range index.Each(context.TODO()) {
+			if _, ok := blobs[pb]; !ok {
+				counts[pb.Type]++
+			}
+			blobs[pb] = struct{}{}
+			packs.Insert(pb.PackID)
+		}
+		sealed.Merge(index.SealedPacks())
+		return nil
+	}))
+
+	for pb := range blobs {
+		rtest.Assert(t, idx.Has(pb.BlobHandle), "blob %v not found", pb.BlobHandle)
+		found := false
+		for _, other := range idx.Lookup(pb.BlobHandle, nil) {
+			found = found || other == pb
+		}
+		rtest.Assert(t, found, "blob %v in pack %v not found", pb.BlobHandle, pb.PackID.Str())
+		rtest.Assert(t, idx.HasPack(pb.PackID), "pack %v not found", pb.PackID.Str())
+	}
+
+	rtest.Assert(t, !idx.Has(restic.NewRandomBlobHandle()), "unknown blob found")
+	rtest.Assert(t, !idx.HasPack(restic.NewRandomID()), "unknown pack found")
+	for id := range packs {
+		rtest.Equals(t, sealed.Has(id), idx.IsSealedPack(id))
+	}
+
+	for _, tpe := range []restic.BlobType{restic.DataBlob, restic.TreeBlob} {
+		rtest.Equals(t, counts[tpe], idx.Count(tpe))
+	}
+	rtest.Equals(t, packs, idx.Packs())
+	rtest.Equals(t, restic.NewIDSet(ids...), restic.NewIDSet(idx.IDs()...))
+
+	n := 0
+	for pb := range idx.Each(context.TODO()) {
+		_, ok := blobs[pb]
+		rtest.Assert(t, ok, "unexpected blob %v", pb)
+		n++
+	}
+	rtest.Equals(t, len(blobs), n)
+
+	n = 0
+	for res := range idx.EachByPack(context.TODO(), restic.NewIDSet()) {
+		for _, blob := range res.blobs {
+			_, ok := blobs[restic.PackedBlob{Blob: blob, PackID: res.packID}]
+			rtest.Assert(t, ok, "unexpected blob %v in pack %v", blob, res.packID.Str())
+			n++
+		}
+	}
+	rtest.Equals(t, len(blobs), n)
+}
+
+func TestDiskIndex(t *testing.T) {
+	// sealed packs must be listed by the disk index
+	repo, cleanup := TestRepositoryWithVersion(t, restic.SealedRepoVersion)
+	defer cleanup()
+
+	for i := 0; i < 4; i++ {
+		restic.TestCreateSnapshot(t, repo, time.Unix(1470492820+int64(i), 0), 3, 0)
+	}
+
+	ids := listIndexIDs(t, repo)
+	rtest.Assert(t, len(ids) > 1, "expected several index files, got %d", len(ids))
+
+	dir, cleanupDir := rtest.TempDir(t)
+	defer cleanupDir()
+	filename := filepath.Join(dir, diskIndexFilename)
+
+	// force temporary files while building the index
+	oldBatchSize := diskIndexBatchSize
+	diskIndexBatchSize = 50
+	defer func() { diskIndexBatchSize = oldBatchSize }()
+
+	idx, err := UpdateDiskIndex(context.TODO(), repo, filename, nil, ids[:1])
+	rtest.OK(t, err)
+	checkDiskIndex(t, repo, idx, ids[:1])
+
+	// add the remaining index files, the first one again is ignored
+	idx, err = UpdateDiskIndex(context.TODO(), repo, filename, idx, ids)
+	rtest.OK(t, err)
+	checkDiskIndex(t, repo, idx, ids)
+	rtest.OK(t, idx.Close())
+
+	// the temporary files are removed
+	files, err := ioutil.ReadDir(dir)
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(files))
+
+	idx, err = OpenDiskIndex(filename)
+	rtest.OK(t, err)
+	checkDiskIndex(t, repo, idx, ids)
+	rtest.OK(t, idx.Close())
+}
+
+func TestDiskIndexInvalid(t *testing.T) {
+	dir, cleanup := rtest.TempDir(t)
+	defer cleanup()
+
+	for _, data := range [][]byte{
+		nil,
+		[]byte("rstcdidx"),
+		make([]byte, diskIndexHeaderSize),
+	} {
+		filename := filepath.Join(dir, diskIndexFilename)
+		rtest.OK(t, ioutil.WriteFile(filename, data, 0600))
+		_, err := OpenDiskIndex(filename)
+		rtest.Assert(t, err != nil, "invalid disk index %q accepted", data)
+	}
+}
+
+// openTestRepo opens the repository in be and loads its index, into a disk
+// index in the cache c if diskIndex is set.
+func openTestRepo(t *testing.T, be restic.Backend, c *cache.Cache, diskIndex bool) *Repository {
+	repo := New(be, Options{DiskIndex: diskIndex})
+	rtest.OK(t, repo.SearchKey(context.TODO(), rtest.TestPassword, 0, ""))
+	repo.UseCache(c)
+	rtest.OK(t, repo.LoadIndex(context.TODO()))
+	return repo
+}
+
+// checkIndexEqual compares the index of repo with the index of want, which is
+// loaded into memory.
+func checkIndexEqual(t *testing.T, want, repo *Repository) {
+	collect := func(mi *MasterIndex) map[restic.PackedBlob]struct{} {
+		blobs := make(map[restic.PackedBlob]struct{})
+		for pb := range mi.Each(context.TODO()) {
+			blobs[pb] = struct{}{}
+		}
+		return blobs
+	}
+
+	wantBlobs := collect(want.idx)
+	rtest.Equals(t, wantBlobs, collect(repo.idx))
+
+	for pb := range wantBlobs {
+		found := false
+		for _, other := range repo.idx.Lookup(pb.BlobHandle) {
+			found = found || other == pb
+		}
+		rtest.Assert(t, found, "blob %v in pack %v not found", pb.BlobHandle, pb.PackID.Str())
+	}
+
+	for _, tpe := range []restic.BlobType{restic.DataBlob, restic.TreeBlob} {
+		rtest.Equals(t, want.idx.Count(tpe), repo.idx.Count(tpe))
+	}
+	rtest.Equals(t, want.idx.Packs(restic.NewIDSet()), repo.idx.Packs(restic.NewIDSet()))
+}
+
+func TestLoadDiskIndex(t *testing.T) {
+	r, cleanup := TestRepository(t)
+	defer cleanup()
+	repo := r.(*Repository)
+	be := repo.Backend()
+
+	c, cleanupCache := cache.TestNewCache(t)
+	defer cleanupCache()
+
+	for i := 0; i < 3; i++ {
+		restic.TestCreateSnapshot(t, repo, time.Unix(1470492820+int64(i), 0), 3, 0)
+	}
+
+	// the disk index is created on the first load
+	diskRepo := openTestRepo(t, be, c, true)
+	rtest.Assert(t, diskRepo.idx.disk != nil, "disk index not used")
+	checkIndexEqual(t, openTestRepo(t, be, nil, false), diskRepo)
+
+	// new index files are added to the existing disk index
+	restic.TestCreateSnapshot(t, repo, time.Unix(1470492830, 0), 3, 0)
+	diskRepo = openTestRepo(t, be, c, true)
+	rtest.Equals(t, restic.NewIDSet(listIndexIDs(t, repo)...), restic.NewIDSet(diskRepo.idx.disk.IDs()...))
+	checkIndexEqual(t, openTestRepo(t, be, nil, false), diskRepo)
+
+	// prune some packs like the prune command, the index files are rewritten
+	// from the disk index
+	removePacks := restic.NewIDSet()
+	keepBlobs := restic.NewBlobSet()
+	i := 0
+	for pb := range diskRepo.idx.Each(context.TODO()) {
+		if len(removePacks) < 3 || removePacks.Has(pb.PackID) {
+			removePacks.Insert(pb.PackID)
+			if i%2 == 0 {
+				keepBlobs.Insert(pb.BlobHandle)
+			}
+			i++
+		}
+	}
+
+	_, err := Repack(context.TODO(), diskRepo, diskRepo, removePacks, keepBlobs, nil)
+	rtest.OK(t, err)
+	obsolete, err := diskRepo.idx.Save(context.TODO(), diskRepo, removePacks, nil, nil)
+	rtest.OK(t, err)
+	for id := range obsolete {
+		rtest.OK(t, diskRepo.Backend().Remove(context.TODO(), restic.Handle{Type: restic.IndexFile, Name: id.String()}))
+	}
+	for id := range removePacks {
+		rtest.OK(t, diskRepo.Backend().Remove(context.TODO(), restic.Handle{Type: restic.PackFile, Name: id.String()}))
+	}
+
+	// the disk index is rebuilt as index files were removed
+	memRepo := openTestRepo(t, be, nil, false)
+	diskRepo = openTestRepo(t, be, c, true)
+	rtest.Equals(t, restic.NewIDSet(listIndexIDs(t, repo)...), restic.NewIDSet(diskRepo.idx.disk.IDs()...))
+	checkIndexEqual(t, memRepo, diskRepo)
+	for id := range removePacks {
+		rtest.Assert(t, !diskRepo.idx.disk.HasPack(id), "removed pack %v still indexed", id.Str())
+	}
+}
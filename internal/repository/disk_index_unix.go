@@ -0,0 +1,20 @@
+//go:build !windows
+// +build !windows
+
+package repository
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// mmapFile maps the first size bytes of f read-only into memory.
+func mmapFile(f *os.File, size int) ([]byte, error) {
+	return unix.Mmap(int(f.Fd()), 0, size, unix.PROT_READ, unix.MAP_SHARED)
+}
+
+// munmap removes a mapping created by mmapFile.
+func munmap(data []byte) error {
+	return unix.Munmap(data)
+}
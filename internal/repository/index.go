@@ -46,6 +46,8 @@ type Index struct {
 	byType     [restic.NumBlobTypes]indexMap
 	packs      restic.IDs
 	mixedPacks restic.IDSet
+	// packs which start with a sealed data key
+	sealedPacks restic.IDSet
 	// only used by Store, StorePacks does not check for already saved packIDs
 	packIDToIndex map[restic.ID]int
 
@@ -60,6 +62,7 @@ func NewIndex() *Index {
 	return &Index{
 		packIDToIndex: make(map[restic.ID]int),
 		mixedPacks:    restic.NewIDSet(),
+		sealedPacks:   restic.NewIDSet(),
 		created:       time.Now(),
 	}
 }
@@ -153,8 +156,8 @@ func (idx *Index) Store(pb restic.PackedBlob) {
 }
 
 // StorePack remembers the ids of all blobs of a given pack
-// in the index
-func (idx *Index) StorePack(id restic.ID, blobs []restic.Blob) {
+// in the index, sealed is set for packs which start with a sealed data key.
+func (idx *Index) StorePack(id restic.ID, blobs []restic.Blob, sealed bool) {
 	idx.m.Lock()
 	defer idx.m.Unlock()
 
@@ -164,6 +167,9 @@ func (idx *Index) StorePack(id restic.ID, blobs []restic.Blob) {
 
 	debug.Log("%v", blobs)
 	packIndex := idx.addToPacks(id)
+	if sealed {
+		idx.sealedPacks.Insert(id)
+	}
 
 	for _, blob := range blobs {
 		idx.store(packIndex, blob)
@@ -291,6 +297,7 @@ func (idx *Index) Each(ctx context.Context) <-chan restic.PackedBlob {
 type EachByPackResult struct {
 	packID restic.ID
 	blobs  []restic.Blob
+	sealed bool
 }
 
 // EachByPack returns a channel that yields all blobs known to the index
@@ -325,6 +332,7 @@ func (idx *Index) EachByPack(ctx context.Context, packBlacklist restic.IDSet) <-
 			for packID, pack := range byPack {
 				var result EachByPackResult
 				result.packID = packID
+				result.sealed = idx.sealedPacks.Has(packID)
 				for _, e := range pack {
 					result.blobs = append(result.blobs, idx.toPackedBlob(e, restic.BlobType(typ)).Blob)
 				}
@@ -365,6 +373,8 @@ func (idx *Index) Count(t restic.BlobType) (n uint) {
 type packJSON struct {
 	ID    restic.ID  `json:"id"`
 	Blobs []blobJSON `json:"blobs"`
+	// Sealed is set for packs which start with a sealed data key.
+	Sealed bool `json:"sealed,omitempty"`
 }
 
 type blobJSON struct {
@@ -394,7 +404,7 @@ func (idx *Index) generatePackList() ([]*packJSON, error) {
 			p, ok := packs[packID]
 			if !ok {
 				// else create new pack
-				p = &packJSON{ID: packID}
+				p = &packJSON{ID: packID, Sealed: idx.sealedPacks.Has(packID)}
 
 				// and append it to the list and map
 				list = append(list, p)
@@ -530,6 +540,13 @@ func (idx *Index) MixedPacks() restic.IDSet {
 	return idx.mixedPacks
 }
 
+// SealedPacks returns an IDSet that contain packs which start with a sealed
+// data key. Packs which are not contained in the set are encrypted with the
+// master key.
+func (idx *Index) SealedPacks() restic.IDSet {
+	return idx.sealedPacks
+}
+
 // merge() merges indexes, i.e. idx.merge(idx2) merges the contents of idx2 into idx.
 // During merging exact duplicates are removed;  idx2 is not changed by this method.
 func (idx *Index) merge(idx2 *Index) error {
@@ -573,6 +590,7 @@ func (idx *Index) merge(idx2 *Index) error {
 	}
 
 	idx.mixedPacks.Merge(idx2.mixedPacks)
+	idx.sealedPacks.Merge(idx2.sealedPacks)
 	idx.ids = append(idx.ids, idx2.ids...)
 	idx.supersedes = append(idx.supersedes, idx2.supersedes...)
 
@@ -630,6 +648,9 @@ func DecodeIndex(buf []byte, id restic.ID) (idx *Index, oldFormat bool, err erro
 		if data && tree {
 			idx.mixedPacks.Insert(pack.ID)
 		}
+		if pack.Sealed {
+			idx.sealedPacks.Insert(pack.ID)
+		}
 	}
 	idx.supersedes = idxJSON.Supersedes
 	idx.ids = append(idx.ids, id)
@@ -17,12 +17,33 @@ const loadIndexParallelism = 5
 func ForAllIndexes(ctx context.Context, repo restic.Repository,
 	fn func(id restic.ID, index *Index, oldFormat bool, err error) error) error {
 
-	debug.Log("Start")
+	return forIndexes(ctx, repo, func(ctx context.Context, fn func(id restic.ID) error) error {
+		return repo.List(ctx, restic.IndexFile, func(id restic.ID, size int64) error {
+			return fn(id)
+		})
+	}, fn)
+}
 
-	type FileInfo struct {
-		restic.ID
-		Size int64
-	}
+// ForIndexes works like ForAllIndexes, but only loads the index files in ids.
+func ForIndexes(ctx context.Context, repo restic.Repository, ids restic.IDs,
+	fn func(id restic.ID, index *Index, oldFormat bool, err error) error) error {
+
+	return forIndexes(ctx, repo, func(ctx context.Context, fn func(id restic.ID) error) error {
+		for _, id := range ids {
+			if err := fn(id); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, fn)
+}
+
+// forIndexes loads the index files passed by list to its callback in parallel.
+func forIndexes(ctx context.Context, repo restic.Repository,
+	list func(ctx context.Context, fn func(id restic.ID) error) error,
+	fn func(id restic.ID, index *Index, oldFormat bool, err error) error) error {
+
+	debug.Log("Start")
 
 	var m sync.Mutex
 
@@ -30,15 +51,15 @@ func ForAllIndexes(ctx context.Context, repo restic.Repository,
 	// cancelled as soon as an error occurs.
 	wg, ctx := errgroup.WithContext(ctx)
 
-	ch := make(chan FileInfo)
+	ch := make(chan restic.ID)
 	// send list of index files through ch, which is closed afterwards
 	wg.Go(func() error {
 		defer close(ch)
-		return repo.List(ctx, restic.IndexFile, func(id restic.ID, size int64) error {
+		return list(ctx, func(id restic.ID) error {
 			select {
 			case <-ctx.Done():
 				return ctx.Err()
-			case ch <- FileInfo{id, size}:
+			case ch <- id:
 			}
 			return nil
 		})
@@ -47,19 +68,19 @@ func ForAllIndexes(ctx context.Context, repo restic.Repository,
 	// a worker receives an index ID from ch, loads the index, and sends it to indexCh
 	worker := func() error {
 		var buf []byte
-		for fi := range ch {
-			debug.Log("worker got file %v", fi.ID.Str())
+		for id := range ch {
+			debug.Log("worker got file %v", id.Str())
 			var err error
 			var idx *Index
 			oldFormat := false
 
-			buf, err = repo.LoadUnpacked(ctx, buf[:0], restic.IndexFile, fi.ID)
+			buf, err = repo.LoadUnpacked(ctx, buf[:0], restic.IndexFile, id)
 			if err == nil {
-				idx, oldFormat, err = DecodeIndex(buf, fi.ID)
+				idx, oldFormat, err = DecodeIndex(buf, id)
 			}
 
 			m.Lock()
-			err = fn(fi.ID, idx, oldFormat, err)
+			err = fn(id, idx, oldFormat, err)
 			m.Unlock()
 			if err != nil {
 				return err
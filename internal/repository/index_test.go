@@ -6,6 +6,7 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/restic/restic/internal/crypto"
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
@@ -467,7 +468,7 @@ func createRandomIndex(rng *rand.Rand, packfiles int) (idx *repository.Index, lo
 
 			offset += size
 		}
-		idx.StorePack(packID, blobs)
+		idx.StorePack(packID, blobs, false)
 
 		if i == 0 {
 			lookupBh = restic.BlobHandle{
@@ -560,3 +561,29 @@ func TestIndexHas(t *testing.T) {
 	rtest.Assert(t, !idx.Has(restic.NewRandomBlobHandle()), "Index reports having a data blob not added to it")
 	rtest.Assert(t, !idx.Has(restic.BlobHandle{ID: tests[0].ID, Type: restic.TreeBlob}), "Index reports having a tree blob added to it with the same id as a data blob")
 }
+
+func TestIndexSealedPacks(t *testing.T) {
+	idx := repository.NewIndex()
+
+	sealedID := restic.NewRandomID()
+	idx.StorePack(sealedID, []restic.Blob{{
+		BlobHandle: restic.NewRandomBlobHandle(),
+		Offset:     crypto.SealedKeySize,
+		Length:     100,
+	}}, true)
+
+	// a blob at the same offset does not mark the pack as sealed
+	plainID := restic.NewRandomID()
+	idx.StorePack(plainID, []restic.Blob{{
+		BlobHandle: restic.NewRandomBlobHandle(),
+		Offset:     crypto.SealedKeySize,
+		Length:     100,
+	}}, false)
+
+	wr := bytes.NewBuffer(nil)
+	rtest.OK(t, idx.Encode(wr))
+
+	idx2, _, err := repository.DecodeIndex(wr.Bytes(), restic.NewRandomID())
+	rtest.OK(t, err)
+	rtest.Equals(t, restic.NewIDSet(sealedID), idx2.SealedPacks())
+}
@@ -37,12 +37,37 @@ type Key struct {
 	Salt []byte `json:"salt"`
 	Data []byte `json:"data"`
 
+	// Pending is set for the new master key of a master key rotation which
+	// has not finished yet.
+	Pending bool `json:"pending,omitempty"`
+	// Rotation records the progress of the master key rotation for a pending
+	// key.
+	Rotation *Rotation `json:"rotation,omitempty"`
+
 	user   *crypto.Key
 	master *crypto.Key
+	// config is only set for write-only keys
+	config *restic.Config
 
 	name string
 }
 
+// keyData is the plaintext of the data of a key file. Write-only keys also
+// contain a copy of the config, as they cannot decrypt the config file.
+type keyData struct {
+	*crypto.Key
+	Config *restic.Config `json:"config,omitempty"`
+}
+
+// Rotation lists the files which existed when a master key rotation was
+// started. These are encrypted with the previous master key, all other files of
+// these types were created with the new master key by the rotation.
+type Rotation struct {
+	Keys      restic.IDs `json:"keys"`
+	Indexes   restic.IDs `json:"indexes"`
+	Snapshots restic.IDs `json:"snapshots"`
+}
+
 // Params tracks the parameters used for the KDF. If not set, it will be
 // calibrated on the first run of AddKey().
 var Params *crypto.Params
@@ -56,9 +81,12 @@ var (
 )
 
 // createMasterKey creates a new master key in the given backend and encrypts
-// it with the password.
-func createMasterKey(ctx context.Context, s *Repository, password string) (*Key, error) {
-	return AddKey(ctx, s, password, "", "", nil)
+// it with the password. The private key is stored together with the master
+// key, it may be nil.
+func createMasterKey(ctx context.Context, s *Repository, password string, private *crypto.PrivateKey) (*Key, error) {
+	master := crypto.NewRandomKey()
+	master.Private = private
+	return AddKey(ctx, s, password, "", "", master)
 }
 
 // OpenKey tries do decrypt the key specified by name with the given password.
@@ -93,12 +121,14 @@ func OpenKey(ctx context.Context, s *Repository, name string, password string) (
 	}
 
 	// restore json
-	k.master = &crypto.Key{}
-	err = json.Unmarshal(buf, k.master)
+	data := keyData{Key: &crypto.Key{}}
+	err = json.Unmarshal(buf, &data)
 	if err != nil {
 		debug.Log("Unmarshal() returned error %v", err)
 		return nil, errors.Wrap(err, "Unmarshal")
 	}
+	k.master = data.Key
+	k.config = data.Config
 	k.name = name
 
 	if !k.Valid() {
@@ -114,12 +144,18 @@ func OpenKey(ctx context.Context, s *Repository, name string, password string) (
 // zero, all keys in the repo are checked.
 func SearchKey(ctx context.Context, s *Repository, password string, maxKeys int, keyHint string) (k *Key, err error) {
 	checked := 0
+	// configErr is returned when a key could be opened, but its master key
+	// cannot decrypt the config
+	var configErr error
 
 	if len(keyHint) > 0 {
 		id, err := restic.Find(ctx, s.Backend(), restic.KeyFile, keyHint)
 
 		if err == nil {
 			key, err := OpenKey(ctx, s, id, password)
+			if err == nil && key.config == nil {
+				err = checkConfigKey(ctx, s, key)
+			}
 
 			if err == nil {
 				debug.Log("successfully opened hinted key %v", id)
@@ -161,6 +197,21 @@ func SearchKey(ctx context.Context, s *Repository, password string, maxKeys int,
 			return err
 		}
 
+		// during a master key rotation, only the keys for either the old or
+		// the new master key can decrypt the config. Write-only keys cannot
+		// decrypt it at all, they contain a copy of the config.
+		if key.config == nil {
+			err = checkConfigKey(ctx, s, key)
+		}
+		if errors.Is(err, crypto.ErrUnauthenticated) {
+			debug.Log("master key of key %v cannot decrypt the config", fi.Name)
+			configErr = errors.Fatalf("config or key %v is damaged: %v", fi.Name, err)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
 		debug.Log("successfully opened key %v", fi.Name)
 		k = key
 		cancel()
@@ -176,12 +227,66 @@ func SearchKey(ctx context.Context, s *Repository, password string, maxKeys int,
 	}
 
 	if k == nil {
+		if configErr != nil {
+			return nil, configErr
+		}
 		return nil, ErrNoKeyFound
 	}
 
 	return k, nil
 }
 
+// checkConfigKey checks that the master key of k can decrypt the config.
+func checkConfigKey(ctx context.Context, s *Repository, k *Key) error {
+	h := restic.Handle{Type: restic.ConfigFile}
+	buf, err := backend.LoadAll(ctx, nil, s.be, h)
+	if err != nil {
+		return err
+	}
+
+	if len(buf) < k.master.NonceSize() {
+		return errors.New("config file is too short")
+	}
+
+	nonce, ciphertext := buf[:k.master.NonceSize()], buf[k.master.NonceSize():]
+	_, err = k.master.Open(ciphertext[:0], nonce, ciphertext, nil)
+	return err
+}
+
+// FindPendingKey returns the pending key which can be opened with password,
+// or nil if there is none.
+func FindPendingKey(ctx context.Context, s *Repository, password string) (*Key, error) {
+	var names []string
+	err := s.List(ctx, restic.KeyFile, func(id restic.ID, size int64) error {
+		names = append(names, id.String())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		k, err := LoadKey(ctx, s, name)
+		if err != nil {
+			return nil, err
+		}
+		if !k.Pending {
+			continue
+		}
+
+		k, err = OpenKey(ctx, s, name, password)
+		if errors.Is(err, crypto.ErrUnauthenticated) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return k, nil
+	}
+
+	return nil, nil
+}
+
 // LoadKey loads a key from the backend.
 func LoadKey(ctx context.Context, s *Repository, name string) (k *Key, err error) {
 	h := restic.Handle{Type: restic.KeyFile, Name: name}
@@ -195,12 +300,68 @@ func LoadKey(ctx context.Context, s *Repository, name string) (k *Key, err error
 	if err != nil {
 		return nil, errors.Wrap(err, "Unmarshal")
 	}
+	k.name = name
 
 	return k, nil
 }
 
 // AddKey adds a new key to an already existing repository.
 func AddKey(ctx context.Context, s *Repository, password, username, hostname string, template *crypto.Key) (*Key, error) {
+	return addKey(ctx, s, password, username, hostname, template, nil, nil)
+}
+
+// CopyKey adds a key with the same content as the key used to open s, which is
+// opened with password. It is used to change the password.
+func CopyKey(ctx context.Context, s *Repository, password string) (*Key, error) {
+	return addKey(ctx, s, password, "", "", s.key, s.keyConfig, nil)
+}
+
+// AddWriteOnlyKey adds a write-only key to a repository with version 3 or
+// later. The key only contains the write key and a copy of the config of s, so
+// it can add new data and lock the repository, but it cannot decrypt the config
+// file, the index or any data.
+func AddWriteOnlyKey(ctx context.Context, s *Repository, password, username, hostname string) (*Key, error) {
+	if s.cfg.Version < restic.SealedRepoVersion {
+		return nil, errors.Errorf("write-only keys require repository version %v", restic.SealedRepoVersion)
+	}
+
+	cfg := s.cfg
+	return addKey(ctx, s, password, username, hostname, cfg.WriteKey, &cfg, nil)
+}
+
+// AddPendingKey adds a key with a new random master key to the repository,
+// which is marked as pending until the master key rotation has finished.
+// Starting from repository version 3, the master key also contains a new
+// private key, the config switched to the new master key uses its public key
+// as the recipient key. The key files, index files and snapshot files which
+// exist at this point are recorded in the pending key.
+func AddPendingKey(ctx context.Context, s *Repository, password, username, hostname string) (*Key, error) {
+	rotation := &Rotation{}
+	for _, files := range []struct {
+		tpe restic.FileType
+		ids *restic.IDs
+	}{
+		{restic.KeyFile, &rotation.Keys},
+		{restic.IndexFile, &rotation.Indexes},
+		{restic.SnapshotFile, &rotation.Snapshots},
+	} {
+		err := s.List(ctx, files.tpe, func(id restic.ID, size int64) error {
+			*files.ids = append(*files.ids, id)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	master := crypto.NewRandomKey()
+	if s.cfg.Version >= restic.SealedRepoVersion {
+		master.Private = crypto.NewRandomPrivateKey()
+	}
+	return addKey(ctx, s, password, username, hostname, master, nil, rotation)
+}
+
+func addKey(ctx context.Context, s *Repository, password, username, hostname string, template *crypto.Key, config *restic.Config, rotation *Rotation) (*Key, error) {
 	// make sure we have valid KDF parameters
 	if Params == nil {
 		p, err := crypto.Calibrate(KDFTimeout, KDFMemory)
@@ -222,6 +383,9 @@ func AddKey(ctx context.Context, s *Repository, password, username, hostname str
 		N:   Params.N,
 		R:   Params.R,
 		P:   Params.P,
+
+		Pending:  rotation != nil,
+		Rotation: rotation,
 	}
 
 	if newkey.Hostname == "" {
@@ -255,26 +419,38 @@ func AddKey(ctx context.Context, s *Repository, password, username, hostname str
 		// copy master keys from old key
 		newkey.master = template
 	}
+	newkey.config = config
+
+	err = saveKey(ctx, s, newkey)
+	if err != nil {
+		return nil, err
+	}
+
+	return newkey, nil
+}
 
+// saveKey encrypts the master key of k with the user key and stores k as a
+// new key file.
+func saveKey(ctx context.Context, s *Repository, k *Key) error {
 	// encrypt master keys (as json) with user key
-	buf, err := json.Marshal(newkey.master)
+	buf, err := json.Marshal(&keyData{Key: k.master, Config: k.config})
 	if err != nil {
-		return nil, errors.Wrap(err, "Marshal")
+		return errors.Wrap(err, "Marshal")
 	}
 
 	nonce := crypto.NewRandomNonce()
 	ciphertext := make([]byte, 0, restic.CiphertextLength(len(buf)))
 	ciphertext = append(ciphertext, nonce...)
-	ciphertext = newkey.user.Seal(ciphertext, nonce, buf, nil)
-	newkey.Data = ciphertext
+	ciphertext = k.user.Seal(ciphertext, nonce, buf, nil)
+	k.Data = ciphertext
 
 	// dump as json
-	buf, err = json.Marshal(newkey)
+	buf, err = json.Marshal(k)
 	if err != nil {
-		return nil, errors.Wrap(err, "Marshal")
+		return errors.Wrap(err, "Marshal")
 	}
 
-	// store in repository and return
+	// store in repository
 	h := restic.Handle{
 		Type: restic.KeyFile,
 		Name: restic.Hash(buf).String(),
@@ -282,12 +458,36 @@ func AddKey(ctx context.Context, s *Repository, password, username, hostname str
 
 	err = s.be.Save(ctx, h, restic.NewByteReader(buf, s.be.Hasher()))
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	newkey.name = h.Name
+	k.name = h.Name
+	return nil
+}
 
-	return newkey, nil
+// AddPrivateKey replaces the key file used to open the repository by a key
+// file whose master key also contains priv. The password does not change.
+func (r *Repository) AddPrivateKey(ctx context.Context, priv *crypto.PrivateKey) error {
+	k, err := LoadKey(ctx, r, r.keyName)
+	if err != nil {
+		return err
+	}
+
+	master := *r.key
+	master.Private = priv
+	k.user = r.userKey
+	k.master = &master
+
+	err = saveKey(ctx, r, k)
+	if err != nil {
+		return err
+	}
+
+	oldName := r.keyName
+	r.setKey(k)
+
+	h := restic.Handle{Type: restic.KeyFile, Name: oldName}
+	return r.be.Remove(ctx, h)
 }
 
 func (k *Key) String() string {
@@ -302,6 +502,13 @@ func (k Key) Name() string {
 	return k.name
 }
 
+// HasPrivateKey returns true if the master key contains the private key, which
+// is needed to read sealed data. Write-only keys and keys which were demoted by
+// the migration to repository version 3 do not contain it.
+func (k *Key) HasPrivateKey() bool {
+	return k.master != nil && k.master.Private != nil
+}
+
 // Valid tests whether the mac and encryption keys are valid (i.e. not zero)
 func (k *Key) Valid() bool {
 	return k.user.Valid() && k.master.Valid()
@@ -17,6 +17,9 @@ type MasterIndex struct {
 	pendingBlobs restic.BlobSet
 	idxMutex     sync.RWMutex
 	compress     bool
+
+	// disk contains the index files loaded into a disk index, if any
+	disk *DiskIndex
 }
 
 // NewMasterIndex creates a new master index.
@@ -33,11 +36,23 @@ func (mi *MasterIndex) markCompressed() {
 	mi.compress = true
 }
 
+// setDiskIndex sets the disk index containing the final index files. The
+// index files must not be inserted again.
+func (mi *MasterIndex) setDiskIndex(disk *DiskIndex) {
+	mi.idxMutex.Lock()
+	defer mi.idxMutex.Unlock()
+
+	mi.disk = disk
+}
+
 // Lookup queries all known Indexes for the ID and returns all matches.
 func (mi *MasterIndex) Lookup(bh restic.BlobHandle) (pbs []restic.PackedBlob) {
 	mi.idxMutex.RLock()
 	defer mi.idxMutex.RUnlock()
 
+	if mi.disk != nil {
+		pbs = mi.disk.Lookup(bh, pbs)
+	}
 	for _, idx := range mi.idx {
 		pbs = idx.Lookup(bh, pbs)
 	}
@@ -50,6 +65,11 @@ func (mi *MasterIndex) LookupSize(bh restic.BlobHandle) (uint, bool) {
 	mi.idxMutex.RLock()
 	defer mi.idxMutex.RUnlock()
 
+	if mi.disk != nil {
+		if size, found := mi.disk.LookupSize(bh); found {
+			return size, found
+		}
+	}
 	for _, idx := range mi.idx {
 		if size, found := idx.LookupSize(bh); found {
 			return size, found
@@ -73,6 +93,9 @@ func (mi *MasterIndex) addPending(bh restic.BlobHandle) bool {
 		return false
 	}
 
+	if mi.disk != nil && mi.disk.Has(bh) {
+		return false
+	}
 	for _, idx := range mi.idx {
 		if idx.Has(bh) {
 			return false
@@ -95,6 +118,9 @@ func (mi *MasterIndex) Has(bh restic.BlobHandle) bool {
 		return true
 	}
 
+	if mi.disk != nil && mi.disk.Has(bh) {
+		return true
+	}
 	for _, idx := range mi.idx {
 		if idx.Has(bh) {
 			return true
@@ -108,6 +134,9 @@ func (mi *MasterIndex) IsMixedPack(packID restic.ID) bool {
 	mi.idxMutex.RLock()
 	defer mi.idxMutex.RUnlock()
 
+	if mi.disk != nil && mi.disk.IsMixedPack(packID) {
+		return true
+	}
 	for _, idx := range mi.idx {
 		if idx.MixedPacks().Has(packID) {
 			return true
@@ -116,12 +145,31 @@ func (mi *MasterIndex) IsMixedPack(packID restic.ID) bool {
 	return false
 }
 
+// IsSealedPack returns true iff the pack starts with a sealed data key.
+func (mi *MasterIndex) IsSealedPack(packID restic.ID) bool {
+	mi.idxMutex.RLock()
+	defer mi.idxMutex.RUnlock()
+
+	if mi.disk != nil && mi.disk.IsSealedPack(packID) {
+		return true
+	}
+	for _, idx := range mi.idx {
+		if idx.SealedPacks().Has(packID) {
+			return true
+		}
+	}
+	return false
+}
+
 // IDs returns the IDs of all indexes contained in the index.
 func (mi *MasterIndex) IDs() restic.IDSet {
 	mi.idxMutex.RLock()
 	defer mi.idxMutex.RUnlock()
 
 	ids := restic.NewIDSet()
+	if mi.disk != nil {
+		ids.Merge(restic.NewIDSet(mi.disk.IDs()...))
+	}
 	for _, idx := range mi.idx {
 		if !idx.Final() {
 			continue
@@ -146,6 +194,9 @@ func (mi *MasterIndex) Packs(packBlacklist restic.IDSet) restic.IDSet {
 	defer mi.idxMutex.RUnlock()
 
 	packs := restic.NewIDSet()
+	if mi.disk != nil {
+		packs.Merge(mi.disk.Packs().Sub(packBlacklist))
+	}
 	for _, idx := range mi.idx {
 		idxPacks := idx.Packs()
 		if idx.final {
@@ -157,12 +208,33 @@ func (mi *MasterIndex) Packs(packBlacklist restic.IDSet) restic.IDSet {
 	return packs
 }
 
+// packFilter returns a function which reports whether a pack is covered by
+// the index. Unlike Packs, this does not collect the packs of the disk index
+// in memory.
+func (mi *MasterIndex) packFilter() func(restic.ID) bool {
+	mi.idxMutex.RLock()
+	defer mi.idxMutex.RUnlock()
+
+	packs := restic.NewIDSet()
+	for _, idx := range mi.idx {
+		packs.Merge(idx.Packs())
+	}
+	disk := mi.disk
+
+	return func(id restic.ID) bool {
+		return packs.Has(id) || (disk != nil && disk.HasPack(id))
+	}
+}
+
 // Count returns the number of blobs of type t in the index.
 func (mi *MasterIndex) Count(t restic.BlobType) (n uint) {
 	mi.idxMutex.RLock()
 	defer mi.idxMutex.RUnlock()
 
 	var sum uint
+	if mi.disk != nil {
+		sum += mi.disk.Count(t)
+	}
 	for _, idx := range mi.idx {
 		sum += idx.Count(t)
 	}
@@ -178,8 +250,9 @@ func (mi *MasterIndex) Insert(idx *Index) {
 	mi.idx = append(mi.idx, idx)
 }
 
-// StorePack remembers the id and pack in the index.
-func (mi *MasterIndex) StorePack(id restic.ID, blobs []restic.Blob) {
+// StorePack remembers the id and pack in the index, sealed is set for packs
+// which start with a sealed data key.
+func (mi *MasterIndex) StorePack(id restic.ID, blobs []restic.Blob, sealed bool) {
 	mi.idxMutex.Lock()
 	defer mi.idxMutex.Unlock()
 
@@ -190,13 +263,13 @@ func (mi *MasterIndex) StorePack(id restic.ID, blobs []restic.Blob) {
 
 	for _, idx := range mi.idx {
 		if !idx.Final() {
-			idx.StorePack(id, blobs)
+			idx.StorePack(id, blobs, sealed)
 			return
 		}
 	}
 
 	newIdx := NewIndex()
-	newIdx.StorePack(id, blobs)
+	newIdx.StorePack(id, blobs, sealed)
 	mi.idx = append(mi.idx, newIdx)
 }
 
@@ -268,15 +341,25 @@ func (mi *MasterIndex) Each(ctx context.Context) <-chan restic.PackedBlob {
 			close(ch)
 		}()
 
-		for _, idx := range mi.idx {
-			idxCh := idx.Each(ctx)
+		// forward passes on the blobs of idxCh and returns false if ctx is cancelled
+		forward := func(idxCh <-chan restic.PackedBlob) bool {
 			for pb := range idxCh {
 				select {
 				case <-ctx.Done():
-					return
+					return false
 				case ch <- pb:
 				}
 			}
+			return true
+		}
+
+		if mi.disk != nil && !forward(mi.disk.Each(ctx)) {
+			return
+		}
+		for _, idx := range mi.idx {
+			if !forward(idx.Each(ctx)) {
+				return
+			}
 		}
 	}()
 
@@ -337,6 +420,39 @@ func (mi *MasterIndex) Save(ctx context.Context, repo restic.Repository, packBla
 
 	wg.Go(func() error {
 		defer close(ch)
+
+		// storePacks adds the packs to newIndex and passes on full indexes
+		storePacks := func(packs <-chan EachByPackResult) error {
+			for pbs := range packs {
+				newIndex.StorePack(pbs.packID, pbs.blobs, pbs.sealed)
+				p.Add(1)
+				if IndexFull(newIndex, mi.compress) {
+					select {
+					case ch <- newIndex:
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+					newIndex = NewIndex()
+				}
+			}
+			return nil
+		}
+
+		if mi.disk != nil {
+			ids := mi.disk.IDs()
+			debug.Log("adding index ids %v of the disk index to supersedes field", ids)
+
+			err = newIndex.AddToSupersedes(ids...)
+			if err != nil {
+				return err
+			}
+			obsolete.Merge(restic.NewIDSet(ids...))
+
+			if err := storePacks(mi.disk.EachByPack(ctx, packBlacklist)); err != nil {
+				return err
+			}
+		}
+
 		for i, idx := range mi.idx {
 			if idx.Final() {
 				ids, err := idx.IDs()
@@ -358,17 +474,8 @@ func (mi *MasterIndex) Save(ctx context.Context, repo restic.Repository, packBla
 
 			debug.Log("adding index %d", i)
 
-			for pbs := range idx.EachByPack(ctx, packBlacklist) {
-				newIndex.StorePack(pbs.packID, pbs.blobs)
-				p.Add(1)
-				if IndexFull(newIndex, mi.compress) {
-					select {
-					case ch <- newIndex:
-					case <-ctx.Done():
-						return ctx.Err()
-					}
-					newIndex = NewIndex()
-				}
+			if err := storePacks(idx.EachByPack(ctx, packBlacklist)); err != nil {
+				return err
 			}
 		}
 
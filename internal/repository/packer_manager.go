@@ -32,18 +32,21 @@ type Packer struct {
 	hw      *hashing.Writer
 	beHw    *hashing.Writer
 	tmpfile *os.File
+
+	// key is used to encrypt the blobs, it is the data key for sealed packs
+	key *crypto.Key
 }
 
 // packerManager keeps a list of open packs and creates new on demand.
 type packerManager struct {
-	be      Saver
-	key     *crypto.Key
-	pm      sync.Mutex
-	packers []*Packer
+	be  Saver
+	key *crypto.Key
+	// if recipient is set, new packs are sealed to it
+	recipient *crypto.PublicKey
+	pm        sync.Mutex
+	packers   []*Packer
 }
 
-const minPackSize = 4 * 1024 * 1024
-
 // newPackerManager returns an new packer manager which writes temporary files
 // to a temporary directory
 func newPackerManager(be Saver, key *crypto.Key) *packerManager {
@@ -71,6 +74,15 @@ func (r *packerManager) findPacker() (packer *Packer, err error) {
 
 	// no suitable packer found, return new
 	debug.Log("create new pack")
+	key := r.key
+	var sealedKey []byte
+	if r.recipient != nil {
+		key, sealedKey, err = r.recipient.NewDataKey()
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	tmpfile, err := fs.TempFile("", "restic-temp-pack-")
 	if err != nil {
 		return nil, errors.Wrap(err, "fs.TempFile")
@@ -85,12 +97,23 @@ func (r *packerManager) findPacker() (packer *Packer, err error) {
 	}
 
 	hw := hashing.NewWriter(w, sha256.New())
-	p := pack.NewPacker(r.key, hw)
+	var p *pack.Packer
+	if sealedKey != nil {
+		p, err = pack.NewSealedPacker(key, sealedKey, hw)
+		if err != nil {
+			_ = tmpfile.Close()
+			_ = fs.RemoveIfExists(tmpfile.Name())
+			return nil, err
+		}
+	} else {
+		p = pack.NewPacker(r.key, hw)
+	}
 	packer = &Packer{
 		Packer:  p,
 		beHw:    beHw,
 		hw:      hw,
 		tmpfile: tmpfile,
+		key:     key,
 	}
 
 	return packer, nil
@@ -148,7 +171,7 @@ func (r *Repository) savePacker(ctx context.Context, t restic.BlobType, p *Packe
 
 	// update blobs in the index
 	debug.Log("  updating blobs %v to pack %v", p.Packer.Blobs(), id)
-	r.idx.StorePack(id, p.Packer.Blobs())
+	r.idx.StorePack(id, p.Packer.Blobs(), p.Packer.Sealed())
 
 	// Save index if full
 	if r.noAutoIndexUpdate {
@@ -57,7 +57,7 @@ func Repack(ctx context.Context, repo restic.Repository, dstRepo restic.Reposito
 
 	worker := func() error {
 		for t := range downloadQueue {
-			err := StreamPack(wgCtx, repo.Backend().Load, repo.Key(), t.PackID, t.Blobs, func(blob restic.BlobHandle, buf []byte, err error) error {
+			err := StreamPack(wgCtx, repo.Backend().Load, repo.Key(), t.PackID, repo.Index().IsSealedPack(t.PackID), t.Blobs, func(blob restic.BlobHandle, buf []byte, err error) error {
 				if err != nil {
 					return err
 				}
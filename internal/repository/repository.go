@@ -8,12 +8,13 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"sort"
 	"sync"
 
 	"github.com/cenkalti/backoff/v4"
+	lru "github.com/hashicorp/golang-lru"
 	"github.com/klauspost/compress/zstd"
-	"github.com/restic/chunker"
 	"github.com/restic/restic/internal/backend/dryrun"
 	"github.com/restic/restic/internal/cache"
 	"github.com/restic/restic/internal/crypto"
@@ -28,14 +29,29 @@ import (
 
 const MaxStreamBufferSize = 4 * 1024 * 1024
 
+// packKeyCacheSize is the number of data keys of sealed pack files which are
+// kept in memory.
+const packKeyCacheSize = 4096
+
+// ErrWriteOnly is returned when data which is sealed to the recipient key of
+// the repository is read with a write-only key.
+var ErrWriteOnly = errors.New("data cannot be decrypted with a write-only key")
+
 // Repository is used to access a repository in a backend.
 type Repository struct {
 	be      restic.Backend
 	cfg     restic.Config
 	key     *crypto.Key
 	keyName string
-	idx     *MasterIndex
-	Cache   *cache.Cache
+	// userKey decrypts the key file keyName
+	userKey *crypto.Key
+	// keyConfig is the copy of the config in a write-only key
+	keyConfig *restic.Config
+	idx       *MasterIndex
+	Cache     *cache.Cache
+
+	// packKeys caches the keys returned by packKey
+	packKeys *lru.Cache
 
 	opts Options
 
@@ -52,6 +68,28 @@ type Repository struct {
 
 type Options struct {
 	Compression CompressionMode
+	// PackSize overrides the target pack size of the repository config if
+	// it is not zero.
+	PackSize uint
+	// DiskIndex loads the index files into a memory-mapped disk index in the
+	// cache directory instead of keeping them in memory.
+	DiskIndex bool
+}
+
+// Limits and default of the target size of pack files.
+const (
+	MinPackSize     = 4 * 1024 * 1024
+	MaxPackSize     = 128 * 1024 * 1024
+	DefaultPackSize = MinPackSize
+)
+
+// CheckPackSize returns an error if size is not a valid target pack size.
+func CheckPackSize(size uint) error {
+	if size < MinPackSize || size > MaxPackSize {
+		return errors.Fatalf("pack size %d MiB is not between %d MiB and %d MiB",
+			size/1024/1024, MinPackSize/1024/1024, MaxPackSize/1024/1024)
+	}
+	return nil
 }
 
 // CompressionMode configures if data should be compressed.
@@ -99,12 +137,18 @@ func (c *CompressionMode) Type() string {
 
 // New returns a new repository with backend be.
 func New(be restic.Backend, opts Options) *Repository {
+	packKeys, err := lru.New(packKeyCacheSize)
+	if err != nil {
+		panic(err)
+	}
+
 	repo := &Repository{
-		be:     be,
-		opts:   opts,
-		idx:    NewMasterIndex(),
-		dataPM: newPackerManager(be, nil),
-		treePM: newPackerManager(be, nil),
+		be:       be,
+		opts:     opts,
+		idx:      NewMasterIndex(),
+		packKeys: packKeys,
+		dataPM:   newPackerManager(be, nil),
+		treePM:   newPackerManager(be, nil),
 	}
 
 	return repo
@@ -122,6 +166,10 @@ func (r *Repository) setConfig(cfg restic.Config) {
 	if r.cfg.Version >= 2 {
 		r.idx.markCompressed()
 	}
+	if r.cfg.Version >= restic.SealedRepoVersion {
+		r.dataPM.recipient = cfg.Recipient
+		r.treePM.recipient = cfg.Recipient
+	}
 }
 
 // Config returns the repository configuration.
@@ -129,6 +177,19 @@ func (r *Repository) Config() restic.Config {
 	return r.cfg
 }
 
+// PackSize returns the target size of new pack files. It is set by the
+// options, then by the repository config and defaults to DefaultPackSize.
+func (r *Repository) PackSize() uint {
+	switch {
+	case r.opts.PackSize != 0:
+		return r.opts.PackSize
+	case r.cfg.PackSize != 0:
+		return r.cfg.PackSize
+	default:
+		return DefaultPackSize
+	}
+}
+
 // UseCache replaces the backend with the wrapped cache.
 func (r *Repository) UseCache(c *cache.Cache) {
 	if c == nil {
@@ -160,6 +221,11 @@ func (r *Repository) LoadUnpacked(ctx context.Context, buf []byte, t restic.File
 
 	debug.Log("load %v with id %v", t, id)
 
+	// write-only keys can only read the lock files of other clients
+	if r.WriteOnly() && t != restic.LockFile {
+		return nil, ErrWriteOnly
+	}
+
 	if t == restic.ConfigFile {
 		id = restic.ID{}
 	}
@@ -184,8 +250,14 @@ func (r *Repository) LoadUnpacked(ctx context.Context, buf []byte, t restic.File
 		return nil, errors.Errorf("load %v: invalid data returned", h)
 	}
 
-	nonce, ciphertext := buf[:r.key.NonceSize()], buf[r.key.NonceSize():]
-	plaintext, err := r.key.Open(ciphertext[:0], nonce, ciphertext, nil)
+	var plaintext []byte
+	if r.sealedFile(t) && crypto.IsSealedKey(buf) {
+		plaintext, err = r.key.Private.Open(nil, buf)
+	} else {
+		key := r.unpackedKey(t)
+		nonce, ciphertext := buf[:key.NonceSize()], buf[key.NonceSize():]
+		plaintext, err = key.Open(ciphertext[:0], nonce, ciphertext, nil)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -277,9 +349,15 @@ func (r *Repository) LoadBlob(ctx context.Context, t restic.BlobType, id restic.
 			continue
 		}
 
+		key, err := r.packKey(ctx, blob.PackID)
+		if err != nil {
+			lastError = errors.Errorf("loading key for blob %v failed: %v", id, err)
+			continue
+		}
+
 		// decrypt
-		nonce, ciphertext := buf[:r.key.NonceSize()], buf[r.key.NonceSize():]
-		plaintext, err := r.key.Open(ciphertext[:0], nonce, ciphertext, nil)
+		nonce, ciphertext := buf[:key.NonceSize()], buf[key.NonceSize():]
+		plaintext, err := key.Open(ciphertext[:0], nonce, ciphertext, nil)
 		if err != nil {
 			lastError = errors.Errorf("decrypting blob %v failed: %v", id, err)
 			continue
@@ -315,6 +393,54 @@ func (r *Repository) LoadBlob(ctx context.Context, t restic.BlobType, id restic.
 	return nil, errors.Errorf("loading blob %v from %v packs failed", id.Str(), len(blobs))
 }
 
+// packKey returns the key for the blobs in the pack file id. The data key is
+// only loaded for packs which the index marks as sealed, all other packs are
+// encrypted with the master key.
+func (r *Repository) packKey(ctx context.Context, id restic.ID) (*crypto.Key, error) {
+	if !r.idx.IsSealedPack(id) {
+		return r.key, nil
+	}
+
+	if key, ok := r.packKeys.Get(id); ok {
+		return key.(*crypto.Key), nil
+	}
+
+	key, err := LoadPackKey(ctx, r.be, r.key, id)
+	if err != nil {
+		return nil, err
+	}
+
+	r.packKeys.Add(id, key)
+	return key, nil
+}
+
+// LoadPackKey returns the data key stored at the start of the sealed pack file
+// id, which is opened with the private key of key.
+func LoadPackKey(ctx context.Context, be restic.Backend, key *crypto.Key, id restic.ID) (*crypto.Key, error) {
+	h := restic.Handle{Type: restic.PackFile, Name: id.String()}
+	buf := make([]byte, crypto.SealedKeySize)
+	_, err := restic.ReadAt(ctx, be, h, 0, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return openPackKey(key, buf)
+}
+
+// openPackKey returns the data key sealed in buf, which holds the start of a
+// sealed pack file.
+func openPackKey(key *crypto.Key, buf []byte) (*crypto.Key, error) {
+	if !crypto.IsSealedKey(buf) {
+		return nil, errors.New("sealed pack does not start with a sealed data key")
+	}
+
+	if key.Private == nil {
+		return nil, ErrWriteOnly
+	}
+
+	return key.Private.OpenDataKey(buf[:crypto.SealedKeySize])
+}
+
 // LoadJSONUnpacked decrypts the data and afterwards calls json.Unmarshal on
 // the item.
 func (r *Repository) LoadJSONUnpacked(ctx context.Context, t restic.FileType, id restic.ID, item interface{}) (err error) {
@@ -395,14 +521,6 @@ func (r *Repository) saveAndEncrypt(ctx context.Context, t restic.BlobType, data
 		}
 	}
 
-	nonce := crypto.NewRandomNonce()
-
-	ciphertext := make([]byte, 0, restic.CiphertextLength(len(data)))
-	ciphertext = append(ciphertext, nonce...)
-
-	// encrypt blob
-	ciphertext = r.key.Seal(ciphertext, nonce, data, nil)
-
 	// find suitable packer and add blob
 	var pm *packerManager
 
@@ -420,6 +538,15 @@ func (r *Repository) saveAndEncrypt(ctx context.Context, t restic.BlobType, data
 		return err
 	}
 
+	nonce := crypto.NewRandomNonce()
+
+	ciphertext := make([]byte, 0, restic.CiphertextLength(len(data)))
+	ciphertext = append(ciphertext, nonce...)
+
+	// encrypt blob with the key of the pack, which differs from the master
+	// key for sealed packs
+	ciphertext = packer.key.Seal(ciphertext, nonce, data, nil)
+
 	// save ciphertext
 	_, err = packer.Add(t, id, ciphertext, uncompressedLength)
 	if err != nil {
@@ -427,7 +554,7 @@ func (r *Repository) saveAndEncrypt(ctx context.Context, t restic.BlobType, data
 	}
 
 	// if the pack is not full enough, put back to the list
-	if packer.Size() < minPackSize {
+	if packer.Size() < r.PackSize() {
 		debug.Log("pack is not full enough (%d bytes)", packer.Size())
 		pm.insertPacker(packer)
 		return nil
@@ -493,12 +620,21 @@ func (r *Repository) SaveUnpacked(ctx context.Context, t restic.FileType, p []by
 		}
 	}
 
-	ciphertext := restic.NewBlobBuffer(len(p))
-	ciphertext = ciphertext[:0]
-	nonce := crypto.NewRandomNonce()
-	ciphertext = append(ciphertext, nonce...)
+	var ciphertext []byte
+	if r.sealedFile(t) {
+		// snapshots and indexes can only be read with the private key
+		ciphertext, err = r.cfg.Recipient.Seal(nil, p)
+		if err != nil {
+			return restic.ID{}, err
+		}
+	} else {
+		ciphertext = restic.NewBlobBuffer(len(p))
+		ciphertext = ciphertext[:0]
+		nonce := crypto.NewRandomNonce()
+		ciphertext = append(ciphertext, nonce...)
 
-	ciphertext = r.key.Seal(ciphertext, nonce, p, nil)
+		ciphertext = r.unpackedKey(t).Seal(ciphertext, nonce, p, nil)
+	}
 
 	if t == restic.ConfigFile {
 		id = restic.ID{}
@@ -517,6 +653,22 @@ func (r *Repository) SaveUnpacked(ctx context.Context, t restic.FileType, p []by
 	return id, nil
 }
 
+// sealedFile returns true for the file types which are sealed to the recipient
+// key, starting from repository version 3.
+func (r *Repository) sealedFile(t restic.FileType) bool {
+	return r.cfg.Version >= restic.SealedRepoVersion && (t == restic.SnapshotFile || t == restic.IndexFile)
+}
+
+// unpackedKey returns the key for unpacked files of type t which are not
+// sealed. Starting from repository version 3, lock files are encrypted with the
+// write key from the config, which is shared with write-only keys.
+func (r *Repository) unpackedKey(t restic.FileType) *crypto.Key {
+	if t == restic.LockFile && r.cfg.WriteKey != nil {
+		return r.cfg.WriteKey
+	}
+	return r.key
+}
+
 // Flush saves all remaining packs and the index
 func (r *Repository) Flush(ctx context.Context) error {
 	if err := r.FlushPacks(ctx); err != nil {
@@ -616,6 +768,17 @@ func (r *Repository) SaveFullIndex(ctx context.Context) error {
 func (r *Repository) LoadIndex(ctx context.Context) error {
 	debug.Log("Loading index")
 
+	// the index files are sealed, write-only keys only deduplicate the data
+	// they add themselves
+	if r.WriteOnly() {
+		debug.Log("write-only key, not loading the index")
+		return nil
+	}
+
+	if r.opts.DiskIndex && r.Cache != nil {
+		return r.loadDiskIndex(ctx)
+	}
+
 	err := ForAllIndexes(ctx, r, func(id restic.ID, idx *Index, oldFormat bool, err error) error {
 		if err != nil {
 			return err
@@ -639,6 +802,11 @@ func (r *Repository) LoadIndex(ctx context.Context) error {
 		return err
 	}
 
+	return r.checkIndex(ctx)
+}
+
+// checkIndex checks the loaded index and prepares the cache.
+func (r *Repository) checkIndex(ctx context.Context) error {
 	if r.cfg.Version < 2 {
 		// sanity check
 		ctx, cancel := context.WithCancel(ctx)
@@ -654,6 +822,79 @@ func (r *Repository) LoadIndex(ctx context.Context) error {
 	return r.PrepareCache()
 }
 
+// loadDiskIndex loads the disk index from the cache directory and adds index
+// files which are not contained in it yet. The disk index is rebuilt if index
+// files were removed from the repository.
+func (r *Repository) loadDiskIndex(ctx context.Context) error {
+	filename := filepath.Join(r.Cache.Dir(), diskIndexFilename)
+
+	ids := restic.NewIDSet()
+	err := r.List(ctx, restic.IndexFile, func(id restic.ID, size int64) error {
+		ids.Insert(id)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	disk, err := OpenDiskIndex(filename)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			debug.Log("unable to open disk index, rebuilding it: %v", err)
+		}
+		disk = nil
+	}
+
+	if disk != nil {
+		known := restic.NewIDSet(disk.IDs()...)
+		if len(known.Sub(ids)) > 0 {
+			debug.Log("index files were removed, rebuilding the disk index")
+			if err := disk.Close(); err != nil {
+				return err
+			}
+			disk = nil
+		} else {
+			ids = ids.Sub(known)
+		}
+	}
+
+	if disk == nil || len(ids) > 0 {
+		debug.Log("adding %d index files to the disk index", len(ids))
+		disk, err = UpdateDiskIndex(ctx, r, filename, disk, ids.List())
+		if err != nil {
+			return errors.Fatalf("unable to update the disk index: %v", err)
+		}
+	}
+
+	r.idx.setDiskIndex(disk)
+	return r.checkIndex(ctx)
+}
+
+// LoadIndexFiles loads the index files ids into the index of r, without
+// using the disk index. This is used while the repository is re-encrypted with
+// a new master key, where the index files encrypted with the previous and the
+// new master key are loaded into different repositories.
+func LoadIndexFiles(ctx context.Context, r *Repository, ids restic.IDs) error {
+	err := ForIndexes(ctx, r, ids, func(id restic.ID, idx *Index, oldFormat bool, err error) error {
+		if err != nil {
+			return errors.Fatalf("unable to load index %v: %v", id.Str(), err)
+		}
+
+		_, err = idx.IDs()
+		if err != nil {
+			return err
+		}
+
+		r.idx.Insert(idx)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return r.idx.MergeFinalIndexes()
+}
+
 const listPackParallelism = 10
 
 // CreateIndexFromPacks creates a new index by reading all given pack files (with sizes).
@@ -691,14 +932,14 @@ func (r *Repository) CreateIndexFromPacks(ctx context.Context, packsize map[rest
 	// a worker receives an pack ID from ch, reads the pack contents, and adds them to idx
 	worker := func() error {
 		for fi := range ch {
-			entries, _, err := r.ListPack(ctx, fi.ID, fi.Size)
+			entries, _, sealed, err := r.listPack(ctx, fi.ID, fi.Size)
 			if err != nil {
 				debug.Log("unable to list pack file %v", fi.ID.Str())
 				m.Lock()
 				invalid = append(invalid, fi.ID)
 				m.Unlock()
 			}
-			idx.StorePack(fi.ID, entries)
+			idx.StorePack(fi.ID, entries, sealed)
 			p.Add(1)
 		}
 
@@ -737,10 +978,8 @@ func (r *Repository) PrepareCache() error {
 		fmt.Fprintf(os.Stderr, "error clearing index files in cache: %v\n", err)
 	}
 
-	packs := r.idx.Packs(restic.NewIDSet())
-
 	// clear old packs
-	err = r.Cache.Clear(restic.PackFile, packs)
+	err = r.Cache.ClearFunc(restic.PackFile, r.idx.packFilter())
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error clearing pack files in cache: %v\n", err)
 	}
@@ -756,15 +995,28 @@ func (r *Repository) SearchKey(ctx context.Context, password string, maxKeys int
 		return err
 	}
 
-	r.key = key.master
-	r.dataPM.key = key.master
-	r.treePM.key = key.master
-	r.keyName = key.Name()
-	cfg, err := restic.LoadConfig(ctx, r)
-	if err == crypto.ErrUnauthenticated {
-		return errors.Fatalf("config or key %v is damaged: %v", key.Name(), err)
-	} else if err != nil {
-		return errors.Fatalf("config cannot be loaded: %v", err)
+	r.setKey(key)
+	var cfg restic.Config
+	if key.config != nil {
+		// write-only keys cannot decrypt the config, they contain a copy
+		cfg = *key.config
+		err = cfg.Check()
+		if err != nil {
+			return errors.Fatalf("config of write-only key %v is invalid: %v", key.Name(), err)
+		}
+	} else {
+		cfg, err = restic.LoadConfig(ctx, r)
+		if err == crypto.ErrUnauthenticated {
+			return errors.Fatalf("config or key %v is damaged: %v", key.Name(), err)
+		} else if err != nil {
+			return errors.Fatalf("config cannot be loaded: %v", err)
+		}
+	}
+
+	if cfg.PackSize != 0 {
+		if err := CheckPackSize(cfg.PackSize); err != nil {
+			return errors.Fatalf("invalid repository config: %v", err)
+		}
 	}
 
 	r.setConfig(cfg)
@@ -772,8 +1024,9 @@ func (r *Repository) SearchKey(ctx context.Context, password string, maxKeys int
 }
 
 // Init creates a new master key with the supplied password, initializes and
-// saves the repository config.
-func (r *Repository) Init(ctx context.Context, version uint, password string, chunkerPolynomial *chunker.Pol) error {
+// saves the repository config. If chunkerParams is nil or its polynomial is
+// zero, a random polynomial is used.
+func (r *Repository) Init(ctx context.Context, version uint, password string, chunkerParams *restic.ChunkerParams) error {
 	if version > restic.MaxRepoVersion {
 		return fmt.Errorf("repo version %v too high", version)
 	}
@@ -794,9 +1047,19 @@ func (r *Repository) Init(ctx context.Context, version uint, password string, ch
 	if err != nil {
 		return err
 	}
-	if chunkerPolynomial != nil {
-		cfg.ChunkerPolynomial = *chunkerPolynomial
+	if chunkerParams != nil {
+		if err := chunkerParams.Check(); err != nil {
+			return err
+		}
+		if chunkerParams.Polynomial != 0 {
+			cfg.ChunkerPolynomial = chunkerParams.Polynomial
+		}
+		cfg.ChunkerMinSize = chunkerParams.MinSize
+		cfg.ChunkerMaxSize = chunkerParams.MaxSize
+		cfg.ChunkerAverageBits = chunkerParams.AverageBits
 	}
+	// store the pack size, so that it is used by all clients
+	cfg.PackSize = r.opts.PackSize
 
 	return r.init(ctx, password, cfg)
 }
@@ -804,18 +1067,58 @@ func (r *Repository) Init(ctx context.Context, version uint, password string, ch
 // init creates a new master key with the supplied password and uses it to save
 // the config into the repo.
 func (r *Repository) init(ctx context.Context, password string, cfg restic.Config) error {
-	key, err := createMasterKey(ctx, r, password)
+	var private *crypto.PrivateKey
+	if cfg.Version >= restic.SealedRepoVersion {
+		// new data is sealed to the public key of a new private key, which is
+		// only stored in the master key
+		private = crypto.NewRandomPrivateKey()
+		cfg.Recipient = private.PublicKey()
+		cfg.WriteKey = crypto.NewRandomKey()
+	}
+
+	key, err := createMasterKey(ctx, r, password, private)
 	if err != nil {
 		return err
 	}
 
+	r.setKey(key)
+	r.setConfig(cfg)
+	_, err = r.SaveJSONUnpacked(ctx, restic.ConfigFile, cfg)
+	return err
+}
+
+func (r *Repository) setKey(key *Key) {
 	r.key = key.master
 	r.dataPM.key = key.master
 	r.treePM.key = key.master
 	r.keyName = key.Name()
-	r.setConfig(cfg)
-	_, err = r.SaveJSONUnpacked(ctx, restic.ConfigFile, cfg)
-	return err
+	r.userKey = key.user
+	r.keyConfig = key.config
+	// the cache holds the previous master key for pack files which are not
+	// sealed
+	r.packKeys.Purge()
+}
+
+// WithKey returns a repository for the same backend and config which uses the
+// master key of key. It is used to re-encrypt the repository with a new master
+// key. If the master key contains a private key, its public key replaces the
+// recipient key of the config, so that new data is sealed to it, and a new
+// write key is generated.
+func (r *Repository) WithKey(key *Key) *Repository {
+	repo := New(r.be, r.opts)
+	repo.setKey(key)
+	cfg := r.cfg
+	if cfg.Version >= restic.SealedRepoVersion && key.HasPrivateKey() {
+		cfg.Recipient = key.master.Private.PublicKey()
+		cfg.WriteKey = crypto.NewRandomKey()
+	}
+	repo.setConfig(cfg)
+	return repo
+}
+
+// HasMasterKey returns whether the repository uses the master key of key.
+func (r *Repository) HasMasterKey(key *Key) bool {
+	return r.key.MACKey.K == key.master.MACKey.K && r.key.EncryptionKey == key.master.EncryptionKey
 }
 
 // Key returns the current master key.
@@ -823,6 +1126,12 @@ func (r *Repository) Key() *crypto.Key {
 	return r.key
 }
 
+// WriteOnly returns true if the repository was opened with a write-only key,
+// which cannot read the sealed pack, index and snapshot files.
+func (r *Repository) WriteOnly() bool {
+	return r.cfg.Version >= restic.SealedRepoVersion && r.key.Private == nil
+}
+
 // KeyName returns the name of the current key in the backend.
 func (r *Repository) KeyName() string {
 	return r.keyName
@@ -843,9 +1152,15 @@ func (r *Repository) List(ctx context.Context, t restic.FileType, fn func(restic
 // ListPack returns the list of blobs saved in the pack id and the length of
 // the the pack header.
 func (r *Repository) ListPack(ctx context.Context, id restic.ID, size int64) ([]restic.Blob, uint32, error) {
+	blobs, hdrSize, _, err := r.listPack(ctx, id, size)
+	return blobs, hdrSize, err
+}
+
+// listPack works like ListPack, it also returns whether the pack is sealed.
+func (r *Repository) listPack(ctx context.Context, id restic.ID, size int64) ([]restic.Blob, uint32, bool, error) {
 	h := restic.Handle{Type: restic.PackFile, Name: id.String()}
 
-	return pack.List(r.Key(), restic.ReaderAt(ctx, r.Backend(), h), size)
+	return pack.ListSealed(r.Key(), restic.ReaderAt(ctx, r.Backend(), h), size)
 }
 
 // Delete calls backend.Delete() if implemented, and returns an error
@@ -929,8 +1244,9 @@ type BackendLoadFn func(ctx context.Context, h restic.Handle, length int, offset
 // StreamPack loads the listed blobs from the specified pack file. The plaintext blob is passed to
 // the handleBlobFn callback or an error if decryption failed or the blob hash does not match. In
 // case of download errors handleBlobFn might be called multiple times for the same blob. If the
-// callback returns an error, then StreamPack will abort and not retry it.
-func StreamPack(ctx context.Context, beLoad BackendLoadFn, key *crypto.Key, packID restic.ID, blobs []restic.Blob, handleBlobFn func(blob restic.BlobHandle, buf []byte, err error) error) error {
+// callback returns an error, then StreamPack will abort and not retry it. sealed must be set for
+// packs which start with a sealed data key.
+func StreamPack(ctx context.Context, beLoad BackendLoadFn, key *crypto.Key, packID restic.ID, sealed bool, blobs []restic.Blob, handleBlobFn func(blob restic.BlobHandle, buf []byte, err error) error) error {
 	if len(blobs) == 0 {
 		// nothing to do
 		return nil
@@ -944,6 +1260,12 @@ func StreamPack(ctx context.Context, beLoad BackendLoadFn, key *crypto.Key, pack
 	dataStart := blobs[0].Offset
 	dataEnd := blobs[len(blobs)-1].Offset + blobs[len(blobs)-1].Length
 
+	// sealed pack files start with the data key, which can only be opened
+	// with the private key. Read it together with the blobs.
+	if sealed {
+		dataStart = 0
+	}
+
 	debug.Log("streaming pack %v (%d to %d bytes), blobs: %v", packID, dataStart, dataEnd, len(blobs))
 
 	dec, err := zstd.NewReader(nil)
@@ -966,6 +1288,21 @@ func StreamPack(ctx context.Context, beLoad BackendLoadFn, key *crypto.Key, pack
 		// create reader here to allow reusing the buffered reader from checker.checkData
 		bufRd := bufio.NewReaderSize(rd, bufferSize)
 		currentBlobEnd := dataStart
+		blobKey := key
+		if sealed {
+			buf := make([]byte, crypto.SealedKeySize)
+			_, err := io.ReadFull(bufRd, buf)
+			if err != nil {
+				return errors.Wrap(err, "ReadFull")
+			}
+
+			blobKey, err = openPackKey(key, buf)
+			if err != nil {
+				return backoff.Permanent(err)
+			}
+			currentBlobEnd = crypto.SealedKeySize
+		}
+
 		var buf []byte
 		var decode []byte
 		for _, entry := range blobs {
@@ -999,14 +1336,14 @@ func StreamPack(ctx context.Context, beLoad BackendLoadFn, key *crypto.Key, pack
 			}
 			currentBlobEnd = entry.Offset + entry.Length
 
-			if int(entry.Length) <= key.NonceSize() {
+			if int(entry.Length) <= blobKey.NonceSize() {
 				debug.Log("%v", blobs)
 				return errors.Errorf("invalid blob length %v", entry)
 			}
 
 			// decryption errors are likely permanent, give the caller a chance to skip them
-			nonce, ciphertext := buf[:key.NonceSize()], buf[key.NonceSize():]
-			plaintext, err := key.Open(ciphertext[:0], nonce, ciphertext, nil)
+			nonce, ciphertext := buf[:blobKey.NonceSize()], buf[blobKey.NonceSize():]
+			plaintext, err := blobKey.Open(ciphertext[:0], nonce, ciphertext, nil)
 			if err == nil && entry.IsCompressed() {
 				// DecodeAll will allocate a slice if it is not large enough since it
 				// knows the decompressed size (because we're using EncodeAll)
@@ -17,7 +17,11 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/klauspost/compress/zstd"
 	"github.com/restic/restic/internal/archiver"
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/mem"
 	"github.com/restic/restic/internal/crypto"
+	"github.com/restic/restic/internal/migrations"
+	"github.com/restic/restic/internal/pack"
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/test"
@@ -551,7 +555,7 @@ func testStreamPack(t *testing.T, version uint) {
 	switch version {
 	case 1:
 		compress = false
-	case 2:
+	case 2, 3:
 		compress = true
 	default:
 		t.Fatal("test does not suport repository version", version)
@@ -620,7 +624,7 @@ func testStreamPack(t *testing.T, version uint) {
 					wantBlobs[blob.ID] = 1
 				}
 
-				err = repository.StreamPack(ctx, load, &key, restic.ID{}, test.blobs, handleBlob)
+				err = repository.StreamPack(ctx, load, &key, restic.ID{}, false, test.blobs, handleBlob)
 				if err != nil {
 					t.Fatal(err)
 				}
@@ -681,7 +685,7 @@ func testStreamPack(t *testing.T, version uint) {
 					return err
 				}
 
-				err = repository.StreamPack(ctx, load, &key, restic.ID{}, test.blobs, handleBlob)
+				err = repository.StreamPack(ctx, load, &key, restic.ID{}, false, test.blobs, handleBlob)
 				if err == nil {
 					t.Fatalf("wanted error %v, got nil", test.err)
 				}
@@ -693,3 +697,203 @@ func testStreamPack(t *testing.T, version uint) {
 		}
 	})
 }
+
+func TestPackSize(t *testing.T) {
+	repository.TestUseLowSecurityKDFParameters(t)
+	be := mem.New()
+	packSize := uint(8 * 1024 * 1024)
+
+	repo := repository.New(be, repository.Options{PackSize: packSize})
+	rtest.OK(t, repo.Init(context.TODO(), restic.StableRepoVersion, rtest.TestPassword, nil))
+	rtest.Equals(t, packSize, repo.Config().PackSize)
+
+	// save incompressible data
+	buf := make([]byte, 1024*1024)
+	for i := 0; i < 30; i++ {
+		_, err := io.ReadFull(rnd, buf)
+		rtest.OK(t, err)
+		_, _, err = repo.SaveBlob(context.TODO(), restic.DataBlob, buf, restic.ID{}, false)
+		rtest.OK(t, err)
+	}
+	rtest.OK(t, repo.Flush(context.TODO()))
+
+	var small int
+	rtest.OK(t, repo.List(context.TODO(), restic.PackFile, func(id restic.ID, size int64) error {
+		if size < int64(packSize) {
+			small++
+		}
+		return nil
+	}))
+	rtest.Assert(t, small <= 1, "found %d packs smaller than %d bytes", small, packSize)
+
+	// the pack size is stored in the config and can be overridden
+	for _, test := range []struct {
+		opts uint
+		want uint
+	}{
+		{0, packSize},
+		{16 * 1024 * 1024, 16 * 1024 * 1024},
+	} {
+		repo = repository.New(be, repository.Options{PackSize: test.opts})
+		rtest.OK(t, repo.SearchKey(context.TODO(), rtest.TestPassword, 10, ""))
+		rtest.Equals(t, test.want, repo.PackSize())
+	}
+}
+
+func TestWriteOnlyKey(t *testing.T) {
+	repository.TestUseLowSecurityKDFParameters(t)
+	ctx := context.TODO()
+	be := mem.New()
+
+	repo := repository.New(be, repository.Options{})
+	rtest.OK(t, repo.Init(ctx, restic.SealedRepoVersion, rtest.TestPassword, nil))
+	rtest.Assert(t, repo.Key().Private != nil, "master key has no private key")
+	rtest.Equals(t, *repo.Key().Private.PublicKey(), *repo.Config().Recipient)
+
+	// a write-only key has neither the master key nor the private key
+	_, err := repository.AddWriteOnlyKey(ctx, repo, "write-only", "", "")
+	rtest.OK(t, err)
+
+	woRepo := repository.New(be, repository.Options{})
+	rtest.OK(t, woRepo.SearchKey(ctx, "write-only", 0, ""))
+	rtest.Assert(t, woRepo.WriteOnly(), "write-only key is not write-only")
+	rtest.Assert(t, woRepo.Key().EncryptionKey != repo.Key().EncryptionKey, "write-only key has the master key")
+	rtest.Equals(t, repo.Config().ID, woRepo.Config().ID)
+
+	data := rtest.Random(23, 100000)
+	id, _, err := woRepo.SaveBlob(ctx, restic.DataBlob, data, restic.ID{}, false)
+	rtest.OK(t, err)
+	rtest.OK(t, woRepo.Flush(ctx))
+
+	sn := restic.Snapshot{Hostname: "foobar"}
+	snID, err := woRepo.SaveJSONUnpacked(ctx, restic.SnapshotFile, &sn)
+	rtest.OK(t, err)
+
+	// the write-only key can lock the repository together with the full key
+	lock, err := restic.NewLock(ctx, woRepo)
+	rtest.OK(t, err)
+	_, err = restic.NewExclusiveLock(ctx, repo)
+	rtest.Assert(t, restic.IsAlreadyLocked(err), "exclusive lock ignored the lock of the write-only key: %v", err)
+	rtest.OK(t, lock.Unlock())
+
+	// the write-only key can neither use the index nor read the data
+	woRepo = repository.New(be, repository.Options{})
+	rtest.OK(t, woRepo.SearchKey(ctx, "write-only", 0, ""))
+	rtest.OK(t, woRepo.LoadIndex(ctx))
+	rtest.Assert(t, !woRepo.Index().Has(restic.BlobHandle{ID: id, Type: restic.DataBlob}), "write-only key loaded the index")
+
+	var sn2 restic.Snapshot
+	err = woRepo.LoadJSONUnpacked(ctx, restic.SnapshotFile, snID, &sn2)
+	rtest.Assert(t, err == repository.ErrWriteOnly,
+		"wrong error for loading a snapshot with a write-only key: %v", err)
+
+	var indexes restic.IDs
+	rtest.OK(t, repo.List(ctx, restic.IndexFile, func(id restic.ID, size int64) error {
+		indexes = append(indexes, id)
+		return nil
+	}))
+	rtest.Equals(t, 1, len(indexes))
+	_, err = woRepo.LoadUnpacked(ctx, nil, restic.IndexFile, indexes[0])
+	rtest.Assert(t, err == repository.ErrWriteOnly,
+		"wrong error for loading an index with a write-only key: %v", err)
+
+	// the index is sealed, the master key alone cannot decrypt it
+	buf, err := backend.LoadAll(ctx, nil, be, restic.Handle{Type: restic.IndexFile, Name: indexes[0].String()})
+	rtest.OK(t, err)
+	rtest.Assert(t, crypto.IsSealedKey(buf), "index is not sealed")
+
+	// the full key can read everything
+	repo = repository.New(be, repository.Options{})
+	rtest.OK(t, repo.SearchKey(ctx, rtest.TestPassword, 0, ""))
+	rtest.OK(t, repo.LoadIndex(ctx))
+
+	buf, err = repo.LoadBlob(ctx, restic.DataBlob, id, nil)
+	rtest.OK(t, err)
+	rtest.Equals(t, data, buf)
+
+	rtest.OK(t, repo.LoadJSONUnpacked(ctx, restic.SnapshotFile, snID, &sn2))
+	rtest.Equals(t, sn.Hostname, sn2.Hostname)
+
+	// the pack files start with the sealed data key
+	rtest.OK(t, repo.List(ctx, restic.PackFile, func(id restic.ID, size int64) error {
+		rtest.Assert(t, repo.Index().IsSealedPack(id), "pack %v is not sealed in the index", id.Str())
+		blobs, _, err := repo.ListPack(ctx, id, size)
+		rtest.OK(t, err)
+		rtest.Equals(t, uint(pack.SealedKeySize), blobs[0].Offset)
+		return nil
+	}))
+}
+
+// packKeyCountingBackend counts the requests for the start of pack files,
+// which are used to load sealed data keys.
+type packKeyCountingBackend struct {
+	restic.Backend
+	loads int
+}
+
+func (be *packKeyCountingBackend) Load(ctx context.Context, h restic.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
+	if h.Type == restic.PackFile && offset == 0 && length == crypto.SealedKeySize {
+		be.loads++
+	}
+	return be.Backend.Load(ctx, h, length, offset, fn)
+}
+
+func TestLoadBlobPackKey(t *testing.T) {
+	repository.TestUseLowSecurityKDFParameters(t)
+	ctx := context.TODO()
+	be := mem.New()
+
+	repo := repository.New(be, repository.Options{})
+	rtest.OK(t, repo.Init(ctx, 2, rtest.TestPassword, nil))
+
+	oldData := rtest.Random(23, 1000)
+	oldID, _, err := repo.SaveBlob(ctx, restic.DataBlob, oldData, restic.ID{}, false)
+	rtest.OK(t, err)
+	rtest.OK(t, repo.Flush(ctx))
+
+	rtest.OK(t, (&migrations.UpgradeRepoV3{}).Apply(ctx, repo))
+
+	repo = repository.New(be, repository.Options{})
+	rtest.OK(t, repo.SearchKey(ctx, rtest.TestPassword, 0, ""))
+	newData := rtest.Random(42, 1000)
+	newID, _, err := repo.SaveBlob(ctx, restic.DataBlob, newData, restic.ID{}, false)
+	rtest.OK(t, err)
+	rtest.OK(t, repo.Flush(ctx))
+
+	counting := &packKeyCountingBackend{Backend: be}
+	repo = repository.New(counting, repository.Options{})
+	rtest.OK(t, repo.SearchKey(ctx, rtest.TestPassword, 0, ""))
+	rtest.OK(t, repo.LoadIndex(ctx))
+
+	// the pack from before the upgrade is read without looking for a sealed
+	// data key
+	buf, err := repo.LoadBlob(ctx, restic.DataBlob, oldID, nil)
+	rtest.OK(t, err)
+	rtest.Equals(t, oldData, buf)
+	rtest.Equals(t, 0, counting.loads)
+
+	// the data key of the sealed pack is loaded once
+	for i := 0; i < 2; i++ {
+		buf, err = repo.LoadBlob(ctx, restic.DataBlob, newID, nil)
+		rtest.OK(t, err)
+		rtest.Equals(t, newData, buf)
+	}
+	rtest.Equals(t, 1, counting.loads)
+}
+
+func TestCheckPackSize(t *testing.T) {
+	for _, test := range []struct {
+		size  uint
+		valid bool
+	}{
+		{0, false},
+		{repository.MinPackSize - 1, false},
+		{repository.MinPackSize, true},
+		{64 * 1024 * 1024, true},
+		{repository.MaxPackSize, true},
+		{repository.MaxPackSize + 1, false},
+	} {
+		err := repository.CheckPackSize(test.size)
+		rtest.Assert(t, (err == nil) == test.valid, "size %d: unexpected result %v", test.size, err)
+	}
+}
@@ -4,8 +4,15 @@ import (
 	"context"
 	"hash"
 	"io"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
 )
 
+// ErrRetained is wrapped by the error Backend.Remove returns for a file which
+// the backend keeps until its retention period has expired.
+var ErrRetained = errors.New("file is still under retention")
+
 // Backend is used to store and access data.
 //
 // Backend operations that return an error will be retried when a Backend is
@@ -70,8 +77,133 @@ type Backend interface {
 	Delete(ctx context.Context) error
 }
 
+// Names of the backend operations, used to select or label the requests.
+const (
+	OpSave   = "save"
+	OpLoad   = "load"
+	OpStat   = "stat"
+	OpTest   = "test"
+	OpList   = "list"
+	OpRemove = "remove"
+	// OpSHA256 is a request of ChecksumBackend.SHA256.
+	OpSHA256 = "sha256"
+)
+
 // FileInfo is contains information about a file in the backend.
 type FileInfo struct {
 	Size int64
 	Name string
 }
+
+// ChecksumBackend is implemented by backends which record the SHA-256 hash of
+// every stored file and can return it without transferring the file.
+type ChecksumBackend interface {
+	// SHA256 returns the SHA-256 hash of the content of the file at h as
+	// recorded by the storage.
+	SHA256(ctx context.Context, h Handle) (ID, error)
+}
+
+// BackendUnwrapper is implemented by backends which wrap another backend.
+type BackendUnwrapper interface {
+	// Unwrap returns the underlying backend.
+	Unwrap() Backend
+}
+
+// DuplicateFile describes a file which is stored more than once.
+type DuplicateFile struct {
+	Handle Handle
+	// Copies is the number of stored copies.
+	Copies int
+	// Identical is true if all copies have the same content.
+	Identical bool
+}
+
+// DuplicatesBackend is implemented by backends which may end up storing a
+// file more than once, e.g. after an interrupted upload.
+type DuplicatesBackend interface {
+	// Duplicates runs fn for every file of type t which is stored more than
+	// once.
+	Duplicates(ctx context.Context, t FileType, fn func(DuplicateFile) error) error
+
+	// RemoveDuplicates removes all copies of the file at h but one. The
+	// copies are kept if their content differs.
+	RemoveDuplicates(ctx context.Context, h Handle) error
+}
+
+// LockExpiringBackend is implemented by backends which remove lock files on
+// their own once they have not been refreshed for some time.
+type LockExpiringBackend interface {
+	// LockExpiration returns the minimum duration lock files are kept after
+	// they were saved.
+	LockExpiration() time.Duration
+}
+
+// findBackend returns the first backend in the chain of wrapped backends
+// starting at be for which match returns true, or nil if there is none.
+func findBackend(be Backend, match func(Backend) bool) Backend {
+	for be != nil {
+		if match(be) {
+			return be
+		}
+
+		u, ok := be.(BackendUnwrapper)
+		if !ok {
+			return nil
+		}
+		be = u.Unwrap()
+	}
+	return nil
+}
+
+// AsChecksumBackend returns the first backend in the chain of wrapped
+// backends starting at be which implements ChecksumBackend, or nil if there
+// is none. Wrappers like RetryBackend implement ChecksumBackend by forwarding
+// the requests, so they are only returned if the innermost backend records
+// checksums.
+func AsChecksumBackend(be Backend) ChecksumBackend {
+	var first ChecksumBackend
+	for be != nil {
+		cb, ok := be.(ChecksumBackend)
+		if ok && first == nil {
+			first = cb
+		}
+
+		u, ok := be.(BackendUnwrapper)
+		if !ok {
+			if cb == nil {
+				return nil
+			}
+			return first
+		}
+		be = u.Unwrap()
+	}
+	return nil
+}
+
+// AsDuplicatesBackend returns the first backend in the chain of wrapped
+// backends starting at be which implements DuplicatesBackend, or nil if
+// there is none.
+func AsDuplicatesBackend(be Backend) DuplicatesBackend {
+	be = findBackend(be, func(be Backend) bool {
+		_, ok := be.(DuplicatesBackend)
+		return ok
+	})
+	if be == nil {
+		return nil
+	}
+	return be.(DuplicatesBackend)
+}
+
+// AsLockExpiringBackend returns the first backend in the chain of wrapped
+// backends starting at be which implements LockExpiringBackend, or nil if
+// there is none.
+func AsLockExpiringBackend(be Backend) LockExpiringBackend {
+	be = findBackend(be, func(be Backend) bool {
+		_, ok := be.(LockExpiringBackend)
+		return ok
+	})
+	if be == nil {
+		return nil
+	}
+	return be.(LockExpiringBackend)
+}
@@ -2,10 +2,12 @@ package restic
 
 import (
 	"context"
+	"io"
 	"testing"
 
 	"github.com/restic/restic/internal/errors"
 
+	"github.com/restic/restic/internal/crypto"
 	"github.com/restic/restic/internal/debug"
 
 	"github.com/restic/chunker"
@@ -16,10 +18,28 @@ type Config struct {
 	Version           uint        `json:"version"`
 	ID                string      `json:"id"`
 	ChunkerPolynomial chunker.Pol `json:"chunker_polynomial"`
+	PackSize          uint        `json:"pack_size,omitempty"`
+
+	// The sizes of the chunks, the defaults of the chunker are used for
+	// zero values.
+	ChunkerMinSize     uint `json:"chunker_min_size,omitempty"`
+	ChunkerMaxSize     uint `json:"chunker_max_size,omitempty"`
+	ChunkerAverageBits uint `json:"chunker_average_bits,omitempty"`
+
+	// Recipient is the public key to which the data keys of pack files and
+	// snapshots are sealed, starting from version 3.
+	Recipient *crypto.PublicKey `json:"recipient,omitempty"`
+	// WriteKey encrypts the lock files starting from version 3. It is also
+	// stored in write-only keys, which cannot read the rest of the repository.
+	WriteKey *crypto.Key `json:"write_key,omitempty"`
 }
 
 const MinRepoVersion = 1
-const MaxRepoVersion = 2
+const MaxRepoVersion = 3
+
+// SealedRepoVersion is the first version which seals data to the recipient
+// key in the config.
+const SealedRepoVersion = 3
 
 // StableRepoVersion is the version that is written to the config when a repository
 // is newly created with Init().
@@ -86,15 +106,125 @@ func LoadConfig(ctx context.Context, r JSONUnpackedLoader) (Config, error) {
 		return Config{}, err
 	}
 
+	err = cfg.Check()
+	if err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+// Check returns an error if the config is invalid.
+func (cfg Config) Check() error {
 	if cfg.Version < MinRepoVersion || cfg.Version > MaxRepoVersion {
-		return Config{}, errors.Errorf("unsupported repository version %v", cfg.Version)
+		return errors.Errorf("unsupported repository version %v", cfg.Version)
+	}
+
+	if cfg.Version >= SealedRepoVersion && cfg.Recipient == nil {
+		return errors.New("recipient key is missing")
+	}
+
+	if cfg.Version >= SealedRepoVersion && (cfg.WriteKey == nil || !cfg.WriteKey.Valid()) {
+		return errors.New("write key is missing")
 	}
 
 	if checkPolynomial {
 		if !cfg.ChunkerPolynomial.Irreducible() {
-			return Config{}, errors.New("invalid chunker polynomial")
+			return errors.New("invalid chunker polynomial")
 		}
 	}
 
-	return cfg, nil
+	if err := cfg.ChunkerParams().Check(); err != nil {
+		return errors.Errorf("invalid chunker parameters: %v", err)
+	}
+
+	return nil
+}
+
+// ChunkerParams returns the parameters of the content defined chunker.
+func (cfg Config) ChunkerParams() ChunkerParams {
+	return ChunkerParams{
+		Polynomial:  cfg.ChunkerPolynomial,
+		MinSize:     cfg.ChunkerMinSize,
+		MaxSize:     cfg.ChunkerMaxSize,
+		AverageBits: cfg.ChunkerAverageBits,
+	}
+}
+
+// Limits and defaults of the chunk sizes.
+const (
+	MinChunkSize = 64 * 1024
+	MaxChunkSize = 64 * 1024 * 1024
+
+	DefaultChunkerAverageBits = 20
+)
+
+// ChunkerParams are the parameters of the content defined chunker. The
+// defaults of the chunker are used for sizes which are zero.
+type ChunkerParams struct {
+	Polynomial chunker.Pol
+
+	MinSize uint
+	MaxSize uint
+	// AverageBits is the number of bits of the average chunk size.
+	AverageBits uint
+}
+
+// sizes returns the minimum, maximum and average chunk size.
+func (p ChunkerParams) sizes() (minSize, maxSize, avgSize uint) {
+	minSize, maxSize, bits := p.MinSize, p.MaxSize, p.AverageBits
+	if minSize == 0 {
+		minSize = chunker.MinSize
+	}
+	if maxSize == 0 {
+		maxSize = chunker.MaxSize
+	}
+	if bits == 0 {
+		bits = DefaultChunkerAverageBits
+	}
+	return minSize, maxSize, 1 << bits
+}
+
+// Check returns an error if the chunk sizes are out of range or not ordered.
+func (p ChunkerParams) Check() error {
+	if p.AverageBits > 32 {
+		return errors.Errorf("average chunk size 2^%d is too large", p.AverageBits)
+	}
+
+	minSize, maxSize, avgSize := p.sizes()
+	if minSize < MinChunkSize || maxSize > MaxChunkSize {
+		return errors.Errorf("chunk sizes must be between %d KiB and %d MiB", MinChunkSize/1024, MaxChunkSize/1024/1024)
+	}
+	if !(minSize < avgSize && avgSize < maxSize) {
+		return errors.Errorf("the average chunk size %d must be between the minimum size %d and the maximum size %d",
+			avgSize, minSize, maxSize)
+	}
+	return nil
+}
+
+// MaxChunkSize returns the maximum size of a chunk.
+func (p ChunkerParams) MaxChunkSize() uint {
+	_, maxSize, _ := p.sizes()
+	return maxSize
+}
+
+// NewChunker returns a new chunker for rd.
+func (p ChunkerParams) NewChunker(rd io.Reader) *chunker.Chunker {
+	minSize, maxSize, _ := p.sizes()
+	c := chunker.NewWithBoundaries(rd, p.Polynomial, minSize, maxSize)
+	p.setAverageBits(c)
+	return c
+}
+
+// ResetChunker reuses the chunker c for rd.
+func (p ChunkerParams) ResetChunker(c *chunker.Chunker, rd io.Reader) {
+	minSize, maxSize, _ := p.sizes()
+	c.ResetWithBoundaries(rd, p.Polynomial, minSize, maxSize)
+	p.setAverageBits(c)
+}
+
+func (p ChunkerParams) setAverageBits(c *chunker.Chunker) {
+	if p.AverageBits != 0 {
+		c.SetAverageBits(int(p.AverageBits))
+	}
 }
@@ -1,7 +1,10 @@
 package restic_test
 
 import (
+	"bytes"
 	"context"
+	"io"
+	"math/rand"
 	"testing"
 
 	"github.com/restic/restic/internal/restic"
@@ -54,3 +57,63 @@ func TestConfig(t *testing.T) {
 	rtest.Assert(t, cfg1 == cfg2,
 		"configs aren't equal: %v != %v", cfg1, cfg2)
 }
+
+func TestChunkerParamsCheck(t *testing.T) {
+	for _, test := range []struct {
+		params restic.ChunkerParams
+		valid  bool
+	}{
+		{restic.ChunkerParams{}, true},
+		{restic.ChunkerParams{AverageBits: 22}, true},
+		{restic.ChunkerParams{MinSize: 2 << 20, AverageBits: 23, MaxSize: 32 << 20}, true},
+		{restic.ChunkerParams{AverageBits: 23}, false},
+		{restic.ChunkerParams{MinSize: 2 << 20}, false},
+		{restic.ChunkerParams{MinSize: 1024}, false},
+		{restic.ChunkerParams{MaxSize: 128 << 20}, false},
+		{restic.ChunkerParams{AverageBits: 63}, false},
+	} {
+		err := test.params.Check()
+		rtest.Assert(t, (err == nil) == test.valid, "unexpected result for %+v: %v", test.params, err)
+	}
+}
+
+func TestChunkerParamsChunker(t *testing.T) {
+	params := restic.ChunkerParams{
+		Polynomial:  0x3DA3358B4DC173,
+		MinSize:     128 * 1024,
+		MaxSize:     1024 * 1024,
+		AverageBits: 18,
+	}
+
+	data := make([]byte, 32*1024*1024)
+	_, err := io.ReadFull(rand.New(rand.NewSource(23)), data)
+	rtest.OK(t, err)
+
+	c := params.NewChunker(nil)
+	for i := 0; i < 2; i++ {
+		// chunkers are reused for several files
+		params.ResetChunker(c, bytes.NewReader(data))
+
+		var chunks, size uint
+		buf := make([]byte, params.MaxChunkSize())
+		for {
+			chunk, err := c.Next(buf)
+			if err == io.EOF {
+				break
+			}
+			rtest.OK(t, err)
+
+			chunks++
+			size += chunk.Length
+			rtest.Assert(t, chunk.Length <= params.MaxSize, "chunk of %d bytes is too large", chunk.Length)
+			if size < uint(len(data)) {
+				rtest.Assert(t, chunk.Length >= params.MinSize, "chunk of %d bytes is too small", chunk.Length)
+			}
+		}
+
+		rtest.Equals(t, uint(len(data)), size)
+		// the average size is close to 256 KiB plus the minimum size
+		avg := size / chunks
+		rtest.Assert(t, avg > 256*1024 && avg < 512*1024, "unexpected average chunk size %d", avg)
+	}
+}
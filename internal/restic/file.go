@@ -34,6 +34,13 @@ func (h Handle) String() string {
 	return fmt.Sprintf("<%s/%s>", h.Type, name)
 }
 
+// IsDataPack returns true if h refers to a pack file which is known to
+// contain data blobs. All other files, including tree packs and packs of
+// unknown content, only hold the metadata of the repository.
+func (h Handle) IsDataPack() bool {
+	return h.Type == PackFile && h.ContainedBlobType == DataBlob
+}
+
 // Valid returns an error if h is not valid.
 func (h Handle) Valid() error {
 	if h.Type == "" {
@@ -26,3 +26,20 @@ func TestHandleValid(t *testing.T) {
 		}
 	}
 }
+
+func TestHandleIsDataPack(t *testing.T) {
+	for _, test := range []struct {
+		h    Handle
+		want bool
+	}{
+		{Handle{Type: PackFile, ContainedBlobType: DataBlob, Name: "foo"}, true},
+		{Handle{Type: PackFile, ContainedBlobType: TreeBlob, Name: "foo"}, false},
+		{Handle{Type: PackFile, Name: "foo"}, false},
+		{Handle{Type: IndexFile, ContainedBlobType: DataBlob, Name: "foo"}, false},
+		{Handle{Type: SnapshotFile, Name: "foo"}, false},
+	} {
+		if got := test.h.IsDataPack(); got != test.want {
+			t.Errorf("%v (%v): IsDataPack() = %v, want %v", test.h, test.h.ContainedBlobType, got, test.want)
+		}
+	}
+}
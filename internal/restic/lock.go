@@ -175,14 +175,19 @@ func (l *Lock) Unlock() error {
 	return l.repo.Backend().Remove(context.TODO(), Handle{Type: LockFile, Name: l.lockID.String()})
 }
 
+// LockRefreshInterval is the interval in which the owner of a lock refreshes
+// it.
+const LockRefreshInterval = 5 * time.Minute
+
 var staleTimeout = 30 * time.Minute
 
 // Stale returns true if the lock is stale. A lock is stale if the timestamp is
-// older than 30 minutes or if it was created on the current machine and the
+// older than 30 minutes, or than the time after which the backend removes lock
+// files on its own, or if it was created on the current machine and the
 // process isn't alive any more.
 func (l *Lock) Stale() bool {
 	debug.Log("testing if lock %v for process %d is stale", l, l.PID)
-	if time.Since(l.Time) > staleTimeout {
+	if time.Since(l.Time) > l.staleTimeout() {
 		debug.Log("lock is stale, timestamp is too old: %v\n", l.Time)
 		return true
 	}
@@ -211,6 +216,21 @@ func (l *Lock) Stale() bool {
 	return false
 }
 
+// staleTimeout returns the age after which the lock is stale. A lock which
+// outlived the expiration of lock files in the backend was not refreshed by
+// its owner.
+func (l *Lock) staleTimeout() time.Duration {
+	if l.repo == nil {
+		return staleTimeout
+	}
+
+	be := AsLockExpiringBackend(l.repo.Backend())
+	if be != nil && be.LockExpiration() < staleTimeout {
+		return be.LockExpiration()
+	}
+	return staleTimeout
+}
+
 // Refresh refreshes the lock by creating a new file in the backend with a new
 // timestamp. Afterwards the old lock is removed.
 func (l *Lock) Refresh(ctx context.Context) error {
@@ -254,7 +274,7 @@ func init() {
 
 // LoadLock loads and unserializes a lock from a repository.
 func LoadLock(ctx context.Context, repo Repository, id ID) (*Lock, error) {
-	lock := &Lock{}
+	lock := &Lock{repo: repo}
 	if err := repo.LoadJSONUnpacked(ctx, LockFile, id, lock); err != nil {
 		return nil, err
 	}
@@ -23,6 +23,7 @@ type Repository interface {
 	LoadIndex(context.Context) error
 
 	Config() Config
+	PackSize() uint
 
 	LookupBlobSize(ID, BlobType) (uint, bool)
 
@@ -75,6 +76,8 @@ type MasterIndex interface {
 	Has(BlobHandle) bool
 	Lookup(BlobHandle) []PackedBlob
 	Count(BlobType) uint
+	// IsSealedPack returns true if the pack starts with a sealed data key.
+	IsSealedPack(ID) bool
 
 	// Each returns a channel that yields all blobs known to the index. When
 	// the context is cancelled, the background goroutine terminates. This
@@ -49,6 +49,7 @@ type packInfo struct {
 type fileRestorer struct {
 	key        *crypto.Key
 	idx        func(restic.BlobHandle) []restic.PackedBlob
+	sealed     func(restic.ID) bool
 	packLoader repository.BackendLoadFn
 
 	filesWriter *filesWriter
@@ -61,11 +62,13 @@ type fileRestorer struct {
 func newFileRestorer(dst string,
 	packLoader repository.BackendLoadFn,
 	key *crypto.Key,
-	idx func(restic.BlobHandle) []restic.PackedBlob) *fileRestorer {
+	idx func(restic.BlobHandle) []restic.PackedBlob,
+	sealed func(restic.ID) bool) *fileRestorer {
 
 	return &fileRestorer{
 		key:         key,
 		idx:         idx,
+		sealed:      sealed,
 		packLoader:  packLoader,
 		filesWriter: newFilesWriter(workerCount),
 		dst:         dst,
@@ -221,7 +224,7 @@ func (r *fileRestorer) downloadPack(ctx context.Context, pack *packInfo) error {
 		return err
 	}
 
-	err := repository.StreamPack(ctx, r.packLoader, r.key, pack.id, blobList, func(h restic.BlobHandle, blobData []byte, err error) error {
+	err := repository.StreamPack(ctx, r.packLoader, r.key, pack.id, r.sealed(pack.id), blobList, func(h restic.BlobHandle, blobData []byte, err error) error {
 		blob := blobs[h.ID]
 		if err != nil {
 			for file := range blob.files {
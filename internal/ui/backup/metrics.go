@@ -0,0 +1,41 @@
+package backup
+
+import (
+	"time"
+
+	"github.com/restic/restic/internal/metrics"
+)
+
+// RecordMetrics adds the numbers of the backup summary to reg, it must be
+// called after Finish.
+func (p *Progress) RecordMetrics(reg *metrics.Registry) {
+	p.summary.Lock()
+	defer p.summary.Unlock()
+
+	s := p.summary
+
+	files := reg.NewGauge("restic_backup_files", "Number of files in the last backup.", "state")
+	files.Set(float64(s.Files.New), "new")
+	files.Set(float64(s.Files.Changed), "changed")
+	files.Set(float64(s.Files.Unchanged), "unmodified")
+
+	dirs := reg.NewGauge("restic_backup_dirs", "Number of directories in the last backup.", "state")
+	dirs.Set(float64(s.Dirs.New), "new")
+	dirs.Set(float64(s.Dirs.Changed), "changed")
+	dirs.Set(float64(s.Dirs.Unchanged), "unmodified")
+
+	blobs := reg.NewGauge("restic_backup_added_blobs", "Number of blobs added to the repository by the last backup.", "type")
+	blobs.Set(float64(s.ItemStats.DataBlobs), "data")
+	blobs.Set(float64(s.ItemStats.TreeBlobs), "tree")
+
+	reg.NewGauge("restic_backup_added_bytes", "Bytes added to the repository by the last backup.").
+		Set(float64(s.ItemStats.DataSize + s.ItemStats.TreeSize))
+	reg.NewGauge("restic_backup_processed_files", "Number of files processed by the last backup.").
+		Set(float64(s.Files.New + s.Files.Changed + s.Files.Unchanged))
+	reg.NewGauge("restic_backup_processed_bytes", "Bytes processed by the last backup.").
+		Set(float64(s.ProcessedBytes))
+	reg.NewGauge("restic_backup_duration_seconds", "Duration of the last backup.").
+		Set(time.Since(p.start).Seconds())
+	reg.NewGauge("restic_backup_timestamp_seconds", "Time at which the last backup finished.").
+		Set(float64(time.Now().Unix()))
+}